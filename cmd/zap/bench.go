@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/paths"
+)
+
+// benchResult holds the measured throughput of one benchmark run
+type benchResult struct {
+	Projects          int     `json:"projects"`
+	Files             int     `json:"files"`
+	Bytes             int64   `json:"bytes"`
+	ScanSeconds       float64 `json:"scan_seconds"`
+	ScanDirsPerSec    float64 `json:"scan_dirs_per_sec"`
+	DeleteSeconds     float64 `json:"delete_seconds"`
+	DeleteFilesPerSec float64 `json:"delete_files_per_sec"`
+	DeleteMBPerSec    float64 `json:"delete_mb_per_sec"`
+}
+
+var benchCommand = &command{
+	spec: commandSpec{
+		Name: "bench", Description: "Measure scan and deletion throughput",
+		Flags: withCommon("projects", "files", "file-size"),
+	},
+	run: func(ctx context.Context, inv *invocation) {
+		handleBench(ctx, inv.jsonOutput, inv.flagValues)
+	},
+}
+
+// handleBench builds a synthetic project tree, then times scanning and deleting it
+func handleBench(ctx context.Context, jsonOutput bool, flagValues map[string]string) {
+	projects := benchIntFlag(flagValues, "projects", 20)
+	filesPerProject := benchIntFlag(flagValues, "files", 500)
+	fileSize := benchIntFlag(flagValues, "file-size", 4096)
+
+	// The tree must live under the home directory: deletion refuses paths outside it
+	homeDir, err := paths.HomeDir()
+	if err != nil {
+		log.Log(log.FAIL, "%v", err)
+		os.Exit(1)
+	}
+	benchParent := filepath.Join(homeDir, ".config", "zap")
+	if err := os.MkdirAll(benchParent, 0755); err != nil {
+		log.Log(log.FAIL, "Failed to create bench directory: %v", err)
+		os.Exit(1)
+	}
+	root, err := os.MkdirTemp(benchParent, "bench-")
+	if err != nil {
+		log.Log(log.FAIL, "Failed to create bench directory: %v", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(root)
+
+	if !jsonOutput {
+		log.Log(log.SCAN, "creating synthetic tree: %d projects x %d files (%s each)", projects, filesPerProject, cleanup.FormatSize(int64(fileSize)))
+	}
+	if err := createBenchTree(root, projects, filesPerProject, fileSize); err != nil {
+		log.Log(log.FAIL, "Failed to create synthetic tree: %v", err)
+		os.Exit(1)
+	}
+
+	// Everything in the synthetic tree is a candidate regardless of age
+	matchAll := func(dir cleanup.DirectoryInfo) bool { return true }
+
+	visited := 0
+	progress := make(chan cleanup.ProgressEvent, 64)
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		for event := range progress {
+			if event.Kind == cleanup.ProgressEntered {
+				visited++
+			}
+		}
+	}()
+	scanStart := time.Now()
+	dirs, err := cleanup.ScanDirectories(ctx, root, nil, matchAll, progress)
+	close(progress)
+	<-progressDone
+	scanDuration := time.Since(scanStart)
+	if err != nil {
+		log.Log(log.FAIL, "Scan failed: %v", err)
+		os.Exit(1)
+	}
+
+	deleteStart := time.Now()
+	for _, dir := range dirs {
+		if err := cleanup.DeleteDirectoryWithContext(ctx, dir.Path); err != nil {
+			log.Log(log.FAIL, "Failed to delete %s: %v", dir.Path, err)
+			os.Exit(1)
+		}
+	}
+	deleteDuration := time.Since(deleteStart)
+
+	totalFiles := projects * filesPerProject
+	totalBytes := cleanup.GetTotalSize(dirs)
+	result := benchResult{
+		Projects:          projects,
+		Files:             totalFiles,
+		Bytes:             totalBytes,
+		ScanSeconds:       scanDuration.Seconds(),
+		ScanDirsPerSec:    float64(visited) / scanDuration.Seconds(),
+		DeleteSeconds:     deleteDuration.Seconds(),
+		DeleteFilesPerSec: float64(totalFiles) / deleteDuration.Seconds(),
+		DeleteMBPerSec:    float64(totalBytes) / (1024 * 1024) / deleteDuration.Seconds(),
+	}
+
+	if jsonOutput {
+		data, _ := json.Marshal(result)
+		fmt.Println(string(data))
+		return
+	}
+
+	log.Log(log.STATS, "scan: %d directories in %v (%.0f dirs/s, %d matches)", visited, scanDuration.Round(time.Millisecond), result.ScanDirsPerSec, len(dirs))
+	log.Log(log.STATS, "delete: %d files, %s in %v (%.0f files/s, %.1f MB/s)", totalFiles, cleanup.FormatSize(totalBytes), deleteDuration.Round(time.Millisecond), result.DeleteFilesPerSec, result.DeleteMBPerSec)
+}
+
+// createBenchTree lays out projects that each contain a node_modules-like dependency tree
+func createBenchTree(root string, projects, filesPerProject, fileSize int) error {
+	content := make([]byte, fileSize)
+	for p := 0; p < projects; p++ {
+		projectDir := filepath.Join(root, fmt.Sprintf("project-%03d", p))
+		if err := os.MkdirAll(filepath.Join(projectDir, "src"), 0755); err != nil {
+			return err
+		}
+		for f := 0; f < filesPerProject; f++ {
+			// Spread files over nested package directories like a real node_modules
+			pkgDir := filepath.Join(projectDir, "node_modules", fmt.Sprintf("pkg-%03d", f/25), "lib")
+			if err := os.MkdirAll(pkgDir, 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(pkgDir, fmt.Sprintf("file-%d.js", f)), content, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func benchIntFlag(flagValues map[string]string, name string, defaultValue int) int {
+	value, ok := flagValues[name]
+	if !ok {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		log.Log(log.FAIL, "Invalid --%s: %s (must be a positive number)", name, value)
+		os.Exit(1)
+	}
+	return n
+}
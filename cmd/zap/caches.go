@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/journal"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/summary"
+)
+
+// handleCacheCleanup prunes package-manager cache entries unused for max_age_days_for_cleanup,
+// leaving recently used packages cached
+func handleCacheCleanup(ctx context.Context, cfg *config.Config, homeDir string, yes, dryRun, jsonOutput bool) {
+	maxAge := time.Duration(cfg.MaxAgeDays()) * 24 * time.Hour
+	log.Log(log.SCAN, "checking package manager caches for entries unused in %d days", cfg.MaxAgeDays())
+
+	entries, err := cleanup.FindStaleCacheEntries(ctx, homeDir, maxAge)
+	if err != nil {
+		log.Log(log.FAIL, "Failed to scan package manager caches: %v", err)
+		os.Exit(1)
+	}
+	totalSize := cleanup.GetCacheEntriesSize(entries)
+
+	if jsonOutput {
+		output := struct {
+			Entries []cleanup.CacheEntry `json:"entries"`
+			Total   int                  `json:"total"`
+			Bytes   int64                `json:"size_bytes"`
+		}{Entries: entries, Total: len(entries), Bytes: totalSize}
+		if output.Entries == nil {
+			output.Entries = []cleanup.CacheEntry{}
+		}
+		data, _ := json.Marshal(output)
+		fmt.Println(string(data))
+		return
+	}
+
+	if cleanup.PnpmStoreExists(homeDir) {
+		log.Log(log.INFO, "pnpm store found - prune it with 'pnpm store prune' (pnpm knows which packages projects still use)")
+	}
+
+	if len(entries) == 0 {
+		log.Log(log.OK, "no stale package cache entries found")
+		return
+	}
+
+	// Summarise per package manager, largest first
+	byManager := make(map[string][]cleanup.CacheEntry)
+	var managers []string
+	for _, entry := range entries {
+		if _, ok := byManager[entry.Manager]; !ok {
+			managers = append(managers, entry.Manager)
+		}
+		byManager[entry.Manager] = append(byManager[entry.Manager], entry)
+	}
+	sort.Slice(managers, func(i, j int) bool {
+		return cleanup.GetCacheEntriesSize(byManager[managers[i]]) > cleanup.GetCacheEntriesSize(byManager[managers[j]])
+	})
+	for _, manager := range managers {
+		group := byManager[manager]
+		log.Log(log.FOUND, "%s: %d entries (%s)", manager, len(group), cleanup.FormatSize(cleanup.GetCacheEntriesSize(group)))
+		for _, entry := range group {
+			log.VerboseLog("  %s (%s, last used %s)", truncateString(entry.Name, 80), cleanup.FormatSize(entry.Size), entry.LastUsed.Format("2006-01-02"))
+		}
+	}
+	explain("last used more than %d days ago (max_age_days_for_cleanup or --older-than), according to npm's index timestamps and yarn cache access times", cfg.MaxAgeDays())
+
+	shouldDelete := yes
+	if !shouldDelete && !dryRun {
+		log.Log(log.ACTION, "prune %d cache entries (%s total)? (y/N): ", len(entries), cleanup.FormatSize(totalSize))
+		shouldDelete = confirm()
+	}
+	if !shouldDelete {
+		return
+	}
+	if dryRun {
+		log.Log(log.INFO, "would prune %d cache entries (%s total)", len(entries), cleanup.FormatSize(totalSize))
+		return
+	}
+
+	outcome := summary.New("cleanup", dryRun)
+	prunedCount := 0
+	failedCount := 0
+	freedSize := int64(0)
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			log.Log(log.INFO, "operation cancelled, %d cache entries left in place", len(entries)-prunedCount-failedCount)
+			break
+		}
+		err := cleanup.DeleteCacheEntry(entry)
+		journalEntry := journal.Entry{
+			Action: journal.ActionDelete,
+			Target: entry.Paths[0],
+			Result: journal.ResultOK,
+			Detail: entry.Manager + " cache: " + entry.Name,
+		}
+		if err != nil {
+			log.Log(log.FAIL, "Failed to prune %s: %v", entry.Name, err)
+			failedCount++
+			journalEntry.Result = journal.ResultFailed
+			journalEntry.Detail = err.Error()
+		} else {
+			log.VerboseLog("pruned %s", entry.Name)
+			prunedCount++
+			freedSize += entry.Size
+			journalEntry.Bytes = entry.Size
+		}
+		if err := journal.Record(journalEntry); err != nil {
+			log.VerboseLog("failed to write journal: %v", err)
+		}
+	}
+
+	outcome.Count("pruned", prunedCount, "cache entries")
+	outcome.Bytes("freed", freedSize)
+	outcome.CountIfAny("failed", failedCount, "")
+	outcome.Print(summary.FormatText)
+	if freedSize > 0 {
+		if _, _, err := state.RecordCleanup(0, freedSize); err != nil {
+			log.VerboseLog("failed to update lifetime stats: %v", err)
+		}
+	}
+}
@@ -0,0 +1,902 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/journal"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/power"
+	"github.com/hugoev/zap/internal/report"
+	"github.com/hugoev/zap/internal/stall"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/summary"
+	"github.com/hugoev/zap/internal/testmode"
+	"github.com/mattn/go-isatty"
+)
+
+var cleanupCommand = &command{
+	spec: commandSpec{
+		Name: "cleanup", Aliases: []string{"clean"}, Description: "Remove stale dependency/cache folders",
+		Flags: withCommon("yes", "dry-run", "interactive", "concurrency", "caches", "category", "compare", "format", "ignore-power", "include-open", "delete-timeout", "explain", "trash", "path", "allow-outside-home", "include-active", "estimate", "older-than", "i-know"),
+	},
+	readOnly: func(args []string) bool { return hasArg(args, "--dry-run") },
+	run: func(ctx context.Context, inv *invocation) {
+		// Stale dependency and build directories are the safe kind of action
+		handleCleanup(ctx, inv.cfg, inv.approve.covers(approveSafe), inv.dryRun, inv.jsonOutput, inv.flags, inv.flagValues, argValues(inv.args, "--path"))
+	},
+}
+
+func handleCleanup(ctx context.Context, cfg *config.Config, yes, dryRun, jsonOutput bool, flags map[string]bool, flagValues map[string]string, roots []string) {
+	atomic.AddInt32(&operationActive, 1)
+	defer atomic.AddInt32(&operationActive, -1)
+	// Validate config
+	if cfg.MaxAgeDaysForCleanup <= 0 {
+		log.Log(log.FAIL, "Invalid configuration: max_age_days_for_cleanup must be greater than 0")
+		os.Exit(1)
+	}
+
+	if value, ok := flagValues["older-than"]; ok {
+		// The value was checked when parsing the arguments
+		days, _ := age.ParseDays(value)
+		if days < 1 || days > 365 {
+			log.Log(log.FAIL, "Invalid --older-than: %s (must be between 1 day and 1y)", value)
+			os.Exit(1)
+		}
+		cfg.OverrideMaxAge(days)
+	}
+
+	homeDir, err := paths.HomeDir()
+	if err != nil {
+		log.Log(log.FAIL, "Cleanup scans your home directory: %v", err)
+		os.Exit(1)
+	}
+
+	// Scheduled runs wait for a better moment instead of grinding the disk on battery
+	if unattended(yes) && !dryRun && !flags["ignore-power"] {
+		if reason := power.Constraint(ctx); reason != "" {
+			log.Log(log.SKIP, "deferring unattended cleanup: %s (use --ignore-power to run anyway)", reason)
+			return
+		}
+	}
+
+	if flags["allow-outside-home"] && len(roots) == 0 {
+		log.Log(log.FAIL, "--allow-outside-home only applies to directories given with --path")
+		os.Exit(1)
+	}
+	trash := flags["trash"]
+	if flags["caches"] {
+		if len(roots) > 0 {
+			log.Log(log.FAIL, "--path doesn't apply to --caches, which prunes the package manager caches")
+			os.Exit(1)
+		}
+		if trash {
+			log.Log(log.FAIL, "--trash doesn't apply to --caches, which prunes individual cache entries")
+			os.Exit(1)
+		}
+		handleCacheCleanup(ctx, cfg, homeDir, yes, dryRun, jsonOutput)
+		return
+	}
+	format := launcherFormat(flagValues)
+	if flags["compare"] && !dryRun {
+		log.Log(log.FAIL, "--compare only works with --dry-run")
+		os.Exit(1)
+	}
+	estimate := flags["estimate"]
+	if estimate && flags["compare"] {
+		log.Log(log.FAIL, "--estimate can't be combined with --compare, which needs exact sizes")
+		os.Exit(1)
+	}
+
+	// The outcome of the run: the STATS line, and the summary of --json
+	outcome := summary.New("cleanup", dryRun)
+	runSummary = outcome
+	deletedStat, freedStat := "deleted", "freed"
+	switch {
+	case dryRun:
+		deletedStat, freedStat = "would_delete", "would_free"
+	case trash:
+		deletedStat, freedStat = "trashed", "in_trash"
+	}
+	outcome.Count(deletedStat, 0, "directories")
+	outcome.Bytes(freedStat, 0)
+
+	// Trees given with --path replace the auto-detected development directories
+	scanPaths, err := scanRoots(homeDir, roots, flags["allow-outside-home"])
+	if err != nil {
+		log.Log(log.FAIL, "Invalid --path: %v", err)
+		os.Exit(1)
+	}
+
+	if len(roots) > 0 {
+		log.VerboseLog("scanning %d path(s) given with --path", len(scanPaths))
+	} else if scanPaths = findProjectDirectories(homeDir); len(scanPaths) == 0 {
+		log.Log(log.INFO, "no common project directories found, scanning home directory")
+		scanPaths = []string{homeDir}
+	} else {
+		log.VerboseLog("scanning %d project directory path(s)", len(scanPaths))
+	}
+	var allDirs []cleanup.DirectoryInfo
+	scannedCount := 0
+
+	// Scan directories in parallel for better performance
+	type scanResult struct {
+		dirs []cleanup.DirectoryInfo
+		err  error
+		path string
+	}
+
+	results := make(chan scanResult, len(scanPaths))
+	semaphore := make(chan struct{}, scanConcurrency(cfg, flagValues))
+
+	// All scans report progress on one channel, consumed here
+	progress := make(chan cleanup.ProgressEvent, 64)
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		for event := range progress {
+			switch event.Kind {
+			case cleanup.ProgressEntered:
+				log.VerboseLog("  checking: %s", event.Path)
+			case cleanup.ProgressError:
+				log.VerboseLog("  %v", event.Err)
+			}
+		}
+	}()
+
+	// Launch parallel scans; a scan stuck on e.g. a dead network mount can be skipped
+	scanCtx, stopWatch := watchStalls(ctx, cfg, yes)
+	if estimate {
+		scanCtx = cleanup.WithEstimate(scanCtx, knownSizes())
+	}
+	for _, scanPath := range scanPaths {
+		if _, err := os.Stat(scanPath); os.IsNotExist(err) {
+			log.VerboseLog("skipping non-existent path: %s", scanPath)
+			results <- scanResult{dirs: nil, err: nil, path: scanPath}
+			continue
+		}
+
+		go func(path string) {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			log.VerboseLog("scanning: %s", path)
+			pathCtx, task := stall.Track(scanCtx, "scan of "+path)
+			defer task.Done()
+			dirs, err := cleanup.ScanDirectories(pathCtx, path, cfg.CleanupPatterns, cfg.ShouldCleanupDirectory, progress)
+			if errors.Is(context.Cause(pathCtx), stall.ErrSkipped) {
+				err = stall.ErrSkipped
+			}
+			results <- scanResult{dirs: dirs, err: err, path: path}
+		}(scanPath)
+	}
+
+	// Collect results; scans that couldn't inspect everything still return what they found
+	var unreadable []string
+	for i := 0; i < len(scanPaths); i++ {
+		result := <-results
+		var incomplete *cleanup.IncompleteScanError
+		if errors.As(result.err, &incomplete) {
+			unreadable = append(unreadable, incomplete.Paths()...)
+		} else if errors.Is(result.err, stall.ErrSkipped) {
+			log.Log(log.SKIP, "%s (made no progress, skipped; nothing under it was checked)", result.path)
+			outcome.AddError(fmt.Sprintf("scan of %s skipped after stalling", result.path))
+			continue
+		} else if result.err != nil {
+			log.VerboseLog("error scanning %s: %v", result.path, result.err)
+			outcome.AddError(fmt.Sprintf("error scanning %s: %v", result.path, result.err))
+			continue
+		}
+		if result.dirs != nil {
+			allDirs = append(allDirs, result.dirs...)
+			scannedCount++
+		}
+	}
+	stopWatch()
+	close(progress)
+	<-progressDone
+	if ctx.Err() != nil {
+		log.Log(log.INFO, "operation cancelled")
+		return
+	}
+
+	log.VerboseLog("scanned %d directory path(s)", scannedCount)
+	if len(unreadable) > 0 {
+		defer log.Log(log.SKIP, "%d paths could not be inspected (permissions) - results may be incomplete; see them with --verbose", len(unreadable))
+	}
+
+	// Well-known cache locations outside projects, on request
+	if categoryList, ok := flagValues["category"]; ok {
+		maxAge := time.Duration(cfg.MaxAgeDays()) * 24 * time.Hour
+		categoryDirs, err := cleanup.ScanCategories(ctx, homeDir, scanPaths, strings.Split(categoryList, ","), maxAge)
+		if ctx.Err() != nil {
+			log.Log(log.INFO, "operation cancelled")
+			return
+		}
+		if err != nil {
+			log.Log(log.FAIL, "Invalid --category: %v", err)
+			os.Exit(1)
+		}
+		projectDirs := allDirs
+		for _, dir := range categoryDirs {
+			if cfg.IsExcluded(dir.Path) {
+				log.VerboseLog("skipping excluded path: %s", dir.Path)
+				continue
+			}
+			// e.g. ~/.cache/JetBrains/... when ~/.cache itself was already matched
+			if insideAny(dir.Path, projectDirs) {
+				continue
+			}
+			allDirs = append(allDirs, dir)
+		}
+		log.VerboseLog("found %d candidate(s) in categories %s", len(categoryDirs), categoryList)
+	}
+
+	// Skip caches of projects that are open in an editor - deleting them breaks the live session
+	if !flags["include-open"] && len(allDirs) > 0 {
+		openProjects := cleanup.DetectOpenProjects(ctx)
+		log.VerboseLog("detected %d open project(s)", len(openProjects))
+		if len(openProjects) > 0 {
+			var closedDirs []cleanup.DirectoryInfo
+			for _, dir := range allDirs {
+				if project, ok := cleanup.FindOpenProject(dir.Path, openProjects); ok {
+					log.Log(log.SKIP, "%s (project open in %s)", dir.Path, project.Source)
+					explain("inside %s, which is open in %s (clean anyway with --include-open)", project.Path, project.Source)
+					continue
+				}
+				closedDirs = append(closedDirs, dir)
+			}
+			allDirs = closedDirs
+		}
+	}
+
+	// Skip directories of repositories someone is working in - deleting their build
+	// output forces an expensive rebuild
+	if !flags["include-active"] && len(allDirs) > 0 {
+		dirPaths := make([]string, len(allDirs))
+		for i, dir := range allDirs {
+			dirPaths[i] = dir.Path
+		}
+		activeRepos := cleanup.DetectActiveRepos(ctx, dirPaths, cfg.ActiveRepoWindow())
+		var inactiveDirs []cleanup.DirectoryInfo
+		for _, dir := range allDirs {
+			if repo, ok := activeRepos[dir.Path]; ok {
+				log.Log(log.SKIP, "%s (git repository %s)", dir.Path, repo.Reason())
+				explain("inside %s, which %s (clean anyway with --include-active, or set active_repo_days)", repo.Root, repo.Reason())
+				continue
+			}
+			inactiveDirs = append(inactiveDirs, dir)
+		}
+		allDirs = inactiveDirs
+	}
+
+	// Directories declined before with "don't propose again" are listed, not proposed
+	var ignoredDirs []cleanup.DirectoryInfo
+	if len(cfg.IgnoredDirs) > 0 {
+		var proposedDirs []cleanup.DirectoryInfo
+		for _, dir := range allDirs {
+			if _, ok := cfg.IgnoredDirFor(dir.Path); ok {
+				ignoredDirs = append(ignoredDirs, dir)
+				continue
+			}
+			proposedDirs = append(proposedDirs, dir)
+		}
+		allDirs = proposedDirs
+	}
+
+	// Project trees cleanup hasn't seen before start out untrusted: what they hold is
+	// only reported until they are trusted; at a terminal zap asks to trust them
+	workspaces := seeWorkspaces(homeDir, scanPaths, allDirs)
+	var untrustedDirs []cleanup.DirectoryInfo
+	var untrustedTrees []string
+	allDirs, untrustedDirs, untrustedTrees = splitUntrusted(workspaces, scanPaths, allDirs, !unattended(yes) && !dryRun && format == "")
+
+	// Remember what a dry run proposes, so the next one can show what a config change did;
+	// estimated sizes would pass for measured ones in the next estimate
+	if dryRun && !estimate {
+		previous := rememberDryRun(allDirs)
+		if flags["compare"] {
+			defer showDryRunDiff(previous, allDirs)
+		}
+	}
+
+	// Estimate what deleting dependency directories costs to undo; the journal remembers
+	// install times measured at earlier deletions for directories reinstalled since
+	history, _ := journal.Read()
+	for i := range allDirs {
+		cost := cleanup.EstimateReinstall(allDirs[i])
+		if cost.Duration == 0 {
+			cost.Duration, _ = journal.LastInstallDuration(history, allDirs[i].Path)
+		}
+		if cost.Known() {
+			allDirs[i].Reinstall = &cost
+		}
+	}
+
+	if format != "" {
+		printLauncherDirs(format, allDirs)
+		return
+	}
+
+	// JSON output reports every candidate and what happened to it once we're done
+	outcomes := make(map[string]directoryResult)
+	if jsonOutput {
+		found := allDirs
+		defer func() { printCleanupJSON(found, ignoredDirs, untrustedDirs, cfg, outcomes, unreadable, outcome) }()
+	}
+
+	if len(allDirs) == 0 {
+		showIgnoredDirs(cfg, ignoredDirs)
+		showUntrustedDirs(untrustedDirs, untrustedTrees)
+		if len(untrustedDirs) > 0 {
+			log.Log(log.OK, "no stale directories found in trusted trees")
+			return
+		}
+		log.Log(log.OK, "no stale directories found")
+		return
+	}
+
+	// Display found directories
+	totalSize := cleanup.GetTotalSize(allDirs)
+
+	// Sort by size (largest first) for better visibility
+	// Use a more efficient sorting algorithm
+	sortedDirs := make([]cleanup.DirectoryInfo, len(allDirs))
+	copy(sortedDirs, allDirs)
+
+	// Quick sort by size (largest first)
+	for i := 0; i < len(sortedDirs)-1; i++ {
+		maxIdx := i
+		for j := i + 1; j < len(sortedDirs); j++ {
+			if sortedDirs[j].Size > sortedDirs[maxIdx].Size {
+				maxIdx = j
+			}
+		}
+		if maxIdx != i {
+			sortedDirs[i], sortedDirs[maxIdx] = sortedDirs[maxIdx], sortedDirs[i]
+		}
+	}
+
+	if estimate {
+		log.Log(log.FOUND, "found %d directories (~%s total, estimated)", len(allDirs), cleanup.FormatSize(totalSize))
+	} else {
+		log.Log(log.FOUND, "found %d directories (%s total)", len(allDirs), cleanup.FormatSize(totalSize))
+	}
+
+	for _, dir := range sortedDirs {
+		dirAge := age.Of(testmode.Since(dir.ModTime))
+		reinstall := ""
+		if dir.Reinstall != nil {
+			reinstall = " - " + dir.Reinstall.String()
+		}
+		if log.Verbose {
+			log.Log(log.FOUND, "%s (%s on disk, %s apparent, %s old)%s", dir.Path, sizeLabel(dir, dir.Size), sizeLabel(dir, dir.ApparentSize), dirAge, reinstall)
+		} else {
+			log.Log(log.FOUND, "%s (%s, %s old)%s", dir.Path, sizeLabel(dir, dir.Size), dirAge, reinstall)
+		}
+		if dir.Category != "" {
+			explain("category %s: %s, last modified %s ago, not under exclude_paths", dir.Category, dir.Pattern, dirAge)
+		} else {
+			maxAgeSource := "max_age_days_for_cleanup"
+			if _, ok := flagValues["older-than"]; ok {
+				maxAgeSource = "--older-than"
+			}
+			sizeLimit := ""
+			if rule := cfg.CleanupRule(dir.Path, dir.Pattern); rule != nil {
+				if rule.MaxAgeDays > 0 && maxAgeSource != "--older-than" {
+					maxAgeSource = fmt.Sprintf("max_age_days of cleanup rule %q", rule.Pattern)
+				}
+				if rule.MinSizeMB > 0 {
+					sizeLimit = fmt.Sprintf(", at least min_size_mb %d of cleanup rule %q", rule.MinSizeMB, rule.Pattern)
+				}
+			}
+			explain("matched pattern %q, last modified %s ago (older than %s %d days)%s, not under exclude_paths", dir.Pattern, dirAge, maxAgeSource, cfg.MaxAgeDaysFor(dir.Path, dir.Pattern), sizeLimit)
+		}
+	}
+	log.VerboseLog("total: %s on disk, %s apparent", cleanup.FormatSize(totalSize), cleanup.FormatSize(cleanup.GetTotalApparentSize(allDirs)))
+	showIgnoredDirs(cfg, ignoredDirs)
+	showUntrustedDirs(untrustedDirs, untrustedTrees)
+
+	// A dry run would delete everything found, asked or not
+	if dryRun {
+		outcome.Count(deletedStat, len(allDirs), "directories")
+		outcome.Bytes(freedStat, totalSize)
+	}
+
+	// The preview was estimated; what is confirmed and deleted is measured
+	if estimate && !dryRun {
+		allDirs, sortedDirs = measureCandidates(ctx, cfg, sortedDirs)
+		if ctx.Err() != nil {
+			log.Log(log.INFO, "operation cancelled")
+			return
+		}
+		if len(allDirs) == 0 {
+			log.Log(log.OK, "no stale directories left after measuring their exact sizes")
+			return
+		}
+		totalSize = cleanup.GetTotalSize(allDirs)
+		log.Log(log.INFO, "measured: %d directories, %s total", len(allDirs), cleanup.FormatSize(totalSize))
+	}
+
+	shouldDelete := yes
+	if interactive(flags) && !yes {
+		// -i: pick individual directories instead of all-or-nothing
+		allDirs = pickDirectories(sortedDirs)
+		offerToIgnoreDirs(ctx, cfg, leftOut(sortedDirs, allDirs))
+		sortedDirs = allDirs
+		totalSize = cleanup.GetTotalSize(allDirs)
+		shouldDelete = len(allDirs) > 0
+		if !shouldDelete {
+			log.Log(log.OK, "nothing selected, no directories deleted")
+			return
+		}
+	} else if !shouldDelete && !dryRun {
+		showDirectoryConfirmation(sortedDirs, totalSize)
+		if trash {
+			log.Log(log.ACTION, "move these %d directories (%s total) to the trash? (y/N): ", len(allDirs), cleanup.FormatSize(totalSize))
+		} else {
+			log.Log(log.ACTION, "delete these %d directories (%s total)? (y/N): ", len(allDirs), cleanup.FormatSize(totalSize))
+		}
+		shouldDelete = confirm()
+		if !shouldDelete {
+			offerToIgnoreDirs(ctx, cfg, sortedDirs)
+		}
+	}
+
+	if shouldDelete {
+		if dryRun {
+			verb := "delete"
+			if trash {
+				verb = "move to trash"
+			}
+			log.Log(log.INFO, "would %s %d directories (%s total)", verb, len(allDirs), cleanup.FormatSize(totalSize))
+			outcome.Count(deletedStat, len(allDirs), "directories")
+			outcome.Bytes(freedStat, totalSize)
+			for _, dir := range sortedDirs {
+				log.Log(log.DELETE, "%s (would %s)", dir.Path, verb)
+				outcomes[dir.Path] = directoryResult{Action: actionWouldDelete}
+			}
+		} else {
+			if yes && !trash {
+				checkHugeCleanup(cfg, allDirs, totalSize, flags["i-know"])
+			}
+			deletedCount := 0
+			freedSize := int64(0)
+			failedCount := 0
+			var timedOut []string
+
+			// Per-directory time budget so one huge or network-mounted tree can't stall the run
+			deletionTimeout := cfg.DeletionTimeout()
+			if timeoutStr, ok := flagValues["delete-timeout"]; ok {
+				parsed, err := time.ParseDuration(timeoutStr)
+				if err != nil || parsed <= 0 {
+					log.Log(log.FAIL, "Invalid --delete-timeout: %s (use e.g. 30s, 5m)", timeoutStr)
+					os.Exit(1)
+				}
+				deletionTimeout = parsed
+			}
+			// All directories trashed by this run are one batch for `zap restore`
+			batch := fmt.Sprintf("%s-%d", testmode.Now().Format("20060102T150405.000000000"), os.Getpid())
+
+			deleteCtx, stopWatch := watchStalls(ctx, cfg, yes)
+			for _, dir := range allDirs {
+				if ctx.Err() != nil {
+					break
+				}
+				// Verify directory still exists before attempting deletion
+				if _, err := os.Stat(dir.Path); os.IsNotExist(err) {
+					log.VerboseLog("%s no longer exists, skipping", dir.Path)
+					continue
+				}
+
+				if trash {
+					trashedPath, err := cleanup.MoveToTrash(ctx, dir.Path)
+					if err == nil && !cleanup.Trashed(dir.Path) {
+						err = fmt.Errorf("%s is still in place", dir.Path)
+					}
+					if err != nil {
+						log.Log(log.FAIL, "Failed to move %s to the trash: %v", dir.Path, err)
+						failedCount++
+						recordTrash(dir, batch, "", journal.ResultFailed, err.Error())
+						outcomes[dir.Path] = directoryResult{Action: actionFailed, Error: err.Error()}
+						continue
+					}
+					log.Log(log.DELETE, "%s (moved to trash)", dir.Path)
+					deletedCount++
+					freedSize += dir.Size
+					recordTrash(dir, batch, trashedPath, journal.ResultOK, "")
+					outcomes[dir.Path] = directoryResult{Action: actionTrashed}
+					continue
+				}
+
+				dirCtx, task := stall.Track(deleteCtx, "deletion of "+dir.Path)
+				err := cleanup.DeleteDirectoryWithTimeout(dirCtx, dir.Path, deletionTimeout)
+				task.Done()
+				if err != nil {
+					if ctx.Err() != nil {
+						log.Log(log.SKIP, "%s (cancelled, partially deleted)", dir.Path)
+						failedCount++
+						recordDeletion(dir, journal.ResultFailed, "cancelled, partially deleted")
+						outcomes[dir.Path] = directoryResult{Action: actionFailed, Error: "cancelled, partially deleted"}
+						break
+					}
+					if errors.Is(err, cleanup.ErrDeletionTimeout) {
+						log.Log(log.SKIP, "%s (deletion exceeded %v, skipped)", dir.Path, deletionTimeout)
+						timedOut = append(timedOut, dir.Path)
+						recordDeletion(dir, journal.ResultSkipped, err.Error())
+						outcomes[dir.Path] = directoryResult{Action: actionTimedOut, Error: err.Error()}
+						continue
+					}
+					if errors.Is(context.Cause(dirCtx), stall.ErrSkipped) {
+						log.Log(log.SKIP, "%s (made no progress, skipped; partially deleted)", dir.Path)
+						failedCount++
+						recordDeletion(dir, journal.ResultSkipped, "stalled, partially deleted")
+						outcomes[dir.Path] = directoryResult{Action: actionStalled, Error: "stalled, partially deleted"}
+						continue
+					}
+					log.Log(log.FAIL, "Failed to delete %s: %v", dir.Path, err)
+					failedCount++
+					recordDeletion(dir, journal.ResultFailed, err.Error())
+					outcomes[dir.Path] = directoryResult{Action: actionFailed, Error: err.Error()}
+				} else {
+					// Verify deletion succeeded
+					if cleanup.Removed(dir.Path) {
+						log.Log(log.DELETE, "%s", dir.Path)
+						deletedCount++
+						freedSize += dir.Size
+						recordDeletion(dir, journal.ResultOK, "")
+						outcomes[dir.Path] = directoryResult{Action: actionDeleted}
+					} else {
+						log.Log(log.FAIL, "Deletion verification failed for %s", dir.Path)
+						failedCount++
+						recordDeletion(dir, journal.ResultFailed, "deletion verification failed")
+						outcomes[dir.Path] = directoryResult{Action: actionFailed, Error: "deletion verification failed"}
+					}
+				}
+			}
+			stopWatch()
+
+			if ctx.Err() != nil {
+				log.Log(log.INFO, "operation cancelled, remaining directories left in place")
+			}
+			outcome.Count(deletedStat, deletedCount, "directories")
+			outcome.Bytes(freedStat, freedSize)
+			outcome.CountIfAny("failed", failedCount, "")
+			outcome.CountIfAny("timed_out", len(timedOut), "")
+			outcome.Print(summary.FormatText)
+			printProjectTotals(freedDirs(allDirs, outcomes))
+			if trash && deletedCount > 0 {
+				log.Log(log.INFO, "the space is freed once the trash is emptied; undo with zap restore")
+			}
+			for _, path := range timedOut {
+				log.Log(log.SKIP, "timed out: %s", path)
+			}
+
+			// Trashed directories still take up space, so they don't count as reclaimed
+			if deletedCount > 0 && !trash {
+				milestone, crossed, err := state.RecordCleanup(deletedCount, freedSize)
+				if err != nil {
+					log.VerboseLog("failed to update lifetime stats: %v", err)
+				} else if crossed && cfg.CelebrateMilestones {
+					log.Log(log.STATS, "milestone: zap has now reclaimed over %s in total - see 'zap stats'", cleanup.FormatSize(milestone))
+				}
+			}
+
+			// Unattended (cron/scheduled) runs report to the team's webhook, if configured
+			if cfg.ReportWebhook != "" && unattended(yes) {
+				webhookReport := report.NewCleanupSummary()
+				webhookReport.Found = len(allDirs)
+				webhookReport.Deleted = deletedCount
+				webhookReport.Failed = failedCount
+				webhookReport.Skipped = len(timedOut)
+				webhookReport.FreedBytes = freedSize
+				if err := report.SendCleanup(ctx, cfg, webhookReport); err != nil {
+					log.Log(log.FAIL, "Failed to send cleanup report: %v", err)
+				} else {
+					log.VerboseLog("sent cleanup report to %s", cfg.ReportWebhook)
+				}
+			}
+		}
+	}
+}
+
+// checkHugeCleanup stops a cleanup deleting more than huge_cleanup_gb or
+// huge_cleanup_dirs without confirmation unless a dry run within dry_run_window_minutes
+// listed every one of its directories, or --i-know was given: a one-shot --yes run with
+// a wrong --path or max age shouldn't be able to wipe out a disk
+func checkHugeCleanup(cfg *config.Config, dirs []cleanup.DirectoryInfo, totalSize int64, iKnow bool) {
+	if !cfg.HugeCleanup(len(dirs), totalSize) {
+		return
+	}
+	if iKnow {
+		log.Log(log.WARN, "deleting %d directories (%s) without a recent dry run (--i-know)", len(dirs), cleanup.FormatSize(totalSize))
+		return
+	}
+	var reason string
+	st, err := state.Load()
+	switch {
+	case err != nil || st.LastDryRun == nil:
+		reason = "no dry run on record"
+	case testmode.Since(st.LastDryRun.Time) > cfg.DryRunWindow():
+		reason = fmt.Sprintf("the last dry run was %s ago", formatRuntime(testmode.Since(st.LastDryRun.Time)))
+	default:
+		previewed := make(map[string]bool, len(st.LastDryRun.Candidates))
+		for _, candidate := range st.LastDryRun.Candidates {
+			previewed[candidate.Path] = true
+		}
+		missing := 0
+		for _, dir := range dirs {
+			if !previewed[dir.Path] {
+				missing++
+			}
+		}
+		if missing == 0 {
+			log.VerboseLog("huge cleanup cleared by the dry run of %s", st.LastDryRun.Time.Format("15:04:05"))
+			return
+		}
+		reason = fmt.Sprintf("the last dry run didn't list %d of them", missing)
+	}
+	log.Log(log.FAIL, "Refusing to delete %d directories (%s) unasked: %s", len(dirs), cleanup.FormatSize(totalSize), reason)
+	log.Log(log.INFO, "preview them with zap cleanup --dry-run (valid for %d minutes), drop --yes to confirm them, or pass --i-know", int(cfg.DryRunWindow().Minutes()))
+	os.Exit(1)
+}
+
+// unattended reports whether nobody is there to answer prompts: --yes, or run from
+// cron/launchd without a terminal
+func unattended(yes bool) bool {
+	return yes || !isatty.IsTerminal(os.Stdin.Fd())
+}
+
+// insideAny reports whether path is one of dirs or inside one of them
+func insideAny(path string, dirs []cleanup.DirectoryInfo) bool {
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(dir.Path, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDeletion writes a cleanup outcome to the journal and prints its RECORD line
+func recordDeletion(dir cleanup.DirectoryInfo, result, detail string) {
+	entry := journal.Entry{
+		Action: journal.ActionDelete,
+		Target: dir.Path,
+		Result: result,
+		Detail: detail,
+	}
+	if result == journal.ResultOK {
+		entry.Bytes = dir.Size
+		if dir.Reinstall != nil {
+			entry.InstallSeconds = dir.Reinstall.Duration.Seconds()
+		}
+	}
+	recordAction(entry)
+}
+
+// recordTrash writes a move to the trash to the journal, where `zap restore` finds it
+func recordTrash(dir cleanup.DirectoryInfo, batch, trashedPath, result, detail string) {
+	entry := journal.Entry{
+		Action:    journal.ActionTrash,
+		Target:    dir.Path,
+		Result:    result,
+		Detail:    detail,
+		TrashPath: trashedPath,
+		Batch:     batch,
+	}
+	if result == journal.ResultOK {
+		entry.Bytes = dir.Size
+	}
+	recordAction(entry)
+}
+
+// showIgnoredDirs lists the candidates the user asked not to propose again, with their reason
+func showIgnoredDirs(cfg *config.Config, dirs []cleanup.DirectoryInfo) {
+	if len(dirs) == 0 {
+		return
+	}
+	fmt.Fprintln(log.Writer())
+	fmt.Fprintf(log.Writer(), "  Ignored (%d, %s total; see zap config ignored-dirs):\n", len(dirs), cleanup.FormatSize(cleanup.GetTotalSize(dirs)))
+	for _, dir := range dirs {
+		reason := ""
+		if ignored, _ := cfg.IgnoredDirFor(dir.Path); ignored.Reason != "" {
+			reason = " - " + ignored.Reason
+		}
+		fmt.Fprintf(log.Writer(), "    %s (%s)%s\n", dir.Path, sizeLabel(dir, dir.Size), reason)
+	}
+	fmt.Fprintln(log.Writer())
+}
+
+// offerToIgnoreDirs asks whether declined directories should be left out of future
+// cleanups, and why, so the list in zap config ignored-dirs explains itself later
+func offerToIgnoreDirs(ctx context.Context, cfg *config.Config, dirs []cleanup.DirectoryInfo) {
+	if len(dirs) == 0 {
+		return
+	}
+	log.Log(log.ACTION, "don't propose these %d directories again? (y/N): ", len(dirs))
+	if !confirm() {
+		return
+	}
+	log.Log(log.ACTION, "reason, for zap config ignored-dirs (optional): ")
+	reason := readLine()
+	dirPaths := make([]string, len(dirs))
+	for i, dir := range dirs {
+		dirPaths[i] = dir.Path
+	}
+	if err := cfg.IgnoreDirs(ctx, dirPaths, reason); err != nil {
+		log.Log(log.FAIL, "Failed to save ignored directories: %v", err)
+		return
+	}
+	log.Log(log.OK, "ignoring %d directories; undo with: zap config ignored-dirs remove <number>", len(dirs))
+}
+
+// leftOut returns the directories of dirs that aren't in picked
+func leftOut(dirs, picked []cleanup.DirectoryInfo) []cleanup.DirectoryInfo {
+	var left []cleanup.DirectoryInfo
+	for _, dir := range dirs {
+		if !slices.ContainsFunc(picked, func(p cleanup.DirectoryInfo) bool { return p.Path == dir.Path }) {
+			left = append(left, dir)
+		}
+	}
+	return left
+}
+
+// sizeLabel formats size, one of dir's sizes, marking estimates with a "~"
+func sizeLabel(dir cleanup.DirectoryInfo, size int64) string {
+	if dir.Estimated {
+		return "~" + cleanup.FormatSize(size)
+	}
+	return cleanup.FormatSize(size)
+}
+
+// knownSizes are the sizes measured by the last dry run, which --estimate reuses for
+// directories unchanged since
+func knownSizes() cleanup.KnownSizes {
+	st, err := state.Load()
+	if err != nil || st.LastDryRun == nil {
+		return cleanup.KnownSizes{}
+	}
+	known := cleanup.KnownSizes{Time: st.LastDryRun.Time, Sizes: make(map[string]int64)}
+	for _, candidate := range st.LastDryRun.Candidates {
+		known.Sizes[candidate.Path] = candidate.Size
+	}
+	return known
+}
+
+// measureCandidates replaces the estimated sizes of dirs, sorted largest first, with
+// measured ones and drops those that no longer qualify (a cleanup rule's min_size_mb
+// may have been met only by the estimate). It returns them in both orders handleCleanup
+// keeps: as found, and largest first.
+func measureCandidates(ctx context.Context, cfg *config.Config, dirs []cleanup.DirectoryInfo) ([]cleanup.DirectoryInfo, []cleanup.DirectoryInfo) {
+	log.Log(log.SCAN, "measuring the exact size of %d directories...", len(dirs))
+	measured := slices.Clone(dirs)
+	if err := cleanup.MeasureExact(ctx, measured); err != nil && ctx.Err() == nil {
+		log.VerboseLog("could not measure every directory: %v", err)
+	}
+	var kept []cleanup.DirectoryInfo
+	for _, dir := range measured {
+		if dir.Category == "" && !cfg.ShouldCleanupDirectory(dir) {
+			log.Log(log.SKIP, "%s (%s once measured, below min_size_mb)", dir.Path, cleanup.FormatSize(dir.Size))
+			continue
+		}
+		kept = append(kept, dir)
+	}
+	sorted := slices.Clone(kept)
+	slices.SortStableFunc(sorted, func(a, b cleanup.DirectoryInfo) int { return cmp.Compare(b.Size, a.Size) })
+	return kept, sorted
+}
+
+// showDirectoryConfirmation displays detailed information about directories before asking for confirmation
+func showDirectoryConfirmation(dirs []cleanup.DirectoryInfo, totalSize int64) {
+	fmt.Fprintln(log.Writer())
+	fmt.Fprintf(log.Writer(), "  Directories to delete (%d, %s total):\n", len(dirs), cleanup.FormatSize(totalSize))
+
+	// Show all directories
+	for i, dir := range dirs {
+		fmt.Fprintf(log.Writer(), "    %d. %s (%s, %s old)\n", i+1, dir.Path, cleanup.FormatSize(dir.Size), age.Of(testmode.Since(dir.ModTime)))
+	}
+	fmt.Fprintln(log.Writer())
+}
+
+// scanRoots resolves the trees given with --path, dropping those inside another. Trees
+// outside the home directory are refused unless allowOutsideHome is set, in which case
+// deletion inside them is allowed too.
+func scanRoots(homeDir string, roots []string, allowOutsideHome bool) ([]string, error) {
+	var absRoots, outsideHome []string
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", root, err)
+		}
+		info, err := os.Stat(absRoot)
+		if err != nil {
+			return nil, fmt.Errorf("cannot access %s: %w", absRoot, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", absRoot)
+		}
+		if filepath.Dir(absRoot) == absRoot {
+			return nil, fmt.Errorf("refusing to scan the filesystem root %s", absRoot)
+		}
+		if !pathInside(homeDir, absRoot) {
+			if !allowOutsideHome {
+				return nil, fmt.Errorf("%s is outside your home directory (add --allow-outside-home to clean it anyway)", absRoot)
+			}
+			outsideHome = append(outsideHome, absRoot)
+		}
+		absRoots = append(absRoots, absRoot)
+	}
+
+	var distinct []string
+	for i, root := range absRoots {
+		nested := false
+		for j, other := range absRoots {
+			if i != j && pathInside(other, root) && (root != other || j < i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			distinct = append(distinct, root)
+		}
+	}
+	if len(outsideHome) > 0 {
+		if err := cleanup.AllowRoots(outsideHome); err != nil {
+			return nil, err
+		}
+	}
+	return distinct, nil
+}
+
+// pathInside reports whether path is dir or inside it
+func pathInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// findProjectDirectories auto-detects common project directory locations
+func findProjectDirectories(homeDir string) []string {
+	var paths []string
+
+	// Common project directory names (case-insensitive on macOS)
+	candidates := []string{
+		"Documents", "Projects", "Code", "workspace", "work",
+		"Development", "dev", "src", "repos", "repositories",
+		"git", "github", "gitlab", "bitbucket",
+	}
+
+	for _, name := range candidates {
+		path := filepath.Join(homeDir, name)
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+
+	// Also check common macOS locations
+	if runtime.GOOS == "darwin" {
+		macPaths := []string{
+			filepath.Join(homeDir, "Desktop"),
+		}
+		for _, path := range macPaths {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	return paths
+}
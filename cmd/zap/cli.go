@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/age"
+)
+
+// commandLine is the arguments of a command, parsed against its spec
+type commandLine struct {
+	flags      map[string]bool
+	flagValues map[string]string
+	positional []string
+}
+
+// parseArgs parses the arguments of the command described by spec. Short flags (-y,
+// -vj) are recorded under their long names. Only flags that take a value consume the
+// next argument (--ports 3000): in `zap kill --udp 5353` the port stays positional, and
+// everything after "--" is positional. Flags the command doesn't honour, missing values
+// and values of the wrong type are errors.
+func parseArgs(spec commandSpec, args []string) (commandLine, error) {
+	defs := flagDefinitions()
+	allowed := allowedFlags(spec)
+	line := commandLine{flags: make(map[string]bool), flagValues: make(map[string]string)}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			line.positional = append(line.positional, args[i+1:]...)
+			return line, nil
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+			def, ok := defs[name]
+			if !ok || def.Name != name || !allowed[name] {
+				return line, unknownFlag(spec, "--"+name)
+			}
+			line.flags[name] = true
+			if !hasValue && def.Value != "" {
+				if i+1 == len(args) || strings.HasPrefix(args[i+1], "-") {
+					return line, fmt.Errorf("--%s needs a value (--%s=<%s>)", name, name, def.Value)
+				}
+				i++
+				value, hasValue = args[i], true
+			}
+			if hasValue {
+				if err := checkFlagValue(def, value); err != nil {
+					return line, err
+				}
+				line.flagValues[name] = value
+			}
+
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			// Short flags, possibly combined: -y, -vj
+			for _, char := range strings.TrimPrefix(arg, "-") {
+				def, ok := defs[string(char)]
+				if !ok || def.Short != string(char) || !allowed[def.Name] {
+					return line, unknownFlag(spec, "-"+string(char))
+				}
+				line.flags[def.Name] = true
+			}
+
+		default:
+			line.positional = append(line.positional, arg)
+		}
+	}
+	return line, nil
+}
+
+// allowedFlags are the flags a command honours: its own, its subcommands', the common
+// flags and --help
+func allowedFlags(spec commandSpec) map[string]bool {
+	allowed := map[string]bool{"help": true}
+	for _, name := range commonFlags {
+		allowed[name] = true
+	}
+	var collect func(spec commandSpec)
+	collect = func(spec commandSpec) {
+		for _, name := range spec.Flags {
+			allowed[name] = true
+		}
+		for _, sub := range spec.Subcommands {
+			collect(sub)
+		}
+	}
+	collect(spec)
+	return allowed
+}
+
+func unknownFlag(spec commandSpec, flag string) error {
+	return fmt.Errorf("Unknown flag %s for zap %s (see zap %s --help)", flag, spec.Name, spec.Name)
+}
+
+// checkFlagValue checks a value against the type its placeholder names: a number for
+// <n> and <bytes>, a duration for <duration>, an age for <age>. Ranges are left to the
+// command.
+func checkFlagValue(def flagSpec, value string) error {
+	switch def.Value {
+	case "n", "bytes":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("Invalid --%s: %s (must be a number)", def.Name, value)
+		}
+	case "duration":
+		if _, err := time.ParseDuration(value); err != nil {
+			return fmt.Errorf("Invalid --%s: %s (use e.g. 30s, 5m)", def.Name, value)
+		}
+	case "age":
+		if _, err := age.ParseDays(value); err != nil {
+			return fmt.Errorf("Invalid --%s: %v", def.Name, err)
+		}
+	}
+	return nil
+}
+
+// printCommandHelp prints the usage of a command, or of the subcommand named by the
+// first positional arguments (`zap config set --help`), generated from the spec
+func printCommandHelp(spec commandSpec, positional []string) {
+	path := "zap " + spec.Name
+	flagNames := allowedFlags(spec)
+	for _, word := range positional {
+		found := false
+		for _, sub := range spec.Subcommands {
+			if sub.Name == word {
+				spec, found = sub, true
+				path += " " + word
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+
+	usage := path
+	if len(spec.Subcommands) > 0 {
+		usage += " <command>"
+	}
+	for _, arg := range spec.Args {
+		word := "<" + arg.Name + ">"
+		if arg.Variadic {
+			word += "..."
+		}
+		if arg.Optional {
+			word = "[" + word + "]"
+		}
+		usage += " " + word
+	}
+	fmt.Printf("Usage: %s [flags]\n\n%s\n", usage, spec.Description)
+	if len(spec.Aliases) > 0 {
+		fmt.Printf("\nAliases: %s\n", strings.Join(spec.Aliases, ", "))
+	}
+
+	if len(spec.Subcommands) > 0 {
+		var rows [][2]string
+		for _, sub := range spec.Subcommands {
+			rows = append(rows, [2]string{sub.Name, sub.Description})
+		}
+		fmt.Println("\nCommands:")
+		printHelpRows(rows)
+	}
+
+	var rows [][2]string
+	for _, def := range buildSpec().Flags {
+		if !flagNames[def.Name] {
+			continue
+		}
+		name := "--" + def.Name
+		if def.Value != "" {
+			name += "=<" + def.Value + ">"
+		}
+		if def.Short != "" {
+			name += ", -" + def.Short
+		}
+		rows = append(rows, [2]string{name, def.Description})
+	}
+	fmt.Println("\nFlags:")
+	printHelpRows(rows)
+}
+
+// printHelpRows prints name/description rows with the descriptions aligned
+func printHelpRows(rows [][2]string) {
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row[0]))
+	}
+	for _, row := range rows {
+		fmt.Printf("  %-*s  %s\n", width, row[0], row[1])
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/lock"
+)
+
+// Command is a zap subcommand. Each command is defined next to its handler and listed in
+// the registry below; main dispatches through it and `zap spec` is generated from it.
+type Command interface {
+	Name() string
+	// Flags are the names of the flags the command honours
+	Flags() []string
+	// Spec describes the command for help and completion engines
+	Spec() commandSpec
+	// ReadOnly reports whether running with args never kills, deletes or writes
+	// anything, so the command may run without the instance lock
+	ReadOnly(args []string) bool
+	Run(ctx context.Context, inv *invocation)
+}
+
+// invocation is a parsed command line plus what main prepared for the command
+type invocation struct {
+	args       []string // as given, flags included
+	positional []string // the arguments that aren't flags or flag values
+	flags      map[string]bool
+	flagValues map[string]string
+	approve    approval // what --yes approves without asking
+	yes        bool     // --yes approves everything
+	dryRun     bool
+	jsonOutput bool
+	cfg        *config.Config
+	lock       *lock.InstanceLock
+}
+
+// command implements Command with a spec and a run function
+type command struct {
+	spec     commandSpec
+	readOnly func(args []string) bool // nil: the command may write
+	run      func(ctx context.Context, inv *invocation)
+}
+
+func (c *command) Name() string      { return c.spec.Name }
+func (c *command) Flags() []string   { return c.spec.Flags }
+func (c *command) Spec() commandSpec { return c.spec }
+
+func (c *command) ReadOnly(args []string) bool {
+	return c.readOnly != nil && c.readOnly(args)
+}
+
+func (c *command) Run(ctx context.Context, inv *invocation) {
+	c.run(ctx, inv)
+}
+
+// commands is every command, in the order `zap spec` lists them. It's filled in init
+// because commands refer back to it (kill and spec read the flag definitions).
+var commands []Command
+
+func init() {
+	commands = []Command{
+		portsCommand,
+		cleanupCommand,
+		restoreCommand,
+		trustCommand,
+		versionCommand,
+		updateCommand,
+		configCommand,
+		benchCommand,
+		setupCommand,
+		doctorCommand,
+		statsCommand,
+		killCommand,
+		whyCommand,
+		forwardCommand,
+		specCommand,
+		completionCommand,
+		installExtrasCommand,
+		helpCommand,
+	}
+}
+
+// lookupCommand finds a command by name or alias, or returns nil
+func lookupCommand(name string) Command {
+	if name == "--help" || name == "-h" {
+		name = "help"
+	}
+	for _, cmd := range commands {
+		if cmd.Name() == name {
+			return cmd
+		}
+		for _, alias := range cmd.Spec().Aliases {
+			if alias == name {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
+
+// always is a ReadOnly func for commands that never write
+func always(args []string) bool { return true }
+
+// hasArg reports whether args contains name, e.g. "--dry-run"
+func hasArg(args []string, name string) bool {
+	for _, arg := range args {
+		if arg == name {
+			return true
+		}
+	}
+	return false
+}
+
+// argValues returns every value of a repeatable flag such as "--path", given as
+// --path=a or --path a, with comma-separated values split
+func argValues(args []string, name string) []string {
+	var values []string
+	for i, arg := range args {
+		var value string
+		switch {
+		case strings.HasPrefix(arg, name+"="):
+			value = strings.TrimPrefix(arg, name+"=")
+		case arg == name && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-"):
+			value = args[i+1]
+		default:
+			continue
+		}
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
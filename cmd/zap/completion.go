@@ -0,0 +1,496 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+)
+
+// Shells `zap completion` writes scripts for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// Values computed when completing, listed by `zap completion values <name>`
+const (
+	dynamicListeningPorts = "listening-ports" // common dev ports something listens on right now
+)
+
+// listeningPortsTimeout bounds the scan behind a completion; a slow answer is worse than none
+const listeningPortsTimeout = 2 * time.Second
+
+var completionCommand = &command{
+	spec: commandSpec{
+		Name: "completion", Description: "Print a shell completion script",
+		Args: []argSpec{{Name: "shell", Suggestions: completionShells}},
+	},
+	readOnly: always,
+	run:      func(ctx context.Context, inv *invocation) { handleCompletion(ctx, inv.cfg, inv.positional) },
+}
+
+// handleCompletion prints the completion script for a shell. The scripts carry the
+// commands, flags and static values of the spec, and call back `zap completion values
+// <name>` for values only known at completion time, such as the ports in use.
+func handleCompletion(ctx context.Context, cfg *config.Config, args []string) {
+	if len(args) == 2 && args[0] == "values" {
+		printDynamicValues(ctx, cfg, args[1])
+		return
+	}
+	if len(args) != 1 || !slices.Contains(completionShells, args[0]) {
+		log.Log(log.FAIL, "Usage: zap completion <%s>", strings.Join(completionShells, "|"))
+		os.Exit(1)
+	}
+
+	fmt.Print(completionScript(args[0]))
+}
+
+// completionScript returns the completion script for one of completionShells
+func completionScript(shell string) string {
+	data := gatherCompletions(buildSpec())
+	switch shell {
+	case "zsh":
+		return data.zsh()
+	case "fish":
+		return data.fish()
+	}
+	return data.bash()
+}
+
+// printDynamicValues prints the values of a Dynamic argument or flag, one per line;
+// errors print nothing, as a completion has nowhere to show them
+func printDynamicValues(ctx context.Context, cfg *config.Config, name string) {
+	switch name {
+	case dynamicListeningPorts:
+		ctx, cancel := context.WithTimeout(ctx, listeningPortsTimeout)
+		defer cancel()
+		processes, _ := ports.ScanPortsRangeWithProtocols(ctx, cfg.DefaultScanPorts(), []string{ports.ProtocolTCP}, 0)
+		var listening []int
+		for _, proc := range processes {
+			if !slices.Contains(listening, proc.Port) {
+				listening = append(listening, proc.Port)
+			}
+		}
+		sort.Ints(listening)
+		for _, port := range listening {
+			fmt.Println(port)
+		}
+	}
+}
+
+// completionValue is a word to offer, with what it means where the shell can show it
+type completionValue struct {
+	word        string
+	description string
+}
+
+// completionRule offers values for the next positional word when the positional words
+// typed so far, joined by spaces, match pattern (a shell glob); the first match wins
+type completionRule struct {
+	pattern string
+	values  []completionValue
+	dynamic string
+}
+
+// flagCompletion is the values of a flag
+type flagCompletion struct {
+	flag    flagSpec
+	values  []string
+	dynamic string
+}
+
+// commandFlags is the flags of a command, selected by its name or aliases
+type commandFlags struct {
+	names []string
+	flags []flagSpec
+}
+
+// completions is everything the scripts complete, gathered from the spec
+type completions struct {
+	commands   []commandFlags
+	rules      []completionRule
+	flagValues []flagCompletion
+	valueFlags []string // "--name" of flags that take the next word as their value
+}
+
+func gatherCompletions(spec cliSpec) completions {
+	defs := make(map[string]flagSpec)
+	var data completions
+	for _, flag := range spec.Flags {
+		defs[flag.Name] = flag
+		if flag.Value != "" {
+			data.valueFlags = append(data.valueFlags, "--"+flag.Name)
+		}
+		if len(flag.Suggestions) > 0 || flag.Dynamic != "" {
+			data.flagValues = append(data.flagValues, flagCompletion{flag: flag, values: flag.Suggestions, dynamic: flag.Dynamic})
+		}
+	}
+
+	root := completionRule{pattern: ""}
+	for _, cmd := range spec.Commands {
+		root.values = append(root.values, completionValue{cmd.Name, cmd.Description})
+
+		// A command completes the flags of its subcommands too (setup path --remove)
+		entry := commandFlags{names: commandNames(cmd)}
+		var collect func(cmd commandSpec)
+		collect = func(cmd commandSpec) {
+			for _, name := range cmd.Flags {
+				if def, ok := defs[name]; ok && !slices.ContainsFunc(entry.flags, func(f flagSpec) bool { return f.Name == name }) {
+					entry.flags = append(entry.flags, def)
+				}
+			}
+			for _, sub := range cmd.Subcommands {
+				collect(sub)
+			}
+		}
+		collect(cmd)
+		data.commands = append(data.commands, entry)
+
+		data.rules = append(data.rules, commandRules(commandNames(cmd), cmd)...)
+	}
+	data.rules = append(data.rules, root)
+
+	// Longer patterns first, so "config set *" (a value) isn't taken for "config set" (a key)
+	sort.SliceStable(data.rules, func(i, j int) bool {
+		return strings.Count(data.rules[i].pattern, " ") > strings.Count(data.rules[j].pattern, " ")
+	})
+	return data
+}
+
+func commandNames(cmd commandSpec) []string {
+	return append([]string{cmd.Name}, cmd.Aliases...)
+}
+
+// commandRules returns the rules for the words after a command reached through any of
+// prefixes: its subcommands, then its arguments
+func commandRules(prefixes []string, cmd commandSpec) []completionRule {
+	var rules []completionRule
+	if len(cmd.Subcommands) > 0 {
+		var values []completionValue
+		for _, sub := range cmd.Subcommands {
+			values = append(values, completionValue{sub.Name, sub.Description})
+		}
+		for _, prefix := range prefixes {
+			rules = append(rules, completionRule{pattern: prefix, values: values})
+		}
+		for _, sub := range cmd.Subcommands {
+			var subPrefixes []string
+			for _, prefix := range prefixes {
+				for _, name := range commandNames(sub) {
+					subPrefixes = append(subPrefixes, prefix+" "+name)
+				}
+			}
+			rules = append(rules, commandRules(subPrefixes, sub)...)
+		}
+	}
+
+	for i, arg := range cmd.Args {
+		var values []completionValue
+		for _, suggestion := range arg.Suggestions {
+			values = append(values, completionValue{word: suggestion})
+		}
+		for _, prefix := range prefixes {
+			pattern := prefix + strings.Repeat(" *", i)
+			// Rules without values still match, so that a later argument doesn't get
+			// the values of an earlier one
+			rules = append(rules, completionRule{pattern: pattern, values: values, dynamic: arg.Dynamic})
+			if arg.Variadic {
+				rules = append(rules, completionRule{pattern: pattern + " *", values: values, dynamic: arg.Dynamic})
+			}
+		}
+	}
+	return rules
+}
+
+// shellQuote quotes s for sh-like shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// casePattern turns a rule pattern into a bash/zsh case pattern: literal parts quoted,
+// * left as a wildcard
+func casePattern(pattern string) string {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		if part != "" || i == 0 && len(parts) == 1 {
+			parts[i] = `"` + part + `"`
+		}
+	}
+	return strings.Join(parts, "*")
+}
+
+// flagWords returns the words of a flag: --name, and -x if it has a short form
+func flagWords(flag flagSpec) []string {
+	words := []string{"--" + flag.Name}
+	if flag.Short != "" {
+		words = append(words, "-"+flag.Short)
+	}
+	return words
+}
+
+func dynamicCall(name string) string {
+	return "command zap completion values " + name + " 2>/dev/null"
+}
+
+func (c completions) bash() string {
+	var b strings.Builder
+	b.WriteString("# bash completion for zap, generated by `zap completion bash`\n")
+	b.WriteString("# Load it with: source <(zap completion bash)\n\n")
+	fmt.Fprintf(&b, "_zap_value_flags=\" %s \"\n\n", strings.Join(c.valueFlags, " "))
+
+	b.WriteString("_zap_flag_values() {\n    case \"$1\" in\n")
+	for _, fc := range c.flagValues {
+		words := strings.Join(fc.values, " ")
+		if fc.dynamic != "" {
+			words = strings.TrimSpace(words + " $(" + dynamicCall(fc.dynamic) + ")")
+		}
+		fmt.Fprintf(&b, "        --%s) echo \"%s\" ;;\n", fc.flag.Name, words)
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString(`_zap() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}" flag=""
+    # "=" is a word break: --ports=30 arrives as "--ports" "=" "30"
+    if [[ $cur == "=" ]]; then
+        flag=$prev cur=""
+    elif [[ $prev == "=" ]]; then
+        flag=${COMP_WORDS[COMP_CWORD-2]}
+    elif [[ $cur == --*=* ]]; then
+        flag=${cur%%=*} cur=${cur#*=}
+    elif [[ $_zap_value_flags == *" $prev "* ]]; then
+        flag=$prev
+    fi
+    if [[ -n $flag ]]; then
+        COMPREPLY=($(compgen -W "$(_zap_flag_values "$flag")" -- "$cur"))
+        return
+    fi
+
+    local -a positional=()
+    local i word before
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        word=${COMP_WORDS[i]} before=${COMP_WORDS[i-1]}
+        [[ $word == -* || $word == "=" || $before == "=" ]] && continue
+        [[ $_zap_value_flags == *" $before "* ]] && continue
+        positional+=("$word")
+    done
+
+    local words=""
+    if [[ $cur == -* ]]; then
+        case "${positional[0]}" in
+`)
+	for _, cmd := range c.commands {
+		var words []string
+		for _, flag := range cmd.flags {
+			words = append(words, flagWords(flag)...)
+		}
+		fmt.Fprintf(&b, "            %s) words=\"%s\" ;;\n", strings.Join(cmd.names, "|"), strings.Join(words, " "))
+	}
+	b.WriteString("            \"\") words=\"--help -h\" ;;\n        esac\n")
+	b.WriteString("        COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n        return\n    fi\n\n")
+
+	b.WriteString("    case \"${positional[*]}\" in\n")
+	for _, rule := range c.rules {
+		var words []string
+		for _, value := range rule.values {
+			words = append(words, value.word)
+		}
+		if rule.dynamic != "" {
+			words = append(words, "$("+dynamicCall(rule.dynamic)+")")
+		}
+		fmt.Fprintf(&b, "        %s) words=\"%s\" ;;\n", casePattern(rule.pattern), strings.Join(words, " "))
+	}
+	b.WriteString("    esac\n    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n}\n\ncomplete -F _zap zap\n")
+	return b.String()
+}
+
+// zshDescribed formats a value for _describe, which splits name and description at
+// the first unescaped colon
+func zshDescribed(word, description string) string {
+	word = strings.ReplaceAll(word, ":", `\:`)
+	if description == "" {
+		return shellQuote(word)
+	}
+	return shellQuote(word + ":" + description)
+}
+
+func (c completions) zsh() string {
+	var b strings.Builder
+	b.WriteString("#compdef zap\n")
+	b.WriteString("# zsh completion for zap, generated by `zap completion zsh`\n")
+	b.WriteString("# Load it with: source <(zap completion zsh), or save it as _zap in a directory of $fpath\n\n")
+	fmt.Fprintf(&b, "_zap_value_flags=(%s)\n\n", strings.Join(c.valueFlags, " "))
+
+	b.WriteString("_zap_flag_values() {\n    case \"$1\" in\n")
+	for _, fc := range c.flagValues {
+		words := strings.Join(fc.values, " ")
+		if fc.dynamic != "" {
+			words = strings.TrimSpace(words + " $(" + dynamicCall(fc.dynamic) + ")")
+		}
+		fmt.Fprintf(&b, "        --%s) echo \"%s\" ;;\n", fc.flag.Name, words)
+	}
+	b.WriteString("    esac\n}\n\n")
+
+	b.WriteString(`_zap() {
+    local cur=${words[CURRENT]} prev=${words[CURRENT-1]} flag=
+    if [[ $cur == --*=* ]]; then
+        flag=${cur%%=*}
+        compset -P '*='
+    elif (( ${_zap_value_flags[(Ie)$prev]} )); then
+        flag=$prev
+    fi
+    if [[ -n $flag ]]; then
+        local -a values
+        values=(${=$(_zap_flag_values $flag)})
+        compadd -a values
+        return
+    fi
+
+    local -a positional
+    local i
+    for (( i = 2; i < CURRENT; i++ )); do
+        [[ ${words[i]} == -* ]] && continue
+        (( ${_zap_value_flags[(Ie)${words[i-1]}]} )) && continue
+        positional+=(${words[i]})
+    done
+
+    local -a described values
+    if [[ $cur == -* ]]; then
+        case "${positional[1]}" in
+`)
+	for _, cmd := range c.commands {
+		var described []string
+		for _, flag := range cmd.flags {
+			for _, word := range flagWords(flag) {
+				described = append(described, zshDescribed(word, flag.Description))
+			}
+		}
+		fmt.Fprintf(&b, "            %s) described=(%s) ;;\n", strings.Join(cmd.names, "|"), strings.Join(described, " "))
+	}
+	b.WriteString("            \"\") described=('--help:Show the help message' '-h:Show the help message') ;;\n        esac\n")
+	b.WriteString("        _describe 'flag' described\n        return\n    fi\n\n")
+
+	b.WriteString("    case \"${positional[*]}\" in\n")
+	for _, rule := range c.rules {
+		var described []string
+		for _, value := range rule.values {
+			described = append(described, zshDescribed(value.word, value.description))
+		}
+		body := fmt.Sprintf("described=(%s)", strings.Join(described, " "))
+		if rule.dynamic != "" {
+			body += fmt.Sprintf("; values=(${(f)\"$(%s)\"})", dynamicCall(rule.dynamic))
+		}
+		fmt.Fprintf(&b, "        %s) %s ;;\n", casePattern(rule.pattern), body)
+	}
+	b.WriteString(`    esac
+    (( ${#described} )) && _describe 'value' described
+    (( ${#values} )) && compadd -a values
+    return 0
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    _zap "$@"
+else
+    compdef _zap zap
+fi
+`)
+	return b.String()
+}
+
+// fishQuote quotes s for fish, where only \ and ' are special inside single quotes
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+func (c completions) fish() string {
+	var b strings.Builder
+	b.WriteString("# fish completion for zap, generated by `zap completion fish`\n")
+	b.WriteString("# Load it with: zap completion fish | source, or save it as ~/.config/fish/completions/zap.fish\n\n")
+	fmt.Fprintf(&b, "set -g __zap_value_flags %s\n\n", strings.Join(c.valueFlags, " "))
+
+	b.WriteString(`# The positional words typed so far, joined by spaces (flags and their values left out)
+function __zap_positional
+    set -l tokens (commandline -opc)
+    set -e tokens[1]
+    set -l positional
+    set -l previous
+    for token in $tokens
+        if not string match -q -- '-*' $token; and not contains -- "$previous" $__zap_value_flags
+            set -a positional $token
+        end
+        set previous $token
+    end
+    string join ' ' -- $positional
+end
+
+# Whether no command was typed yet
+function __zap_no_command
+    set -l words (__zap_positional)
+    test -z "$words"
+end
+
+# Whether the command being completed is one of argv
+function __zap_using
+    set -l command (string split ' ' -- (__zap_positional))[1]
+    test -n "$command"; and contains -- $command $argv
+end
+
+function __zap_values
+    set -l words (__zap_positional)
+    switch "$words"
+`)
+	for _, rule := range c.rules {
+		fmt.Fprintf(&b, "        case %s\n", fishQuote(rule.pattern))
+		if len(rule.values) > 0 {
+			// Words are followed by a tab and their description, if they have one
+			described := slices.ContainsFunc(rule.values, func(v completionValue) bool { return v.description != "" })
+			var args []string
+			for _, value := range rule.values {
+				args = append(args, fishQuote(value.word))
+				if described {
+					args = append(args, fishQuote(value.description))
+				}
+			}
+			format := `'%s\n'`
+			if described {
+				format = `'%s\t%s\n'`
+			}
+			fmt.Fprintf(&b, "            printf %s %s\n", format, strings.Join(args, " "))
+		}
+		if rule.dynamic != "" {
+			fmt.Fprintf(&b, "            %s\n", dynamicCall(rule.dynamic))
+		}
+	}
+	b.WriteString("    end\nend\n\n")
+
+	b.WriteString("complete -c zap -f -a '(__zap_values)'\n")
+	b.WriteString("complete -c zap -n __zap_no_command -s h -l help -d 'Show the help message'\n")
+	for _, cmd := range c.commands {
+		condition := fishQuote("__zap_using " + strings.Join(cmd.names, " "))
+		for _, flag := range cmd.flags {
+			line := fmt.Sprintf("complete -c zap -n %s -l %s", condition, flag.Name)
+			if flag.Short != "" {
+				line += " -s " + flag.Short
+			}
+			if flag.Value != "" {
+				if flag.Suggestions != nil || flag.Dynamic != "" {
+					words := strings.Join(flag.Suggestions, " ")
+					if flag.Dynamic != "" {
+						words = strings.TrimSpace(words + " (" + dynamicCall(flag.Dynamic) + ")")
+					}
+					line += " -x -a " + fishQuote(words)
+				} else {
+					line += " -r"
+				}
+			}
+			line += " -d " + fishQuote(flag.Description)
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
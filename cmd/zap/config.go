@@ -1,18 +1,127 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/cleanup"
 	"github.com/hugoev/zap/internal/config"
 	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
 )
 
-func handleConfig(cfg *config.Config, args []string) {
-	if len(args) == 0 {
+// configKeys are the keys `zap config set` accepts
+var configKeys = []string{
+	"protected_ports", "max_age_days", "exclude_path", "auto_confirm", "deletion_timeout", "path_setup",
+	"report_webhook", "report_webhook_format", "celebrate_milestones", "protect_current_project", "scan_concurrency",
+	"allow_sudo", "signal_escalation", "watch_interval", "cleanup_patterns", "cleanup_rule",
+	"active_repo_days", "stall_timeout", "port_backend", "protected_processes",
+	"process_verification", "size_units", "scan_ports", "update_check",
+	"huge_cleanup_gb", "huge_cleanup_dirs", "dry_run_window", "cpu_spike_percent", "cpu_spike_minutes",
+}
+
+// setKeys maps config.json keys to the `zap config set` key when it differs; "" means
+// the key isn't set through `zap config set`
+var setKeys = map[string]string{
+	"max_age_days_for_cleanup":  "max_age_days",
+	"exclude_paths":             "exclude_path",
+	"auto_confirm_safe_actions": "auto_confirm",
+	"deletion_timeout_seconds":  "deletion_timeout",
+	"watch_interval_seconds":    "watch_interval",
+	"cleanup_rules":             "cleanup_rule",
+	"stall_timeout_seconds":     "stall_timeout",
+	"dry_run_window_minutes":    "dry_run_window",
+	"ignored_processes":         "",
+	"ignored_dirs":              "",
+}
+
+// configFileKeys are the config.json keys, which config get and unset take as well as
+// the config set keys
+func configFileKeys() []string {
+	defaults := config.Default()
+	var keys []string
+	for _, key := range config.Keys(&defaults) {
+		keys = append(keys, key.Key)
+	}
+	return keys
+}
+
+// fileKey returns the config.json key zap config set changes under name
+func fileKey(name string) string {
+	for key, setKey := range setKeys {
+		if setKey == name {
+			return key
+		}
+	}
+	return name
+}
+
+var configCommand = &command{
+	spec: commandSpec{
+		Name: "config", Description: "Manage configuration", Flags: withCommon("yes", "dry-run", "list"),
+		Subcommands: []commandSpec{
+			{Name: "show", Description: "Show the current configuration"},
+			{
+				Name: "set", Description: "Change a setting (--dry-run shows the change without saving)",
+				Args: []argSpec{{Name: "key", Suggestions: configKeys}, {Name: "value"}},
+			},
+			{
+				Name: "get", Description: "Print a setting's current value (no key: every key's current and default value)",
+				Args: []argSpec{{Name: "key", Optional: true, Suggestions: configFileKeys()}},
+			},
+			{
+				Name: "unset", Description: "Put a setting back to its default (--dry-run shows the change without saving)",
+				Args: []argSpec{{Name: "key", Suggestions: configFileKeys()}},
+			},
+			{Name: "edit", Description: "Edit config.json in $EDITOR, saving it only if it is valid (--dry-run shows the change without saving)"},
+			{Name: "reset", Description: "Restore the default configuration (--dry-run shows what would change)"},
+			{
+				Name: "restore", Description: "Bring back a previous configuration (--list shows the backups)",
+				Args: []argSpec{{Name: "number"}},
+			},
+			{Name: "suggest-protected", Description: "Offer to protect the ports of long-running databases, caches and Docker"},
+			{Name: "keys", Description: "List every config key with its type, default, current value and description"},
+			{
+				Name: "ignored", Description: "List or forget processes you told zap to ignore",
+				Subcommands: []commandSpec{
+					{Name: "list", Description: "List ignored processes"},
+					{Name: "remove", Description: "Stop ignoring a process", Args: []argSpec{{Name: "number", Suggestions: []string{"all"}}}},
+				},
+			},
+			{
+				Name: "ignored-dirs", Description: "List, add or forget directories zap cleanup no longer proposes",
+				Subcommands: []commandSpec{
+					{Name: "list", Description: "List ignored directories"},
+					{Name: "add", Description: "Stop proposing a directory", Args: []argSpec{{Name: "path"}, {Name: "reason", Optional: true, Variadic: true}}},
+					{Name: "remove", Description: "Propose a directory again", Args: []argSpec{{Name: "number", Suggestions: []string{"all"}}}},
+				},
+			},
+		},
+	},
+	readOnly: func(args []string) bool {
+		return len(args) == 0 || args[0] == "show" || args[0] == "keys" || args[0] == "get" ||
+			((args[0] == "set" || args[0] == "unset" || args[0] == "edit" || args[0] == "reset" || args[0] == "restore" || args[0] == "suggest-protected") && hasArg(args, "--dry-run")) ||
+			(args[0] == "restore" && hasArg(args, "--list"))
+	},
+	run: func(ctx context.Context, inv *invocation) {
+		handleConfig(ctx, inv)
+	},
+}
+
+func handleConfig(ctx context.Context, inv *invocation) {
+	cfg, positional, dryRun := inv.cfg, inv.positional, inv.dryRun
+
+	if len(positional) == 0 {
 		// Show current config
 		data, err := json.MarshalIndent(cfg, "", "  ")
 		if err != nil {
@@ -23,7 +132,7 @@ func handleConfig(cfg *config.Config, args []string) {
 		return
 	}
 
-	subcommand := args[0]
+	subcommand := positional[0]
 	switch subcommand {
 	case "show":
 		data, err := json.MarshalIndent(cfg, "", "  ")
@@ -34,89 +143,1045 @@ func handleConfig(cfg *config.Config, args []string) {
 		fmt.Println(string(data))
 
 	case "set":
-		if len(args) < 3 {
-			log.Log(log.FAIL, "Usage: zap config set <key> <value>")
-			log.Log(log.INFO, "Keys: protected_ports, max_age_days, exclude_path, auto_confirm")
-			os.Exit(1)
-		}
-		key := args[1]
-		value := args[2]
-
-		switch key {
-		case "protected_ports":
-			ports := strings.Split(value, ",")
-			var portList []int
-			for _, p := range ports {
-				port, err := strconv.Atoi(strings.TrimSpace(p))
-				if err != nil {
-					log.Log(log.FAIL, "Invalid port: %s", p)
-					os.Exit(1)
-				}
-				portList = append(portList, port)
-			}
-			cfg.ProtectedPorts = portList
-			if err := config.Save(cfg); err != nil {
-				log.Log(log.FAIL, "Failed to save config: %v", err)
-				os.Exit(1)
+		if len(positional) < 3 {
+			log.Log(log.FAIL, "Usage: zap config set <key> <value> [--dry-run]")
+			log.Log(log.INFO, "Keys: %s", strings.Join(configKeys, ", "))
+			os.Exit(1)
+		}
+		before := configJSON(cfg)
+		message := setConfigValue(cfg, positional[1], positional[2])
+		if dryRun {
+			if !printConfigDiff(before, configJSON(cfg)) {
+				log.Log(log.INFO, "no change (dry run)")
+				return
 			}
-			log.Log(log.OK, "Updated protected ports: %v", portList)
+			log.Log(log.INFO, "would update %s (dry run, config not saved)", positional[1])
+			return
+		}
+		if err := config.Save(ctx, cfg); err != nil {
+			log.Log(log.FAIL, "Failed to save config: %v", err)
+			os.Exit(1)
+		}
+		log.Log(log.OK, "%s", message)
+		if env := cfg.EnvOverride(fileKey(positional[1])); env != "" {
+			log.Log(log.WARN, "%s is set, so it overrides the saved value while it is", env)
+		}
 
-		case "max_age_days":
-			days, err := strconv.Atoi(value)
+	case "reset":
+		defaults := config.Default()
+		changed := diffKeys(cfg, &defaults)
+		if dryRun {
+			if !printConfigDiff(configJSON(cfg), configJSON(&defaults)) {
+				log.Log(log.INFO, "configuration is already the default (dry run)")
+				return
+			}
+			log.Log(log.INFO, "would reset %s (dry run, config not saved)", strings.Join(changed, ", "))
+			return
+		}
+		backupPath := ""
+		if len(changed) > 0 {
+			path, err := config.BackupBeforeReset(ctx)
 			if err != nil {
-				log.Log(log.FAIL, "Invalid number of days: %s", value)
+				log.Log(log.FAIL, "Not resetting, could not back up the current config: %v", err)
 				os.Exit(1)
 			}
-			if days < 1 || days > 365 {
-				log.Log(log.FAIL, "Days must be between 1 and 365")
+			backupPath = path
+		}
+		*cfg = defaults
+		if err := config.Save(ctx, cfg); err != nil {
+			log.Log(log.FAIL, "Failed to save config: %v", err)
+			os.Exit(1)
+		}
+		if len(changed) > 0 {
+			log.Log(log.OK, "Reset configuration to defaults (changed: %s)", strings.Join(changed, ", "))
+		} else {
+			log.Log(log.OK, "Reset configuration to defaults")
+		}
+		if backupPath != "" {
+			log.Log(log.INFO, "previous config saved to %s; undo with zap config restore", backupPath)
+		}
+
+	case "restore":
+		handleConfigRestore(ctx, inv, positional[1:])
+
+	case "suggest-protected":
+		handleSuggestProtected(ctx, inv)
+
+	case "ignored":
+		handleIgnored(ctx, cfg, positional[1:])
+
+	case "ignored-dirs":
+		handleIgnoredDirs(ctx, cfg, positional[1:])
+
+	case "keys":
+		handleConfigKeys(cfg, inv.jsonOutput)
+
+	case "get":
+		handleConfigGet(cfg, positional[1:], inv.jsonOutput)
+
+	case "unset":
+		handleConfigUnset(ctx, cfg, positional[1:], dryRun)
+
+	case "edit":
+		handleConfigEdit(ctx, cfg, dryRun)
+
+	default:
+		log.Log(log.FAIL, "Unknown config command: %s", subcommand)
+		log.Log(log.INFO, "Available commands: show, get, set, unset, edit, reset, restore, suggest-protected, ignored, ignored-dirs, keys")
+		os.Exit(1)
+	}
+}
+
+// setConfigValue applies `zap config set <key> <value>` to cfg without saving it and
+// returns the message to show once saved; invalid keys and values exit
+func setConfigValue(cfg *config.Config, key, value string) string {
+	switch key {
+	case "protected_ports":
+		ports := strings.Split(value, ",")
+		var portList []int
+		for _, p := range ports {
+			port, err := strconv.Atoi(strings.TrimSpace(p))
+			if err != nil {
+				log.Log(log.FAIL, "Invalid port: %s", p)
 				os.Exit(1)
 			}
-			cfg.MaxAgeDaysForCleanup = days
-			if err := config.Save(cfg); err != nil {
-				log.Log(log.FAIL, "Failed to save config: %v", err)
+			portList = append(portList, port)
+		}
+		cfg.ProtectedPorts = portList
+		return fmt.Sprintf("Updated protected ports: %v", portList)
+
+	case "protected_processes":
+		// add=a,b adds, remove=a,b removes, none clears, a,b replaces
+		patterns, err := editProtectedProcesses(cfg.ProtectedProcesses, value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid protected_processes: %v", err)
+			log.Log(log.INFO, "Usage: zap config set protected_processes add=<patterns>|remove=<patterns>|none|<patterns> (e.g. add='postgres*,*ssh*')")
+			os.Exit(1)
+		}
+		cfg.ProtectedProcesses = patterns
+		if len(patterns) == 0 {
+			return "Updated protected_processes: none"
+		}
+		return fmt.Sprintf("Updated protected_processes: %s", strings.Join(patterns, ", "))
+
+	case "max_age_days":
+		days, err := age.ParseDays(value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid max age: %v", err)
+			os.Exit(1)
+		}
+		if days < 1 || days > 365 {
+			log.Log(log.FAIL, "Max age must be between 1 and 365 days (1y)")
+			os.Exit(1)
+		}
+		cfg.MaxAgeDaysForCleanup = age.Days(days)
+		return fmt.Sprintf("Updated max age for cleanup: %d days", days)
+
+	case "exclude_path":
+		if err := cfg.AddExcludePath(value); err != nil {
+			log.Log(log.FAIL, "Failed to add exclude path: %v", err)
+			os.Exit(1)
+		}
+		return fmt.Sprintf("Added exclude path: %s", value)
+
+	case "cleanup_patterns":
+		// add=a,b adds, remove=a,b removes, default restores the defaults, a,b replaces
+		patterns, err := editCleanupPatterns(cfg.CleanupPatterns, value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid cleanup_patterns: %v", err)
+			log.Log(log.INFO, "Usage: zap config set cleanup_patterns add=<names>|remove=<names>|default|<names> (e.g. add=.terraform,Pods)")
+			os.Exit(1)
+		}
+		cfg.CleanupPatterns = patterns
+		return fmt.Sprintf("Updated cleanup_patterns: %s", strings.Join(patterns, ", "))
+
+	case "cleanup_rule":
+		// pattern=max_age_days:N,min_size_mb:N,enabled:BOOL or pattern=default
+		pattern, settings, ok := strings.Cut(value, "=")
+		if !ok {
+			log.Log(log.FAIL, "Usage: zap config set cleanup_rule <pattern>=<settings> (e.g. node_modules=max_age_days:30,min_size_mb:50, .venv=enabled:false, or node_modules=default)")
+			os.Exit(1)
+		}
+		rules, err := editCleanupRules(cfg.CleanupRules, pattern, settings)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid cleanup_rule: %v", err)
+			os.Exit(1)
+		}
+		cfg.CleanupRules = rules
+		if settings == "default" {
+			return fmt.Sprintf("Removed cleanup rule for %s", pattern)
+		}
+		return fmt.Sprintf("Updated cleanup rule for %s: %s", pattern, settings)
+
+	case "auto_confirm":
+		autoConfirm := value == "true" || value == "1" || value == "yes"
+		cfg.AutoConfirmSafeActions = autoConfirm
+		return fmt.Sprintf("Updated auto_confirm_safe_actions: %v", autoConfirm)
+
+	case "protect_current_project":
+		protect := value == "true" || value == "1" || value == "yes"
+		cfg.ProtectCurrentProject = &protect
+		return fmt.Sprintf("Updated protect_current_project: %v", protect)
+
+	case "celebrate_milestones":
+		celebrate := value == "true" || value == "1" || value == "yes"
+		cfg.CelebrateMilestones = celebrate
+		return fmt.Sprintf("Updated celebrate_milestones: %v", celebrate)
+
+	case "update_check":
+		check := value == "true" || value == "1" || value == "yes"
+		cfg.UpdateCheck = check
+		return fmt.Sprintf("Updated update_check: %v", check)
+
+	case "allow_sudo":
+		allow := value == "true" || value == "1" || value == "yes"
+		cfg.AllowSudo = allow
+		return fmt.Sprintf("Updated allow_sudo: %v", allow)
+
+	case "signal_escalation":
+		// class=STEP,STEP,... or class=default
+		class, steps, ok := strings.Cut(value, "=")
+		if !ok {
+			log.Log(log.FAIL, "Usage: zap config set signal_escalation <class>=<steps> (e.g. infrastructure=INT,TERM@5s,KILL@10s, or safe=default)")
+			os.Exit(1)
+		}
+		if steps == "default" {
+			delete(cfg.SignalEscalation, class)
+		} else {
+			stepList := strings.Split(steps, ",")
+			if err := config.ValidateEscalation(class, stepList); err != nil {
+				log.Log(log.FAIL, "Invalid signal_escalation: %v", err)
 				os.Exit(1)
 			}
-			log.Log(log.OK, "Updated max age for cleanup: %d days", days)
+			cfg.SignalEscalation[class] = stepList
+		}
+		return fmt.Sprintf("Updated signal_escalation for %s: %s", class, steps)
 
-		case "exclude_path":
-			if err := cfg.AddExcludePath(value); err != nil {
-				log.Log(log.FAIL, "Failed to add exclude path: %v", err)
-				os.Exit(1)
+	case "scan_concurrency":
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers < 0 || workers > config.MaxScanConcurrency {
+			log.Log(log.FAIL, "Invalid scan_concurrency: %s (must be 0 for auto, or 1-%d)", value, config.MaxScanConcurrency)
+			os.Exit(1)
+		}
+		cfg.ScanConcurrency = workers
+		return fmt.Sprintf("Updated scan_concurrency: %d", workers)
+
+	case "port_backend":
+		backend, err := ports.ParseBackend(value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid port_backend: %v", err)
+			os.Exit(1)
+		}
+		cfg.PortBackend = backend
+		return fmt.Sprintf("Updated port_backend: %s", backend)
+
+	case "scan_ports":
+		if value == "default" {
+			cfg.ScanPorts = ""
+			return "Updated scan_ports: default (common development ports)"
+		}
+		scanPorts, err := ports.ParsePortRange(value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid scan_ports: %v (use e.g. 3000-3999,8000-8999, or default)", err)
+			os.Exit(1)
+		}
+		cfg.ScanPorts = strings.ReplaceAll(value, " ", "")
+		return fmt.Sprintf("Updated scan_ports: %s (%d ports)", cfg.ScanPorts, len(scanPorts))
+
+	case "process_verification":
+		strictness, err := ports.ParseStrictness(value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid process_verification: %v", err)
+			os.Exit(1)
+		}
+		cfg.ProcessVerification = strictness
+		return fmt.Sprintf("Updated process_verification: %s", strictness)
+
+	case "size_units":
+		units, err := cleanup.ParseUnits(value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid size_units: %v", err)
+			os.Exit(1)
+		}
+		cfg.SizeUnits = units
+		return fmt.Sprintf("Updated size_units: %s", units)
+
+	case "path_setup":
+		switch value {
+		case config.PathSetupNever, config.PathSetupPrompt, config.PathSetupAuto:
+		default:
+			log.Log(log.FAIL, "Invalid path_setup: %s (must be never, prompt or auto)", value)
+			os.Exit(1)
+		}
+		cfg.PathSetup = value
+		return fmt.Sprintf("Updated path_setup: %s", value)
+
+	case "deletion_timeout":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 1 {
+			log.Log(log.FAIL, "Invalid deletion timeout (seconds): %s", value)
+			os.Exit(1)
+		}
+		cfg.DeletionTimeoutSeconds = seconds
+		return fmt.Sprintf("Updated deletion timeout: %d seconds", seconds)
+
+	case "watch_interval":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 1 {
+			log.Log(log.FAIL, "Invalid watch interval (seconds): %s", value)
+			os.Exit(1)
+		}
+		cfg.WatchIntervalSeconds = seconds
+		return fmt.Sprintf("Updated watch interval: %d seconds", seconds)
+
+	case "cpu_spike_percent":
+		percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percent < 1 || percent > 10000 {
+			log.Log(log.FAIL, "Invalid CPU spike threshold (percent of one core): %s (must be 1-10000)", value)
+			os.Exit(1)
+		}
+		cfg.CPUSpikePercent = percent
+		return fmt.Sprintf("Updated CPU spike threshold: %d%% of a core", percent)
+
+	case "cpu_spike_minutes":
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 0 || minutes > 24*60 {
+			log.Log(log.FAIL, "Invalid CPU spike duration (minutes): %s (must be 0-%d)", value, 24*60)
+			os.Exit(1)
+		}
+		cfg.CPUSpikeMinutes = &minutes
+		if minutes == 0 {
+			return "Updated CPU spike duration: CPU usage isn't watched"
+		}
+		return fmt.Sprintf("Updated CPU spike duration: %d minutes", minutes)
+
+	case "active_repo_days":
+		days, err := age.ParseDays(value)
+		if err != nil || days < 0 || days > 365 {
+			log.Log(log.FAIL, "Invalid number of days: %s (must be 0-365)", value)
+			os.Exit(1)
+		}
+		cfg.ActiveRepoDays = &days
+		if days == 0 {
+			return "Updated active repository window: only repositories with uncommitted changes are skipped"
+		}
+		return fmt.Sprintf("Updated active repository window: %d days", days)
+
+	case "huge_cleanup_gb", "huge_cleanup_dirs":
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			log.Log(log.FAIL, "Invalid %s: %s (must be a number, 0 for no limit)", key, value)
+			os.Exit(1)
+		}
+		if key == "huge_cleanup_gb" {
+			cfg.HugeCleanupGB = &limit
+		} else {
+			cfg.HugeCleanupDirs = &limit
+		}
+		if limit == 0 {
+			return fmt.Sprintf("Updated %s: no limit", key)
+		}
+		return fmt.Sprintf("Updated %s: %d", key, limit)
+
+	case "dry_run_window":
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 1 || minutes > 24*60 {
+			log.Log(log.FAIL, "Invalid dry run window (minutes): %s (must be 1-%d)", value, 24*60)
+			os.Exit(1)
+		}
+		cfg.DryRunWindowMinutes = minutes
+		return fmt.Sprintf("Updated dry run window: %d minutes", minutes)
+
+	case "stall_timeout":
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || seconds > 3600 {
+			log.Log(log.FAIL, "Invalid stall timeout (seconds): %s (must be 0-3600)", value)
+			os.Exit(1)
+		}
+		cfg.StallTimeoutSeconds = &seconds
+		if seconds == 0 {
+			return "Updated stall timeout: stall detection off"
+		}
+		return fmt.Sprintf("Updated stall timeout: %d seconds", seconds)
+
+	case "report_webhook":
+		// "none" clears the webhook
+		if value == "none" {
+			value = ""
+		} else if err := config.ValidateWebhookURL(value); err != nil {
+			log.Log(log.FAIL, "%v", err)
+			os.Exit(1)
+		}
+		cfg.ReportWebhook = value
+		if value == "" {
+			return "Removed report webhook"
+		}
+		return fmt.Sprintf("Updated report webhook: %s", value)
+
+	case "report_webhook_format":
+		switch value {
+		case config.ReportFormatJSON, config.ReportFormatSlack:
+		default:
+			log.Log(log.FAIL, "Invalid report_webhook_format: %s (must be json or slack)", value)
+			os.Exit(1)
+		}
+		cfg.ReportWebhookFormat = value
+		return fmt.Sprintf("Updated report_webhook_format: %s", value)
+
+	default:
+		log.Log(log.FAIL, "Unknown config key: %s", key)
+		log.Log(log.INFO, "Available keys: %s", strings.Join(configKeys, ", "))
+		os.Exit(1)
+	}
+
+	return ""
+}
+
+// configJSON is cfg as `zap config show` prints it
+func configJSON(cfg *config.Config) []byte {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		log.Log(log.FAIL, "Failed to serialize config: %v", err)
+		os.Exit(1)
+	}
+	return data
+}
+
+// diffKeys lists the config keys whose values differ between from and to
+func diffKeys(from, to *config.Config) []string {
+	toKeys := config.Keys(to)
+	var changed []string
+	for i, key := range config.Keys(from) {
+		if string(key.Current) != string(toKeys[i].Current) {
+			changed = append(changed, key.Key)
+		}
+	}
+	return changed
+}
+
+// printConfigDiff prints how the config JSON changes from before to after, diff style:
+// removed lines prefixed with "-", added ones with "+", and two unchanged lines around
+// each change. It reports whether anything changed.
+func printConfigDiff(before, after []byte) bool {
+	oldLines := strings.Split(string(before), "\n")
+	newLines := strings.Split(string(after), "\n")
+
+	// common[i][j] is the length of the longest common subsequence of oldLines[i:] and newLines[j:]
+	common := make([][]int, len(oldLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
 			}
-			log.Log(log.OK, "Added exclude path: %s", value)
+		}
+	}
 
-		case "auto_confirm":
-			autoConfirm := value == "true" || value == "1" || value == "yes"
-			cfg.AutoConfirmSafeActions = autoConfirm
-			if err := config.Save(cfg); err != nil {
-				log.Log(log.FAIL, "Failed to save config: %v", err)
-				os.Exit(1)
+	var diff []string
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && oldLines[i] == newLines[j]:
+			diff = append(diff, "  "+oldLines[i])
+			i++
+			j++
+		case i < len(oldLines) && (j == len(newLines) || common[i+1][j] >= common[i][j+1]):
+			diff = append(diff, "- "+oldLines[i])
+			i++
+		default:
+			diff = append(diff, "+ "+newLines[j])
+			j++
+		}
+	}
+
+	const contextLines = 2
+	changed := false
+	lastPrinted := -1
+	for k, line := range diff {
+		if strings.HasPrefix(line, "  ") {
+			continue
+		}
+		changed = true
+		from := max(k-contextLines, lastPrinted+1)
+		if lastPrinted >= 0 && from > lastPrinted+1 {
+			fmt.Println("  ...")
+		}
+		to := min(k+contextLines, len(diff)-1)
+		for n := from; n <= to; n++ {
+			fmt.Println(diff[n])
+		}
+		lastPrinted = to
+	}
+	return changed
+}
+
+// handleConfigRestore lists the config backups (--list) or brings one back: the newest,
+// or the given number of the list. The config it replaces goes into the backup rotation,
+// so a restore can be undone the same way.
+func handleConfigRestore(ctx context.Context, inv *invocation, args []string) {
+	backups, err := config.Backups()
+	if err != nil {
+		log.Log(log.FAIL, "Failed to list config backups: %v", err)
+		os.Exit(1)
+	}
+
+	if inv.flags["list"] {
+		if inv.jsonOutput {
+			if backups == nil {
+				backups = []config.Backup{}
 			}
-			log.Log(log.OK, "Updated auto_confirm_safe_actions: %v", autoConfirm)
+			data, _ := json.MarshalIndent(backups, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+		if len(backups) == 0 {
+			log.Log(log.OK, "no config backups that differ from the current config")
+			return
+		}
+		for i, backup := range backups {
+			detail := "previous save"
+			if backup.Kind == config.BackupReset {
+				detail = "before reset"
+			}
+			if restored, err := config.ReadBackup(backup); err != nil {
+				detail += ", unusable: " + err.Error()
+			} else if changed := diffKeys(inv.cfg, restored); len(changed) > 0 {
+				detail += ", differs in " + strings.Join(changed, ", ")
+			}
+			log.Log(log.INFO, "%d. %s (%s)", i+1, backup.Time.Format("2006-01-02 15:04:05"), detail)
+		}
+		return
+	}
 
+	if len(backups) == 0 {
+		log.Log(log.FAIL, "No config backups that differ from the current config")
+		os.Exit(1)
+	}
+	number := 1
+	if len(args) > 0 {
+		number, err = strconv.Atoi(args[0])
+		if err != nil || number < 1 || number > len(backups) {
+			log.Log(log.FAIL, "Invalid backup number: %s (see zap config restore --list)", args[0])
+			os.Exit(1)
+		}
+	}
+	backup := backups[number-1]
+	restored, err := config.ReadBackup(backup)
+	if err != nil {
+		log.Log(log.FAIL, "Cannot restore: %v", err)
+		os.Exit(1)
+	}
+
+	when := backup.Time.Format("2006-01-02 15:04:05")
+	if inv.dryRun {
+		printConfigDiff(configJSON(inv.cfg), configJSON(restored))
+		log.Log(log.INFO, "would restore the config of %s (dry run, config not saved)", when)
+		return
+	}
+	changed := diffKeys(inv.cfg, restored)
+	if err := config.Save(ctx, restored); err != nil {
+		log.Log(log.FAIL, "Failed to save config: %v", err)
+		os.Exit(1)
+	}
+	*inv.cfg = *restored
+	log.Log(log.OK, "Restored the config of %s (changed: %s)", when, strings.Join(changed, ", "))
+}
+
+// handleConfigKeys documents every config key: type, default, current value and what it does
+func handleConfigKeys(cfg *config.Config, jsonOutput bool) {
+	keys := config.Keys(cfg)
+	if jsonOutput {
+		data, _ := json.MarshalIndent(keys, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	for i, key := range keys {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s)\n", key.Key, key.Type)
+		fmt.Printf("  %s\n", key.Description)
+		switch {
+		case key.EnvSet != "":
+			fmt.Printf("  default: %s, current: %s (from %s)\n", key.Default, key.Current, key.EnvSet)
+		case string(key.Current) == string(key.Default):
+			fmt.Printf("  default: %s\n", key.Default)
 		default:
-			log.Log(log.FAIL, "Unknown config key: %s", key)
-			log.Log(log.INFO, "Available keys: protected_ports, max_age_days, exclude_path, auto_confirm")
+			fmt.Printf("  default: %s, current: %s\n", key.Default, key.Current)
+		}
+		fmt.Printf("  env:     %s\n", strings.Join(key.Env, ", "))
+		setKey, renamed := setKeys[key.Key]
+		switch {
+		case !renamed:
+			fmt.Printf("  set:     zap config set %s <value>\n", key.Key)
+		case setKey != "":
+			fmt.Printf("  set:     zap config set %s <value>\n", setKey)
+		}
+	}
+}
+
+// lookupKey finds a config key by its config.json or zap config set name; unknown keys exit
+func lookupKey(cfg *config.Config, name string) config.KeyInfo {
+	key := fileKey(name)
+	for _, info := range config.Keys(cfg) {
+		if info.Key == key {
+			return info
+		}
+	}
+	log.Log(log.FAIL, "Unknown config key: %s", name)
+	log.Log(log.INFO, "Keys: %s (see zap config keys)", strings.Join(configFileKeys(), ", "))
+	os.Exit(1)
+	return config.KeyInfo{}
+}
+
+// handleConfigGet prints a key's value for scripts: strings as they are, anything else
+// as JSON. Without a key it lists every key with its current and default value.
+func handleConfigGet(cfg *config.Config, args []string, jsonOutput bool) {
+	if len(args) == 0 {
+		keys := config.Keys(cfg)
+		if jsonOutput {
+			data, _ := json.MarshalIndent(keys, "", "  ")
+			fmt.Println(string(data))
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tTYPE\tCURRENT\tDEFAULT")
+		for _, key := range keys {
+			current := compactJSON(key.Current)
+			switch {
+			case key.EnvSet != "":
+				current += " (from " + key.EnvSet + ")"
+			case string(key.Current) == string(key.Default):
+				current += " (default)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", key.Key, key.Type, current, compactJSON(key.Default))
+		}
+		w.Flush()
+		return
+	}
+
+	key := lookupKey(cfg, args[0])
+	if jsonOutput {
+		data, _ := json.MarshalIndent(key, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	var text string
+	if json.Unmarshal(key.Current, &text) != nil {
+		text = string(key.Current)
+	}
+	fmt.Println(text)
+	if key.EnvSet != "" {
+		log.VerboseLog("%s is set by %s", key.Key, key.EnvSet)
+	}
+}
+
+// handleConfigUnset puts a key back to its default and saves the config
+func handleConfigUnset(ctx context.Context, cfg *config.Config, args []string, dryRun bool) {
+	if len(args) == 0 {
+		log.Log(log.FAIL, "Usage: zap config unset <key> [--dry-run]")
+		os.Exit(1)
+	}
+	key := lookupKey(cfg, args[0])
+	before := configJSON(cfg)
+	if err := cfg.Unset(key.Key); err != nil {
+		log.Log(log.FAIL, "Cannot unset %s: %v", args[0], err)
+		os.Exit(1)
+	}
+	if dryRun {
+		if !printConfigDiff(before, configJSON(cfg)) {
+			log.Log(log.INFO, "%s is already the default (dry run)", key.Key)
+			return
+		}
+		log.Log(log.INFO, "would put %s back to %s (dry run, config not saved)", key.Key, key.Default)
+		return
+	}
+	if string(key.Current) == string(key.Default) && key.EnvSet == "" {
+		log.Log(log.OK, "%s is already the default (%s)", key.Key, key.Default)
+		return
+	}
+	if err := config.Save(ctx, cfg); err != nil {
+		log.Log(log.FAIL, "Failed to save config: %v", err)
+		os.Exit(1)
+	}
+	log.Log(log.OK, "%s is back to its default: %s", key.Key, key.Default)
+	if key.EnvSet != "" {
+		log.Log(log.WARN, "%s is set, so it overrides the saved value while it is", key.EnvSet)
+	}
+}
+
+// handleConfigEdit opens config.json in the user's editor and saves the result once it
+// is valid; an invalid edit can be corrected or is left in a temporary file, and
+// config.json stays as it was
+func handleConfigEdit(ctx context.Context, cfg *config.Config, dryRun bool) {
+	configPath, err := config.Path()
+	if err != nil {
+		log.Log(log.FAIL, "Cannot locate config.json: %v", err)
+		os.Exit(1)
+	}
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		// Load creates config.json; it's only missing with a read-only zap directory
+		original = configJSON(cfg)
+	}
+
+	tempFile, err := os.CreateTemp("", "zap-config-*.json")
+	if err != nil {
+		log.Log(log.FAIL, "Cannot create a file to edit: %v", err)
+		os.Exit(1)
+	}
+	tempPath := tempFile.Name()
+	tempFile.Close()
+
+	text := original
+	var edited *config.Config
+	for {
+		if err := os.WriteFile(tempPath, text, 0o600); err != nil {
+			log.Log(log.FAIL, "Cannot write %s: %v", tempPath, err)
+			os.Exit(1)
+		}
+		if err := runEditor(tempPath); err != nil {
+			log.Log(log.FAIL, "Editor failed: %v", err)
+			log.Log(log.INFO, "config.json not changed; your edit is kept in %s", tempPath)
+			os.Exit(1)
+		}
+		if text, err = os.ReadFile(tempPath); err != nil {
+			log.Log(log.FAIL, "Cannot read %s: %v", tempPath, err)
+			os.Exit(1)
+		}
+		if edited, err = config.Parse(text); err == nil {
+			break
+		}
+		log.Log(log.FAIL, "Invalid config: %v", err)
+		log.Log(log.ACTION, "Edit again? (y/N): ")
+		if !confirm() {
+			log.Log(log.INFO, "config.json not changed; your edit is kept in %s", tempPath)
 			os.Exit(1)
 		}
+	}
+	os.Remove(tempPath)
 
-	case "reset":
-		*cfg = config.Config{
-			ProtectedPorts:         []int{5432, 6379, 3306, 27017},
-			MaxAgeDaysForCleanup:   14,
-			ExcludePaths:           []string{},
-			AutoConfirmSafeActions: false,
+	if !printConfigDiff(original, text) {
+		log.Log(log.INFO, "no change")
+		return
+	}
+	previous, err := config.Parse(original)
+	if err != nil {
+		previous = cfg
+	}
+	changed := diffKeys(previous, edited)
+	if dryRun {
+		log.Log(log.INFO, "would update %s (dry run, config not saved)", strings.Join(changed, ", "))
+		return
+	}
+	if err := config.Save(ctx, edited); err != nil {
+		log.Log(log.FAIL, "Failed to save config: %v", err)
+		os.Exit(1)
+	}
+	log.Log(log.OK, "Saved %s (changed: %s)", configPath, strings.Join(changed, ", "))
+	for _, key := range changed {
+		if env := cfg.EnvOverride(key); env != "" {
+			log.Log(log.WARN, "%s is set, so it overrides the saved value while it is", env)
 		}
-		if err := config.Save(cfg); err != nil {
+	}
+}
+
+// runEditor edits path with $VISUAL or $EDITOR, which may carry arguments
+// ("code --wait"), falling back to vi, or Notepad on Windows
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// handleIgnored lists or forgets processes that `zap ports` was told to ignore
+func handleIgnored(ctx context.Context, cfg *config.Config, args []string) {
+	if len(args) == 0 || args[0] == "list" {
+		if len(cfg.IgnoredProcesses) == 0 {
+			log.Log(log.OK, "no ignored processes")
+			return
+		}
+		for i, ignored := range cfg.IgnoredProcesses {
+			log.Log(log.INFO, "%d. %s - %s [%s] (since %s)", i+1, ignored.Name, truncateString(ignored.Cmd, 60), ignored.WorkingDir, ignored.Since.Format("2006-01-02"))
+		}
+		return
+	}
+
+	if args[0] != "remove" || len(args) < 2 {
+		log.Log(log.FAIL, "Usage: zap config ignored [list | remove <number|all>]")
+		os.Exit(1)
+	}
+
+	if args[1] == "all" {
+		cfg.IgnoredProcesses = []config.IgnoredProcess{}
+		if err := config.Save(ctx, cfg); err != nil {
 			log.Log(log.FAIL, "Failed to save config: %v", err)
 			os.Exit(1)
 		}
-		log.Log(log.OK, "Reset configuration to defaults")
+		log.Log(log.OK, "Removed all ignored processes")
+		return
+	}
+
+	number, err := strconv.Atoi(args[1])
+	if err != nil {
+		log.Log(log.FAIL, "Invalid number: %s (see zap config ignored list)", args[1])
+		os.Exit(1)
+	}
+	removed, err := cfg.RemoveIgnoredProcess(ctx, number-1)
+	if err != nil {
+		log.Log(log.FAIL, "Failed to remove ignored process: %v", err)
+		os.Exit(1)
+	}
+	log.Log(log.OK, "zap will ask about %s again", truncateString(removed.Cmd, 60))
+}
 
+// handleIgnoredDirs lists, adds or forgets directories that `zap cleanup` was told to ignore
+func handleIgnoredDirs(ctx context.Context, cfg *config.Config, args []string) {
+	if len(args) == 0 || args[0] == "list" {
+		if len(cfg.IgnoredDirs) == 0 {
+			log.Log(log.OK, "no ignored directories")
+			return
+		}
+		for i, ignored := range cfg.IgnoredDirs {
+			reason := ""
+			if ignored.Reason != "" {
+				reason = " - " + ignored.Reason
+			}
+			log.Log(log.INFO, "%d. %s%s (since %s)", i+1, ignored.Path, reason, ignored.Since.Format("2006-01-02"))
+		}
+		return
+	}
+
+	switch {
+	case args[0] == "add" && len(args) >= 2:
+		path, err := filepath.Abs(args[1])
+		if err != nil {
+			log.Log(log.FAIL, "Invalid path %s: %v", args[1], err)
+			os.Exit(1)
+		}
+		if err := cfg.IgnoreDirs(ctx, []string{path}, strings.Join(args[2:], " ")); err != nil {
+			log.Log(log.FAIL, "Failed to save ignored directory: %v", err)
+			os.Exit(1)
+		}
+		log.Log(log.OK, "zap cleanup will no longer propose %s", path)
+		return
+	case args[0] == "remove" && len(args) >= 2:
 	default:
-		log.Log(log.FAIL, "Unknown config command: %s", subcommand)
-		log.Log(log.INFO, "Available commands: show, set, reset")
+		log.Log(log.FAIL, "Usage: zap config ignored-dirs [list | add <path> [reason] | remove <number|all>]")
+		os.Exit(1)
+	}
+
+	if args[1] == "all" {
+		cfg.IgnoredDirs = []config.IgnoredDir{}
+		if err := config.Save(ctx, cfg); err != nil {
+			log.Log(log.FAIL, "Failed to save config: %v", err)
+			os.Exit(1)
+		}
+		log.Log(log.OK, "Removed all ignored directories")
+		return
+	}
+
+	number, err := strconv.Atoi(args[1])
+	if err != nil {
+		log.Log(log.FAIL, "Invalid number: %s (see zap config ignored-dirs list)", args[1])
+		os.Exit(1)
+	}
+	removed, err := cfg.RemoveIgnoredDir(ctx, number-1)
+	if err != nil {
+		log.Log(log.FAIL, "Failed to remove ignored directory: %v", err)
 		os.Exit(1)
 	}
+	log.Log(log.OK, "zap cleanup will propose %s again", removed.Path)
+}
+
+// editCleanupPatterns applies a `zap config set cleanup_patterns` value to patterns:
+// "add=a,b", "remove=a,b", "default", or "a,b" to replace the list
+func editCleanupPatterns(patterns []string, value string) ([]string, error) {
+	if value == "default" {
+		return cleanup.DefaultPatterns(), nil
+	}
+	op, list, hasOp := strings.Cut(value, "=")
+	if !hasOp {
+		op, list = "", value
+	}
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := cleanup.ValidatePattern(name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no patterns given")
+	}
+
+	switch op {
+	case "":
+		return names, nil
+	case "add":
+		result := append([]string(nil), patterns...)
+		for _, name := range names {
+			if !slices.Contains(result, name) {
+				result = append(result, name)
+			}
+		}
+		return result, nil
+	case "remove":
+		for _, name := range names {
+			if !slices.Contains(patterns, name) {
+				return nil, fmt.Errorf("%s is not a cleanup pattern", name)
+			}
+		}
+		var result []string
+		for _, pattern := range patterns {
+			if !slices.Contains(names, pattern) {
+				result = append(result, pattern)
+			}
+		}
+		if len(result) == 0 {
+			return nil, fmt.Errorf("removing %s would leave no cleanup patterns", strings.Join(names, ", "))
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q (must be add or remove)", op)
+	}
+}
+
+// editProtectedProcesses applies a `zap config set protected_processes` value to
+// patterns: "add=a,b", "remove=a,b", "none", or a list that replaces them
+func editProtectedProcesses(patterns []string, value string) ([]string, error) {
+	if value == "none" {
+		return []string{}, nil
+	}
+	op, list, hasOp := strings.Cut(value, "=")
+	if !hasOp || (op != "add" && op != "remove") {
+		op, list = "", value
+	}
+	var given []string
+	for _, pattern := range strings.Split(list, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if err := config.ValidateProcessPattern(pattern); err != nil {
+			return nil, err
+		}
+		given = append(given, pattern)
+	}
+	if len(given) == 0 {
+		return nil, fmt.Errorf("no patterns given")
+	}
+
+	switch op {
+	case "add":
+		result := append([]string{}, patterns...)
+		for _, pattern := range given {
+			if !slices.Contains(result, pattern) {
+				result = append(result, pattern)
+			}
+		}
+		return result, nil
+	case "remove":
+		result := []string{}
+		for _, pattern := range given {
+			if !slices.Contains(patterns, pattern) {
+				return nil, fmt.Errorf("%s is not in protected_processes", pattern)
+			}
+		}
+		for _, pattern := range patterns {
+			if !slices.Contains(given, pattern) {
+				result = append(result, pattern)
+			}
+		}
+		return result, nil
+	default:
+		return given, nil
+	}
 }
 
+// editCleanupRules applies a `zap config set cleanup_rule` value to rules: settings
+// ("max_age_days:30,min_size_mb:50,enabled:false") are merged into pattern's rule, which
+// is created if needed; "default" removes it
+func editCleanupRules(rules []config.CleanupRule, pattern, settings string) ([]config.CleanupRule, error) {
+	index := -1
+	for i, rule := range rules {
+		if rule.Pattern == pattern {
+			index = i
+			break
+		}
+	}
+	if settings == "default" {
+		if index < 0 {
+			return nil, fmt.Errorf("no cleanup rule for %s", pattern)
+		}
+		return append(append([]config.CleanupRule{}, rules[:index]...), rules[index+1:]...), nil
+	}
+
+	rule := config.CleanupRule{Pattern: pattern}
+	if index >= 0 {
+		rule = rules[index]
+	}
+	for _, setting := range strings.Split(settings, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(setting), ":")
+		if !ok {
+			return nil, fmt.Errorf("setting %q must be name:value", setting)
+		}
+		switch name {
+		case "max_age_days":
+			days, err := age.ParseDays(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid max_age_days: %w", err)
+			}
+			rule.MaxAgeDays = age.Days(days)
+		case "min_size_mb":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid min_size_mb: %s", value)
+			}
+			rule.MinSizeMB = size
+		case "enabled":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid enabled: %s (must be true or false)", value)
+			}
+			rule.Enabled = &enabled
+			if enabled {
+				// Enabled is the default, so it isn't written out
+				rule.Enabled = nil
+			}
+		default:
+			return nil, fmt.Errorf("unknown setting %s (must be max_age_days, min_size_mb or enabled)", name)
+		}
+	}
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+
+	result := append([]config.CleanupRule{}, rules...)
+	if index >= 0 {
+		result[index] = rule
+	} else {
+		result = append(result, rule)
+	}
+	return result, nil
+}
@@ -0,0 +1,542 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/semver"
+	"github.com/hugoev/zap/internal/version"
+	"golang.org/x/sys/unix"
+)
+
+// zapBinary is one zap executable found on the system
+type zapBinary struct {
+	Path     string `json:"path"`     // as found on PATH
+	Resolved string `json:"resolved"` // after following symlinks
+	Version  string `json:"version"`  // "" if it could not be determined
+	Active   bool   `json:"active"`   // first zap on PATH (what the shell runs)
+	Running  bool   `json:"running"`  // the binary currently executing
+}
+
+// Outcomes of a doctor check
+const (
+	checkOK   = "ok"
+	checkWarn = "warn" // works, but something may bite later
+	checkFail = "fail" // something zap needs is broken
+)
+
+// doctorCheck is one finding of `zap doctor`, with what to do about it unless it is ok
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// doctorReport is the --json output of `zap doctor`
+type doctorReport struct {
+	Healthy  bool          `json:"healthy"` // no check failed
+	Checks   []doctorCheck `json:"checks"`
+	Binaries []zapBinary   `json:"binaries"`
+}
+
+// lowDiskSpace is the free space below which doctor warns that zap's files (config,
+// journal, history, trash) may fail to save
+const lowDiskSpace = 100 << 20
+
+var doctorCommand = &command{
+	spec:     commandSpec{Name: "doctor", Description: "Diagnose the installation and environment", Flags: withCommon("fix", "remove", "yes")},
+	readOnly: func(args []string) bool { return !hasArg(args, "--fix") },
+	run: func(ctx context.Context, inv *invocation) {
+		handleDoctor(ctx, inv)
+	},
+}
+
+// handleDoctor checks zap's installation and environment: binaries on PATH, the tools
+// port scans rely on, the config file, the instance lock, permissions and disk space.
+// Every problem comes with a fix; --fix repairs stale binaries. It exits with 1 when a
+// check fails.
+func handleDoctor(ctx context.Context, inv *invocation) {
+	cfg, flags := inv.cfg, inv.flags
+	if !inv.jsonOutput {
+		log.Log(log.SCAN, "checking zap binaries on PATH")
+	}
+
+	// Other zap binaries take the instance lock when asked for their version
+	inv.lock.Release()
+	binaries := findZapBinaries()
+	if err := inv.lock.Reacquire(); err != nil {
+		log.Log(log.FAIL, "failed to re-acquire lock: %v", err)
+		os.Exit(1)
+	}
+	newest, stale := findStaleBinaries(binaries)
+
+	report := doctorReport{Binaries: binaries, Healthy: true}
+	if report.Binaries == nil {
+		report.Binaries = []zapBinary{}
+	}
+	report.Checks = append(report.Checks, checkBinaries(binaries, newest, stale), checkPath())
+	report.Checks = append(report.Checks, checkTools()...)
+	report.Checks = append(report.Checks,
+		checkPortBackend(cfg),
+		checkConfig(),
+		checkLock(),
+		checkPermissions(ctx, cfg),
+		checkDiskSpace(),
+	)
+	report.Healthy = !anyFailed(report.Checks, "")
+
+	if inv.jsonOutput {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printBinaries(binaries)
+		printChecks(report)
+	}
+
+	if len(stale) > 0 && flags["fix"] && fixStaleBinaries(newest, stale, inv.yes, flags["remove"]) {
+		report.Healthy = !anyFailed(report.Checks, "binaries")
+	}
+	if !report.Healthy {
+		os.Exit(1)
+	}
+}
+
+// anyFailed reports whether a check other than the one named except failed
+func anyFailed(checks []doctorCheck, except string) bool {
+	for _, check := range checks {
+		if check.Status == checkFail && check.Name != except {
+			return true
+		}
+	}
+	return false
+}
+
+// printBinaries lists the zap binaries found, marking the one the shell runs
+func printBinaries(binaries []zapBinary) {
+	for _, bin := range binaries {
+		versionStr := bin.Version
+		if versionStr == "" {
+			versionStr = "unknown version"
+		}
+		var tags []string
+		if bin.Active {
+			tags = append(tags, "active")
+		}
+		if bin.Running {
+			tags = append(tags, "running")
+		}
+		if bin.Resolved != bin.Path {
+			tags = append(tags, "-> "+bin.Resolved)
+		}
+		if len(tags) > 0 {
+			log.Log(log.FOUND, "%s (%s) [%s]", bin.Path, versionStr, strings.Join(tags, ", "))
+		} else {
+			log.Log(log.FOUND, "%s (%s)", bin.Path, versionStr)
+		}
+	}
+}
+
+// printChecks prints one line per check, followed by the fix of those that aren't ok
+func printChecks(report doctorReport) {
+	problems := 0
+	for _, check := range report.Checks {
+		level := log.OK
+		switch check.Status {
+		case checkWarn:
+			level = log.WARN
+		case checkFail:
+			level = log.FAIL
+		}
+		log.Log(level, "%s: %s", check.Name, check.Detail)
+		if check.Status != checkOK {
+			problems++
+			if check.Fix != "" {
+				log.Log(log.INFO, "  fix: %s", check.Fix)
+			}
+		}
+	}
+	if problems == 0 {
+		log.Log(log.OK, "zap installation looks healthy")
+	} else {
+		log.Log(log.STATS, "%d of %d check(s) need attention", problems, len(report.Checks))
+	}
+}
+
+// checkBinaries reports zap binaries older than the newest one found
+func checkBinaries(binaries []zapBinary, newest zapBinary, stale []zapBinary) doctorCheck {
+	check := doctorCheck{Name: "binaries", Status: checkOK}
+	switch {
+	case len(binaries) == 0:
+		check.Status = checkWarn
+		check.Detail = fmt.Sprintf("no installed zap binary on PATH or in %s", determineGoBinPath())
+		check.Fix = "install zap with 'go install github.com/hugoev/zap/cmd/zap@latest'"
+	case len(stale) > 0:
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("version drift: %d zap binar(ies) older than %s (%s)", len(stale), newest.Path, newest.Version)
+		for _, bin := range stale {
+			if bin.Active {
+				check.Detail += fmt.Sprintf("; %s shadows the newer binary - running 'zap' uses version %s", bin.Path, bin.Version)
+			}
+		}
+		check.Fix = "run 'zap doctor --fix' to replace stale binaries (or --fix --remove to delete them)"
+	case len(binaries) > 1:
+		check.Detail = fmt.Sprintf("%d zap binaries on PATH, all at the same version", len(binaries))
+	default:
+		check.Detail = fmt.Sprintf("%s (%s)", binaries[0].Path, binaries[0].Version)
+	}
+	return check
+}
+
+// checkPath reports whether zap can be run by name
+func checkPath() doctorCheck {
+	check := doctorCheck{Name: "PATH", Status: checkOK}
+	if path, err := execx.Get("zap").Lookup(); err == nil {
+		check.Detail = "'zap' runs " + path
+		return check
+	}
+	goBinPath, needed := pathSetupNeeded()
+	check.Status = checkWarn
+	check.Detail = "'zap' is not on PATH"
+	if needed {
+		check.Detail = fmt.Sprintf("zap is installed in %s, which is not on PATH", goBinPath)
+		check.Fix = "run 'zap setup path', or set path_setup to prompt to be asked next time"
+	} else {
+		check.Fix = "put the directory containing zap on your PATH"
+	}
+	return check
+}
+
+// checkTools reports the external tools zap relies on: ps for process details and the
+// instance lock, lsof, ss and netstat for port scans where /proc isn't read directly
+func checkTools() []doctorCheck {
+	var checks []doctorCheck
+	for _, tool := range []string{"ps", ports.BackendLsof, ports.BackendSS, ports.BackendNetstat} {
+		check := doctorCheck{Name: tool, Status: checkOK}
+		path, err := execx.Get(tool).Lookup()
+		switch {
+		case err == nil:
+			check.Detail = path
+		case tool == "ps" && runtime.GOOS != "windows":
+			check.Status = checkFail
+			check.Detail = "not found - zap can't read process details or tell if a process is still running"
+			check.Fix = "install ps (the procps package on most Linux distributions)"
+		default:
+			check.Status = checkWarn
+			check.Detail = "not found"
+			check.Fix = fmt.Sprintf("install %s, or ignore this if the port backend doesn't need it", tool)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkPortBackend reports whether port_backend can scan ports on this system
+func checkPortBackend(cfg *config.Config) doctorCheck {
+	check := doctorCheck{Name: "port backend", Status: checkOK}
+	backend := cfg.PortBackend
+	if err := ports.CheckBackend(backend); err != nil {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s: %v", backend, err)
+		check.Fix = "install lsof, or pick an available backend with 'zap config set port_backend <name>'"
+		return check
+	}
+	check.Detail = backend
+	if backend == ports.BackendAuto {
+		if ports.NativeScanAvailable() {
+			check.Detail += " (reads /proc directly)"
+		} else {
+			check.Detail += " (lsof, ss and netstat in turn)"
+		}
+	}
+	return check
+}
+
+// checkConfig reports problems in config.json that Load would silently repair or ignore
+func checkConfig() doctorCheck {
+	check := doctorCheck{Name: "config", Status: checkOK}
+	path, _ := config.Path()
+	if err := config.Check(); err != nil {
+		check.Status = checkFail
+		check.Detail = err.Error()
+		check.Fix = "correct it in config.json, or go back to a backup with 'zap config restore'"
+		return check
+	}
+	check.Detail = path + " is valid"
+	if corrupted := config.CorruptedFiles(); len(corrupted) > 0 {
+		check.Status = checkWarn
+		check.Detail += fmt.Sprintf("; %d unreadable earlier config(s) were set aside, e.g. %s", len(corrupted), corrupted[len(corrupted)-1])
+		check.Fix = "copy any settings you still need from the set-aside file, then delete it"
+	}
+	return check
+}
+
+// checkLock reports whether zap can take its instance lock, which every command that
+// kills, deletes or saves settings needs
+func checkLock() doctorCheck {
+	check := doctorCheck{Name: "lock", Status: checkOK}
+	path, err := paths.File(".lock")
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = err.Error()
+		check.Fix = fmt.Sprintf("set HOME, or %s to a writable directory", paths.EnvHome)
+		return check
+	}
+	if paths.ReadOnly() {
+		check.Status = checkWarn
+		check.Detail = fmt.Sprintf("%s is read-only - only commands that change nothing can run", filepath.Dir(path))
+		check.Fix = fmt.Sprintf("set %s to a writable directory", paths.EnvHome)
+		return check
+	}
+	check.Detail = path + " (held by this run, so no other zap is running)"
+	if !lockHeld(path) {
+		check.Detail = path + " can be created"
+	}
+	return check
+}
+
+// lockHeld reports whether the lock file records this process, i.e. doctor holds the lock
+func lockHeld(path string) bool {
+	data, err := os.ReadFile(path)
+	return err == nil && strings.TrimSpace(string(data)) == fmt.Sprint(os.Getpid())
+}
+
+// checkPermissions reports which processes zap can terminate
+func checkPermissions(ctx context.Context, cfg *config.Config) doctorCheck {
+	check := doctorCheck{Name: "permissions", Status: checkOK}
+	if current, err := user.Current(); err == nil && current.Uid == "0" {
+		check.Detail = "running as root - can terminate any process"
+		return check
+	}
+	check.Detail = "can terminate your own processes"
+	switch {
+	case !cfg.AllowSudo:
+		check.Detail += "; processes of other users or root are reported, not terminated (allow_sudo is off)"
+	case ports.SudoAvailable(ctx):
+		check.Detail += "; others through passwordless sudo (allow_sudo)"
+	default:
+		check.Status = checkWarn
+		check.Detail += "; allow_sudo is on, but sudo asks for a password"
+		check.Fix = "run 'sudo -v' before zap, or allow 'kill' without a password in sudoers"
+	}
+	if procHidden() {
+		check.Status = checkWarn
+		check.Detail += "; /proc is mounted with hidepid, so other users' processes are invisible"
+		check.Fix = "run zap as root (or with sudo) to see processes of other users"
+	}
+	return check
+}
+
+// procHidden reports whether Linux hides other users' processes (/proc mounted with hidepid)
+func procHidden() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	data, err := os.ReadFile("/proc/mounts")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[1] != "/proc" {
+			continue
+		}
+		for _, option := range strings.Split(fields[3], ",") {
+			if value, ok := strings.CutPrefix(option, "hidepid="); ok && value != "0" && value != "off" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkDiskSpace reports the free space where zap keeps its files
+func checkDiskSpace() doctorCheck {
+	check := doctorCheck{Name: "disk space", Status: checkOK}
+	dir, err := paths.BaseDir()
+	if err != nil || runtime.GOOS == "windows" {
+		check.Detail = "not checked"
+		return check
+	}
+	var stat unix.Statfs_t
+	if err := unix.Statfs(dir, &stat); err != nil {
+		check.Detail = fmt.Sprintf("not checked: %v", err)
+		return check
+	}
+	free := int64(stat.Bavail) * int64(stat.Bsize)
+	check.Detail = fmt.Sprintf("%s free on the filesystem of %s", cleanup.FormatSize(free), dir)
+	if free < lowDiskSpace {
+		check.Status = checkWarn
+		check.Fix = "free some space ('zap cleanup --dry-run' shows what zap can reclaim) - saving config, history and the trash may fail"
+	}
+	return check
+}
+
+// fixStaleBinaries replaces stale binaries with the newest one, or removes them, and
+// reports whether all of them were
+func fixStaleBinaries(newest zapBinary, stale []zapBinary, yes, remove bool) bool {
+	fixed := 0
+	for _, bin := range stale {
+		action := "replace"
+		if remove {
+			action = "remove"
+		}
+		if !yes {
+			log.Log(log.ACTION, "%s %s (version %s)? (y/N): ", action, bin.Path, bin.Version)
+			if !confirm() {
+				log.Log(log.SKIP, "%s", bin.Path)
+				continue
+			}
+		}
+
+		var err error
+		if remove {
+			err = os.Remove(bin.Path)
+		} else {
+			err = replaceBinary(newest.Resolved, bin.Resolved)
+		}
+		if err != nil {
+			log.Log(log.FAIL, "failed to %s %s: %v", action, bin.Path, err)
+			if os.IsPermission(err) {
+				log.Log(log.INFO, "%s is not writable - retry with sudo or remove it manually", filepath.Dir(bin.Resolved))
+			}
+			continue
+		}
+		fixed++
+		if remove {
+			log.Log(log.OK, "removed %s", bin.Path)
+		} else {
+			log.Log(log.OK, "replaced %s with version %s", bin.Path, newest.Version)
+		}
+	}
+	log.Log(log.INFO, "run 'hash -r' (or restart your terminal) if your shell cached the old location")
+	return fixed == len(stale)
+}
+
+// findZapBinaries lists every zap executable on PATH plus the Go bin and running binary
+func findZapBinaries() []zapBinary {
+	exeName := "zap"
+	if runtime.GOOS == "windows" {
+		exeName = "zap.exe"
+	}
+
+	runningPath, _ := os.Executable()
+	runningResolved, _ := filepath.EvalSymlinks(runningPath)
+
+	candidates := []string{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir != "" {
+			candidates = append(candidates, filepath.Join(dir, exeName))
+		}
+	}
+	// Also look where `go install` and `zap update` put binaries, even if not on PATH
+	candidates = append(candidates, filepath.Join(determineGoBinPath(), exeName))
+
+	var binaries []zapBinary
+	seen := make(map[string]bool)
+	for i, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(candidate)
+		if err != nil {
+			resolved = candidate
+		}
+		if seen[resolved] {
+			continue
+		}
+		seen[resolved] = true
+
+		bin := zapBinary{
+			Path:     candidate,
+			Resolved: resolved,
+			Active:   len(binaries) == 0 && i < len(candidates)-1,
+			Running:  resolved == runningResolved,
+		}
+		if bin.Running {
+			bin.Version = version.Get()
+		} else {
+			bin.Version = probeBinaryVersion(candidate)
+		}
+		binaries = append(binaries, bin)
+	}
+	return binaries
+}
+
+// probeBinaryVersion runs "<bin> version" and extracts the version number
+func probeBinaryVersion(path string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := execx.Run(ctx, path, "version")
+	if err != nil {
+		return ""
+	}
+	if v, err := semver.Extract(string(output)); err == nil {
+		return v
+	}
+	if strings.Contains(string(output), "dev") {
+		return "dev"
+	}
+	return ""
+}
+
+// findStaleBinaries returns the newest binary and every binary with an older version.
+// Binaries whose version can't be parsed (dev builds) are never considered stale.
+func findStaleBinaries(binaries []zapBinary) (zapBinary, []zapBinary) {
+	var newest zapBinary
+	var newestVer semver.Version
+	found := false
+	for _, bin := range binaries {
+		ver, err := semver.Parse(bin.Version)
+		if err != nil {
+			continue
+		}
+		if !found || ver.Compare(newestVer) > 0 {
+			newest, newestVer, found = bin, ver, true
+		}
+	}
+	if !found {
+		return newest, nil
+	}
+
+	var stale []zapBinary
+	for _, bin := range binaries {
+		if ver, err := semver.Parse(bin.Version); err == nil && ver.Compare(newestVer) < 0 {
+			stale = append(stale, bin)
+		}
+	}
+	return newest, stale
+}
+
+// replaceBinary atomically overwrites dst with a copy of src
+func replaceBinary(src, dst string) error {
+	tempPath := dst + ".new"
+	if err := copyFile(src, tempPath); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, 0755); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := renameFile(tempPath, dst); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
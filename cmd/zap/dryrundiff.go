@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// rememberDryRun stores this dry run's candidates for the next `zap cleanup --dry-run
+// --compare` and returns the previous dry run (nil on the first one)
+func rememberDryRun(dirs []cleanup.DirectoryInfo) *state.DryRun {
+	var previous *state.DryRun
+	if st, err := state.Load(); err == nil {
+		previous = st.LastDryRun
+	} else {
+		log.VerboseLog("could not read previous dry run: %v", err)
+	}
+
+	run := state.DryRun{Time: testmode.Now(), Candidates: []state.Candidate{}}
+	for _, dir := range dirs {
+		pattern := dir.Pattern
+		if dir.Category != "" {
+			pattern = "category " + dir.Category
+		}
+		run.Candidates = append(run.Candidates, state.Candidate{Path: dir.Path, Size: dir.Size, Pattern: pattern})
+	}
+	if err := state.SaveDryRun(run); err != nil {
+		log.VerboseLog("could not save dry run: %v", err)
+	}
+	return previous
+}
+
+// showDryRunDiff prints which directories became or stopped being candidates since the
+// previous dry run, e.g. after changing max_age_days_for_cleanup or exclude_paths
+func showDryRunDiff(previous *state.DryRun, dirs []cleanup.DirectoryInfo) {
+	if previous == nil {
+		log.Log(log.INFO, "no previous dry run to compare with; change your config and run zap cleanup --dry-run --compare again")
+		return
+	}
+
+	current := state.DryRun{}
+	for _, dir := range dirs {
+		current.Candidates = append(current.Candidates, state.Candidate{Path: dir.Path, Size: dir.Size})
+	}
+	diff := state.CompareDryRuns(*previous, current)
+
+	since := previous.Time.Format("2006-01-02 15:04:05")
+	if diff.Empty() {
+		log.Log(log.OK, "same candidates as the dry run of %s", since)
+		return
+	}
+	for _, c := range diff.Added {
+		log.Log(log.FOUND, "added   %s (%s)", c.Path, cleanup.FormatSize(c.Size))
+	}
+	for _, c := range diff.Removed {
+		log.Log(log.INFO, "dropped %s (%s, was %s)", c.Path, cleanup.FormatSize(c.Size), c.Pattern)
+	}
+	log.Log(log.STATS, "%d added (+%s), %d dropped (-%s) since the dry run of %s",
+		len(diff.Added), cleanup.FormatSize(state.SizeOf(diff.Added)),
+		len(diff.Removed), cleanup.FormatSize(state.SizeOf(diff.Removed)), since)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/lock"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/paths"
+)
+
+// extraGroups are what `zap install-extras` can install
+var extraGroups = []string{"completions", "man", "config"}
+
+var installExtrasCommand = &command{
+	spec: commandSpec{
+		Name: "install-extras", Description: "Install shell completions, the man page and a config template (default: all of them)",
+		Args:  []argSpec{{Name: "extra", Optional: true, Variadic: true, Suggestions: extraGroups}},
+		Flags: withCommon("dry-run", "output-dir", "refresh"),
+	},
+	readOnly: func(args []string) bool { return hasArg(args, "--dry-run") },
+	run: func(ctx context.Context, inv *invocation) {
+		handleInstallExtras(inv.positional, inv.flagValues["output-dir"], inv.flags["refresh"], inv.dryRun, inv.jsonOutput)
+	},
+}
+
+// What happened to an extra file, in extrasResult
+const (
+	actionInstalled    = "installed"
+	actionWouldInstall = "would_install" // --dry-run
+	actionNotInstalled = "skipped"       // its shell isn't installed, or --refresh and it wasn't there
+)
+
+// extraFile is a file install-extras writes. Everything is generated by this binary,
+// so the files always match its commands.
+type extraFile struct {
+	Extra string `json:"extra"`
+	// Name is the file's path in --output-dir, e.g. in a release archive
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+	// shell is the shell a completion script is for; it's only installed with it
+	shell   string
+	content func() string
+}
+
+type extrasResult struct {
+	Files  []extraFile `json:"files"`
+	DryRun bool        `json:"dry_run"`
+}
+
+// extraFiles lists the extras and where they are installed for the current user:
+// the XDG locations bash-completion, zsh, fish and man look in, and zap's directory
+func extraFiles() ([]extraFile, error) {
+	homeDir, err := paths.HomeDir()
+	if err != nil {
+		return nil, err
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(homeDir, ".config")
+	}
+	templatePath, err := paths.File("config.example.json")
+	if err != nil {
+		return nil, err
+	}
+
+	completion := func(shell string) func() string {
+		return func() string { return completionScript(shell) }
+	}
+	return []extraFile{
+		{Extra: "completions", Name: "completions/zap.bash", Path: filepath.Join(dataHome, "bash-completion", "completions", "zap"), shell: "bash", content: completion("bash")},
+		{Extra: "completions", Name: "completions/_zap", Path: filepath.Join(dataHome, "zsh", "site-functions", "_zap"), shell: "zsh", content: completion("zsh")},
+		{Extra: "completions", Name: "completions/zap.fish", Path: filepath.Join(configHome, "fish", "completions", "zap.fish"), shell: "fish", content: completion("fish")},
+		{Extra: "man", Name: "man/man1/zap.1", Path: filepath.Join(dataHome, "man", "man1", "zap.1"), content: func() string { return manPage(buildSpec()) }},
+		{Extra: "config", Name: "config.example.json", Path: templatePath, content: configTemplate},
+	}, nil
+}
+
+// configTemplate is config.json with every key at its default
+func configTemplate() string {
+	defaults := config.Default()
+	data, _ := json.MarshalIndent(&defaults, "", "  ")
+	return string(data) + "\n"
+}
+
+// handleInstallExtras writes the extras (all, or the groups named) to where they are
+// found, or with outputDir into one directory, as the release archives ship them.
+// With refresh, only files installed before are rewritten, which zap update does so
+// they keep up with the new binary.
+func handleInstallExtras(groups []string, outputDir string, refresh, dryRun, jsonOutput bool) {
+	for _, group := range groups {
+		if !slices.Contains(extraGroups, group) {
+			log.Log(log.FAIL, "Unknown extra: %s (choose from %s)", group, strings.Join(extraGroups, ", "))
+			os.Exit(1)
+		}
+	}
+	if refresh && outputDir != "" {
+		log.Log(log.FAIL, "--refresh rewrites installed extras and can't be combined with --output-dir")
+		os.Exit(1)
+	}
+	files, err := extraFiles()
+	if err != nil {
+		log.Log(log.FAIL, "Cannot locate where extras go: %v", err)
+		os.Exit(1)
+	}
+
+	result := extrasResult{Files: []extraFile{}, DryRun: dryRun}
+	failed := false
+	for _, file := range files {
+		if len(groups) > 0 && !slices.Contains(groups, file.Extra) {
+			continue
+		}
+		if outputDir != "" {
+			file.Path = filepath.Join(outputDir, filepath.FromSlash(file.Name))
+		}
+		file.Action = actionInstalled
+		switch {
+		case refresh:
+			if _, err := os.Stat(file.Path); err != nil {
+				file.Action = actionNotInstalled
+			}
+		case outputDir == "" && file.shell != "":
+			if _, err := exec.LookPath(file.shell); err != nil {
+				log.VerboseLog("skipping %s: %s isn't installed", file.Path, file.shell)
+				file.Action = actionNotInstalled
+			}
+		}
+
+		switch {
+		case file.Action == actionNotInstalled:
+		case dryRun:
+			file.Action = actionWouldInstall
+			log.Log(log.INFO, "would write %s (%s)", file.Path, file.Extra)
+		default:
+			if err := writeExtra(file.Path, file.content()); err != nil {
+				file.Action, file.Error = actionFailed, err.Error()
+				failed = true
+				log.Log(log.FAIL, "Failed to write %s: %v", file.Path, err)
+				break
+			}
+			log.Log(log.OK, "wrote %s (%s)", file.Path, file.Extra)
+			if file.shell == "zsh" && outputDir == "" && !refresh {
+				log.Log(log.INFO, "zsh loads it once %s is in fpath: add fpath=(%s $fpath) to ~/.zshrc before compinit", filepath.Dir(file.Path), filepath.Dir(file.Path))
+			}
+		}
+		result.Files = append(result.Files, file)
+	}
+
+	if jsonOutput {
+		data, _ := json.Marshal(result)
+		fmt.Println(string(data))
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// writeExtra replaces path with content, creating its directory
+func writeExtra(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tempPath := path + ".tmp"
+	if err := os.WriteFile(tempPath, []byte(content), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// refreshExtras has a freshly installed binary rewrite the extras an earlier
+// install-extras put in place, so completions and the man page describe its commands.
+// Versions from before install-extras don't have it, so failures are only mentioned
+// with --verbose.
+func refreshExtras(instanceLock *lock.InstanceLock, binaryPath string) {
+	output, err, lockErr := runUnlocked(instanceLock, binaryPath, "install-extras", "--refresh")
+	if lockErr != nil {
+		log.VerboseLog("failed to re-acquire lock after refreshing extras: %v", lockErr)
+	}
+	if err != nil {
+		log.VerboseLog("installed extras not refreshed: %v", err)
+		return
+	}
+	if text := strings.TrimSpace(string(output)); text != "" {
+		log.VerboseLog("refreshed extras:\n%s", text)
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/summary"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// What happened to a forwarding, in forwardEvent
+const (
+	eventListening   = "listening"
+	eventBackendDown = "backend_down"
+	eventBackendUp   = "backend_up"
+	eventConnected   = "connected"
+	eventDropped     = "dropped" // the target didn't answer within --timeout
+)
+
+// defaultForwardTimeout is how long a connection waits for a target that went away
+const defaultForwardTimeout = 10 * time.Second
+
+// forwardEvent is a line of `zap forward --json`
+type forwardEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	ListenPort int       `json:"listen_port"`
+	Target     string    `json:"target"`
+	Client     string    `json:"client,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+var forwardCommand = &command{
+	spec: commandSpec{
+		Name: "forward", Description: "Bridge a port to another until Ctrl-C, e.g. 8080:3000 to serve port 3000 on 8080, instead of freeing it",
+		Args:  []argSpec{{Name: "listen:target", Variadic: true, Suggestions: []string{"8080:3000"}}},
+		Flags: withCommon("interface", "timeout", "interval"),
+	},
+	readOnly: always,
+	run:      handleForward,
+}
+
+// forwarder bridges connections to a local port to a target address, and keeps track
+// of whether the target answers
+type forwarder struct {
+	listenPort int
+	target     string // host:port
+	timeout    time.Duration
+	jsonOutput bool
+
+	mu sync.Mutex
+	up *bool // whether the target answered last time; nil before the first try
+
+	connections atomic.Int64
+	dropped     atomic.Int64
+}
+
+// parseForwarding reads listen:target, where target is a port on localhost or host:port
+func parseForwarding(arg string) (*forwarder, error) {
+	listen, target, ok := strings.Cut(arg, ":")
+	if !ok {
+		return nil, fmt.Errorf("%s: expected listen:target, e.g. 8080:3000", arg)
+	}
+	listenPort, err := strconv.Atoi(listen)
+	if err != nil || listenPort < 1 || listenPort > 65535 {
+		return nil, fmt.Errorf("%s: invalid port %s (must be 1-65535)", arg, listen)
+	}
+	host, port := "localhost", target
+	if h, p, err := net.SplitHostPort(target); err == nil {
+		host, port = h, p
+	}
+	targetPort, err := strconv.Atoi(port)
+	if err != nil || targetPort < 1 || targetPort > 65535 {
+		return nil, fmt.Errorf("%s: invalid port %s (must be 1-65535)", arg, port)
+	}
+	if ip := net.ParseIP(host); targetPort == listenPort && (host == "localhost" || ip != nil && ip.IsLoopback()) {
+		return nil, fmt.Errorf("%s: forwards port %d to itself", arg, listenPort)
+	}
+	return &forwarder{listenPort: listenPort, target: net.JoinHostPort(host, port)}, nil
+}
+
+// handleForward keeps a TCP proxy from each listen port to its target until
+// interrupted, so a tool that insists on an occupied port can be bridged to where the
+// server really is instead of killing what holds the port. Connections made while the
+// target is down (e.g. a dev server restarting) wait for it to come back.
+func handleForward(ctx context.Context, inv *invocation) {
+	if len(inv.positional) == 0 {
+		log.Log(log.FAIL, "Usage: zap forward <listen:target>... (e.g. zap forward 8080:3000)")
+		os.Exit(1)
+	}
+	iface := inv.flagValues["interface"]
+	if iface == "" {
+		iface = ports.InterfaceLoopback
+	}
+	if iface != ports.InterfaceLoopback && iface != ports.InterfaceAll {
+		log.Log(log.FAIL, "Invalid --interface: %s (must be %s or %s)", iface, ports.InterfaceLoopback, ports.InterfaceAll)
+		os.Exit(1)
+	}
+	timeout := defaultForwardTimeout
+	if value, ok := inv.flagValues["timeout"]; ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed <= 0 {
+			log.Log(log.FAIL, "Invalid --timeout: %s (use e.g. 10s)", value)
+			os.Exit(1)
+		}
+		timeout = parsed
+	}
+	interval := inv.cfg.WatchInterval()
+	if value, ok := inv.flagValues["interval"]; ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 100*time.Millisecond {
+			log.Log(log.FAIL, "Invalid --interval: %s (use e.g. 2s, 1m; at least 100ms)", value)
+			os.Exit(1)
+		}
+		interval = parsed
+	}
+
+	var forwarders []*forwarder
+	seen := make(map[int]bool)
+	for _, arg := range inv.positional {
+		f, err := parseForwarding(arg)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid forwarding %v", err)
+			os.Exit(1)
+		}
+		if seen[f.listenPort] {
+			log.Log(log.FAIL, "Port %d is forwarded twice", f.listenPort)
+			os.Exit(1)
+		}
+		seen[f.listenPort] = true
+		f.timeout, f.jsonOutput = timeout, inv.jsonOutput
+		forwarders = append(forwarders, f)
+	}
+
+	// Every port is bound before anything is served, so a taken port fails the command
+	listeners := make([][]net.Listener, len(forwarders))
+	for i, f := range forwarders {
+		var err error
+		if listeners[i], err = listenForward(f.listenPort, iface); err != nil {
+			log.Log(log.FAIL, "Cannot listen on port %d: %v", f.listenPort, err)
+			log.Log(log.INFO, "see what holds it: zap why %d", f.listenPort)
+			for _, bound := range listeners[:i] {
+				for _, listener := range bound {
+					listener.Close()
+				}
+			}
+			os.Exit(1)
+		}
+	}
+
+	// Forwarding runs until Ctrl-C; other zap commands can run meanwhile
+	inv.lock.Release()
+	outcome := summary.New("forward", false)
+	var wg sync.WaitGroup
+	for i, f := range forwarders {
+		f.report(eventListening, "", "")
+		if iface == ports.InterfaceAll {
+			log.Log(log.WARN, "port %d is reachable from the network, not just this machine (--interface=all)", f.listenPort)
+		}
+		for _, listener := range listeners[i] {
+			wg.Add(1)
+			go func(listener net.Listener) {
+				defer wg.Done()
+				f.serve(ctx, listener)
+			}(listener)
+		}
+		wg.Add(1)
+		go func(f *forwarder) {
+			defer wg.Done()
+			f.monitor(ctx, interval)
+		}(f)
+	}
+	if !inv.jsonOutput {
+		log.Log(log.INFO, "Ctrl-C to stop")
+	}
+	wg.Wait()
+
+	var connections, dropped int
+	for _, f := range forwarders {
+		connections += int(f.connections.Load())
+		dropped += int(f.dropped.Load())
+	}
+	outcome.Count("connections", connections, "connection(s)")
+	outcome.CountIfAny("dropped", dropped, "connection(s)")
+	if inv.jsonOutput {
+		outcome.Print(summary.FormatNDJSON)
+		return
+	}
+	log.Log(log.OK, "stopped forwarding")
+	outcome.Print(summary.FormatText)
+}
+
+// listenForward binds port on loopback (IPv4, and IPv6 where there is one, since
+// "localhost" may resolve to either) or on every interface
+func listenForward(port int, iface string) ([]net.Listener, error) {
+	if iface == ports.InterfaceAll {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{listener}, nil
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	listeners := []net.Listener{listener}
+	if listener6, err := net.Listen("tcp", fmt.Sprintf("[::1]:%d", port)); err == nil {
+		listeners = append(listeners, listener6)
+	} else {
+		log.VerboseLog("not listening on [::1]:%d: %v", port, err)
+	}
+	return listeners, nil
+}
+
+// serve accepts connections on listener until ctx is done. If the listener fails, it
+// is bound again, retrying every second until that works.
+func (f *forwarder) serve(ctx context.Context, listener net.Listener) {
+	address := listener.Addr().String()
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	for {
+		conn, err := listener.Accept()
+		if err == nil {
+			go f.bridge(ctx, conn)
+			continue
+		}
+		stop()
+		listener.Close()
+		if ctx.Err() != nil {
+			return
+		}
+		log.Log(log.WARN, "listener on %s failed: %v; listening again", address, err)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			if listener, err = net.Listen("tcp", address); err == nil {
+				break
+			}
+			log.VerboseLog("cannot listen on %s yet: %v", address, err)
+		}
+		log.Log(log.OK, "listening on %s again", address)
+		stop = context.AfterFunc(ctx, func() { listener.Close() })
+	}
+}
+
+// bridge copies between client and the target until both sides are done
+func (f *forwarder) bridge(ctx context.Context, client net.Conn) {
+	defer client.Close()
+	f.connections.Add(1)
+	peer := client.RemoteAddr().String()
+	backend, err := f.dialTarget(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			f.dropped.Add(1)
+			f.report(eventDropped, peer, err.Error())
+		}
+		return
+	}
+	defer backend.Close()
+	f.report(eventConnected, peer, "")
+	stop := context.AfterFunc(ctx, func() {
+		client.Close()
+		backend.Close()
+	})
+	defer stop()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		// Pass on the end of one direction; the other may still have data to send
+		if tcp, ok := dst.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go pipe(backend, client)
+	go pipe(client, backend)
+	<-done
+	<-done
+}
+
+// dialTarget connects to the target, retrying for up to the timeout while it doesn't
+// answer, so a connection made while a dev server restarts reaches the new one
+func (f *forwarder) dialTarget(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", f.target)
+		if err == nil {
+			f.setUp(true, nil)
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s didn't answer within %v", f.target, f.timeout)
+		}
+		f.setUp(false, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// monitor checks every interval whether the target answers, so it going away and
+// coming back is reported even without connections
+func (f *forwarder) monitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		dialer := net.Dialer{Timeout: min(interval, time.Second)}
+		conn, err := dialer.DialContext(ctx, "tcp", f.target)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			conn.Close()
+		}
+		f.setUp(err == nil, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setUp records whether the target answered, reporting when that changes
+func (f *forwarder) setUp(up bool, err error) {
+	f.mu.Lock()
+	first, changed := f.up == nil, f.up == nil || *f.up != up
+	f.up = &up
+	f.mu.Unlock()
+	if !changed {
+		return
+	}
+	switch {
+	case up:
+		if !first {
+			f.report(eventBackendUp, "", "")
+		}
+	case first && !f.jsonOutput:
+		log.Log(log.INFO, "nothing answers on %s yet; connections wait up to %v for it", f.target, f.timeout)
+	default:
+		f.report(eventBackendDown, "", err.Error())
+	}
+}
+
+// report logs an event, or with --json prints it
+func (f *forwarder) report(event, client, detail string) {
+	if f.jsonOutput {
+		data, _ := json.Marshal(forwardEvent{
+			Time:       testmode.Now(),
+			Event:      event,
+			ListenPort: f.listenPort,
+			Target:     f.target,
+			Client:     client,
+			Error:      detail,
+		})
+		forwardOutput.Lock()
+		fmt.Println(string(data))
+		forwardOutput.Unlock()
+		return
+	}
+	switch event {
+	case eventListening:
+		log.Log(log.SCAN, "forwarding port %d to %s", f.listenPort, f.target)
+	case eventConnected:
+		log.VerboseLog("port %d: %s connected, bridged to %s", f.listenPort, client, f.target)
+	case eventDropped:
+		log.Log(log.WARN, "port %d: dropped %s: %s", f.listenPort, client, detail)
+	case eventBackendDown:
+		log.Log(log.WARN, "%s stopped answering; connections to port %d wait up to %v for it to come back", f.target, f.listenPort, f.timeout)
+	case eventBackendUp:
+		log.Log(log.OK, "%s answers again", f.target)
+	}
+}
+
+// forwardOutput keeps --json lines from the connections apart
+var forwardOutput sync.Mutex
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/picker"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// interactive reports whether -i/--interactive asked for the picker instead of y/N prompts
+func interactive(flags map[string]bool) bool {
+	return flags["interactive"]
+}
+
+// pickProcesses lets the user choose which processes to terminate; the first preselected
+// ones (the safe dev servers) start out selected. It exits if the picker can't run.
+func pickProcesses(cfg *config.Config, processes []ports.ProcessInfo, preselected int) []ports.ProcessInfo {
+	items := make([]picker.Item, len(processes))
+	for i, proc := range processes {
+		listener := describeListener(cfg, proc)
+		details := []string{
+			"command: " + proc.Cmd,
+			fmt.Sprintf("user:    %s, running %s", proc.User, formatRuntime(proc.Runtime)),
+		}
+		if proc.WorkingDir != "" {
+			details = append(details, "cwd:     "+proc.WorkingDir)
+		}
+		if proc.BindAddress != "" {
+			details = append(details, "bound:   "+proc.BindAddress)
+		}
+		class := listener.Class
+		if listener.Reason != "" {
+			class += " (" + listener.Reason + ")"
+		}
+		details = append(details, "class:   "+class)
+		items[i] = picker.Item{
+			Label:    fmt.Sprintf("%-10s PID %-7d %-16s %s", proc.PortLabel(), proc.PID, truncateString(proc.Name, 16), truncateString(proc.Cmd, 60)),
+			Details:  details,
+			Selected: i < preselected,
+		}
+	}
+
+	chosen := runPicker("Terminate processes", items)
+	picked := make([]ports.ProcessInfo, len(chosen))
+	for i, index := range chosen {
+		picked[i] = processes[index]
+	}
+	return picked
+}
+
+// pickDirectories lets the user choose which directories to delete, all selected at first
+func pickDirectories(dirs []cleanup.DirectoryInfo) []cleanup.DirectoryInfo {
+	items := make([]picker.Item, len(dirs))
+	for i, dir := range dirs {
+		details := []string{
+			"path:     " + dir.Path,
+			fmt.Sprintf("size:     %s on disk, %s apparent", cleanup.FormatSize(dir.Size), cleanup.FormatSize(dir.ApparentSize)),
+			fmt.Sprintf("modified: %s (%s ago)", dir.ModTime.Format("2006-01-02"), age.Of(testmode.Since(dir.ModTime))),
+			"rule:     " + dir.Pattern,
+		}
+		if dir.Reinstall != nil {
+			details = append(details, "restore:  "+dir.Reinstall.String())
+		}
+		items[i] = picker.Item{
+			Label:    fmt.Sprintf("%10s  %s", cleanup.FormatSize(dir.Size), dir.Path),
+			Details:  details,
+			Selected: true,
+		}
+	}
+
+	chosen := runPicker("Delete directories", items)
+	picked := make([]cleanup.DirectoryInfo, len(chosen))
+	for i, index := range chosen {
+		picked[i] = dirs[index]
+	}
+	return picked
+}
+
+func runPicker(title string, items []picker.Item) []int {
+	chosen, err := picker.Run(title, items)
+	if errors.Is(err, picker.ErrCancelled) {
+		log.Log(log.INFO, "cancelled, nothing changed")
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Log(log.FAIL, "Interactive mode failed: %v (run without -i to answer y/N prompts)", err)
+		os.Exit(1)
+	}
+	return chosen
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/summary"
+)
+
+// The --json output of `zap ports` and `zap cleanup`. Field names are part of zap's
+// interface (documented in the README under "JSON output"): fields may be added, but
+// never renamed or removed.
+
+// What happened to a process, in portsResult
+const (
+	actionTerminated     = "terminated"
+	actionWouldTerminate = "would_terminate" // --dry-run, unless protected or ignored
+	actionFailed         = "failed"          // still running after the kill attempt
+	actionDeclined       = "declined"        // not confirmed
+	actionProtected      = "protected"       // port in protected_ports
+	actionIgnored        = "ignored"         // in the ignored processes list
+	actionLeftRunning    = "left_running"    // not swept by --kill-all: infrastructure, container or current project
+)
+
+// What happened to a directory, in cleanupResult
+const (
+	actionDeleted     = "deleted"
+	actionTrashed     = "trashed"      // moved to the trash with --trash
+	actionWouldDelete = "would_delete" // --dry-run
+	actionTimedOut    = "timed_out"    // deletion exceeded the time budget
+	actionStalled     = "stalled"      // deletion made no progress and was skipped
+	actionKept        = "kept"         // not confirmed
+	actionUntrusted   = "untrusted"    // in a tree zap cleanup doesn't trust yet
+)
+
+type processResult struct {
+	portListener
+	Port           int    `json:"port"`
+	Protocol       string `json:"protocol"`
+	Protected      bool   `json:"protected"`
+	RuntimeSeconds int64  `json:"runtime_seconds"`
+	Action         string `json:"action"`
+}
+
+type portsResult struct {
+	Processes      []processResult  `json:"processes"`
+	Total          int              `json:"total"`
+	Safe           int              `json:"safe"`
+	Infrastructure int              `json:"infrastructure"`
+	Skipped        int              `json:"skipped"` // protected or ignored
+	Terminated     int              `json:"terminated"`
+	DryRun         bool             `json:"dry_run"`
+	Errors         []string         `json:"errors"`
+	Summary        *summary.Summary `json:"summary"`
+}
+
+// printPortsJSON prints the outcome for every process found; attempted holds the PIDs
+// zap tried to terminate, spared those --kill-all leaves running
+func printPortsJSON(cfg *config.Config, processes []ports.ProcessInfo, attempted, spared map[int]bool, outcome *summary.Summary) {
+	outcome.Finish()
+	dryRun := outcome.DryRun
+	result := portsResult{Processes: []processResult{}, DryRun: dryRun, Errors: outcome.Errors, Summary: outcome}
+	if result.Errors == nil {
+		result.Errors = []string{}
+	}
+	for _, proc := range processes {
+		entry := processResult{
+			portListener:   describeListener(cfg, proc),
+			Port:           proc.Port,
+			Protocol:       proc.Protocol,
+			Protected:      cfg.IsProtected(proc),
+			RuntimeSeconds: int64(proc.Runtime / time.Second),
+		}
+		switch {
+		case entry.Protected:
+			entry.Action = actionProtected
+		case entry.Ignored:
+			entry.Action = actionIgnored
+		case spared[proc.PID]:
+			entry.Action = actionLeftRunning
+		case dryRun:
+			// A dry run never asks, so everything not skipped would be terminated
+			entry.Action = actionWouldTerminate
+		case !attempted[proc.PID]:
+			entry.Action = actionDeclined
+		case ports.IsProcessRunning(proc.PID):
+			entry.Action = actionFailed
+		default:
+			entry.Action = actionTerminated
+		}
+
+		switch entry.Class {
+		case "safe":
+			result.Safe++
+		case "infrastructure":
+			result.Infrastructure++
+		}
+		switch entry.Action {
+		case actionProtected, actionIgnored:
+			result.Skipped++
+		case actionTerminated, actionWouldTerminate:
+			result.Terminated++
+		}
+		result.Processes = append(result.Processes, entry)
+	}
+	result.Total = len(result.Processes)
+
+	data, _ := json.Marshal(result)
+	fmt.Println(string(data))
+}
+
+type directoryResult struct {
+	cleanup.DirectoryInfo
+	Action string `json:"action"`
+	Error  string `json:"error,omitempty"`
+	// Reason is why the user ignored the directory (action "ignored")
+	Reason string `json:"reason,omitempty"`
+}
+
+type cleanupResult struct {
+	Directories []directoryResult `json:"directories"`
+	Total       int               `json:"total"`
+	Bytes       int64             `json:"size_bytes"`
+	Deleted     int               `json:"deleted"`
+	FreedBytes  int64             `json:"freed_bytes"`
+	Trashed     int               `json:"trashed"`
+	Failed      int               `json:"failed"`
+	Ignored     int               `json:"ignored"`
+	Untrusted   int               `json:"untrusted"`
+	// Projects rolls the deleted (or, in a dry run, to be deleted) directories up by project
+	Projects   []projectTotal   `json:"projects"`
+	DryRun     bool             `json:"dry_run"`
+	Unreadable []string         `json:"unreadable_paths"`
+	Errors     []string         `json:"errors"`
+	Summary    *summary.Summary `json:"summary"`
+}
+
+// printCleanupJSON prints the outcome for every directory found, ignored and untrusted
+// ones last; outcomes holds the directories zap acted on, by path
+func printCleanupJSON(dirs, ignored, untrusted []cleanup.DirectoryInfo, cfg *config.Config, outcomes map[string]directoryResult, unreadable []string, outcome *summary.Summary) {
+	outcome.Finish()
+	dryRun := outcome.DryRun
+	result := cleanupResult{
+		Directories: []directoryResult{},
+		Total:       len(dirs),
+		Bytes:       cleanup.GetTotalSize(dirs),
+		DryRun:      dryRun,
+		Unreadable:  unreadable,
+		Errors:      outcome.Errors,
+		Summary:     outcome,
+	}
+	if result.Unreadable == nil {
+		result.Unreadable = []string{}
+	}
+	if result.Errors == nil {
+		result.Errors = []string{}
+	}
+	var freed []cleanup.DirectoryInfo
+	for _, dir := range dirs {
+		entry, ok := outcomes[dir.Path]
+		switch {
+		case ok:
+		case dryRun:
+			entry.Action = actionWouldDelete
+		default:
+			entry.Action = actionKept
+		}
+		entry.DirectoryInfo = dir
+		switch entry.Action {
+		case actionDeleted:
+			result.Deleted++
+			result.FreedBytes += dir.Size
+		case actionTrashed:
+			result.Trashed++
+		case actionFailed:
+			result.Failed++
+		}
+		if entry.Action == actionDeleted || entry.Action == actionTrashed || entry.Action == actionWouldDelete {
+			freed = append(freed, dir)
+		}
+		result.Directories = append(result.Directories, entry)
+	}
+	result.Projects = groupByProject(freed)
+	for _, dir := range ignored {
+		entry := directoryResult{DirectoryInfo: dir, Action: actionIgnored}
+		if ignoredDir, ok := cfg.IgnoredDirFor(dir.Path); ok {
+			entry.Reason = ignoredDir.Reason
+		}
+		result.Directories = append(result.Directories, entry)
+	}
+	result.Ignored = len(ignored)
+	for _, dir := range untrusted {
+		result.Directories = append(result.Directories, directoryResult{DirectoryInfo: dir, Action: actionUntrusted})
+	}
+	result.Untrusted = len(untrusted)
+
+	data, _ := json.Marshal(result)
+	fmt.Println(string(data))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+)
+
+var killCommand = &command{
+	spec: commandSpec{
+		Name: "kill", Description: "Free the given port(s) directly, without scanning the common ports",
+		Args:  []argSpec{{Name: "port", Variadic: true, Dynamic: dynamicListeningPorts}},
+		Flags: withCommon("yes", "dry-run", "backend", "udp", "proto", "probe", "docker", "signal", "timeout", "explain"),
+	},
+	readOnly: func(args []string) bool { return hasArg(args, "--dry-run") },
+	run: func(ctx context.Context, inv *invocation) {
+		handleKill(ctx, inv.cfg, inv.positional, inv.approve, inv.dryRun, inv.jsonOutput, inv.flags, inv.flagValues)
+	},
+}
+
+// handleKill frees the given ports (`zap kill 3000`, `zap kill 3000,8080 5173-5175`):
+// only those ports are scanned, and naming a port counts as confirmation for safe dev
+// servers on it. Protected ports, ignored processes and infrastructure are treated
+// exactly as by `zap ports`.
+func handleKill(ctx context.Context, cfg *config.Config, portArgs []string, approve approval, dryRun, jsonOutput bool, flags map[string]bool, flagValues map[string]string) {
+	if len(portArgs) == 0 {
+		log.Log(log.FAIL, "Usage: zap kill <port>[,<port>|<from>-<to>...]")
+		os.Exit(1)
+	}
+	if _, err := ports.ParsePortRange(strings.Join(portArgs, ",")); err != nil {
+		log.Log(log.FAIL, "Invalid port range: %v", err)
+		os.Exit(1)
+	}
+
+	flagValues["ports"] = strings.Join(portArgs, ",")
+	flags["kill"] = true
+	handlePorts(ctx, cfg, approve, dryRun, jsonOutput, flags, flagValues)
+}
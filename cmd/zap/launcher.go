@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// Launcher output formats (--format), for one-keystroke workflows in Raycast and Alfred
+const (
+	formatRaycast = "raycast" // plain lines for a script command in fullOutput mode
+	formatAlfred  = "alfred"  // Alfred Script Filter JSON
+)
+
+// alfredItem is an item of an Alfred Script Filter result
+type alfredItem struct {
+	UID       string            `json:"uid"`
+	Title     string            `json:"title"`
+	Subtitle  string            `json:"subtitle"`
+	Arg       string            `json:"arg"`
+	Valid     bool              `json:"valid"`
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// launcherFormat returns the requested --format ("" if none), exiting on unknown formats
+func launcherFormat(flagValues map[string]string) string {
+	format, ok := flagValues["format"]
+	if !ok {
+		return ""
+	}
+	if format != formatRaycast && format != formatAlfred {
+		log.Log(log.FAIL, "Invalid --format: %s (use raycast or alfred)", format)
+		os.Exit(1)
+	}
+	return format
+}
+
+// printLauncherPorts lists occupied ports for a launcher. The Alfred arg is the port,
+// so a workflow action can run `zap ports --ports={query} --yes`.
+func printLauncherPorts(format string, cfg *config.Config, processes []ports.ProcessInfo) {
+	sorted := make([]ports.ProcessInfo, len(processes))
+	copy(sorted, processes)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Port < sorted[j].Port })
+
+	var items []alfredItem
+	seen := make(map[string]bool)
+	for _, proc := range sorted {
+		uid := fmt.Sprintf("port-%d-%d", proc.Port, proc.PID)
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+
+		listener := describeListener(cfg, proc)
+		status := "unknown process"
+		switch {
+		case cfg.IsProtected(proc):
+			status = "protected"
+		case listener.Ignored:
+			status = "ignored"
+		case listener.Class == "safe":
+			status = "safe dev server"
+		case listener.Class == "infrastructure":
+			status = "infrastructure"
+		}
+		subtitle := status
+		if listener.Project != "" {
+			subtitle += " · " + listener.Project
+		} else if proc.WorkingDir != "" {
+			subtitle += " · " + proc.WorkingDir
+		}
+		if !proc.StartTime.IsZero() {
+			subtitle += " · up " + formatRuntime(testmode.Since(proc.StartTime))
+		}
+
+		items = append(items, alfredItem{
+			UID:       uid,
+			Title:     fmt.Sprintf(":%d %s (PID %d)", proc.Port, proc.Name, proc.PID),
+			Subtitle:  subtitle,
+			Arg:       fmt.Sprintf("%d", proc.Port),
+			Valid:     status != "protected" && status != "ignored",
+			Variables: map[string]string{"pid": fmt.Sprintf("%d", proc.PID), "port": fmt.Sprintf("%d", proc.Port)},
+		})
+	}
+	printLauncherItems(format, items, "no processes found on the scanned ports")
+}
+
+// printLauncherDirs lists cleanup candidates for a launcher; the Alfred arg is the path
+func printLauncherDirs(format string, dirs []cleanup.DirectoryInfo) {
+	sorted := make([]cleanup.DirectoryInfo, len(dirs))
+	copy(sorted, dirs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Size > sorted[j].Size })
+
+	var items []alfredItem
+	for _, dir := range sorted {
+		items = append(items, alfredItem{
+			UID:      "dir-" + dir.Path,
+			Title:    filepath.Base(filepath.Dir(dir.Path)) + "/" + filepath.Base(dir.Path),
+			Subtitle: fmt.Sprintf("%s · %s old · %s", cleanup.FormatSize(dir.Size), age.Of(testmode.Since(dir.ModTime)), dir.Path),
+			Arg:      dir.Path,
+			Valid:    true,
+		})
+	}
+	printLauncherItems(format, items, "no stale directories found")
+}
+
+func printLauncherItems(format string, items []alfredItem, emptyMessage string) {
+	if format == formatAlfred {
+		if len(items) == 0 {
+			items = []alfredItem{{UID: "empty", Title: emptyMessage, Valid: false}}
+		}
+		data, _ := json.Marshal(struct {
+			Items []alfredItem `json:"items"`
+		}{items})
+		fmt.Println(string(data))
+		return
+	}
+
+	if len(items) == 0 {
+		fmt.Println(emptyMessage)
+		return
+	}
+	for _, item := range items {
+		fmt.Printf("%s  %s\n", item.Title, item.Subtitle)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/version"
+)
+
+// manPage renders zap(1) from the command spec and the config keys, so it can't fall
+// behind the commands the binary has
+func manPage(spec cliSpec) string {
+	var b strings.Builder
+	date := ""
+	if version.Date != "unknown" {
+		date, _, _ = strings.Cut(version.Date, "T")
+	}
+	fmt.Fprintf(&b, ".TH ZAP 1 %q %q \"User Commands\"\n", date, "zap "+version.Get())
+	b.WriteString(".SH NAME\nzap \\- free ports and clean up stale dependency directories\n")
+	b.WriteString(".SH SYNOPSIS\n.B zap\n\\fIcommand\\fR [\\fIflags\\fR]\n")
+	b.WriteString(".SH DESCRIPTION\n")
+	b.WriteString("zap finds processes holding development ports and offers to terminate them, and finds stale dependency and build directories (node_modules, .venv, target, ...) and offers to delete them.\n")
+
+	b.WriteString(".SH COMMANDS\n")
+	for _, cmd := range spec.Commands {
+		writeManCommand(&b, cmd, "")
+	}
+
+	b.WriteString(".SH FLAGS\n")
+	b.WriteString("Flags are written \\fB\\-\\-name\\fR or \\fB\\-\\-name=value\\fR; \\fBzap help\\fR \\fIcommand\\fR lists the ones a command accepts.\n")
+	for _, flag := range spec.Flags {
+		fmt.Fprintf(&b, ".TP\n\\fB\\-\\-%s\\fR", roffEscape(flag.Name))
+		if flag.Value != "" {
+			fmt.Fprintf(&b, "=\\fI%s\\fR", roffEscape(flag.Value))
+		}
+		if flag.Short != "" {
+			fmt.Fprintf(&b, ", \\fB\\-%s\\fR", roffEscape(flag.Short))
+		}
+		fmt.Fprintf(&b, "\n%s\n", roffEscape(flag.Description))
+	}
+
+	defaults := config.Default()
+	b.WriteString(".SH CONFIGURATION\n")
+	b.WriteString("Settings are read from \\fIconfig.json\\fR (see FILES) and changed with \\fBzap config set\\fR \\fIkey value\\fR. A \\fI.zaprc\\fR or \\fIzap.json\\fR in the working directory or a parent overrides some of them for a project.\n")
+	for _, key := range config.Keys(&defaults) {
+		fmt.Fprintf(&b, ".TP\n\\fB%s\\fR (%s, default %s)\n%s\n", roffEscape(key.Key), key.Type, roffEscape(compactJSON(key.Default)), roffEscape(key.Description))
+	}
+
+	b.WriteString(".SH ENVIRONMENT\n")
+	fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\nDirectory for zap's config, state, lock and journal (default \\fI~/.config/zap\\fR).\n", paths.EnvHome)
+	fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\nPath of the config file to use instead of \\fIconfig.json\\fR.\n", config.EnvConfig)
+	fmt.Fprintf(&b, ".TP\n\\fB%sKEY\\fR\nSets a config key for one run, e.g. \\fBZAP_PROTECTED_PORTS=5432,6379\\fR; see CONFIGURATION for the keys.\n", config.EnvPrefix)
+
+	b.WriteString(".SH FILES\n")
+	b.WriteString(".TP\n\\fI~/.config/zap/config.json\\fR\nSettings.\n")
+	b.WriteString(".TP\n\\fI~/.config/zap/config.example.json\\fR\nThe default settings, installed by \\fBzap install\\-extras\\fR.\n")
+	b.WriteString(".TP\n\\fI~/.config/zap/journal.jsonl\\fR\nEvery kill, deletion, restore and update.\n")
+	b.WriteString(".SH SEE ALSO\nhttps://github.com/hugoev/zap\n")
+	return b.String()
+}
+
+// writeManCommand writes a command and its subcommands as tagged paragraphs
+func writeManCommand(b *strings.Builder, cmd commandSpec, parent string) {
+	name := strings.TrimSpace(parent + " " + cmd.Name)
+	fmt.Fprintf(b, ".TP\n\\fBzap %s\\fR", roffEscape(name))
+	for _, arg := range cmd.Args {
+		switch {
+		case arg.Optional && arg.Variadic:
+			fmt.Fprintf(b, " [\\fI%s\\fR...]", roffEscape(arg.Name))
+		case arg.Optional:
+			fmt.Fprintf(b, " [\\fI%s\\fR]", roffEscape(arg.Name))
+		case arg.Variadic:
+			fmt.Fprintf(b, " \\fI%s\\fR...", roffEscape(arg.Name))
+		default:
+			fmt.Fprintf(b, " \\fI%s\\fR", roffEscape(arg.Name))
+		}
+	}
+	fmt.Fprintf(b, "\n%s", roffEscape(cmd.Description))
+	if len(cmd.Aliases) > 0 {
+		fmt.Fprintf(b, " (also: %s)", roffEscape(strings.Join(cmd.Aliases, ", ")))
+	}
+	b.WriteString("\n")
+	for _, sub := range cmd.Subcommands {
+		writeManCommand(b, sub, name)
+	}
+}
+
+// roffEscape keeps text from being read as roff: backslashes, hyphens (which roff may
+// turn into dashes) and a leading dot or quote, which would start a request
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
+
+func compactJSON(data json.RawMessage) string {
+	text := string(data)
+	if len(text) > 60 {
+		return text[:57] + "..."
+	}
+	return text
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// rememberPortScan stores this scan for the next `zap ports --diff` and returns the
+// previous one (nil on the first run)
+func rememberPortScan(scanned []int, processes []ports.ProcessInfo) *state.PortScan {
+	var previous *state.PortScan
+	if st, err := state.Load(); err == nil {
+		previous = st.LastScan
+	} else {
+		log.VerboseLog("could not read previous scan: %v", err)
+	}
+
+	if err := state.SavePortScan(newPortScan(scanned, processes)); err != nil {
+		log.VerboseLog("could not save scan result: %v", err)
+	}
+	return previous
+}
+
+func newPortScan(scanned []int, processes []ports.ProcessInfo) state.PortScan {
+	scan := state.PortScan{Time: testmode.Now(), Ports: scanned}
+	for _, proc := range processes {
+		scan.Listeners = append(scan.Listeners, state.Listener{
+			Port:       proc.Port,
+			PID:        proc.PID,
+			Name:       proc.Name,
+			Cmd:        proc.Cmd,
+			WorkingDir: proc.WorkingDir,
+		})
+	}
+	return scan
+}
+
+// showPortDiff prints what changed on the scanned ports since the previous scan
+func showPortDiff(previous *state.PortScan, scanned []int, processes []ports.ProcessInfo, jsonOutput bool) {
+	if previous == nil {
+		if jsonOutput {
+			fmt.Println(`{"previous":null,"new":[],"gone":[],"changed":[]}`)
+		} else {
+			log.Log(log.INFO, "no previous scan to compare with; run zap ports --diff again later")
+		}
+		return
+	}
+
+	diff := state.Diff(*previous, newPortScan(scanned, processes))
+
+	if jsonOutput {
+		type change struct {
+			Port   int            `json:"port"`
+			Before state.Listener `json:"before"`
+			After  state.Listener `json:"after"`
+		}
+		output := struct {
+			Previous time.Time        `json:"previous"`
+			New      []state.Listener `json:"new"`
+			Gone     []state.Listener `json:"gone"`
+			Changed  []change         `json:"changed"`
+		}{Previous: previous.Time, New: diff.Added, Gone: diff.Removed, Changed: []change{}}
+		if output.New == nil {
+			output.New = []state.Listener{}
+		}
+		if output.Gone == nil {
+			output.Gone = []state.Listener{}
+		}
+		for _, c := range diff.Changed {
+			output.Changed = append(output.Changed, change{Port: c[1].Port, Before: c[0], After: c[1]})
+		}
+		data, _ := json.Marshal(output)
+		fmt.Println(string(data))
+		return
+	}
+
+	since := previous.Time.Format("2006-01-02 15:04:05")
+	if diff.Empty() {
+		log.Log(log.OK, "no changes since last scan (%s)", since)
+		return
+	}
+
+	for _, l := range diff.Added {
+		log.Log(log.FOUND, "new     :%d PID %d (%s)", l.Port, l.PID, l.Name)
+	}
+	for _, l := range diff.Removed {
+		log.Log(log.INFO, "gone    :%d PID %d (%s)", l.Port, l.PID, l.Name)
+	}
+	for _, c := range diff.Changed {
+		log.Log(log.FOUND, "changed :%d PID %d (%s) -> PID %d (%s)", c[1].Port, c[0].PID, c[0].Name, c[1].PID, c[1].Name)
+	}
+	log.Log(log.STATS, "%d new, %d gone, %d changed since %s", len(diff.Added), len(diff.Removed), len(diff.Changed), since)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/ports"
+)
+
+// listedProcess is an entry of `zap ports --list --json`: the fields of `zap ports --json`
+// without an action, since a listing never acts
+type listedProcess struct {
+	portListener
+	Port           int    `json:"port"`
+	Protocol       string `json:"protocol"`
+	Protected      bool   `json:"protected"`
+	RuntimeSeconds int64  `json:"runtime_seconds"`
+}
+
+type portList struct {
+	Processes []listedProcess `json:"processes"`
+	Total     int             `json:"total"`
+}
+
+// printPortList prints every listener found, one per port, sorted by port: a table with
+// a header line, or a JSON object with --json. It never asks or kills anything.
+func printPortList(cfg *config.Config, processes []ports.ProcessInfo, jsonOutput bool) {
+	sorted := make([]ports.ProcessInfo, len(processes))
+	copy(sorted, processes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Port != sorted[j].Port {
+			return sorted[i].Port < sorted[j].Port
+		}
+		return sorted[i].PID < sorted[j].PID
+	})
+
+	if jsonOutput {
+		list := portList{Processes: []listedProcess{}}
+		for _, proc := range sorted {
+			list.Processes = append(list.Processes, listedProcess{
+				portListener:   describeListener(cfg, proc),
+				Port:           proc.Port,
+				Protocol:       proc.Protocol,
+				Protected:      cfg.IsProtected(proc),
+				RuntimeSeconds: int64(proc.Runtime / time.Second),
+			})
+		}
+		list.Total = len(list.Processes)
+		data, _ := json.Marshal(list)
+		fmt.Println(string(data))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PORT\tPID\tUSER\tUP\tCLASS\tARCH\tNAME\tDIRECTORY")
+	for _, proc := range sorted {
+		listener := describeListener(cfg, proc)
+		up := "-"
+		if proc.Runtime > 0 {
+			up = formatRuntime(proc.Runtime)
+		}
+		arch := orDash(proc.Arch)
+		if proc.Emulated {
+			arch += " (emulated)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", proc.PortLabel(), proc.PID, orDash(proc.User), up, listedClass(cfg, proc, listener), arch, proc.Name, orDash(proc.WorkingDir))
+	}
+	w.Flush()
+}
+
+// listedClass is the CLASS column: why zap would leave the process alone, else its class
+func listedClass(cfg *config.Config, proc ports.ProcessInfo, listener portListener) string {
+	switch {
+	case cfg.IsProtected(proc):
+		return "protected"
+	case listener.Ignored:
+		return "ignored"
+	default:
+		return listener.Class
+	}
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,828 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/journal"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/summary"
+)
+
+func getCommonPorts() []int {
+	return []int{
+		3000, 3001, 3002, 3003,
+		5173, 5174, 5175,
+		8000, 8001, 8080, 8081,
+		4000, 4001,
+		5000, 5001,
+		4200,
+		9000, 9001,
+		7000, 7001,
+	}
+}
+
+// formatPorts joins ports for display, e.g. ":3000, :8080"
+func formatPorts(portList []int) string {
+	formatted := make([]string, len(portList))
+	for i, port := range portList {
+		formatted[i] = fmt.Sprintf(":%d", port)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// withoutPorts returns portList minus the ports in remove
+func withoutPorts(portList, remove []int) []int {
+	removed := make(map[int]bool, len(remove))
+	for _, port := range remove {
+		removed[port] = true
+	}
+	var kept []int
+	for _, port := range portList {
+		if !removed[port] {
+			kept = append(kept, port)
+		}
+	}
+	return kept
+}
+
+var portsCommand = &command{
+	spec: commandSpec{
+		Name: "ports", Aliases: []string{"port"}, Description: "Scan and free up ports",
+		Flags: withCommon("yes", "dry-run", "interactive", "ports", "interface", "concurrency", "backend", "diff", "udp", "proto", "probe", "docker", "format", "watch", "interval", "auto-kill", "kill-all", "signal", "timeout", "explain", "list", "all"),
+	},
+	readOnly: func(args []string) bool {
+		return hasArg(args, "--dry-run") || hasArg(args, "--list") || hasArg(args, "--diff") || (hasArg(args, "--watch") && !hasArg(args, "--auto-kill"))
+	},
+	run: func(ctx context.Context, inv *invocation) {
+		// `zap ports kill 3000` is the same as `zap kill 3000`
+		if len(inv.positional) > 0 && inv.positional[0] == "kill" {
+			handleKill(ctx, inv.cfg, inv.positional[1:], inv.approve, inv.dryRun, inv.jsonOutput, inv.flags, inv.flagValues)
+			return
+		}
+		if inv.flags["kill-all"] {
+			for _, other := range []string{"interactive", "watch", "diff", "format"} {
+				if inv.flags[other] {
+					log.Log(log.FAIL, "--kill-all can't be combined with --%s", other)
+					os.Exit(1)
+				}
+			}
+		}
+		if inv.flags["list"] {
+			for _, other := range []string{"interactive", "watch", "diff", "format", "kill-all", "yes", "dry-run"} {
+				if inv.flags[other] {
+					log.Log(log.FAIL, "--list can't be combined with --%s", other)
+					os.Exit(1)
+				}
+			}
+		}
+		if inv.flags["watch"] {
+			handleWatch(ctx, inv)
+			return
+		}
+		handlePorts(ctx, inv.cfg, inv.approve, inv.dryRun, inv.jsonOutput, inv.flags, inv.flagValues)
+	},
+}
+
+func handlePorts(ctx context.Context, cfg *config.Config, approve approval, dryRun, jsonOutput bool, flags map[string]bool, flagValues map[string]string) {
+	atomic.AddInt32(&operationActive, 1)
+	defer atomic.AddInt32(&operationActive, -1)
+	format := launcherFormat(flagValues)
+
+	// The outcome of the run: the STATS line, and the summary of --json
+	command := "ports"
+	if flags["kill"] {
+		command = "kill"
+	}
+	outcome := summary.New(command, dryRun)
+	runSummary = outcome
+	terminatedStat := "terminated"
+	if dryRun {
+		terminatedStat = "would_terminate"
+	}
+	outcome.Count(terminatedStat, 0, "process(es)")
+	outcome.Count("skipped", 0, "")
+
+	portsToScan, protocols := scanTargets(ctx, cfg, flags, flagValues)
+
+	if flags["kill"] {
+		log.Log(log.SCAN, "checking %s", formatPorts(portsToScan))
+	} else if flags["all"] {
+		log.Log(log.SCAN, "checking every listening port (%d found)", len(portsToScan))
+	} else if cfg.ScanPorts != "" {
+		log.Log(log.SCAN, "checking scan_ports %s", cfg.ScanPorts)
+	} else {
+		log.Log(log.SCAN, "checking commonly used development ports")
+	}
+	if log.Verbose {
+		log.VerboseLog("scanning ports: %v", portsToScan)
+	}
+
+	scanCtx, stopWatch := watchStalls(ctx, cfg, approve.all())
+	processes, err := ports.ScanPortsRangeWithProtocols(scanCtx, portsToScan, protocols, scanConcurrency(cfg, flagValues))
+	stopWatch()
+	var partialScan *ports.PartialScanError
+	if errors.As(err, &partialScan) {
+		// Slow environment: carry on with what was found, but say what's missing
+		log.Log(log.SKIP, "scan incomplete: %v; not checked: %s", partialScan.Err, formatPorts(partialScan.Unchecked))
+		outcome.AddError(fmt.Sprintf("scan incomplete: %v; not checked: %s", partialScan.Err, formatPorts(partialScan.Unchecked)))
+		portsToScan = withoutPorts(portsToScan, partialScan.Unchecked)
+		err = nil
+	}
+	if err != nil {
+		if err == context.Canceled {
+			log.Log(log.INFO, "operation cancelled")
+			os.Exit(130) // Standard exit code for SIGINT
+		}
+		log.Log(log.FAIL, "Failed to scan ports: %v", err)
+		os.Exit(1)
+	}
+
+	previousScan := rememberPortScan(portsToScan, processes)
+	if flags["diff"] {
+		showPortDiff(previousScan, portsToScan, processes, jsonOutput)
+		return
+	}
+
+	if iface, ok := flagValues["interface"]; ok {
+		processes, err = ports.FilterByInterface(processes, iface)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid --interface: %v", err)
+			os.Exit(1)
+		}
+		log.VerboseLog("%d processes listening on interface %s", len(processes), iface)
+	}
+
+	if format != "" {
+		printLauncherPorts(format, cfg, processes)
+		return
+	}
+	if flags["list"] {
+		if dockerEnabled(flags, flagValues) {
+			if err := ports.AttachContainers(ctx, processes); err != nil {
+				log.Log(log.SKIP, "can't map ports to containers: %v", err)
+			}
+		}
+		printPortList(cfg, processes, jsonOutput)
+		return
+	}
+
+	if len(processes) == 0 {
+		if jsonOutput {
+			printPortsJSON(cfg, nil, nil, nil, outcome)
+		} else if flags["kill"] {
+			log.Log(log.OK, "nothing is listening on %s", formatPorts(portsToScan))
+		} else if flags["all"] {
+			log.Log(log.OK, "no processes found listening on any port")
+		} else {
+			log.Log(log.OK, "no processes found on common development ports")
+		}
+		return
+	}
+
+	log.VerboseLog("found %d processes on scanned ports", len(processes))
+
+	// --docker: ports published by containers are freed by stopping the container, since
+	// killing Docker's forwarder leaves the container running
+	docker := dockerEnabled(flags, flagValues)
+	if docker {
+		if err := ports.AttachContainers(ctx, processes); err != nil {
+			log.Log(log.SKIP, "can't map ports to containers: %v", err)
+			outcome.AddError(fmt.Sprintf("can't map ports to containers: %v", err))
+		}
+	}
+
+	// Remove duplicate processes (same PID can appear on multiple ports)
+	seenPIDs := make(map[int]bool)
+	var uniqueProcesses []ports.ProcessInfo
+	for _, proc := range processes {
+		if !seenPIDs[proc.PID] {
+			seenPIDs[proc.PID] = true
+			uniqueProcesses = append(uniqueProcesses, proc)
+		} else {
+			log.VerboseLog("skipping duplicate PID %d", proc.PID)
+		}
+	}
+
+	if len(uniqueProcesses) != len(processes) {
+		log.VerboseLog("removed %d duplicate process entries", len(processes)-len(uniqueProcesses))
+	}
+
+	attempted := make(map[int]bool)
+	spared := make(map[int]bool)
+	if jsonOutput {
+		defer func() { printPortsJSON(cfg, uniqueProcesses, attempted, spared, outcome) }()
+	}
+	// terminate kills procs, or stops the containers they forward ports for (with
+	// --dry-run, says it would), and remembers the attempt
+	terminate := func(procs []ports.ProcessInfo) int {
+		var processes, forwarders []ports.ProcessInfo
+		for _, proc := range procs {
+			attempted[proc.PID] = true
+			if proc.Container != nil {
+				forwarders = append(forwarders, proc)
+			} else {
+				processes = append(processes, proc)
+			}
+		}
+		if dryRun {
+			for _, proc := range procs {
+				if proc.Container != nil {
+					log.Log(log.STOP, "container %s (would stop, frees %s)", proc.Container.Name, proc.PortLabel())
+				} else {
+					log.Log(log.STOP, "PID %d (would terminate)", proc.PID)
+				}
+			}
+			return len(procs)
+		}
+		return killProcesses(ctx, cfg, processes) + stopContainers(ctx, forwarders)
+	}
+
+	var safeToKill []ports.ProcessInfo
+	var needsConfirmation []ports.ProcessInfo
+	var currentProject []ports.ProcessInfo
+	var containers []ports.ProcessInfo
+	var ignored []ports.ProcessInfo
+	var skipped []ports.ProcessInfo
+
+	// Processes of the project zap is run from are most likely the ones being worked on
+	currentProjectRoot := ""
+	if cfg.ProtectsCurrentProject() {
+		if cwd, err := os.Getwd(); err == nil {
+			currentProjectRoot = ports.FindProjectRoot(cwd)
+		}
+	}
+
+	dockerHinted := false
+	for _, proc := range uniqueProcesses {
+		if reason := cfg.ProtectionReason(proc); reason != "" {
+			log.Log(log.SKIP, "%s PID %d (%s) protected", proc.PortLabel(), proc.PID, proc.Name)
+			explain("%s", reason)
+			skipped = append(skipped, proc)
+			continue
+		}
+		if cfg.IsProcessIgnored(proc.Cmd, proc.WorkingDir) {
+			log.Log(log.SKIP, "%s PID %d (%s) ignored [%s]", proc.PortLabel(), proc.PID, proc.Name, truncateString(proc.WorkingDir, 40))
+			ignored = append(ignored, proc)
+			explain("command and working directory match an ignored process (zap config ignored list)")
+			continue
+		}
+
+		log.VerboseLog("%s PID %d bound to %s", proc.PortLabel(), proc.PID, proc.BindAddress)
+		if proc.Executable != "" {
+			log.VerboseLog("%s PID %d runs %s", proc.PortLabel(), proc.PID, proc.Executable)
+		}
+
+		// Format process info - always show command and working directory
+		runtimeStr := formatRuntime(proc.Runtime)
+		if arch := proc.ArchLabel(); arch != "" {
+			runtimeStr += ", " + arch
+		}
+		procInfo := fmt.Sprintf("%s PID %d (%s) [%s]", proc.PortLabel(), proc.PID, proc.Name, runtimeStr)
+
+		// Always show command preview so user knows what they're killing
+		if proc.Cmd != "" {
+			cmdPreview := truncateString(proc.Cmd, 60)
+			procInfo += fmt.Sprintf(" - %s", cmdPreview)
+		} else {
+			procInfo += " - (command not available)"
+		}
+
+		// Always show working directory
+		if proc.WorkingDir != "" {
+			procInfo += fmt.Sprintf(" [%s]", truncateString(proc.WorkingDir, 40))
+		}
+
+		if proc.Container != nil {
+			containers = append(containers, proc)
+			log.Log(log.FOUND, procInfo+fmt.Sprintf(" (container %s, %s)", proc.Container.Name, proc.Container.Image))
+			explain("forwards a port published by container %s, freed with docker stop after asking", proc.Container.Name)
+		} else if ports.InProject(proc, currentProjectRoot) {
+			currentProject = append(currentProject, proc)
+			log.Log(log.FOUND, procInfo+" (current project)")
+			explain("runs in the current project %s, always asks before terminating (protect_current_project)", currentProjectRoot)
+		} else if keyword := ports.InfrastructureReason(proc); keyword != "" {
+			needsConfirmation = append(needsConfirmation, proc)
+			log.Log(log.FOUND, procInfo)
+			explain("infrastructure: matched keyword %q, always asks before terminating", keyword)
+		} else if reason := ports.SafeDevServerReason(proc); reason != "" {
+			safeToKill = append(safeToKill, proc)
+			log.Log(log.FOUND, procInfo)
+			explain("safe dev server: %s, terminated without asking under --yes or auto_confirm_safe_actions", reason)
+		} else {
+			needsConfirmation = append(needsConfirmation, proc)
+			log.Log(log.FOUND, procInfo)
+			explain("unknown: no dev server or infrastructure rule matched name %q or command, asks before terminating", proc.Name)
+		}
+
+		if proc.Container == nil && !docker && ports.IsDockerForwarder(proc) && !dockerHinted {
+			log.Log(log.INFO, "  %s is forwarded by Docker; killing the forwarder leaves the container running (use --docker to stop the container instead)", proc.PortLabel())
+			dockerHinted = true
+		}
+
+		if flags["probe"] && proc.Protocol != ports.ProtocolUDP {
+			if result, err := ports.Probe(ctx, proc); err == nil {
+				log.Log(log.INFO, "  probe: %s", result)
+			} else {
+				log.Log(log.INFO, "  probe: %v", err)
+			}
+		}
+	}
+
+	// Track actual kills
+	actualKilledCount := 0
+
+	// -i: pick individual processes instead of answering y/N per category
+	if interactive(flags) && len(safeToKill)+len(needsConfirmation)+len(currentProject)+len(containers) > 0 {
+		candidates := append(append(append(append([]ports.ProcessInfo{}, safeToKill...), needsConfirmation...), currentProject...), containers...)
+		actualKilledCount = terminate(pickProcesses(cfg, candidates, len(safeToKill)))
+		// Everything was decided in the picker
+		safeToKill, needsConfirmation, currentProject, containers = nil, nil, nil, nil
+		if actualKilledCount == 0 {
+			log.Log(log.OK, "no processes terminated")
+			return
+		}
+	}
+
+	// --kill-all: one decision for every dev server and unknown listener; the rest stays
+	swept := 0
+	if flags["kill-all"] {
+		sweep, left := sweepTargets(safeToKill, needsConfirmation)
+		left = append(append(left, containers...), currentProject...)
+		swept = len(sweep) + len(left)
+		for _, proc := range left {
+			spared[proc.PID] = true
+			log.Log(log.SKIP, "%s PID %d (%s) left running", proc.PortLabel(), proc.PID, proc.Name)
+		}
+		outcome.CountIfAny("left_running", len(left), "")
+		if len(sweep) > 0 && sweepApproved(cfg, approve, sweep, dryRun) {
+			actualKilledCount += terminate(sweep)
+		}
+		safeToKill, needsConfirmation, currentProject, containers = nil, nil, nil, nil
+	}
+
+	// Kill safe processes
+	if len(safeToKill) > 0 {
+		pids := make([]int, len(safeToKill))
+		for i, proc := range safeToKill {
+			pids[i] = proc.PID
+		}
+
+		// zap kill <port> names the port, which is confirmation enough for a dev server
+		shouldKill := approve.covers(approveSafe) || cfg.AutoConfirmSafeActions || flags["kill"]
+		if !shouldKill && !dryRun {
+			showProcessConfirmation("Safe dev servers", safeToKill)
+			log.Log(log.ACTION, "terminate %d safe dev server process(es)? (y/N): ", len(safeToKill))
+			shouldKill = confirm()
+			if !shouldKill {
+				offerToIgnore(ctx, cfg, safeToKill)
+			}
+		}
+
+		if shouldKill {
+			actualKilledCount += terminate(safeToKill)
+		}
+	}
+
+	// Handle processes that need confirmation
+	if len(needsConfirmation) > 0 {
+		pids := make([]int, len(needsConfirmation))
+		for i, proc := range needsConfirmation {
+			pids[i] = proc.PID
+		}
+
+		// --yes=<scopes> may approve infrastructure and unknown processes separately
+		var approved, ask []ports.ProcessInfo
+		for _, proc := range needsConfirmation {
+			scope := approveUnknown
+			if ports.InfrastructureReason(proc) != "" {
+				scope = approveInfrastructure
+			}
+			if approve.covers(scope) {
+				approved = append(approved, proc)
+			} else {
+				ask = append(ask, proc)
+			}
+		}
+		if len(ask) > 0 && !dryRun {
+			showProcessConfirmation("Infrastructure/unknown processes", ask)
+			log.Log(log.ACTION, "terminate %d infrastructure/unknown process(es)? (y/N): ", len(ask))
+			if confirm() {
+				approved = append(approved, ask...)
+			} else {
+				offerToIgnore(ctx, cfg, ask)
+			}
+		}
+
+		if len(approved) > 0 {
+			actualKilledCount += terminate(approved)
+		}
+	}
+
+	// Containers are stopped, not killed: ask like for infrastructure
+	if len(containers) > 0 {
+		shouldStop := approve.covers(approveContainers)
+		if !shouldStop && !dryRun {
+			showProcessConfirmation("Docker containers", containers)
+			log.Log(log.ACTION, "stop %d container(s) with docker stop? (y/N): ", countContainers(containers))
+			shouldStop = confirm()
+		}
+		if shouldStop {
+			actualKilledCount += terminate(containers)
+		}
+	}
+
+	// Processes of the current project need an explicit answer, even with --yes
+	if len(currentProject) > 0 {
+		if dryRun {
+			for _, proc := range currentProject {
+				log.Log(log.SKIP, "PID %d (current project, would ask)", proc.PID)
+			}
+		} else {
+			showProcessConfirmation("Current project", currentProject)
+			log.Log(log.ACTION, "terminate %d process(es) of the current project %s? (y/N): ", len(currentProject), currentProjectRoot)
+			if confirm() {
+				actualKilledCount += terminate(currentProject)
+			} else {
+				offerToIgnore(ctx, cfg, currentProject)
+			}
+		}
+	}
+
+	// Summary statistics - only show success if processes were actually killed
+	outcome.Count(terminatedStat, actualKilledCount, "process(es)")
+	outcome.Count("skipped", len(skipped)+len(ignored), "")
+	if actualKilledCount > 0 {
+		outcome.Print(summary.FormatText)
+		if !dryRun {
+			if err := state.RecordKills(actualKilledCount); err != nil {
+				log.VerboseLog("failed to update lifetime stats: %v", err)
+			}
+		}
+	} else {
+		// No processes were killed
+		candidates := len(safeToKill) + len(needsConfirmation) + len(currentProject) + len(containers) + swept
+		totalFound := candidates + len(skipped) + len(ignored)
+		if totalFound == 0 {
+			log.Log(log.OK, "no processes found on common development ports")
+		} else if len(ignored) > 0 && candidates == 0 {
+			log.Log(log.OK, "no processes to terminate, %d protected, %d ignored", len(skipped), len(ignored))
+		} else if len(skipped) > 0 && candidates == 0 {
+			log.Log(log.OK, "no processes to terminate, %d protected", len(skipped))
+		} else {
+			log.Log(log.OK, "no processes terminated")
+		}
+	}
+}
+
+// sweepTargets splits the candidates of --kill-all into those it terminates (safe dev
+// servers and unknown processes) and infrastructure, which it leaves running
+func sweepTargets(safe, needsConfirmation []ports.ProcessInfo) (sweep, left []ports.ProcessInfo) {
+	sweep = append(sweep, safe...)
+	for _, proc := range needsConfirmation {
+		if ports.InfrastructureReason(proc) != "" {
+			left = append(left, proc)
+		} else {
+			sweep = append(sweep, proc)
+		}
+	}
+	return sweep, left
+}
+
+// sweepApproved asks once whether to terminate everything --kill-all found, unless
+// --yes (or auto_confirm_safe_actions, for dev servers) already approves all of it
+func sweepApproved(cfg *config.Config, approve approval, sweep []ports.ProcessInfo, dryRun bool) bool {
+	if dryRun {
+		return true
+	}
+	approved := true
+	for _, proc := range sweep {
+		if ports.SafeDevServerReason(proc) != "" {
+			approved = approved && (approve.covers(approveSafe) || cfg.AutoConfirmSafeActions)
+		} else {
+			approved = approved && approve.covers(approveUnknown)
+		}
+	}
+	if approved {
+		return true
+	}
+	showProcessConfirmation("Sweep", sweep)
+	log.Log(log.ACTION, "terminate all %d process(es)? (y/N): ", len(sweep))
+	return confirm()
+}
+
+// scanTargets returns the ports (--ports, every listening port with --all, or scan_ports,
+// by default the common development ports) and protocols (--udp, --proto) to scan, and checks that they can be
+// scanned
+func scanTargets(ctx context.Context, cfg *config.Config, flags map[string]bool, flagValues map[string]string) ([]int, []string) {
+	if _, ok := flagValues["ports"]; ok && flags["all"] {
+		log.Log(log.FAIL, "--all can't be combined with --ports")
+		os.Exit(1)
+	}
+
+	// Check for custom port range
+	portsToScan := cfg.DefaultScanPorts()
+	if portsStr, ok := flagValues["ports"]; ok {
+		parsedPorts, err := ports.ParsePortRange(portsStr)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid port range: %v", err)
+			os.Exit(1)
+		}
+		portsToScan = parsedPorts
+		log.VerboseLog("scanning custom port range: %v", portsToScan)
+	}
+
+	protocols := []string{ports.ProtocolTCP}
+	if flags["udp"] {
+		protocols = append(protocols, ports.ProtocolUDP)
+	}
+	if value, ok := flagValues["proto"]; ok {
+		parsed, err := ports.ParseProtocols(value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid --proto: %v", err)
+			os.Exit(1)
+		}
+		protocols = parsed
+	}
+
+	// Check that the port backend can run here (on Linux, auto reads /proc directly)
+	if err := ports.CheckBackend(ports.BackendFrom(ctx)); err != nil {
+		log.Log(log.FAIL, "Cannot scan ports: %v", err)
+		os.Exit(1)
+	}
+	if flags["all"] {
+		portsToScan = listeningPorts(ctx, protocols)
+	}
+	return portsToScan, protocols
+}
+
+// listeningPorts returns every port something listens on, for --all
+func listeningPorts(ctx context.Context, protocols []string) []int {
+	found, err := ports.ListeningPorts(ctx, protocols)
+	if err != nil {
+		log.Log(log.FAIL, "Failed to list listening ports: %v", err)
+		os.Exit(1)
+	}
+	log.VerboseLog("listening ports: %v", found)
+	return found
+}
+
+// killProcesses terminates processes that are still running and returns how many were stopped
+func killProcesses(ctx context.Context, cfg *config.Config, procs []ports.ProcessInfo) int {
+	killed := 0
+	for _, proc := range procs {
+		// Verify process is still running before attempting kill
+		if !ports.IsProcessRunning(proc.PID) {
+			log.VerboseLog("PID %d no longer running, skipping", proc.PID)
+			continue
+		}
+
+		// Use verification to prevent PID reuse race condition
+		policy := escalationFor(cfg, proc)
+		children := ports.Descendants(ctx, proc.PID)
+		err := ports.KillProcessWithVerification(proc.PID, proc, policy)
+		detail := ""
+		if err != nil && ports.IsPermissionError(err) && cfg.AllowSudo {
+			if ports.SudoAvailable(ctx) {
+				log.Log(log.ACTION, "PID %d: %v - retrying with sudo (allow_sudo)", proc.PID, err)
+				err = ports.KillProcessWithSudo(ctx, proc.PID, policy)
+				detail = "via sudo"
+			} else {
+				log.VerboseLog("allow_sudo is set, but sudo needs a password; not retrying PID %d", proc.PID)
+			}
+		}
+		if err != nil {
+			log.Log(log.FAIL, "Failed to kill PID %d: %v", proc.PID, err)
+			recordKill(proc, journal.ResultFailed, strings.TrimSpace(detail+" "+err.Error()))
+			// Continue with other processes
+		} else {
+			// Verify it was actually killed and port is free
+			if !ports.IsProcessRunning(proc.PID) {
+				if detail != "" {
+					log.Log(log.STOP, "PID %d (%s)", proc.PID, detail)
+				} else {
+					log.Log(log.STOP, "PID %d", proc.PID)
+				}
+				killed++
+				recordKill(proc, journal.ResultOK, detail)
+
+				// Verify port is actually free: children that outlived their parent may still hold it
+				time.Sleep(100 * time.Millisecond) // Brief delay for port release
+				stillInUse := ports.IsPortInUse
+				if proc.Protocol == ports.ProtocolUDP {
+					stillInUse = ports.IsUDPPortInUse
+				}
+				if stillInUse(proc.Port) {
+					killed += killOrphanedChildren(ctx, cfg, proc, children)
+				}
+			} else {
+				log.Log(log.FAIL, "PID %d still running after kill attempt", proc.PID)
+				recordKill(proc, journal.ResultFailed, "still running after kill attempt")
+			}
+		}
+	}
+	return killed
+}
+
+// stopContainers frees the ports of Docker forwarders by stopping their containers, once
+// per container; it returns how many of the forwarded ports were freed
+func stopContainers(ctx context.Context, forwarders []ports.ProcessInfo) int {
+	stopped := make(map[string]error)
+	freed := 0
+	for _, proc := range forwarders {
+		container := *proc.Container
+		err, done := stopped[container.ID]
+		if !done {
+			err = ports.StopContainer(ctx, container)
+			stopped[container.ID] = err
+			if err != nil {
+				log.Log(log.FAIL, "Failed to stop container %s: %v", container.Name, err)
+			} else {
+				log.Log(log.STOP, "container %s (%s)", container.Name, container.Image)
+			}
+		}
+		if err != nil {
+			recordKill(proc, journal.ResultFailed, fmt.Sprintf("docker stop %s: %v", container.Name, err))
+			continue
+		}
+		recordKill(proc, journal.ResultOK, "stopped container "+container.Name)
+		freed++
+	}
+	return freed
+}
+
+// countContainers returns how many distinct containers forwarders belong to
+func countContainers(forwarders []ports.ProcessInfo) int {
+	ids := make(map[string]bool)
+	for _, proc := range forwarders {
+		ids[proc.Container.ID] = true
+	}
+	return len(ids)
+}
+
+// dockerEnabled reports whether --docker asks to map ports to containers; --docker=false
+// turns it off again, e.g. after an alias
+func dockerEnabled(flags map[string]bool, flagValues map[string]string) bool {
+	if value, ok := flagValues["docker"]; ok {
+		// Anything else is the next argument, not a value (`zap kill --docker 5432`)
+		if enabled, err := strconv.ParseBool(value); err == nil {
+			return enabled
+		}
+	}
+	return flags["docker"]
+}
+
+// killOrphanedChildren finishes the job when a process died but children it spawned were
+// re-parented and keep its port; it returns how many of them were terminated
+func killOrphanedChildren(ctx context.Context, cfg *config.Config, parent ports.ProcessInfo, children map[int]bool) int {
+	survivors, err := ports.SurvivingChildren(ctx, parent.Port, parent.Protocol, children)
+	if err != nil {
+		log.VerboseLog("could not check port %d for surviving children: %v", parent.Port, err)
+		return 0
+	}
+	if len(survivors) == 0 {
+		log.VerboseLog("Port %d immediately reused by another process", parent.Port)
+		return 0
+	}
+
+	killed := 0
+	for _, child := range survivors {
+		log.Log(log.FOUND, ":%d still held by PID %d (%s), orphaned child of PID %d", child.Port, child.PID, child.Name, parent.PID)
+		detail := fmt.Sprintf("orphaned child of PID %d", parent.PID)
+		if err := ports.KillProcessWithVerification(child.PID, child, escalationFor(cfg, child)); err != nil {
+			log.Log(log.FAIL, "Failed to kill PID %d: %v", child.PID, err)
+			recordKill(child, journal.ResultFailed, detail+": "+err.Error())
+			continue
+		}
+		log.Log(log.STOP, "PID %d (%s)", child.PID, detail)
+		recordKill(child, journal.ResultOK, detail)
+		killed++
+	}
+	return killed
+}
+
+// escalationFor returns the signal escalation configured for the class of proc
+// (signal_escalation), falling back to SIGTERM then SIGKILL
+func escalationFor(cfg *config.Config, proc ports.ProcessInfo) ports.Escalation {
+	if killPolicy != nil {
+		log.VerboseLog("PID %d: %s", proc.PID, killPolicy)
+		return killPolicy
+	}
+	class := "unknown"
+	if ports.InfrastructureReason(proc) != "" {
+		class = "infrastructure"
+	} else if ports.SafeDevServerReason(proc) != "" {
+		class = "safe"
+	}
+	policy, err := ports.ParseEscalation(cfg.EscalationFor(class))
+	if err != nil {
+		log.VerboseLog("ignoring signal_escalation for %s: %v", class, err)
+		return ports.DefaultEscalation()
+	}
+	log.VerboseLog("PID %d (%s): %s", proc.PID, class, policy)
+	return policy
+}
+
+// killPolicy is set by --signal and --timeout; it replaces signal_escalation for the run
+var killPolicy ports.Escalation
+
+// parseKillPolicy returns the escalation --signal and --timeout ask for: the signal
+// (TERM by default), then SIGKILL after the timeout (3s by default); nil without them
+func parseKillPolicy(flagValues map[string]string) ports.Escalation {
+	signal, hasSignal := flagValues["signal"]
+	timeout, hasTimeout := flagValues["timeout"]
+	if !hasSignal && !hasTimeout {
+		return nil
+	}
+	if !hasSignal {
+		signal = "TERM"
+	}
+	grace := ports.GracefulTerminationTimeout
+	if hasTimeout {
+		var err error
+		if grace, err = time.ParseDuration(timeout); err != nil || grace <= 0 {
+			log.Log(log.FAIL, "Invalid --timeout: %s (use e.g. 10s)", timeout)
+			os.Exit(1)
+		}
+	}
+	policy, err := ports.SignalEscalation(signal, grace)
+	if err != nil {
+		log.Log(log.FAIL, "Invalid --signal: %v", err)
+		os.Exit(1)
+	}
+	return policy
+}
+
+// offerToIgnore asks whether declined processes should be left out of future prompts
+func offerToIgnore(ctx context.Context, cfg *config.Config, procs []ports.ProcessInfo) {
+	var ignorable []ports.ProcessInfo
+	for _, proc := range procs {
+		// Without a command line there's nothing stable to recognise the process by
+		if proc.Cmd != "" {
+			ignorable = append(ignorable, proc)
+		}
+	}
+	if len(ignorable) == 0 {
+		return
+	}
+
+	log.Log(log.ACTION, "don't ask about these %d process(es) again? (y/N): ", len(ignorable))
+	if !confirm() {
+		return
+	}
+	for _, proc := range ignorable {
+		if err := cfg.IgnoreProcess(ctx, proc.Name, proc.Cmd, proc.WorkingDir); err != nil {
+			log.Log(log.FAIL, "Failed to save ignored process: %v", err)
+			return
+		}
+	}
+	log.Log(log.OK, "ignoring %d process(es); undo with: zap config ignored remove <number>", len(ignorable))
+}
+
+// recordKill writes a terminated process to the journal and prints its RECORD line
+func recordKill(proc ports.ProcessInfo, result, detail string) {
+	entry := journal.Entry{
+		Action: journal.ActionKill,
+		Target: fmt.Sprintf("PID %d (%s) %s", proc.PID, proc.Name, proc.PortLabel()),
+		Result: result,
+		Detail: detail,
+	}
+	recordAction(entry)
+}
+
+// showProcessConfirmation displays detailed information about processes before asking for confirmation
+func showProcessConfirmation(category string, processes []ports.ProcessInfo) {
+	fmt.Fprintln(log.Writer())
+	fmt.Fprintf(log.Writer(), "  %s (%d):\n", category, len(processes))
+	for i, proc := range processes {
+		runtimeStr := formatRuntime(proc.Runtime)
+		if arch := proc.ArchLabel(); arch != "" {
+			runtimeStr += ", " + arch
+		}
+		cmdPreview := truncateString(proc.Cmd, 50)
+		dirPreview := truncateString(proc.WorkingDir, 35)
+
+		fmt.Fprintf(log.Writer(), "    %d. %s PID %d (%s) [%s]", i+1, proc.PortLabel(), proc.PID, proc.Name, runtimeStr)
+		if proc.Container != nil {
+			fmt.Fprintf(log.Writer(), " - container %s (%s)", proc.Container.Name, proc.Container.Image)
+			cmdPreview = ""
+		}
+		if cmdPreview != "" {
+			fmt.Fprintf(log.Writer(), " - %s", cmdPreview)
+		}
+		if dirPreview != "" {
+			fmt.Fprintf(log.Writer(), " [%s]", dirPreview)
+		}
+		fmt.Fprintln(log.Writer())
+	}
+	fmt.Fprintln(log.Writer())
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+)
+
+// projectTotal is what a cleanup freed in one project
+type projectTotal struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Bytes int64  `json:"size_bytes"`
+	// Directories are the deleted directories, relative to Path
+	Directories []string `json:"directories"`
+}
+
+// groupByProject rolls directories up by the project they belong to, biggest first.
+// A directory's project is the nearest enclosing directory with a project marker
+// (.git, package.json, go.mod...), else its parent.
+func groupByProject(dirs []cleanup.DirectoryInfo) []projectTotal {
+	byRoot := make(map[string]int)
+	totals := []projectTotal{}
+	for _, dir := range dirs {
+		parent := filepath.Dir(dir.Path)
+		root := ports.FindProjectRoot(parent)
+		if root == "" {
+			root = parent
+		}
+		i, ok := byRoot[root]
+		if !ok {
+			i = len(totals)
+			byRoot[root] = i
+			totals = append(totals, projectTotal{Name: filepath.Base(root), Path: root, Directories: []string{}})
+		}
+		rel, err := filepath.Rel(root, dir.Path)
+		if err != nil {
+			rel = dir.Path
+		}
+		totals[i].Bytes += dir.Size
+		totals[i].Directories = append(totals[i].Directories, rel)
+	}
+	sort.SliceStable(totals, func(i, j int) bool { return totals[i].Bytes > totals[j].Bytes })
+	return totals
+}
+
+// printProjectTotals logs one line per project, e.g.
+// "myapp: 1.2 GiB across node_modules, .next, coverage"; projects sharing a name are
+// told apart by their path
+func printProjectTotals(dirs []cleanup.DirectoryInfo) {
+	totals := groupByProject(dirs)
+	names := make(map[string]int)
+	for _, total := range totals {
+		names[total.Name]++
+	}
+	for _, total := range totals {
+		label := total.Name
+		if names[label] > 1 {
+			label = total.Path
+		}
+		log.Log(log.STATS, "%s: %s across %s", label, cleanup.FormatSize(total.Bytes), strings.Join(total.Directories, ", "))
+	}
+}
+
+// freedDirs returns the directories of dirs that were deleted or moved to the trash
+func freedDirs(dirs []cleanup.DirectoryInfo, outcomes map[string]directoryResult) []cleanup.DirectoryInfo {
+	var freed []cleanup.DirectoryInfo
+	for _, dir := range dirs {
+		switch outcomes[dir.Path].Action {
+		case actionDeleted, actionTrashed:
+			freed = append(freed, dir)
+		}
+	}
+	return freed
+}
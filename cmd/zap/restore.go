@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/journal"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/summary"
+)
+
+var restoreCommand = &command{
+	spec: commandSpec{
+		Name: "restore", Description: "Move the directories of the last cleanup --trash back out of the trash",
+		Flags: withCommon("dry-run"),
+	},
+	readOnly: func(args []string) bool { return hasArg(args, "--dry-run") },
+	run: func(ctx context.Context, inv *invocation) {
+		handleRestore(ctx, inv.dryRun, inv.jsonOutput)
+	},
+}
+
+// What happened to a trashed directory, in restoreResult
+const (
+	actionRestored     = "restored"
+	actionWouldRestore = "would_restore" // --dry-run
+)
+
+type restoredDirectory struct {
+	Path      string `json:"path"`
+	TrashPath string `json:"trash_path"`
+	Bytes     int64  `json:"size_bytes"`
+	Action    string `json:"action"`
+	Error     string `json:"error,omitempty"`
+}
+
+type restoreResult struct {
+	Directories []restoredDirectory `json:"directories"`
+	Restored    int                 `json:"restored"`
+	Failed      int                 `json:"failed"`
+	DryRun      bool                `json:"dry_run"`
+	Summary     *summary.Summary    `json:"summary"`
+}
+
+// handleRestore moves the directories the most recent `zap cleanup --trash` run put in
+// the trash back where they were. Directories that have been recreated since (e.g. a
+// reinstalled node_modules) are left alone, and so is the trashed copy.
+func handleRestore(ctx context.Context, dryRun, jsonOutput bool) {
+	entries, err := journal.Read()
+	if err != nil {
+		log.Log(log.FAIL, "Failed to read journal: %v", err)
+		os.Exit(1)
+	}
+	batch := journal.LastTrashBatch(entries)
+
+	outcome := summary.New("restore", dryRun)
+	runSummary = outcome
+	result := restoreResult{Directories: []restoredDirectory{}, DryRun: dryRun, Summary: outcome}
+	if jsonOutput {
+		defer func() {
+			outcome.Count("restored", result.Restored, "directories")
+			outcome.CountIfAny("failed", result.Failed, "")
+			outcome.Finish()
+			data, _ := json.Marshal(result)
+			fmt.Println(string(data))
+		}()
+	}
+	if len(batch) == 0 {
+		log.Log(log.OK, "nothing to restore - no directories moved to the trash by zap cleanup --trash")
+		return
+	}
+
+	var size int64
+	for _, entry := range batch {
+		size += entry.Bytes
+	}
+	log.Log(log.FOUND, "%d directories (%s) trashed by the cleanup --trash of %s", len(batch), cleanup.FormatSize(size), batch[0].Time.Format("2006-01-02 15:04:05"))
+
+	for _, entry := range batch {
+		dir := restoredDirectory{Path: entry.Target, TrashPath: entry.TrashPath, Bytes: entry.Bytes}
+		switch {
+		case ctx.Err() != nil:
+			log.Log(log.INFO, "operation cancelled, remaining directories left in the trash")
+			return
+		case dryRun:
+			log.Log(log.INFO, "%s (would restore)", entry.Target)
+			dir.Action = actionWouldRestore
+		default:
+			if err := cleanup.RestoreFromTrash(entry.TrashPath, entry.Target); err != nil {
+				log.Log(log.FAIL, "Failed to restore %s: %v", entry.Target, err)
+				dir.Action, dir.Error = actionFailed, err.Error()
+				result.Failed++
+				recordRestore(entry, journal.ResultFailed, err.Error())
+				break
+			}
+			log.Log(log.OK, "restored %s", entry.Target)
+			dir.Action = actionRestored
+			result.Restored++
+			recordRestore(entry, journal.ResultOK, "")
+		}
+		result.Directories = append(result.Directories, dir)
+	}
+
+	if dryRun {
+		log.Log(log.INFO, "would restore %d directories (dry run)", len(batch))
+		return
+	}
+	outcome.Count("restored", result.Restored, "directories")
+	outcome.CountIfAny("failed", result.Failed, "")
+	outcome.Print(summary.FormatText)
+}
+
+// recordRestore writes a restore to the journal, so the directory isn't restored twice
+func recordRestore(trashed journal.Entry, result, detail string) {
+	entry := journal.Entry{
+		Action:    journal.ActionRestore,
+		Target:    trashed.Target,
+		Result:    result,
+		Detail:    detail,
+		TrashPath: trashed.TrashPath,
+		Batch:     trashed.Batch,
+	}
+	recordAction(entry)
+}
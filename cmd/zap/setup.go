@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/mattn/go-isatty"
+)
+
+var setupCommand = &command{
+	spec: commandSpec{
+		Name: "setup", Description: "Shell integration",
+		Subcommands: []commandSpec{
+			{Name: "path", Description: "Add the Go bin directory to your shell PATH", Flags: []string{"remove", "yes"}},
+		},
+	},
+	run: func(ctx context.Context, inv *invocation) {
+		handleSetup(inv.positional, inv.yes, inv.flags)
+	},
+}
+
+// handleSetup runs explicit environment setup steps (currently only "path")
+func handleSetup(args []string, yes bool, flags map[string]bool) {
+	if len(args) == 0 || args[0] != "path" {
+		log.Log(log.FAIL, "Usage: zap setup path [--yes] [--remove]")
+		os.Exit(1)
+	}
+
+	if flags["remove"] {
+		if err := removePathSetup(); err != nil {
+			log.Log(log.FAIL, "Failed to remove PATH setup: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	goBinPath := determineGoBinPath()
+	if strings.Contains(os.Getenv("PATH"), goBinPath) {
+		log.Log(log.OK, "%s is already in PATH", goBinPath)
+		return
+	}
+	if err := setupPath(goBinPath, !yes); err != nil {
+		log.Log(log.FAIL, "PATH setup failed: %v", err)
+		os.Exit(1)
+	}
+}
+
+// checkPathSetup offers to add the Go bin directory to PATH when zap is installed there
+// but not reachable, according to the path_setup config (never touches rc files by default)
+func checkPathSetup(cfg *config.Config) {
+	if cfg.PathSetup == config.PathSetupNever {
+		return
+	}
+	goBinPath, needed := pathSetupNeeded()
+	if !needed {
+		return
+	}
+
+	// Never block a non-interactive run on a prompt
+	prompt := cfg.PathSetup == config.PathSetupPrompt
+	if prompt && !isatty.IsTerminal(os.Stdin.Fd()) {
+		return
+	}
+
+	log.Log(log.INFO, "zap is installed but not in PATH")
+	if err := setupPath(goBinPath, prompt); err != nil {
+		log.VerboseLog("PATH setup failed: %v", err)
+	}
+}
+
+// pathSetupNeeded reports whether zap is installed in the Go bin directory but can't be
+// run by name because that directory isn't on PATH
+func pathSetupNeeded() (string, bool) {
+	goBinPath := determineGoBinPath()
+	if execx.Available("zap") {
+		return goBinPath, false
+	}
+	if _, err := os.Stat(filepath.Join(goBinPath, "zap")); err != nil || strings.Contains(os.Getenv("PATH"), goBinPath) {
+		return goBinPath, false
+	}
+	return goBinPath, true
+}
+
+// determineGoBinPath determines where Go installs binaries
+func determineGoBinPath() string {
+	goBinPath := os.Getenv("GOBIN")
+	if goBinPath != "" {
+		return goBinPath
+	}
+	gopath := os.Getenv("GOPATH")
+	if gopath != "" {
+		return filepath.Join(gopath, "bin")
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, "go", "bin")
+}
+
+// setupPath configures PATH for the user's shell, asking first when prompt is true.
+// zap's lines are kept in a delimited block that is updated in place and can be
+// removed again with `zap setup path --remove`.
+func setupPath(goBinPath string, prompt bool) error {
+	// Check if already in PATH
+	currentPath := os.Getenv("PATH")
+	if strings.Contains(currentPath, goBinPath) {
+		return nil // Already configured
+	}
+
+	homeDir, err := paths.HomeDir()
+	if err != nil {
+		return err
+	}
+
+	shellName := detectShellName()
+
+	// On Windows the user PATH lives in the registry and is shared by every shell
+	// (nushell keeps its own env.nu, so it is configured like on Unix)
+	if runtime.GOOS == "windows" && shellName != "nu" {
+		if prompt {
+			fmt.Println()
+			log.Log(log.ACTION, "add %s to your user PATH? (y/N): ", goBinPath)
+			if !confirm() {
+				showPathInstructions(goBinPath, shellName)
+				return nil
+			}
+		}
+		return addWindowsUserPath(goBinPath)
+	}
+
+	configFile, ok := shellConfigFile(shellName, homeDir)
+	if !ok {
+		// Unknown shell, provide instructions instead
+		log.Log(log.INFO, "detected shell: %s (not automatically configurable)", shellName)
+		showPathInstructions(goBinPath, shellName)
+		return nil
+	}
+
+	existingContent, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	block := pathBlock(shellName, goBinPath)
+	current, hasBlock := findManagedBlock(string(existingContent))
+	if hasBlock && current == block {
+		log.Log(log.INFO, "PATH already configured in %s", configFile)
+		log.Log(log.INFO, "run 'source %s' or restart your terminal to use zap", configFile)
+		return nil
+	}
+	if !hasBlock && !hasLegacyPathLines(string(existingContent)) && strings.Contains(string(existingContent), goBinPath) {
+		// Configured by the user themselves - leave their file alone
+		log.Log(log.INFO, "PATH already configured in %s", configFile)
+		log.Log(log.INFO, "run 'source %s' or restart your terminal to use zap", configFile)
+		return nil
+	}
+
+	// Ask user if they want to add it
+	if prompt {
+		fmt.Println()
+		log.Log(log.ACTION, "add %s to PATH in %s? (y/N): ", goBinPath, configFile)
+		if !confirm() {
+			showPathInstructions(goBinPath, shellName)
+			return nil
+		}
+	}
+
+	if err := writeManagedBlock(configFile, block); err != nil {
+		return err
+	}
+
+	if hasBlock {
+		log.Log(log.OK, "updated PATH entry for %s in %s", goBinPath, configFile)
+	} else {
+		log.Log(log.OK, "added %s to PATH in %s", goBinPath, configFile)
+	}
+	log.Log(log.INFO, "run 'source %s' or restart your terminal to use zap", configFile)
+	log.Log(log.INFO, "undo with: zap setup path --remove")
+
+	return nil
+}
+
+// removePathSetup strips zap's PATH block (and lines written by older versions) from
+// every shell config file zap may have edited
+func removePathSetup() error {
+	homeDir, err := paths.HomeDir()
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	if runtime.GOOS == "windows" {
+		changed, err := removeWindowsUserPath(determineGoBinPath())
+		if err != nil {
+			return err
+		}
+		if changed {
+			log.Log(log.OK, "removed %s from your user PATH", determineGoBinPath())
+			removed++
+		}
+	}
+	for _, configFile := range allShellConfigFiles(homeDir) {
+		changed, err := removeManagedBlock(configFile)
+		if err != nil {
+			return err
+		}
+		if changed {
+			log.Log(log.OK, "removed zap PATH entry from %s", configFile)
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		log.Log(log.OK, "no zap PATH entries found")
+	} else {
+		log.Log(log.INFO, "restart your terminal for the change to take effect")
+	}
+	return nil
+}
+
+// detectShellName returns the base name of the user's login shell
+func detectShellName() string {
+	// nushell sets $NU_VERSION for child processes but doesn't change $SHELL
+	if os.Getenv("NU_VERSION") != "" {
+		return "nu"
+	}
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		if runtime.GOOS == "windows" {
+			if os.Getenv("PSModulePath") != "" {
+				return "pwsh"
+			}
+			return "cmd"
+		}
+		// Fallback: try to detect from common shells
+		shell = "/bin/bash" // Default fallback
+	}
+	return strings.TrimSuffix(filepath.Base(shell), ".exe")
+}
+
+// shellConfigFile returns the rc file zap edits for a shell
+func shellConfigFile(shellName, homeDir string) (string, bool) {
+	switch shellName {
+	case "bash":
+		// Try .bash_profile first (macOS), then .bashrc (Linux)
+		configFile := filepath.Join(homeDir, ".bashrc")
+		if runtime.GOOS == "darwin" {
+			configFile = filepath.Join(homeDir, ".bash_profile")
+		}
+		// Fallback to .bashrc if .bash_profile doesn't exist
+		if _, err := os.Stat(configFile); os.IsNotExist(err) {
+			configFile = filepath.Join(homeDir, ".bashrc")
+		}
+		return configFile, true
+	case "zsh":
+		return filepath.Join(homeDir, ".zshrc"), true
+	case "fish":
+		return filepath.Join(homeDir, ".config", "fish", "config.fish"), true
+	case "pwsh", "powershell":
+		return powershellProfile(homeDir), true
+	case "nu", "nushell":
+		return nushellEnvFile(homeDir), true
+	default:
+		return "", false
+	}
+}
+
+// powershellProfile returns the CurrentUserCurrentHost profile ($PROFILE) path
+func powershellProfile(homeDir string) string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(homeDir, "Documents", "PowerShell", "Microsoft.PowerShell_profile.ps1")
+	}
+	return filepath.Join(homeDir, ".config", "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
+// nushellEnvFile returns the path of nushell's env.nu ($nu.env-path)
+func nushellEnvFile(homeDir string) string {
+	switch runtime.GOOS {
+	case "darwin":
+		return filepath.Join(homeDir, "Library", "Application Support", "nushell", "env.nu")
+	case "windows":
+		return filepath.Join(homeDir, "AppData", "Roaming", "nushell", "env.nu")
+	default:
+		return filepath.Join(homeDir, ".config", "nushell", "env.nu")
+	}
+}
+
+// allShellConfigFiles lists every rc file zap may have written to, for removal
+func allShellConfigFiles(homeDir string) []string {
+	return []string{
+		filepath.Join(homeDir, ".bash_profile"),
+		filepath.Join(homeDir, ".bashrc"),
+		filepath.Join(homeDir, ".zshrc"),
+		filepath.Join(homeDir, ".config", "fish", "config.fish"),
+		powershellProfile(homeDir),
+		filepath.Join(homeDir, "Documents", "WindowsPowerShell", "Microsoft.PowerShell_profile.ps1"),
+		nushellEnvFile(homeDir),
+	}
+}
+
+func validatePath(path string) error {
+	if path == "" {
+		return fmt.Errorf("path cannot be empty")
+	}
+
+	// Basic validation - ensure no shell injection characters
+	if strings.ContainsAny(path, "\n\r\t$`\"'\\") {
+		return fmt.Errorf("path contains invalid characters")
+	}
+
+	return nil
+}
+
+func shellEscape(s string) string {
+	// Remove any shell metacharacters and wrap in single quotes
+	escaped := strings.ReplaceAll(s, "'", "'\"'\"'")
+	return "'" + escaped + "'"
+}
+
+func showPathInstructions(goBinPath, shellName string) {
+	fmt.Println()
+	log.Log(log.INFO, "to add %s to your PATH manually:", goBinPath)
+
+	// Escape path for display
+	escapedPath := shellEscape(goBinPath)
+
+	switch shellName {
+	case "bash":
+		if runtime.GOOS == "darwin" {
+			log.Log(log.INFO, "  echo 'export PATH=\"$PATH:%s\"' >> ~/.bash_profile", escapedPath)
+			log.Log(log.INFO, "  source ~/.bash_profile")
+		} else {
+			log.Log(log.INFO, "  echo 'export PATH=\"$PATH:%s\"' >> ~/.bashrc", escapedPath)
+			log.Log(log.INFO, "  source ~/.bashrc")
+		}
+	case "zsh":
+		log.Log(log.INFO, "  echo 'export PATH=\"$PATH:%s\"' >> ~/.zshrc", escapedPath)
+		log.Log(log.INFO, "  source ~/.zshrc")
+	case "fish":
+		log.Log(log.INFO, "  echo 'set -gx PATH $PATH %s' >> ~/.config/fish/config.fish", escapedPath)
+		log.Log(log.INFO, "  source ~/.config/fish/config.fish")
+	case "pwsh", "powershell":
+		log.Log(log.INFO, "  Add-Content $PROFILE \"`$env:PATH += [IO.Path]::PathSeparator + %s\"", powershellEscape(goBinPath))
+		log.Log(log.INFO, "  . $PROFILE")
+	case "nu", "nushell":
+		log.Log(log.INFO, "  add to $nu.env-path: $env.PATH = ($env.PATH | split row (char esep) | append %s)", nushellEscape(goBinPath))
+	case "cmd":
+		log.Log(log.INFO, "  add %s to Path under System Properties > Environment Variables", goBinPath)
+	default:
+		log.Log(log.INFO, "  add %s to your PATH in your shell configuration file", goBinPath)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Markers delimiting the block zap owns inside a shell rc file
+const (
+	rcBlockBegin = "# >>> zap PATH setup >>>"
+	rcBlockEnd   = "# <<< zap PATH setup <<<"
+)
+
+// legacyPathLines matches the undelimited lines appended by older zap versions
+var legacyPathLines = regexp.MustCompile(`\n?# Added by zap - Go bin directory\n\n?export PATH=\$PATH:[^\n]*\n?`)
+
+// pathBlock renders the managed block adding goBinPath to PATH for a shell
+func pathBlock(shellName, goBinPath string) string {
+	var line string
+	switch shellName {
+	case "fish":
+		line = fmt.Sprintf("set -gx PATH $PATH %s", shellEscape(goBinPath))
+	case "pwsh", "powershell":
+		line = fmt.Sprintf("$env:PATH += [IO.Path]::PathSeparator + %s", powershellEscape(goBinPath))
+	case "nu", "nushell":
+		line = fmt.Sprintf("$env.PATH = ($env.PATH | split row (char esep) | append %s)", nushellEscape(goBinPath))
+	default:
+		line = fmt.Sprintf("export PATH=\"$PATH\":%s", shellEscape(goBinPath))
+	}
+	return rcBlockBegin + "\n" +
+		"# Managed by zap - remove with: zap setup path --remove\n" +
+		line + "\n" +
+		rcBlockEnd + "\n"
+}
+
+// findManagedBlock returns zap's block (markers included) if content has one
+func findManagedBlock(content string) (string, bool) {
+	start, end, ok := managedBlockBounds(content)
+	if !ok {
+		return "", false
+	}
+	return content[start:end], true
+}
+
+// managedBlockBounds locates the block, including the trailing newline after the end marker
+func managedBlockBounds(content string) (int, int, bool) {
+	start := strings.Index(content, rcBlockBegin)
+	if start == -1 {
+		return 0, 0, false
+	}
+	endIdx := strings.Index(content[start:], rcBlockEnd)
+	if endIdx == -1 {
+		return 0, 0, false
+	}
+	end := start + endIdx + len(rcBlockEnd)
+	if end < len(content) && content[end] == '\n' {
+		end++
+	}
+	return start, end, true
+}
+
+// powershellEscape quotes s as a PowerShell single-quoted (verbatim) string
+func powershellEscape(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// nushellEscape quotes s as a nushell raw string, which needs no escaping
+func nushellEscape(s string) string {
+	hashes := "#"
+	for strings.Contains(s, "'"+hashes) {
+		hashes += "#"
+	}
+	return "r" + hashes + "'" + s + "'" + hashes
+}
+
+func hasLegacyPathLines(content string) bool {
+	return legacyPathLines.MatchString(content)
+}
+
+// writeManagedBlock inserts or replaces zap's block in configFile, migrating any legacy
+// lines into it so repeated runs never accumulate duplicate exports
+func writeManagedBlock(configFile, block string) error {
+	existing, err := os.ReadFile(configFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	content := legacyPathLines.ReplaceAllString(string(existing), "\n")
+	if start, end, ok := managedBlockBounds(content); ok {
+		content = content[:start] + block + content[end:]
+	} else {
+		// Separate the block from existing content by exactly one blank line
+		content = strings.TrimRight(content, "\n")
+		if content != "" {
+			content += "\n\n"
+		}
+		content += block
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configFile), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(configFile), err)
+	}
+	return writeFileAtomic(configFile, []byte(content))
+}
+
+// removeManagedBlock deletes zap's block and any legacy lines; reports whether anything changed
+func removeManagedBlock(configFile string) (bool, error) {
+	existing, err := os.ReadFile(configFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read %s: %w", configFile, err)
+	}
+
+	content := string(existing)
+	if start, end, ok := managedBlockBounds(content); ok {
+		// Also drop the blank separator line we added before the block
+		if start > 0 && strings.HasSuffix(content[:start], "\n\n") {
+			start--
+		}
+		content = content[:start] + content[end:]
+	}
+	content = legacyPathLines.ReplaceAllString(content, "\n")
+
+	if content == string(existing) {
+		return false, nil
+	}
+	// Don't leave the blank lines that used to separate our lines behind
+	if strings.HasSuffix(content, "\n\n") {
+		content = strings.TrimRight(content, "\n") + "\n"
+	}
+	return true, writeFileAtomic(configFile, []byte(content))
+}
+
+// writeFileAtomic replaces path with data via a temp file, keeping the original permissions
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tempPath := path + ".zap.tmp"
+	if err := os.WriteFile(tempPath, data, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tempPath, err)
+	}
+	if err := renameFile(tempPath, path); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	return nil
+}
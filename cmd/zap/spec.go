@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+)
+
+// commandSpec describes a command for completion engines (`zap spec --json`)
+type commandSpec struct {
+	Name        string        `json:"name"`
+	Aliases     []string      `json:"aliases,omitempty"`
+	Description string        `json:"description"`
+	Args        []argSpec     `json:"args,omitempty"`
+	Subcommands []commandSpec `json:"subcommands,omitempty"`
+	Flags       []string      `json:"flags,omitempty"` // names of the flags the command honours
+}
+
+// argSpec is a positional argument
+type argSpec struct {
+	Name        string   `json:"name"`
+	Optional    bool     `json:"optional,omitempty"`
+	Variadic    bool     `json:"variadic,omitempty"` // may be repeated
+	Suggestions []string `json:"suggestions,omitempty"`
+	Dynamic     string   `json:"dynamic,omitempty"` // values listed at completion time by `zap completion values <name>`
+}
+
+// flagSpec is a flag; flags with a Value placeholder take a value (--name=value)
+type flagSpec struct {
+	Name        string   `json:"name"`
+	Short       string   `json:"short,omitempty"`
+	Description string   `json:"description"`
+	Value       string   `json:"value,omitempty"`
+	Suggestions []string `json:"suggestions,omitempty"`
+	Dynamic     string   `json:"dynamic,omitempty"` // values listed at completion time by `zap completion values <name>`
+}
+
+// cliSpec is the whole command-line interface
+type cliSpec struct {
+	Name     string        `json:"name"`
+	Commands []commandSpec `json:"commands"`
+	Flags    []flagSpec    `json:"flags"`
+}
+
+// commonFlags are honoured by every command
+var commonFlags = []string{"verbose", "json", "trace-exec", "log-format", "log-file", "color", "no-color", "size-units"}
+
+// withCommon adds commonFlags to a command's own flags
+func withCommon(flags ...string) []string {
+	return append(flags, commonFlags...)
+}
+
+var specCommand = &command{
+	spec:     commandSpec{Name: "spec", Description: "Print this command spec as JSON, for completion engines", Flags: commonFlags},
+	readOnly: always,
+	run:      func(ctx context.Context, inv *invocation) { handleSpec() },
+}
+
+func buildSpec() cliSpec {
+	categories := append(cleanup.CategoryNames(), "all")
+
+	spec := cliSpec{
+		Name: "zap",
+		Flags: []flagSpec{
+			{Name: "help", Short: "h", Description: "Show help for the command"},
+			{Name: "yes", Short: "y", Description: "Execute without confirmation; --yes=<scopes> limits it to some risk levels", Suggestions: approveScopes},
+			{Name: "dry-run", Description: "Preview actions without making changes"},
+			{Name: "verbose", Short: "v", Description: "Show detailed information (-vv for per-port scan timings)"},
+			{Name: "interactive", Short: "i", Description: "Pick individual processes or directories to act on"},
+			{Name: "json", Short: "j", Description: "Output in JSON format (for scripting)"},
+			{Name: "ports", Description: "Custom port range", Value: "range", Suggestions: []string{"3000-3010", "8080"}, Dynamic: dynamicListeningPorts},
+			{Name: "interface", Description: "Only processes listening on loopback or reachable from the network (forward: where to listen)", Value: "interface", Suggestions: []string{"lo", "all"}},
+			{Name: "concurrency", Description: "Parallel port/directory scans", Value: "n"},
+			{Name: "backend", Description: "Port scanning method (overrides port_backend)", Value: "name", Suggestions: ports.Backends},
+			{Name: "diff", Description: "Show listeners that appeared, disappeared or changed PID since the last scan"},
+			{Name: "udp", Description: "Also find processes bound to UDP ports"},
+			{Name: "proto", Description: "Protocols to scan", Value: "list", Suggestions: []string{"tcp", "udp", "tcp,udp"}},
+			{Name: "probe", Description: "Send an HTTP GET to each port and show what answered"},
+			{Name: "docker", Description: "Free ports published by Docker containers with docker stop"},
+			{Name: "watch", Description: "Keep re-scanning and report listeners as they bind and go away"},
+			{Name: "interval", Description: "Time between --watch scans (forward: between checks of the target)", Value: "duration", Suggestions: []string{"1s", "2s", "10s"}},
+			{Name: "auto-kill", Description: "With --watch, terminate new safe dev servers without asking"},
+			{Name: "signal", Description: "Signal that asks processes to stop (overrides signal_escalation)", Value: "name", Suggestions: []string{"TERM", "INT", "HUP", "KILL"}},
+			{Name: "timeout", Description: "Time to wait for processes to stop before SIGKILL (forward: for the target to come back)", Value: "duration", Suggestions: []string{"3s", "10s", "30s"}},
+			{Name: "kill-all", Description: "Terminate every dev server and unknown listener after one confirmation, leaving infrastructure running"},
+			{Name: "all", Description: "Scan every listening port instead of the common development ports"},
+			{Name: "list", Description: "List and exit without changing anything (ports: listeners with their details; config restore: the backups)"},
+			{Name: "caches", Description: "Prune npm/yarn cache entries unused for max_age_days"},
+			{Name: "category", Description: "Also clean well-known caches outside projects", Value: "names", Suggestions: categories},
+			{Name: "compare", Description: "Show what changed since the previous dry run"},
+			{Name: "estimate", Description: "Preview cleanup sizes by sampling large directories; exact sizes are measured before confirming"},
+			{Name: "older-than", Description: "Only clean directories unmodified for this long, overriding max_age_days_for_cleanup and cleanup rules", Value: "age", Suggestions: []string{"30d", "3mo", "6mo", "1y"}},
+			{Name: "trash", Description: "Move directories to the trash instead of deleting them (undo with zap restore)"},
+			{Name: "path", Description: "Scan these trees instead of the auto-detected project directories (repeatable)", Value: "dirs"},
+			{Name: "allow-outside-home", Description: "Allow --path trees outside the home directory"},
+			{Name: "format", Description: "List occupied ports/cleanup candidates for a launcher", Value: "name", Suggestions: []string{formatRaycast, formatAlfred}},
+			{Name: "ignore-power", Description: "Run unattended cleanups even on battery, in low power mode or while throttled"},
+			{Name: "include-open", Description: "Also clean projects currently open in an editor"},
+			{Name: "include-active", Description: "Also clean git repositories with uncommitted changes or recent commits"},
+			{Name: "delete-timeout", Description: "Skip a directory if deleting it takes longer", Value: "duration", Suggestions: []string{"30s", "2m", "5m"}},
+			{Name: "trace-exec", Description: "Log every external command run, with duration and exit code"},
+			{Name: "log-format", Description: "Format of log lines: text, or json for one object per line", Value: "name", Suggestions: []string{log.FormatText, log.FormatJSON}},
+			{Name: "log-file", Description: "Also append log lines to this file, without colors", Value: "file"},
+			{Name: "color", Description: "Color log lines: auto (terminal, unless NO_COLOR is set), always, never", Value: "when", Suggestions: log.ColorModes},
+			{Name: "no-color", Description: "Same as --color=never"},
+			{Name: "size-units", Description: "How sizes are shown: binary (GiB), si (GB, as df -H), bytes (overrides size_units)", Value: "units", Suggestions: cleanup.SizeUnits},
+			{Name: "explain", Description: "Show which rule classified each process/directory candidate"},
+			{Name: "fix", Description: "Repair stale binaries found by doctor"},
+			{Name: "remove", Description: "Undo the PATH setup"},
+			{Name: "projects", Description: "Synthetic projects to create", Value: "n"},
+			{Name: "files", Description: "Files per synthetic project", Value: "n"},
+			{Name: "file-size", Description: "Size of each synthetic file in bytes", Value: "bytes"},
+			{Name: "channel", Description: "Release channel to update from: stable, or beta to include pre-releases", Value: "name", Suggestions: updateChannels},
+			{Name: "i-know", Description: "cleanup --yes: allow a huge cleanup without a recent dry run"},
+			{Name: "revoke", Description: "trust: stop trusting the given trees"},
+			{Name: "output-dir", Description: "install-extras: write every extra into this directory, as release archives ship them", Value: "dir"},
+			{Name: "refresh", Description: "install-extras: only rewrite extras installed before (zap update does this)"},
+			{Name: "check", Description: "Only report whether a newer version exists"},
+			{Name: "rollback", Description: "Put back the binary the last update replaced (zap.backup)"},
+			{Name: "to", Description: "Install this version, e.g. v0.4.2; older versions are a downgrade and need confirming", Value: "version"},
+		},
+	}
+	for _, cmd := range commands {
+		spec.Commands = append(spec.Commands, cmd.Spec())
+	}
+	return spec
+}
+
+// flagDefinitions indexes the flags of the spec by name and by short name
+func flagDefinitions() map[string]flagSpec {
+	defs := make(map[string]flagSpec)
+	for _, flag := range buildSpec().Flags {
+		defs[flag.Name] = flag
+		if flag.Short != "" {
+			defs[flag.Short] = flag
+		}
+	}
+	return defs
+}
+
+// handleSpec prints the command spec; it is always JSON, --json is accepted for symmetry
+func handleSpec() {
+	data, _ := json.MarshalIndent(buildSpec(), "", "  ")
+	fmt.Println(string(data))
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/stall"
+)
+
+// watchStalls reports work under the returned context (port lookups, directory scans,
+// deletions) that makes no progress for the configured stall timeout. With someone at
+// the terminal it asks whether to skip the stuck target; unattended it says what it is
+// still waiting for and keeps waiting. stop ends the watch; it waits for a pending
+// question to be answered, so two prompts never read stdin at once.
+func watchStalls(ctx context.Context, cfg *config.Config, yes bool) (context.Context, func()) {
+	threshold := cfg.StallTimeout()
+	if threshold <= 0 {
+		return ctx, func() {}
+	}
+	prompt := !unattended(yes)
+	watchdog := stall.New(threshold, func(target string, idle time.Duration) bool {
+		idle = idle.Round(time.Second)
+		if !prompt {
+			log.Log(log.INFO, "%s has made no progress for %v, still waiting", target, idle)
+			return false
+		}
+		log.Log(log.ACTION, "%s has made no progress for %v - skip it? (y/N): ", target, idle)
+		return confirm()
+	})
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watchdog.Run(watchCtx)
+	}()
+	return stall.WithWatchdog(ctx, watchdog), func() {
+		cancel()
+		wg.Wait()
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/state"
+)
+
+var statsCommand = &command{
+	spec:     commandSpec{Name: "stats", Description: "Show space reclaimed and processes terminated over zap's lifetime", Flags: commonFlags},
+	readOnly: always,
+	run: func(ctx context.Context, inv *invocation) {
+		handleStats(inv.jsonOutput)
+	},
+}
+
+// handleStats shows zap's lifetime counters
+func handleStats(jsonOutput bool) {
+	st, err := state.Load()
+	if err != nil {
+		log.Log(log.FAIL, "Failed to load stats: %v", err)
+		os.Exit(1)
+	}
+	lifetime := st.Lifetime
+
+	if jsonOutput {
+		data, _ := json.Marshal(lifetime)
+		fmt.Println(string(data))
+		return
+	}
+
+	if lifetime.Since.IsZero() {
+		log.Log(log.INFO, "nothing reclaimed yet - run 'zap cleanup' or 'zap ports'")
+		return
+	}
+
+	since := lifetime.Since.Format("2006-01-02")
+	log.Log(log.STATS, "reclaimed %s across %d directories since %s", cleanup.FormatSize(lifetime.BytesReclaimed), lifetime.DirectoriesDeleted, since)
+	log.Log(log.STATS, "terminated %d process(es) since %s", lifetime.ProcessesKilled, since)
+	if next := state.NextMilestone(lifetime.BytesReclaimed); next > 0 {
+		log.Log(log.INFO, "next milestone: %s (%s to go)", cleanup.FormatSize(next), cleanup.FormatSize(next-lifetime.BytesReclaimed))
+	}
+}
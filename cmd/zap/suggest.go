@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+)
+
+// suggestMinRuntime is how long an infrastructure listener must have been running for
+// suggest-protected to consider it part of the machine's setup rather than a one-off
+const suggestMinRuntime = time.Hour
+
+// protectionSuggestion is a port `zap config suggest-protected` proposes to protect
+type protectionSuggestion struct {
+	Port    int    `json:"port"`
+	PID     int    `json:"pid"`
+	Name    string `json:"name"`
+	Cmd     string `json:"cmd"`
+	Keyword string `json:"matched"`
+	// RuntimeSeconds is 0 when the start time is unknown
+	RuntimeSeconds int64 `json:"runtime_seconds"`
+}
+
+// handleSuggestProtected looks for long-running infrastructure (databases, caches,
+// Docker) listening on well-known service ports and the common dev ports, and offers to
+// add their ports to protected_ports. --dry-run and --json only list them; --yes adds
+// them without asking.
+func handleSuggestProtected(ctx context.Context, inv *invocation) {
+	cfg := inv.cfg
+	portsToScan := append(append([]int{}, ports.InfrastructurePorts...), ports.CommonDevPorts...)
+	processes, err := ports.ScanPortsRangeWithProtocols(ctx, portsToScan, []string{ports.ProtocolTCP}, scanConcurrency(cfg, inv.flagValues))
+	var partialScan *ports.PartialScanError
+	if errors.As(err, &partialScan) {
+		log.VerboseLog("scan incomplete: %v; not checked: %s", partialScan.Err, formatPorts(partialScan.Unchecked))
+		err = nil
+	}
+	if ctx.Err() != nil {
+		log.Log(log.INFO, "operation cancelled")
+		return
+	}
+	if err != nil {
+		log.Log(log.FAIL, "Failed to scan ports: %v", err)
+		os.Exit(1)
+	}
+
+	suggestions := suggestProtected(cfg, processes)
+	if inv.jsonOutput {
+		data, _ := json.MarshalIndent(suggestions, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	if len(suggestions) == 0 {
+		log.Log(log.OK, "no unprotected long-running infrastructure found (protected_ports: %v)", cfg.ProtectedPorts)
+		return
+	}
+
+	var portList []int
+	for _, s := range suggestions {
+		runtime := "running time unknown"
+		if s.RuntimeSeconds > 0 {
+			runtime = "running " + formatRuntime(time.Duration(s.RuntimeSeconds)*time.Second)
+		}
+		log.Log(log.FOUND, ":%d PID %d (%s) - %s, matched %q", s.Port, s.PID, s.Name, runtime, s.Keyword)
+		if s.Cmd != "" {
+			log.VerboseLog("  %s", truncateString(s.Cmd, 100))
+		}
+		portList = append(portList, s.Port)
+	}
+
+	if inv.dryRun {
+		log.Log(log.INFO, "would add %s to protected_ports (dry run)", formatPorts(portList))
+		return
+	}
+	if !inv.yes {
+		log.Log(log.ACTION, "add %s to protected_ports? (y/N): ", formatPorts(portList))
+		if !confirm() {
+			log.Log(log.OK, "protected_ports unchanged")
+			return
+		}
+	}
+	cfg.ProtectedPorts = append(cfg.ProtectedPorts, portList...)
+	if err := config.Save(ctx, cfg); err != nil {
+		log.Log(log.FAIL, "Failed to save config: %v", err)
+		os.Exit(1)
+	}
+	log.Log(log.OK, "Updated protected ports: %v", cfg.ProtectedPorts)
+}
+
+// suggestProtected picks the listeners worth protecting: infrastructure running for at
+// least suggestMinRuntime (or for an unknown time) on a port not yet protected. Docker's
+// forwarder counts only on well-known service ports, since on other ports it usually
+// forwards an app container. Each port is suggested once, in port order.
+func suggestProtected(cfg *config.Config, processes []ports.ProcessInfo) []protectionSuggestion {
+	servicePort := make(map[int]bool, len(ports.InfrastructurePorts))
+	for _, port := range ports.InfrastructurePorts {
+		servicePort[port] = true
+	}
+
+	suggestions := []protectionSuggestion{}
+	seen := make(map[int]bool)
+	for _, proc := range processes {
+		keyword := ports.InfrastructureReason(proc)
+		if keyword == "" || seen[proc.Port] || cfg.IsProtected(proc) {
+			continue
+		}
+		if ports.IsDockerForwarder(proc) && !servicePort[proc.Port] {
+			continue
+		}
+		if proc.Runtime > 0 && proc.Runtime < suggestMinRuntime {
+			continue
+		}
+		seen[proc.Port] = true
+		suggestions = append(suggestions, protectionSuggestion{
+			Port:           proc.Port,
+			PID:            proc.PID,
+			Name:           proc.Name,
+			Cmd:            proc.Cmd,
+			Keyword:        keyword,
+			RuntimeSeconds: int64(proc.Runtime.Seconds()),
+		})
+	}
+	sort.Slice(suggestions, func(i, j int) bool { return suggestions[i].Port < suggestions[j].Port })
+	return suggestions
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/hugoev/zap/internal/journal"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// envRunMain makes the test binary run zap itself, so tests drive the real commands
+const envRunMain = "ZAP_TEST_RUN_MAIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(envRunMain) != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// newSandbox returns an empty sandbox directory, with symlinks resolved so paths match
+// the ones zap records
+func newSandbox(t *testing.T) string {
+	t.Helper()
+	sandbox, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sandbox
+}
+
+// runZap runs zap with args in test mode against sandbox and fails the test if it
+// doesn't succeed
+func runZap(t *testing.T, sandbox string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), envRunMain+"=1", testmode.EnvTestMode+"="+sandbox, "HOME="+filepath.Join(sandbox, "home"))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("zap %v: %v\n%s", args, err, output)
+	}
+	return string(output)
+}
+
+// readJSONL reads the JSON lines of path, failing the test if it is missing
+func readJSONL(t *testing.T, path string) []map[string]any {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var entries []map[string]any
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid line in %s: %v", path, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// hasEntry reports whether entries has one with action and target
+func hasEntry(entries []map[string]any, action, target string) bool {
+	for _, entry := range entries {
+		if entry["action"] == action && entry["target"] == target {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTestModeKillIsRecorded(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	sandbox := newSandbox(t)
+	// A real process behind the fixture listener shows that nothing is sent to it
+	sleeper := exec.Command("sleep", "60")
+	if err := sleeper.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		sleeper.Process.Kill()
+		sleeper.Wait()
+	}()
+	pid := sleeper.Process.Pid
+	listeners := []testmode.Listener{{
+		PID: pid, Port: 3000, Name: "node", Cmd: "node server.js",
+		StartTime: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC),
+	}}
+	data, _ := json.Marshal(listeners)
+	if err := os.WriteFile(filepath.Join(sandbox, "listeners.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runZap(t, sandbox, "ports", "--kill-all", "--yes")
+
+	actions := readJSONL(t, filepath.Join(sandbox, "actions.jsonl"))
+	if !hasEntry(actions, testmode.ActionKill, fmt.Sprint(pid)) {
+		t.Errorf("kill of PID %d not recorded in actions.jsonl: %v", pid, actions)
+	}
+	entries := readJSONL(t, filepath.Join(sandbox, "zap", "journal.jsonl"))
+	if !hasEntry(entries, journal.ActionKill, fmt.Sprintf("PID %d (node) :3000", pid)) {
+		t.Errorf("kill of PID %d not in the journal: %v", pid, entries)
+	}
+	if err := sleeper.Process.Signal(syscall.Signal(0)); err != nil {
+		t.Errorf("PID %d was signalled in test mode: %v", pid, err)
+	}
+}
+
+func TestTestModeCleanupIsRecorded(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		args    []string
+		action  string
+		journal string
+	}{
+		{"delete", []string{"cleanup", "--yes"}, testmode.ActionDelete, journal.ActionDelete},
+		{"trash", []string{"cleanup", "--yes", "--trash"}, testmode.ActionTrash, journal.ActionTrash},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			sandbox := newSandbox(t)
+			project := filepath.Join(sandbox, "home", "Projects", "app")
+			stale := filepath.Join(project, "node_modules")
+			if err := os.MkdirAll(filepath.Join(stale, "left-pad"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(project, "package.json"), []byte("{}"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			// Well before the frozen clock, so node_modules is stale
+			old := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+			for _, path := range []string{filepath.Join(stale, "left-pad"), stale, filepath.Join(project, "package.json")} {
+				if err := os.Chtimes(path, old, old); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			runZap(t, sandbox, test.args...)
+
+			actions := readJSONL(t, filepath.Join(sandbox, "actions.jsonl"))
+			if !hasEntry(actions, test.action, stale) {
+				t.Errorf("%s of %s not recorded in actions.jsonl: %v", test.action, stale, actions)
+			}
+			entries := readJSONL(t, filepath.Join(sandbox, "zap", "journal.jsonl"))
+			if !hasEntry(entries, test.journal, stale) {
+				t.Errorf("%s of %s not in the journal: %v", test.journal, stale, entries)
+			}
+			if _, err := os.Stat(filepath.Join(stale, "left-pad")); err != nil {
+				t.Errorf("%s was touched in test mode: %v", stale, err)
+			}
+		})
+	}
+}
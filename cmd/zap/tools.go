@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/state"
+)
+
+// detectedTools are the tools port scans and process lookups rely on
+var detectedTools = []string{"ps", ports.BackendLsof, ports.BackendSS, ports.BackendNetstat, "pwdx", "sudo"}
+
+// toolCapabilities is what this run knows about the tools; set in main
+var toolCapabilities *state.Tools
+
+// loadTools finds the tools zap shells out to, reusing the detection of an earlier run
+// while it still applies (same user and PATH, under a day old, tools still in place),
+// so scans don't search PATH for every port. refresh detects them anew, as doctor does.
+func loadTools(refresh bool) *state.Tools {
+	path, uid := os.Getenv("PATH"), os.Geteuid()
+	if !refresh {
+		if st, err := state.Load(); err == nil && st.Tools.Fresh(path, uid) {
+			execx.Remember(st.Tools.Paths)
+			log.DebugLog("tools detected %v ago: %s", time.Since(st.Tools.Time).Round(time.Second), describeTools(st.Tools))
+			return st.Tools
+		}
+	}
+
+	execx.Forget()
+	tools := state.Tools{
+		Time:      time.Now(),
+		PATH:      path,
+		UID:       uid,
+		Paths:     make(map[string]string),
+		NeedsSudo: uid != 0,
+	}
+	for _, name := range detectedTools {
+		tools.Paths[name], _ = execx.Get(name).Lookup()
+	}
+	log.DebugLog("detected tools: %s", describeTools(&tools))
+	if !paths.ReadOnly() {
+		if err := state.SaveTools(tools); err != nil {
+			log.VerboseLog("could not save tool detection: %v", err)
+		}
+	}
+	return &tools
+}
+
+// describeTools lists the tools found and missing, e.g. "lsof=/usr/bin/lsof, ss=missing"
+func describeTools(tools *state.Tools) string {
+	names := make([]string, 0, len(tools.Paths))
+	for name := range tools.Paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		resolved := tools.Paths[name]
+		if resolved == "" {
+			resolved = "missing"
+		}
+		parts = append(parts, name+"="+resolved)
+	}
+	return strings.Join(parts, ", ")
+}
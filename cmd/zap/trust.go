@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+var trustCommand = &command{
+	spec: commandSpec{
+		Name: "trust", Description: "Let cleanup delete in a tree it only reports on so far (no path: list the project trees it has found)",
+		Args:  []argSpec{{Name: "path", Optional: true, Variadic: true}},
+		Flags: withCommon("revoke"),
+	},
+	readOnly: func(args []string) bool {
+		for _, arg := range args {
+			if !strings.HasPrefix(arg, "-") {
+				return false
+			}
+		}
+		return true
+	},
+	run: func(ctx context.Context, inv *invocation) {
+		handleTrust(inv.positional, inv.flags["revoke"], inv.jsonOutput)
+	},
+}
+
+// handleTrust trusts (or with --revoke, distrusts) the trees at paths for cleanup, or
+// lists the trees cleanup has scanned
+func handleTrust(paths []string, revoke, jsonOutput bool) {
+	if len(paths) == 0 {
+		if revoke {
+			log.Log(log.FAIL, "Usage: zap trust --revoke <path>...")
+			os.Exit(1)
+		}
+		listWorkspaces(jsonOutput)
+		return
+	}
+
+	var absPaths []string
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid path %s: %v", path, err)
+			os.Exit(1)
+		}
+		// An unmounted drive can still be distrusted
+		if info, err := os.Stat(absPath); !revoke && (err != nil || !info.IsDir()) {
+			log.Log(log.FAIL, "Cannot trust %s: not a directory", absPath)
+			os.Exit(1)
+		}
+		absPaths = append(absPaths, absPath)
+	}
+	if err := state.SetTrusted(absPaths, !revoke, testmode.Now()); err != nil {
+		log.Log(log.FAIL, "Failed to save trust: %v", err)
+		os.Exit(1)
+	}
+
+	st, _ := state.Load()
+	for _, path := range absPaths {
+		switch {
+		case !revoke:
+			log.Log(log.OK, "trusting %s: zap cleanup may delete stale directories in it", path)
+		case st != nil && st.Trusted(path):
+			log.Log(log.WARN, "%s stays trusted as part of a trusted tree (see zap trust)", path)
+		default:
+			log.Log(log.OK, "no longer trusting %s: zap cleanup only reports what it finds there", path)
+		}
+	}
+}
+
+func listWorkspaces(jsonOutput bool) {
+	st, err := state.Load()
+	if err != nil {
+		log.Log(log.FAIL, "Failed to read state: %v", err)
+		os.Exit(1)
+	}
+	if jsonOutput {
+		workspaces := st.Workspaces
+		if workspaces == nil {
+			workspaces = []state.Workspace{}
+		}
+		data, _ := json.Marshal(map[string]any{"workspaces": workspaces})
+		fmt.Println(string(data))
+		return
+	}
+	if len(st.Workspaces) == 0 {
+		log.Log(log.INFO, "zap cleanup hasn't found any project trees yet")
+		return
+	}
+	for _, workspace := range st.Workspaces {
+		trust := "untrusted, only reported"
+		if workspace.Trusted {
+			trust = "trusted"
+		}
+		fmt.Printf("  %s (%s, first seen %s)\n", workspace.Path, trust, workspace.FirstSeen.Local().Format("2006-01-02"))
+	}
+}
+
+// seeWorkspaces records the project trees the candidates in dirs are in, returning the
+// state. Trees are untrusted when first seen, except on the first cleanup to track
+// trust: every tree in the scanned directories then, inside the home directory and on
+// its filesystem, was cleaned before trust existed and stays so.
+func seeWorkspaces(homeDir string, scanPaths []string, dirs []cleanup.DirectoryInfo) *state.State {
+	st, err := state.Load()
+	if err != nil {
+		log.VerboseLog("failed to read workspace trust: %v", err)
+		return &state.State{}
+	}
+	firstRun := st.TrustSince.IsZero()
+	var trees []string
+	if firstRun {
+		trees = projectTrees(scanPaths)
+	}
+	for _, dir := range dirs {
+		if dir.Category == "" {
+			trees = append(trees, projectTree(scanPaths, dir.Path))
+		}
+	}
+	saved, err := state.SeeWorkspaces(trees, testmode.Now(), func(path string) bool {
+		return firstRun && pathInside(homeDir, path) && cleanup.SameFilesystem(homeDir, path)
+	})
+	if err != nil {
+		log.VerboseLog("failed to save workspace trust: %v", err)
+	}
+	if saved == nil {
+		return st
+	}
+	return saved
+}
+
+// splitUntrusted separates the directories in untrusted project trees from dirs,
+// returning the rest, those, and the trees they are in. Asked at a terminal, the user
+// can trust a tree then and there. Category caches are well-known locations, not trees.
+func splitUntrusted(st *state.State, scanPaths []string, dirs []cleanup.DirectoryInfo, ask bool) (trusted, untrusted []cleanup.DirectoryInfo, trees []string) {
+	byTree := make(map[string][]cleanup.DirectoryInfo)
+	for _, dir := range dirs {
+		if dir.Category != "" || st.Trusted(dir.Path) {
+			trusted = append(trusted, dir)
+			continue
+		}
+		tree := projectTree(scanPaths, dir.Path)
+		if _, ok := byTree[tree]; !ok {
+			trees = append(trees, tree)
+		}
+		byTree[tree] = append(byTree[tree], dir)
+	}
+
+	var untrustedTrees []string
+	for _, tree := range trees {
+		treeDirs := byTree[tree]
+		if ask {
+			log.Log(log.ACTION, "zap doesn't trust %s yet: %d stale directories (%s) found there. Trust it, so they can be deleted? (y/N): ", tree, len(treeDirs), cleanup.FormatSize(cleanup.GetTotalSize(treeDirs)))
+			if confirm() {
+				err := state.SetTrusted([]string{tree}, true, testmode.Now())
+				if err == nil {
+					log.Log(log.OK, "trusting %s (undo with zap trust --revoke %s)", tree, tree)
+					trusted = append(trusted, treeDirs...)
+					continue
+				}
+				log.Log(log.FAIL, "Failed to save trust, so %s stays untrusted: %v", tree, err)
+			}
+		}
+		untrusted = append(untrusted, treeDirs...)
+		untrustedTrees = append(untrustedTrees, tree)
+	}
+	return trusted, untrusted, untrustedTrees
+}
+
+// scanRootOf returns the innermost scanned tree containing path
+func scanRootOf(scanPaths []string, path string) string {
+	root := ""
+	for _, scanPath := range scanPaths {
+		if pathInside(scanPath, path) && len(scanPath) > len(root) {
+			root = scanPath
+		}
+	}
+	if root == "" {
+		return filepath.Dir(path)
+	}
+	return root
+}
+
+// projectTree returns the tree trust is kept for that holds path: the scanned directory
+// if it is a repository itself, else the directory right under it, so each project
+// cloned into ~/Projects is trusted on its own
+func projectTree(scanPaths []string, path string) string {
+	root := scanRootOf(scanPaths, path)
+	if isRepository(root) {
+		return root
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || !filepath.IsLocal(rel) {
+		return root
+	}
+	first, _, _ := strings.Cut(rel, string(filepath.Separator))
+	return filepath.Join(root, first)
+}
+
+// projectTrees lists the project trees in the scanned directories
+func projectTrees(scanPaths []string) []string {
+	var trees []string
+	for _, root := range scanPaths {
+		if isRepository(root) {
+			trees = append(trees, root)
+			continue
+		}
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				trees = append(trees, filepath.Join(root, entry.Name()))
+			}
+		}
+	}
+	return trees
+}
+
+func isRepository(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// showUntrustedDirs lists the candidates in untrusted trees, which are only reported
+func showUntrustedDirs(dirs []cleanup.DirectoryInfo, trees []string) {
+	if len(dirs) == 0 {
+		return
+	}
+	fmt.Fprintln(log.Writer())
+	fmt.Fprintf(log.Writer(), "  Untrusted, only reported (%d, %s total):\n", len(dirs), cleanup.FormatSize(cleanup.GetTotalSize(dirs)))
+	for _, dir := range dirs {
+		fmt.Fprintf(log.Writer(), "    %s (%s)\n", dir.Path, sizeLabel(dir, dir.Size))
+	}
+	fmt.Fprintln(log.Writer())
+	for _, tree := range trees {
+		log.Log(log.INFO, "zap doesn't trust %s yet; clean it after: zap trust %s", tree, tree)
+	}
+}
@@ -0,0 +1,1118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/journal"
+	"github.com/hugoev/zap/internal/lock"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/release"
+	"github.com/hugoev/zap/internal/semver"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/summary"
+	"github.com/hugoev/zap/internal/testmode"
+	"github.com/hugoev/zap/internal/version"
+	"github.com/mattn/go-isatty"
+)
+
+// isOperationActive checks if zap is currently performing a ports or cleanup operation
+// This prevents updates during active operations which could corrupt state
+var operationActive int32 // atomic counter for active operations
+var updateCommand = &command{
+	spec:     commandSpec{Name: "update", Description: "Update to latest version", Flags: withCommon("channel", "to", "yes", "check", "rollback")},
+	readOnly: func(args []string) bool { return hasArg(args, "--check") },
+	run: func(ctx context.Context, inv *invocation) {
+		target := parseUpdateTarget(inv.flagValues)
+		if inv.flags["rollback"] {
+			if target.version != nil || target.channel != channelStable || inv.flags["check"] {
+				log.Log(log.FAIL, "--rollback can't be combined with --to, --channel or --check")
+				os.Exit(1)
+			}
+			rollbackUpdate(inv.lock, inv.yes)
+			return
+		}
+		if inv.flags["check"] {
+			checkForUpdate(ctx, target, inv.jsonOutput)
+			return
+		}
+		handleUpdate(inv.cfg, inv.lock, target, inv.yes)
+	},
+}
+
+// Release channels zap update follows
+const (
+	channelStable = "stable" // releases only
+	channelBeta   = "beta"   // pre-releases too
+)
+
+var updateChannels = []string{channelStable, channelBeta}
+
+// updateTarget is the version zap update installs: the newest on a channel, or the
+// one pinned with --to
+type updateTarget struct {
+	channel string
+	version *semver.Version
+}
+
+// parseUpdateTarget reads --channel and --to
+func parseUpdateTarget(flagValues map[string]string) updateTarget {
+	target := updateTarget{channel: channelStable}
+	if channel, ok := flagValues["channel"]; ok {
+		target.channel = strings.ToLower(strings.TrimSpace(channel))
+		if target.channel != channelStable && target.channel != channelBeta {
+			log.Log(log.FAIL, "Invalid --channel: %s (must be %s)", channel, strings.Join(updateChannels, ", "))
+			os.Exit(1)
+		}
+	}
+	if value, ok := flagValues["to"]; ok {
+		if _, ok := flagValues["channel"]; ok {
+			log.Log(log.FAIL, "--to can't be combined with --channel")
+			os.Exit(1)
+		}
+		ver, err := semver.Parse(value)
+		if err != nil {
+			log.Log(log.FAIL, "Invalid --to: %v", err)
+			os.Exit(1)
+		}
+		target.version = &ver
+	}
+	return target
+}
+
+// accepts reports whether ver may be installed: the pinned version, or any version on
+// the channel
+func (t updateTarget) accepts(ver semver.Version, prerelease bool) bool {
+	if t.version != nil {
+		return ver.Compare(*t.version) == 0
+	}
+	return t.channel == channelBeta || (!prerelease && !ver.IsPrerelease())
+}
+
+// selectRelease returns the release to install from releases, newest first
+func (t updateTarget) selectRelease(releases []release.Release) (release.Release, bool) {
+	for _, r := range releases {
+		if t.accepts(r.Version, r.Prerelease) {
+			return r, true
+		}
+	}
+	return release.Release{}, false
+}
+
+func (t updateTarget) String() string {
+	if t.version != nil {
+		return "v" + t.version.String()
+	}
+	return "the " + t.channel + " channel"
+}
+
+// confirmDowngrade asks before installing a version older than the running one, which
+// only happens with --to
+func confirmDowngrade(to semver.Version, yes bool) bool {
+	log.Log(log.WARN, "v%s is older than the running version %s", to, version.Get())
+	if yes {
+		return true
+	}
+	log.Log(log.ACTION, "downgrade to v%s? (y/N): ", to)
+	if !confirm() {
+		log.Log(log.INFO, "update cancelled")
+		return false
+	}
+	return true
+}
+
+func handleUpdate(cfg *config.Config, instanceLock *lock.InstanceLock, target updateTarget, yes bool) {
+	// Check if any operations are active
+	if atomic.LoadInt32(&operationActive) > 0 {
+		log.Log(log.FAIL, "cannot update while operations are in progress")
+		log.Log(log.INFO, "please wait for current operation to complete")
+		os.Exit(1)
+	}
+	if testmode.Enabled() {
+		log.Log(log.FAIL, "zap update is disabled in test mode (%s)", testmode.EnvTestMode)
+		os.Exit(1)
+	}
+	log.Log(log.SCAN, "checking for updates...")
+	outcome := summary.New("update", false)
+
+	// Prebuilt release binaries need neither Go nor git; building from source is the
+	// fallback for releases without a binary for this platform
+	if updateFromRelease(instanceLock, outcome, target, yes) {
+		return
+	}
+	log.Log(log.INFO, "building the update from source")
+
+	// Check all required dependencies upfront with helpful messages
+	dependencies := map[string]struct {
+		installMsg string
+		url        string
+	}{
+		"go": {
+			installMsg: "Go is required for updates",
+			url:        "https://golang.org/dl/",
+		},
+		"git": {
+			installMsg: "Git is required to fetch version tags",
+			url:        "https://git-scm.com/downloads",
+		},
+	}
+
+	for cmd, info := range dependencies {
+		if _, err := execx.Get(cmd).Lookup(); err != nil {
+			log.Log(log.FAIL, "%s not found in PATH", cmd)
+			log.Log(log.INFO, "%s. Install from: %s", info.installMsg, info.url)
+			os.Exit(1)
+		}
+	}
+
+	goPath, _ := execx.Get("go").Lookup()
+	log.VerboseLog("using go at: %s", goPath)
+
+	// Get current version
+	currentVersion := version.Get()
+	log.Log(log.INFO, "current version: %s", currentVersion)
+
+	// Check the installed binary's modification time to see if it was recently updated
+	var originalModTime time.Time
+	var originalZapPath string
+	zapPath, pathErr := execx.Get("zap").Lookup()
+	if pathErr == nil {
+		originalZapPath = zapPath
+		if info, statErr := os.Stat(zapPath); statErr == nil {
+			originalModTime = info.ModTime()
+			// If binary was modified in the last minute, assume it's already up to date
+			if target.version == nil && target.channel == channelStable && time.Since(originalModTime) < time.Minute {
+				log.Log(log.OK, "already up to date (version %s)", version.Get())
+				log.VerboseLog("binary was recently updated")
+				return
+			}
+		}
+	}
+
+	// Determine where go install will put the binary
+	goBinPath := os.Getenv("GOBIN")
+	if goBinPath == "" {
+		gopath := os.Getenv("GOPATH")
+		if gopath == "" {
+			homeDir, _ := os.UserHomeDir()
+			goBinPath = filepath.Join(homeDir, "go", "bin")
+		} else {
+			goBinPath = filepath.Join(gopath, "bin")
+		}
+	}
+	expectedZapPath := filepath.Join(goBinPath, "zap")
+
+	// Warn if current binary is not in the Go bin directory
+	if originalZapPath != "" && originalZapPath != expectedZapPath {
+		log.VerboseLog("current binary at: %s", originalZapPath)
+		log.VerboseLog("will install to: %s", expectedZapPath)
+	}
+
+	// Try to get the latest commit info (optional, don't fail if it doesn't work)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := execx.Run(ctx, "go", "list", "-m", "-f", "{{.Version}}", "github.com/hugoev/zap@main")
+	latestModuleVersion := strings.TrimSpace(string(output))
+
+	if err != nil || latestModuleVersion == "" {
+		log.VerboseLog("could not determine latest version, proceeding with update...")
+	} else {
+		log.VerboseLog("latest module version: %s", latestModuleVersion)
+	}
+
+	// Try to get the latest version tag from GitHub with retry logic
+	var installTarget string
+	var latestTag string
+	var latestVersion semver.Version
+
+	maxRetries := 5
+	baseDelay := 1 * time.Second
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		ctx2, cancel2 := context.WithTimeout(context.Background(), 10*time.Second)
+
+		tagOutput, tagErr := execx.Run(ctx2, "git", "ls-remote", "--tags", "--sort=-v:refname", "https://github.com/hugoev/zap.git", "v*")
+		cancel2()
+
+		if tagErr == nil && len(tagOutput) > 0 {
+			// Parse all tags and find the latest valid semantic version
+			lines := strings.Split(strings.TrimSpace(string(tagOutput)), "\n")
+			for _, line := range lines {
+				if strings.TrimSpace(line) == "" {
+					continue
+				}
+				// Extract tag name from line like "refs/tags/v0.3.0" or "refs/tags/v0.3.0^{}"
+				parts := strings.Fields(line)
+				if len(parts) < 2 {
+					continue
+				}
+				tagRef := parts[1]
+				if strings.HasPrefix(tagRef, "refs/tags/") {
+					tag := strings.TrimPrefix(tagRef, "refs/tags/")
+					// Remove ^{} suffix if present (dereferenced tag pointer)
+					tag = strings.TrimSuffix(tag, "^{}")
+					// Skip if not a version tag
+					if !strings.HasPrefix(tag, "v") {
+						continue
+					}
+					// Try to parse as semantic version; pre-releases are only installed
+					// from the beta channel or with --to
+					if ver, err := semver.Parse(tag); err == nil && target.accepts(ver, false) {
+						// Found a valid version, check if it's newer
+						if installTarget == "" || ver.Compare(latestVersion) > 0 {
+							latestTag = tag
+							latestVersion = ver
+							installTarget = fmt.Sprintf("github.com/hugoev/zap/cmd/zap@%s", tag)
+						}
+					}
+				}
+			}
+
+			if installTarget != "" {
+				log.VerboseLog("found latest tag: %s (version %s)", latestTag, latestVersion)
+				break
+			}
+		}
+
+		if attempt < maxRetries {
+			// Exponential backoff: 1s, 2s, 4s, 8s, 16s
+			delay := baseDelay * time.Duration(1<<uint(attempt-1))
+			log.VerboseLog("network error (attempt %d/%d), retrying in %v...", attempt, maxRetries, delay)
+			time.Sleep(delay)
+		} else {
+			log.VerboseLog("failed to fetch tags after %d attempts", maxRetries)
+		}
+	}
+
+	if installTarget == "" && target.version != nil {
+		log.Log(log.FAIL, "no release %s found", target)
+		log.Log(log.INFO, "see https://github.com/%s/releases for the published versions", release.Repo)
+		os.Exit(1)
+	}
+
+	// Compare with current version
+	currentVer, parseErr := semver.Parse(version.Get())
+	if parseErr == nil && installTarget != "" {
+		switch cmp := latestVersion.Compare(currentVer); {
+		case cmp == 0 || (cmp < 0 && target.version == nil):
+			log.Log(log.OK, "already up to date (version %s)", version.Get())
+			return
+		case cmp < 0:
+			if !confirmDowngrade(latestVersion, yes) {
+				return
+			}
+		default:
+			log.VerboseLog("update available: %s -> %s", version.Get(), latestVersion)
+		}
+	}
+
+	// Fallback to @main if we can't get tags
+	if installTarget == "" {
+		installTarget = "github.com/hugoev/zap/cmd/zap@main"
+		log.VerboseLog("using main branch as fallback")
+		latestTag = "main" // Set latestTag for fallback case
+	}
+
+	// Install latest version with version injection
+	// go install doesn't support ldflags, so we need to build manually
+	log.Log(log.INFO, "downloading and installing latest version...")
+
+	// Only try git clone if we have a valid tag (not "main")
+	if latestTag != "" && latestTag != "main" {
+		// Create temp directory for cloning
+		tempDir, err := os.MkdirTemp("", "zap-update-*")
+		if err != nil {
+			log.Log(log.FAIL, "failed to create temp directory: %v", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(tempDir)
+
+		// Clone the repo at the specific tag
+		log.VerboseLog("cloning repository at tag %s...", latestTag)
+		cloneCtx, cloneCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		_, cloneErr := execx.Run(cloneCtx, "git", "clone", "--depth", "1", "--branch", latestTag, "https://github.com/hugoev/zap.git", tempDir)
+		cloneCancel()
+
+		if cloneErr != nil {
+			log.VerboseLog("failed to clone with tag, trying full clone: %v", cloneErr)
+			// Fallback: clone main and checkout tag
+			cloneCtx2, cloneCancel2 := context.WithTimeout(context.Background(), 30*time.Second)
+			_, cloneErr2 := execx.Run(cloneCtx2, "git", "clone", "--depth", "1", "https://github.com/hugoev/zap.git", tempDir)
+			cloneCancel2()
+
+			if cloneErr2 != nil {
+				log.Log(log.FAIL, "failed to clone repository: %v", cloneErr2)
+				log.Log(log.INFO, "falling back to go install (version may show as 'dev')")
+				// Fallback to regular go install
+				updateCtx, updateCancel := context.WithTimeout(context.Background(), 60*time.Second)
+				defer updateCancel()
+				if _, err := execx.Run(updateCtx, "go", "install", installTarget); err != nil {
+					log.Log(log.FAIL, "failed to install: %v", err)
+					os.Exit(1)
+				}
+				return // Exit early if we used fallback
+			} else {
+				// Checkout the tag
+				log.VerboseLog("checking out tag %s...", latestTag)
+				checkoutCtx, checkoutCancel := context.WithTimeout(context.Background(), 10*time.Second)
+				_, checkoutErr := execx.Run(checkoutCtx, "git", "-C", tempDir, "checkout", latestTag)
+				checkoutCancel()
+				if checkoutErr != nil {
+					log.Log(log.FAIL, "failed to checkout tag: %v", checkoutErr)
+					log.Log(log.INFO, "falling back to go install (version may show as 'dev')")
+					// Fallback
+					updateCtx, updateCancel := context.WithTimeout(context.Background(), 60*time.Second)
+					defer updateCancel()
+					if _, err := execx.Run(updateCtx, "go", "install", installTarget); err != nil {
+						log.Log(log.FAIL, "failed to install: %v", err)
+						os.Exit(1)
+					}
+					return // Exit early if we used fallback
+				}
+			}
+		}
+
+		// Build with version injection
+		log.VerboseLog("building with version injection...")
+		buildCtx, buildCancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer buildCancel()
+
+		versionStr := strings.TrimPrefix(latestTag, "v") // Remove 'v' prefix
+		commitCtx, commitCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		commitOutput, _ := execx.Run(commitCtx, "git", "-C", tempDir, "rev-parse", "--short", "HEAD")
+		commitCancel()
+		commitHash := strings.TrimSpace(string(commitOutput))
+		if commitHash == "" {
+			commitHash = "unknown"
+		}
+
+		dateStr := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+
+		ldflags := fmt.Sprintf("-X github.com/hugoev/zap/internal/version.Version=%s -X github.com/hugoev/zap/internal/version.Commit=%s -X github.com/hugoev/zap/internal/version.Date=%s",
+			versionStr, commitHash, dateStr)
+
+		log.VerboseLog("building with version: %s, commit: %s", versionStr, commitHash)
+
+		// Build to temporary location first (safety: don't replace existing binary until verified)
+		tempBinaryPath := expectedZapPath + ".new"
+		// go -C (Go 1.20+) builds the checkout without changing zap's working directory
+		_, buildErr := execx.Run(buildCtx, "go", "-C", tempDir, "build", "-ldflags", ldflags, "-o", tempBinaryPath, "./cmd/zap")
+		buildCancel()
+
+		if buildErr != nil {
+			// Clean up temp binary on build failure
+			os.Remove(tempBinaryPath)
+			log.Log(log.FAIL, "failed to build update: %v", buildErr)
+			log.Log(log.INFO, "falling back to go install (version may show as 'dev')")
+			// Fallback to regular go install
+			updateCtx, updateCancel := context.WithTimeout(context.Background(), 60*time.Second)
+			defer updateCancel()
+			if _, err := execx.Run(updateCtx, "go", "install", installTarget); err != nil {
+				log.Log(log.FAIL, "failed to install: %v", err)
+				os.Exit(1)
+			}
+		} else {
+			// Make the binary executable
+			os.Chmod(tempBinaryPath, 0755)
+			log.VerboseLog("built binary with version %s at %s", versionStr, tempBinaryPath)
+
+			// Verify architecture matches before proceeding
+			log.VerboseLog("verifying binary architecture...")
+			currentArch := runtime.GOARCH
+			binaryArch, archErr := getBinaryArchitecture(tempBinaryPath)
+			if archErr != nil {
+				log.VerboseLog("could not determine binary architecture: %v", archErr)
+			} else if binaryArch != currentArch {
+				os.Remove(tempBinaryPath)
+				log.Log(log.FAIL, "architecture mismatch: binary is %s, system is %s", binaryArch, currentArch)
+				log.Log(log.INFO, "update aborted - architecture mismatch")
+				os.Exit(1)
+			}
+
+			// Verify the new binary works before replacing the old one
+			// Temporarily release the lock so the new binary can acquire it during verification
+			log.VerboseLog("verifying new binary...")
+			if instanceLock != nil {
+				log.VerboseLog("temporarily releasing lock for verification...")
+				instanceLock.Release()
+			}
+
+			verifyCtx, verifyCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			verifyOutput, verifyErr := execx.Run(verifyCtx, tempBinaryPath, "version")
+			verifyCancel()
+
+			// Re-acquire the lock immediately after verification with retry logic
+			if instanceLock != nil {
+				log.VerboseLog("re-acquiring lock after verification...")
+				var reacquireErr error
+				maxRetries := 3
+				retryDelay := 100 * time.Millisecond
+				for attempt := 0; attempt < maxRetries; attempt++ {
+					reacquireErr = instanceLock.Reacquire()
+					if reacquireErr == nil {
+						break // Successfully re-acquired
+					}
+					if attempt < maxRetries-1 {
+						log.VerboseLog("lock re-acquisition attempt %d/%d failed, retrying...", attempt+1, maxRetries)
+						time.Sleep(retryDelay)
+						retryDelay *= 2 // Exponential backoff
+					}
+				}
+				if reacquireErr != nil {
+					// Couldn't re-acquire lock - another instance might have started
+					os.Remove(tempBinaryPath)
+					log.Log(log.FAIL, "failed to re-acquire lock after verification: %v", reacquireErr)
+					log.Log(log.INFO, "update aborted - another instance may have started")
+					os.Exit(1)
+				}
+			}
+
+			if verifyErr != nil {
+				// New binary is corrupted or doesn't work - don't replace
+				os.Remove(tempBinaryPath)
+				log.Log(log.FAIL, "new binary verification failed: %v", verifyErr)
+				log.Log(log.INFO, "update aborted - existing binary unchanged")
+				log.Log(log.INFO, "output: %s", string(verifyOutput))
+				os.Exit(1)
+			}
+
+			// Binary works - create backup of existing binary if it exists
+			var backupPath string
+			if _, err := os.Stat(expectedZapPath); err == nil {
+				backupPath = expectedZapPath + ".backup"
+				log.VerboseLog("creating backup of existing binary: %s", backupPath)
+				if err := copyFile(expectedZapPath, backupPath); err != nil {
+					os.Remove(tempBinaryPath)
+					log.Log(log.FAIL, "failed to create backup: %v", err)
+					log.Log(log.INFO, "update aborted - cannot backup existing binary")
+					os.Exit(1)
+				}
+			}
+
+			// Replace old binary with new one (atomic on most filesystems, fallback for cross-filesystem)
+			log.VerboseLog("replacing binary: %s -> %s", tempBinaryPath, expectedZapPath)
+			if err := renameFile(tempBinaryPath, expectedZapPath); err != nil {
+				// Replacement failed - restore backup if we created one
+				os.Remove(tempBinaryPath)
+				if backupPath != "" {
+					log.Log(log.FAIL, "failed to replace binary: %v", err)
+					log.Log(log.INFO, "restoring from backup...")
+					if restoreErr := copyFile(backupPath, expectedZapPath); restoreErr != nil {
+						log.Log(log.FAIL, "failed to restore backup: %v", restoreErr)
+						log.Log(log.INFO, "original binary may be corrupted - manual recovery required")
+					} else {
+						log.Log(log.INFO, "backup restored successfully")
+					}
+				} else {
+					log.Log(log.FAIL, "failed to replace binary: %v", err)
+				}
+				os.Exit(1)
+			}
+
+			// Verify the replaced binary still works
+			// Temporarily release lock for final verification
+			log.VerboseLog("verifying replaced binary...")
+			if instanceLock != nil {
+				log.VerboseLog("temporarily releasing lock for final verification...")
+				instanceLock.Release()
+			}
+
+			finalVerifyCtx, finalVerifyCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			finalVerifyOutput, finalVerifyErr := execx.Run(finalVerifyCtx, expectedZapPath, "version")
+			finalVerifyCancel()
+
+			// Re-acquire lock after final verification with retry logic
+			if instanceLock != nil {
+				log.VerboseLog("re-acquiring lock after final verification...")
+				var reacquireErr error
+				maxRetries := 3
+				retryDelay := 100 * time.Millisecond
+				for attempt := 0; attempt < maxRetries; attempt++ {
+					reacquireErr = instanceLock.Reacquire()
+					if reacquireErr == nil {
+						break // Successfully re-acquired
+					}
+					if attempt < maxRetries-1 {
+						time.Sleep(retryDelay)
+						retryDelay *= 2 // Exponential backoff
+					}
+				}
+				if reacquireErr != nil {
+					log.Log(log.INFO, "warning: could not re-acquire lock after final verification (another instance may have started)")
+					// Don't fail - update is complete
+				}
+			}
+
+			if finalVerifyErr != nil {
+				// Replacement corrupted the binary - restore from backup
+				log.Log(log.FAIL, "replaced binary verification failed: %v", finalVerifyErr)
+				if backupPath != "" {
+					log.Log(log.INFO, "restoring from backup...")
+					if restoreErr := copyFile(backupPath, expectedZapPath); restoreErr != nil {
+						log.Log(log.FAIL, "failed to restore backup: %v", restoreErr)
+						log.Log(log.INFO, "original binary may be corrupted - manual recovery required")
+					} else {
+						log.Log(log.INFO, "backup restored successfully")
+					}
+				} else {
+					log.Log(log.FAIL, "no backup available - binary may be corrupted")
+				}
+				os.Exit(1)
+			}
+
+			// Success - clean up backup (optional, keep for safety)
+			log.VerboseLog("update successful - new binary verified")
+			recordAction(journal.Entry{
+				Action: journal.ActionUpdate,
+				Target: expectedZapPath,
+				Result: journal.ResultOK,
+				Detail: version.Get() + " -> " + versionStr,
+			})
+			log.VerboseLog("new version output: %s", strings.TrimSpace(string(finalVerifyOutput)))
+			refreshExtras(instanceLock, expectedZapPath)
+			// Keep backup for now (user can clean it up later if needed)
+			if backupPath != "" {
+				log.VerboseLog("backup kept at: %s (safe to delete)", backupPath)
+			}
+		}
+	} else {
+		// No tag available, fallback to go install
+		log.VerboseLog("no version tag available, using go install (version may show as 'dev')")
+		updateCtx, updateCancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer updateCancel()
+		if _, err := execx.Run(updateCtx, "go", "install", installTarget); err != nil {
+			log.Log(log.FAIL, "failed to install: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// Verify the update by checking the new binary's version
+	// Give filesystem a moment to sync (only needed for go install fallback)
+	if latestTag == "" || latestTag == "main" {
+		time.Sleep(200 * time.Millisecond)
+	}
+
+	// Check if PATH needs to be configured
+	if !strings.Contains(os.Getenv("PATH"), goBinPath) {
+		if cfg.PathSetup == config.PathSetupNever {
+			log.Log(log.INFO, "%s is not in PATH - run 'zap setup path' to add it", goBinPath)
+		} else {
+			log.Log(log.INFO, "setting up PATH...")
+			if err := setupPath(goBinPath, cfg.PathSetup == config.PathSetupPrompt); err != nil {
+				log.VerboseLog("PATH setup failed: %v", err)
+				log.Log(log.INFO, "add %s to your PATH manually to use the updated version", goBinPath)
+			}
+		}
+	}
+
+	// Check the installed binary (in Go bin directory)
+	var installedZapPath string
+	if _, err := os.Stat(expectedZapPath); err == nil {
+		installedZapPath = expectedZapPath
+	} else if pathErr == nil {
+		// Fallback to checking the original path
+		installedZapPath = originalZapPath
+	}
+
+	// Check if binary was updated by comparing modification times
+	if installedZapPath != "" {
+		if newInfo, err := os.Stat(installedZapPath); err == nil {
+			if !originalModTime.IsZero() && newInfo.ModTime().After(originalModTime) {
+				// Binary was updated, verify by running it and checking version
+				verifyCtx, verifyCancel := context.WithTimeout(context.Background(), 5*time.Second)
+				verifyOutput, verifyErr := execx.Run(verifyCtx, installedZapPath, "version")
+				verifyCancel()
+
+				if verifyErr == nil {
+					outputStr := strings.TrimSpace(string(verifyOutput))
+
+					// Extract and compare versions
+					newVerStr, extractErr := semver.Extract(outputStr)
+					if extractErr == nil {
+						newVer, parseErr := semver.Parse(newVerStr)
+						if parseErr == nil {
+							currentVer, _ := semver.Parse(version.Get())
+							if newVer.Compare(currentVer) > 0 {
+								updateComplete(outcome)
+								log.Log(log.INFO, "upgraded from %s to %s", version.Get(), newVer)
+							} else if newVer.Compare(currentVer) == 0 {
+								updateComplete(outcome)
+								log.Log(log.INFO, "version: %s (same version, binary updated)", newVer)
+							} else if target.version != nil {
+								updateComplete(outcome)
+								log.Log(log.INFO, "downgraded from %s to %s", version.Get(), newVer)
+							} else {
+								updateComplete(outcome)
+								log.Log(log.INFO, "warning: new version %s appears older than current %s", newVer, version.Get())
+								log.Log(log.INFO, "this may indicate a downgrade or version mismatch")
+							}
+						} else {
+							updateComplete(outcome)
+							log.Log(log.INFO, "new version: %s", outputStr)
+						}
+					} else {
+						updateComplete(outcome)
+						log.Log(log.INFO, "new version: %s", outputStr)
+					}
+
+					// Check if PATH needs updating
+					if installedZapPath == expectedZapPath && originalZapPath != expectedZapPath {
+						log.Log(log.INFO, "updated binary installed to: %s", expectedZapPath)
+						if !strings.Contains(os.Getenv("PATH"), goBinPath) {
+							log.Log(log.INFO, "add %s to your PATH to use the updated version", goBinPath)
+						}
+					}
+
+					// Warn about shell cache
+					if strings.Contains(outputStr, version.Get()) {
+						log.Log(log.INFO, "note: version may be cached, restart your terminal or run: hash -r")
+					}
+				} else {
+					updateComplete(outcome)
+					log.Log(log.INFO, "could not verify new version (binary may be corrupted)")
+					log.Log(log.INFO, "run 'zap version' to verify the new version")
+				}
+			} else if !originalModTime.IsZero() {
+				log.Log(log.OK, "already up to date (version %s)", version.Get())
+			} else {
+				updateComplete(outcome)
+				log.Log(log.INFO, "run 'zap version' to verify the new version")
+			}
+			return
+		}
+	}
+
+	// If we can't verify, still report success but warn user
+	updateComplete(outcome)
+	log.Log(log.INFO, "run 'zap version' to verify the new version")
+	if originalZapPath != "" && originalZapPath != expectedZapPath {
+		log.Log(log.INFO, "note: binary installed to %s (current: %s)", expectedZapPath, originalZapPath)
+		// Check if PATH needs to be configured
+		if !strings.Contains(os.Getenv("PATH"), goBinPath) {
+			if cfg.PathSetup == config.PathSetupNever {
+				log.Log(log.INFO, "run 'zap setup path' to add %s to your PATH", goBinPath)
+			} else {
+				log.Log(log.INFO, "setting up PATH...")
+				if err := setupPath(goBinPath, cfg.PathSetup == config.PathSetupPrompt); err != nil {
+					log.VerboseLog("PATH setup failed: %v", err)
+					log.Log(log.INFO, "if version hasn't changed, ensure %s is in your PATH", goBinPath)
+				}
+			}
+		} else {
+			log.Log(log.INFO, "if version hasn't changed, ensure %s is in your PATH", goBinPath)
+		}
+	} else {
+		log.Log(log.INFO, "if version hasn't changed, try: hash -r  (or restart your terminal)")
+	}
+}
+
+// updateFromRelease installs the prebuilt binary of the target release over the running
+// zap, verified against the release's checksums. It returns false, having changed
+// nothing, when there is no usable release binary and the update should be built from
+// source instead.
+func updateFromRelease(instanceLock *lock.InstanceLock, outcome *summary.Summary, target updateTarget, yes bool) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	releases, err := release.List(ctx)
+	if err != nil {
+		log.VerboseLog("could not list releases: %v", err)
+		return false
+	}
+	latest, ok := target.selectRelease(releases)
+	if !ok {
+		log.VerboseLog("no release published for %s", target)
+		return false
+	}
+	log.VerboseLog("selected release: %s", latest.Tag)
+	targetPath, err := os.Executable()
+	if err == nil {
+		targetPath, err = filepath.EvalSymlinks(targetPath)
+	}
+	if err != nil {
+		log.VerboseLog("could not locate the running binary: %v", err)
+		return false
+	}
+	// Checked before the downgrade prompt: without a binary the source build asks it again
+	if _, ok := latest.Asset(release.BinaryName(latest.Version, runtime.GOOS, runtime.GOARCH)); !ok {
+		log.VerboseLog("%s has no prebuilt binary for %s/%s", latest.Tag, runtime.GOOS, runtime.GOARCH)
+		return false
+	}
+
+	downgrade := false
+	if current, err := semver.Parse(version.Get()); err == nil {
+		switch cmp := latest.Version.Compare(current); {
+		case cmp == 0 || (cmp < 0 && target.version == nil):
+			log.Log(log.OK, "already up to date (version %s)", version.Get())
+			return true
+		case cmp < 0:
+			if !confirmDowngrade(latest.Version, yes) {
+				return true
+			}
+			downgrade = true
+		}
+	}
+	newPath := targetPath + ".new"
+	log.Log(log.INFO, "downloading %s for %s/%s...", latest.Tag, runtime.GOOS, runtime.GOARCH)
+	err = latest.DownloadBinary(ctx, runtime.GOOS, runtime.GOARCH, newPath)
+	var checksumErr *release.ChecksumError
+	switch {
+	case errors.Is(err, release.ErrNoBinary):
+		log.VerboseLog("%v", err)
+		return false
+	case errors.As(err, &checksumErr):
+		log.Log(log.FAIL, "%v", err)
+		log.Log(log.INFO, "update aborted - existing binary unchanged")
+		os.Exit(1)
+	case err != nil:
+		log.Log(log.FAIL, "failed to download update: %v", err)
+		if os.IsPermission(err) {
+			log.Log(log.INFO, "%s is not writable - rerun with the permissions it was installed with", filepath.Dir(targetPath))
+		}
+		os.Exit(1)
+	}
+	log.VerboseLog("downloaded and verified %s", newPath)
+
+	// The new binary must run before it replaces this one
+	verifyOutput, verifyErr, lockErr := runUnlocked(instanceLock, newPath, "version")
+	if lockErr != nil {
+		os.Remove(newPath)
+		log.Log(log.FAIL, "failed to re-acquire lock after verification: %v", lockErr)
+		log.Log(log.INFO, "update aborted - another instance may have started")
+		os.Exit(1)
+	}
+	if verifyErr != nil || !strings.Contains(string(verifyOutput), latest.Version.String()) {
+		os.Remove(newPath)
+		log.Log(log.FAIL, "new binary verification failed: %v", verifyErr)
+		log.Log(log.INFO, "update aborted - existing binary unchanged")
+		log.Log(log.INFO, "output: %s", strings.TrimSpace(string(verifyOutput)))
+		os.Exit(1)
+	}
+
+	// Windows can't replace a running executable but can rename it, so the backup is
+	// moved aside there; elsewhere it is a copy and the rename below swaps atomically
+	backupPath := targetPath + ".backup"
+	if runtime.GOOS == "windows" {
+		os.Remove(backupPath)
+		err = os.Rename(targetPath, backupPath)
+	} else {
+		err = copyFile(targetPath, backupPath)
+	}
+	if err != nil {
+		os.Remove(newPath)
+		log.Log(log.FAIL, "failed to create backup: %v", err)
+		log.Log(log.INFO, "update aborted - cannot backup existing binary")
+		os.Exit(1)
+	}
+	log.VerboseLog("backup kept at: %s (safe to delete)", backupPath)
+	if err := renameFile(newPath, targetPath); err != nil {
+		os.Remove(newPath)
+		log.Log(log.FAIL, "failed to replace binary: %v", err)
+		if runtime.GOOS == "windows" {
+			if restoreErr := os.Rename(backupPath, targetPath); restoreErr != nil {
+				log.Log(log.INFO, "original binary kept at %s - rename it back to %s", backupPath, targetPath)
+			}
+		}
+		os.Exit(1)
+	}
+
+	recordAction(journal.Entry{
+		Action: journal.ActionUpdate,
+		Target: targetPath,
+		Result: journal.ResultOK,
+		Detail: version.Get() + " -> " + latest.Version.String(),
+	})
+	refreshExtras(instanceLock, targetPath)
+	updateComplete(outcome)
+	if downgrade {
+		log.Log(log.INFO, "downgraded from %s to %s", version.Get(), latest.Version)
+	} else {
+		log.Log(log.INFO, "upgraded from %s to %s", version.Get(), latest.Version)
+	}
+	log.Log(log.INFO, "installed to: %s", targetPath)
+	return true
+}
+
+// runUnlocked runs another zap binary with the instance lock released, as it takes the
+// lock like any other zap, and takes the lock back afterwards; lockErr reports that
+// another instance got the lock meanwhile
+func runUnlocked(instanceLock *lock.InstanceLock, path string, args ...string) (output []byte, err, lockErr error) {
+	if instanceLock != nil {
+		instanceLock.Release()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	output, err = execx.Run(ctx, path, args...)
+	cancel()
+	if instanceLock != nil {
+		// Reacquire keeps the caller's lock usable: later runUnlocked calls and the
+		// deferred Release in main act on the lock actually held
+		lockErr = instanceLock.Reacquire()
+	}
+	return output, err, lockErr
+}
+
+// rollbackUpdate puts back the binary the last update replaced, kept next to zap as
+// zap.backup, once it has shown it runs. The binary rolled back from becomes the
+// backup in turn, so a second rollback undoes the first.
+func rollbackUpdate(instanceLock *lock.InstanceLock, yes bool) {
+	if atomic.LoadInt32(&operationActive) > 0 {
+		log.Log(log.FAIL, "cannot roll back while operations are in progress")
+		os.Exit(1)
+	}
+	if testmode.Enabled() {
+		log.Log(log.FAIL, "zap update is disabled in test mode (%s)", testmode.EnvTestMode)
+		os.Exit(1)
+	}
+	target, err := os.Executable()
+	if err == nil {
+		target, err = filepath.EvalSymlinks(target)
+	}
+	if err != nil {
+		log.Log(log.FAIL, "Failed to locate the running binary: %v", err)
+		os.Exit(1)
+	}
+	backupPath := target + ".backup"
+	if _, err := os.Stat(backupPath); err != nil {
+		log.Log(log.FAIL, "no backup to roll back to: %s not found", backupPath)
+		log.Log(log.INFO, "zap update keeps the binary it replaces there; to install an older release use zap update --to <version>")
+		os.Exit(1)
+	}
+
+	output, runErr, lockErr := runUnlocked(instanceLock, backupPath, "version")
+	if lockErr != nil {
+		log.Log(log.FAIL, "failed to re-acquire lock after verification: %v", lockErr)
+		log.Log(log.INFO, "rollback aborted - another instance may have started")
+		os.Exit(1)
+	}
+	if runErr != nil {
+		log.Log(log.FAIL, "backup %s doesn't run: %v", backupPath, runErr)
+		log.Log(log.INFO, "rollback aborted - existing binary unchanged")
+		os.Exit(1)
+	}
+	backupVersion, err := semver.Extract(string(output))
+	if err != nil {
+		backupVersion = strings.TrimSpace(string(output))
+	}
+	log.Log(log.FOUND, "backup %s is version %s", backupPath, backupVersion)
+	if !yes {
+		log.Log(log.ACTION, "roll back from %s to %s? (y/N): ", version.Get(), backupVersion)
+		if !confirm() {
+			log.Log(log.INFO, "rollback cancelled")
+			return
+		}
+	}
+
+	// Swap the two binaries through a third name; Windows can't overwrite a running
+	// executable but can rename it, elsewhere the running one is copied so the path
+	// is never missing
+	swapPath := target + ".new"
+	if runtime.GOOS == "windows" {
+		err = os.Rename(target, swapPath)
+	} else {
+		err = copyFile(target, swapPath)
+	}
+	if err == nil {
+		if err = renameFile(backupPath, target); err != nil && runtime.GOOS == "windows" {
+			os.Rename(swapPath, target)
+		}
+	}
+	if err != nil {
+		os.Remove(swapPath)
+		recordAction(journal.Entry{Action: journal.ActionRollback, Target: target, Result: journal.ResultFailed, Detail: err.Error()})
+		log.Log(log.FAIL, "Failed to roll back: %v", err)
+		log.Log(log.INFO, "rollback aborted - existing binary unchanged")
+		os.Exit(1)
+	}
+	if err := renameFile(swapPath, backupPath); err != nil {
+		log.Log(log.WARN, "the binary rolled back from is at %s: %v", swapPath, err)
+	}
+
+	recordAction(journal.Entry{
+		Action: journal.ActionRollback,
+		Target: target,
+		Result: journal.ResultOK,
+		Detail: version.Get() + " -> " + backupVersion,
+	})
+	log.Log(log.OK, "rolled back from %s to %s", version.Get(), backupVersion)
+	log.Log(log.INFO, "%s is kept as %s; zap update --rollback again to undo", version.Get(), backupPath)
+}
+
+// updateCheck is the answer of zap update --check --json
+type updateCheck struct {
+	Current         string `json:"current"`
+	Latest          string `json:"latest"` // "" if the channel has no release
+	Channel         string `json:"channel"`
+	UpdateAvailable bool   `json:"update_available"`
+}
+
+// checkForUpdate reports whether the channel has a release newer than the running zap,
+// without installing anything
+func checkForUpdate(ctx context.Context, target updateTarget, jsonOutput bool) {
+	if target.version != nil {
+		log.Log(log.FAIL, "--check can't be combined with --to")
+		os.Exit(1)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	releases, err := release.List(ctx)
+	if err != nil {
+		log.Log(log.FAIL, "Failed to check for updates: %v", err)
+		os.Exit(1)
+	}
+
+	result := updateCheck{Current: version.Get(), Channel: target.channel}
+	latest, ok := target.selectRelease(releases)
+	if ok {
+		result.Latest = latest.Version.String()
+		current, err := semver.Parse(version.Get())
+		result.UpdateAvailable = err != nil || latest.Version.Compare(current) > 0
+	}
+	if target.channel == channelStable {
+		state.SaveUpdateCheck(state.UpdateCheck{Time: time.Now(), Latest: result.Latest})
+	}
+
+	if jsonOutput {
+		data, _ := json.Marshal(result)
+		fmt.Println(string(data))
+		return
+	}
+	switch {
+	case !ok:
+		log.Log(log.INFO, "no release published on the %s channel", target.channel)
+	case result.UpdateAvailable:
+		log.Log(log.FOUND, "update available: %s -> %s (run zap update%s)", version.Get(), latest.Version, channelFlag(target))
+	default:
+		log.Log(log.OK, "up to date (version %s, newest on the %s channel: %s)", version.Get(), target.channel, latest.Version)
+	}
+}
+
+// channelFlag is the --channel a zap update hint needs to stay on target's channel
+func channelFlag(target updateTarget) string {
+	if target.channel == channelStable {
+		return ""
+	}
+	return " --channel " + target.channel
+}
+
+// startUpdateCheck looks for a newer stable release in the background, at most once a
+// day, when update_check is on. The function it returns prints a one-line hint when a
+// newer release is known by the end of the command. It gives a check still running
+// then a second to finish; one that takes longer is redone by a later command.
+func startUpdateCheck(cfg *config.Config, commandName string, machineOutput bool) func() {
+	noHint := func() {}
+	if !cfg.UpdateCheck || machineOutput || testmode.Enabled() || !isatty.IsTerminal(os.Stdout.Fd()) {
+		return noHint
+	}
+	switch commandName {
+	case "update", "version", "spec", "completion", "help":
+		return noHint
+	}
+
+	var last *state.UpdateCheck
+	if st, err := state.Load(); err == nil {
+		last = st.UpdateCheck
+	}
+	var fresh chan *state.UpdateCheck
+	if last.Due() {
+		fresh = make(chan *state.UpdateCheck, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			check := state.UpdateCheck{Time: time.Now()}
+			if last != nil {
+				check.Latest = last.Latest
+			}
+			if releases, err := release.List(ctx); err != nil {
+				log.DebugLog("update check failed: %v", err)
+			} else if latest, ok := (updateTarget{channel: channelStable}).selectRelease(releases); ok {
+				check.Latest = latest.Version.String()
+			}
+			if err := state.SaveUpdateCheck(check); err != nil {
+				log.DebugLog("failed to save update check: %v", err)
+			}
+			fresh <- &check
+		}()
+	}
+
+	return func() {
+		check := last
+		if fresh != nil {
+			select {
+			case check = <-fresh:
+			case <-time.After(time.Second):
+			}
+		}
+		if check == nil || check.Latest == "" {
+			return
+		}
+		latest, err := semver.Parse(check.Latest)
+		current, currentErr := semver.Parse(version.Get())
+		if err != nil || currentErr != nil || latest.Compare(current) <= 0 {
+			return
+		}
+		log.Log(log.INFO, "zap %s is available (you have %s) - run zap update", latest, version.Get())
+	}
+}
+
+// getBinaryArchitecture determines the architecture of a compiled binary
+func getBinaryArchitecture(binaryPath string) (string, error) {
+	if runtime.GOOS == "windows" {
+		// Windows: use file command or PE header parsing
+		// For now, assume it matches runtime.GOARCH if we can't determine
+		return runtime.GOARCH, nil
+	}
+
+	// Unix: use file command to determine architecture
+	output, err := execx.Run(context.Background(), "file", binaryPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to run file command: %w", err)
+	}
+
+	fileOutput := strings.ToLower(string(output))
+
+	// Parse architecture from file output
+	// Examples:
+	// "ELF 64-bit LSB executable, x86-64" -> "amd64"
+	// "Mach-O 64-bit executable arm64" -> "arm64"
+	// "ELF 64-bit LSB executable, ARM aarch64" -> "arm64"
+
+	if strings.Contains(fileOutput, "x86-64") || strings.Contains(fileOutput, "x86_64") {
+		return "amd64", nil
+	}
+	if strings.Contains(fileOutput, "aarch64") || strings.Contains(fileOutput, "arm64") {
+		return "arm64", nil
+	}
+	if strings.Contains(fileOutput, "arm") && !strings.Contains(fileOutput, "arm64") {
+		return "arm", nil
+	}
+	if strings.Contains(fileOutput, "386") || strings.Contains(fileOutput, "i386") {
+		return "386", nil
+	}
+	if strings.Contains(fileOutput, "ppc64") {
+		return "ppc64", nil
+	}
+	if strings.Contains(fileOutput, "mips") {
+		return "mips", nil
+	}
+
+	// If we can't determine, return error
+	return "", fmt.Errorf("unable to determine architecture from file output: %s", fileOutput)
+}
+
+// updateComplete reports a finished update and its summary
+func updateComplete(outcome *summary.Summary) {
+	log.Log(log.OK, "update complete!")
+	outcome.Count("updated", 1, "binary")
+	outcome.Print(summary.FormatText)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hugoev/zap/internal/version"
+)
+
+var versionCommand = &command{
+	spec:     commandSpec{Name: "version", Aliases: []string{"v"}, Description: "Show version", Flags: commonFlags},
+	readOnly: always,
+	run: func(ctx context.Context, inv *invocation) {
+		if inv.jsonOutput {
+			fmt.Printf(`{"version":"%s","commit":"%s","date":"%s"}`+"\n", version.Get(), version.GetCommit(), version.GetDate())
+		} else {
+			fmt.Printf("zap version %s\n", version.Get())
+		}
+	},
+}
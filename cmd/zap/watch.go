@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/state"
+	"github.com/hugoev/zap/internal/summary"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// What happened on a port, in watchEvent
+const (
+	eventBound    = "bound"
+	eventReleased = "released"
+	eventCPUSpike = "cpu_spike"
+)
+
+// watchEvent is a line of `zap ports --watch --json`: a listener that appeared, went
+// away or has been busy for cpu_spike_minutes, or what --auto-kill did to it (the ports
+// JSON actions)
+type watchEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	portListener
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"`
+	// CPUPercent and Suggestion are set for cpu_spike: the usage over the last scan, in
+	// percent of one core, and the command that stops the listener (unless protected)
+	CPUPercent int    `json:"cpu_percent,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// cpuUsage follows a listener's CPU time from scan to scan
+type cpuUsage struct {
+	cpu time.Duration
+	at  time.Time
+	// busySince is when usage went above cpu_spike_percent; zero while below it
+	busySince time.Time
+	reported  bool
+}
+
+// handleWatch re-scans the ports every interval until interrupted, reporting listeners
+// as they bind and go away, and listeners that use more than cpu_spike_percent of a
+// core for cpu_spike_minutes. With --auto-kill, new listeners that are safe dev servers
+// are terminated without asking; busy ones never are. The instance lock is only held while terminating, so
+// other zap commands can run in the meantime.
+func handleWatch(ctx context.Context, inv *invocation) {
+	cfg, flags, flagValues := inv.cfg, inv.flags, inv.flagValues
+	portsToScan, protocols := scanTargets(ctx, cfg, flags, flagValues)
+	iface, filterInterface := flagValues["interface"]
+	if filterInterface && iface != ports.InterfaceLoopback && iface != ports.InterfaceAll {
+		log.Log(log.FAIL, "Invalid --interface: %s (must be %s or %s)", iface, ports.InterfaceLoopback, ports.InterfaceAll)
+		os.Exit(1)
+	}
+
+	interval := cfg.WatchInterval()
+	if value, ok := flagValues["interval"]; ok {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 100*time.Millisecond {
+			log.Log(log.FAIL, "Invalid --interval: %s (use e.g. 2s, 1m; at least 100ms)", value)
+			os.Exit(1)
+		}
+		interval = parsed
+	}
+	autoKill := flags["auto-kill"]
+	cpuPercent, cpuDuration := cfg.CPUSpike()
+
+	currentProjectRoot := ""
+	if cfg.ProtectsCurrentProject() {
+		if cwd, err := os.Getwd(); err == nil {
+			currentProjectRoot = ports.FindProjectRoot(cwd)
+		}
+	}
+
+	inv.lock.Release()
+	watched := formatPorts(portsToScan)
+	if flags["all"] {
+		watched = "every listening port"
+	}
+	log.Log(log.SCAN, "watching %s every %v (Ctrl-C to stop)", watched, interval)
+	if autoKill {
+		log.Log(log.INFO, "new safe dev servers will be terminated without asking (--auto-kill)")
+	}
+
+	outcome := summary.New("watch", inv.dryRun)
+	terminatedStat := "terminated"
+	if inv.dryRun {
+		terminatedStat = "would_terminate"
+	}
+	concurrency := scanConcurrency(cfg, flagValues)
+	// known is what the previous scan found, once per port and PID; nil before the first scan
+	var known map[string]bool
+	var previous []ports.ProcessInfo
+	// retry is what --auto-kill couldn't terminate because another zap run held the lock
+	var retry map[string]bool
+	usage := make(map[int]*cpuUsage)
+	killed := 0
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for ctx.Err() == nil {
+		if flags["all"] && known != nil {
+			// Ports bound since the last scan are only found by listing them again
+			portsToScan = listeningPorts(ctx, protocols)
+		}
+		processes, err := ports.ScanPortsRangeWithProtocols(ctx, portsToScan, protocols, concurrency)
+		var partialScan *ports.PartialScanError
+		if errors.As(err, &partialScan) {
+			log.VerboseLog("scan incomplete: %v; not checked: %s", partialScan.Err, formatPorts(partialScan.Unchecked))
+			err = nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			log.Log(log.FAIL, "Failed to scan ports: %v", err)
+			os.Exit(1)
+		}
+		if filterInterface {
+			processes, _ = ports.FilterByInterface(processes, iface)
+		}
+
+		// A process listening on several addresses of a port is reported once
+		current := make(map[string]bool, len(processes))
+		var listeners []ports.ProcessInfo
+		for _, proc := range processes {
+			if key := watchKey(proc); !current[key] {
+				current[key] = true
+				listeners = append(listeners, proc)
+			}
+		}
+
+		for _, proc := range previous {
+			if !current[watchKey(proc)] {
+				reportWatch(inv, eventReleased, proc)
+			}
+		}
+		var autoKillable []ports.ProcessInfo
+		for _, proc := range listeners {
+			if known[watchKey(proc)] {
+				if retry[watchKey(proc)] {
+					autoKillable = append(autoKillable, proc)
+				}
+				continue
+			}
+			reportWatch(inv, eventBound, proc)
+			// Listeners already there when watching started are reported, never auto-killed
+			if known != nil && autoKill && watchAutoKillable(cfg, proc, currentProjectRoot) {
+				autoKillable = append(autoKillable, proc)
+			}
+		}
+		retry = nil
+		if len(autoKillable) > 0 {
+			stopped, locked := autoKillWatched(ctx, inv, autoKillable)
+			killed += stopped
+			if !locked {
+				retry = make(map[string]bool, len(autoKillable))
+				for _, proc := range autoKillable {
+					retry[watchKey(proc)] = true
+				}
+			}
+		}
+		if cpuDuration > 0 {
+			checkCPUSpikes(ctx, inv, listeners, usage, cpuPercent, cpuDuration)
+		}
+		known, previous = current, listeners
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+	}
+
+	if killed > 0 && !inv.dryRun {
+		if err := state.RecordKills(killed); err != nil {
+			log.VerboseLog("failed to update lifetime stats: %v", err)
+		}
+	}
+	// The events already listed what --auto-kill did, so the summary only counts it
+	outcome.Count(terminatedStat, killed, "process(es)")
+	if inv.jsonOutput {
+		outcome.Print(summary.FormatNDJSON)
+		return
+	}
+	log.Log(log.OK, "stopped watching")
+	if autoKill {
+		outcome.Print(summary.FormatText)
+	}
+}
+
+// watchKey identifies a listener between scans: the same PID on the same port; a
+// restarted server has a new PID and is reported as a new listener
+func watchKey(proc ports.ProcessInfo) string {
+	return fmt.Sprintf("%s/%d", proc.PortLabel(), proc.PID)
+}
+
+// watchAutoKillable reports whether --auto-kill may terminate proc without asking: a
+// safe dev server that isn't protected, ignored, part of the current project or
+// Docker's forwarder
+func watchAutoKillable(cfg *config.Config, proc ports.ProcessInfo, currentProjectRoot string) bool {
+	return !cfg.IsProtected(proc) &&
+		!cfg.IsProcessIgnored(proc.Cmd, proc.WorkingDir) &&
+		!ports.InProject(proc, currentProjectRoot) &&
+		!ports.IsDockerForwarder(proc) &&
+		ports.InfrastructureReason(proc) == "" &&
+		ports.SafeDevServerReason(proc) != ""
+}
+
+// autoKillWatched terminates procs under the instance lock and returns how many were
+// stopped. It returns false if another zap run holds the lock, so they are tried again
+// on the next scan.
+func autoKillWatched(ctx context.Context, inv *invocation, procs []ports.ProcessInfo) (int, bool) {
+	if inv.dryRun {
+		for _, proc := range procs {
+			log.Log(log.STOP, "PID %d (would terminate)", proc.PID)
+			reportWatchAction(inv, actionWouldTerminate, proc)
+		}
+		return len(procs), true
+	}
+	if err := inv.lock.Reacquire(); err != nil {
+		log.Log(log.SKIP, "not terminating %d new process(es) yet: %v", len(procs), err)
+		return 0, false
+	}
+	defer inv.lock.Release()
+
+	killed := killProcesses(ctx, inv.cfg, procs)
+	for _, proc := range procs {
+		action := actionTerminated
+		if ports.IsProcessRunning(proc.PID) {
+			action = actionFailed
+		}
+		reportWatchAction(inv, action, proc)
+	}
+	return killed, true
+}
+
+// checkCPUSpikes samples the CPU time of listeners and reports the ones that have used
+// more than percent of a core since the last scan, for duration, once until they calm
+// down. They are only reported, with the command that stops them: a busy dev server may
+// be compiling, so it is never terminated automatically.
+func checkCPUSpikes(ctx context.Context, inv *invocation, listeners []ports.ProcessInfo, usage map[int]*cpuUsage, percent int, duration time.Duration) {
+	seen := make(map[int]bool, len(listeners))
+	for _, proc := range listeners {
+		// A process listening on several ports is sampled once, and reported on the first
+		if seen[proc.PID] {
+			continue
+		}
+		seen[proc.PID] = true
+		cpu, err := ports.CPUTime(ctx, proc.PID)
+		if err != nil {
+			log.VerboseLog("CPU time of PID %d not available: %v", proc.PID, err)
+			continue
+		}
+		now := time.Now()
+		last, ok := usage[proc.PID]
+		usage[proc.PID] = &cpuUsage{cpu: cpu, at: now}
+		if !ok || !now.After(last.at) {
+			continue
+		}
+		used := int(100 * (cpu - last.cpu) / now.Sub(last.at))
+		if used < percent {
+			continue
+		}
+		sample := usage[proc.PID]
+		sample.busySince, sample.reported = last.busySince, last.reported
+		if sample.busySince.IsZero() {
+			sample.busySince = last.at
+		}
+		if !sample.reported && now.Sub(sample.busySince) >= duration {
+			sample.reported = true
+			reportCPUSpike(inv, proc, used, now.Sub(sample.busySince))
+		}
+	}
+	for pid := range usage {
+		if !seen[pid] {
+			delete(usage, pid)
+		}
+	}
+}
+
+// reportCPUSpike warns about a listener busy for busyFor, or with --json prints it
+func reportCPUSpike(inv *invocation, proc ports.ProcessInfo, used int, busyFor time.Duration) {
+	suggestion := ""
+	if !inv.cfg.IsProtected(proc) {
+		suggestion = fmt.Sprintf("zap kill %d", proc.Port)
+	}
+	if inv.jsonOutput {
+		event := newWatchEvent(inv.cfg, eventCPUSpike, proc)
+		event.CPUPercent, event.Suggestion = used, suggestion
+		data, _ := json.Marshal(event)
+		fmt.Println(string(data))
+		return
+	}
+	listener := describeListener(inv.cfg, proc)
+	message := fmt.Sprintf("busy     %s PID %d (%s, %s) at %d%% CPU for %s", proc.PortLabel(), proc.PID, proc.Name, listener.Class, used, formatRuntime(busyFor))
+	if suggestion != "" {
+		message += " - stop it with " + suggestion
+	}
+	log.Log(log.WARN, "%s", message)
+}
+
+// reportWatch logs a listener that bound or was released, or with --json prints it
+func reportWatch(inv *invocation, event string, proc ports.ProcessInfo) {
+	if inv.jsonOutput {
+		printWatchEvent(inv.cfg, event, proc)
+		return
+	}
+	if event == eventReleased {
+		log.Log(log.INFO, "released %s PID %d (%s)", proc.PortLabel(), proc.PID, proc.Name)
+		return
+	}
+	procInfo := fmt.Sprintf("bound    %s PID %d (%s)", proc.PortLabel(), proc.PID, proc.Name)
+	if proc.Cmd != "" {
+		procInfo += " - " + truncateString(proc.Cmd, 60)
+	}
+	if proc.WorkingDir != "" {
+		procInfo += fmt.Sprintf(" [%s]", truncateString(proc.WorkingDir, 40))
+	}
+	log.Log(log.FOUND, procInfo)
+}
+
+// reportWatchAction prints what --auto-kill did with --json; otherwise killProcesses
+// has already logged it
+func reportWatchAction(inv *invocation, action string, proc ports.ProcessInfo) {
+	if inv.jsonOutput {
+		printWatchEvent(inv.cfg, action, proc)
+	}
+}
+
+func printWatchEvent(cfg *config.Config, event string, proc ports.ProcessInfo) {
+	data, _ := json.Marshal(newWatchEvent(cfg, event, proc))
+	fmt.Println(string(data))
+}
+
+func newWatchEvent(cfg *config.Config, event string, proc ports.ProcessInfo) watchEvent {
+	return watchEvent{
+		Time:         testmode.Now(),
+		Event:        event,
+		portListener: describeListener(cfg, proc),
+		Port:         proc.Port,
+		Protocol:     proc.Protocol,
+	}
+}
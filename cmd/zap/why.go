@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// portListener is one process holding a port, as reported by `zap why`
+type portListener struct {
+	PID         int       `json:"pid"`
+	Name        string    `json:"name"`
+	Cmd         string    `json:"cmd"`
+	User        string    `json:"user"`
+	StartTime   time.Time `json:"start_time"`
+	WorkingDir  string    `json:"working_dir"`
+	Executable  string    `json:"executable"` // the program file, "" if unknown
+	Arch        string    `json:"arch"`       // e.g. "arm64", "" if unknown
+	Emulated    bool      `json:"emulated"`   // translated by Rosetta or run by an emulator
+	Project     string    `json:"project"`
+	BindAddress string    `json:"bind_address"`
+	Ignored     bool      `json:"ignored"`
+	Class       string    `json:"class"` // "safe", "infrastructure" or "unknown"
+	Reason      string    `json:"reason"`
+	// Protection is why zap never terminates the listener, e.g. a protected_processes
+	// pattern it matches; "" if it may
+	Protection string `json:"protection,omitempty"`
+	// Container is set when the listener forwards a port published by a container (--docker)
+	Container *ports.Container `json:"container,omitempty"`
+}
+
+// portReport answers "why can't I bind to this port?"
+type portReport struct {
+	Port      int            `json:"port"`
+	InUse     bool           `json:"in_use"`
+	Protected bool           `json:"protected"`
+	Listeners []portListener `json:"listeners"`
+	TimeWait  int            `json:"time_wait"`
+}
+
+var whyCommand = &command{
+	spec: commandSpec{
+		Name: "why", Description: "Explain who holds a port, since when, and whether zap would free it",
+		Args:  []argSpec{{Name: "port", Dynamic: dynamicListeningPorts}},
+		Flags: withCommon("backend"),
+	},
+	readOnly: always,
+	run: func(ctx context.Context, inv *invocation) {
+		handleWhy(ctx, inv.cfg, inv.positional, inv.jsonOutput)
+	},
+}
+
+// handleWhy explains in one step who occupies a port and whether zap would free it
+func handleWhy(ctx context.Context, cfg *config.Config, args []string, jsonOutput bool) {
+	if len(args) == 0 {
+		log.Log(log.FAIL, "Usage: zap why <port>")
+		os.Exit(1)
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil || port < 1 || port > 65535 {
+		log.Log(log.FAIL, "Invalid port: %s (must be 1-65535)", args[0])
+		os.Exit(1)
+	}
+
+	processes, err := ports.ScanPortsRange(ctx, []int{port})
+	var partialScan *ports.PartialScanError
+	if err != nil && !errors.As(err, &partialScan) {
+		log.Log(log.FAIL, "Failed to scan port %d: %v", port, err)
+		os.Exit(1)
+	}
+
+	report := portReport{
+		Port:      port,
+		InUse:     len(processes) > 0 || ports.IsPortInUse(port),
+		Protected: cfg.IsPortProtected(port),
+		Listeners: []portListener{},
+	}
+	seenPIDs := make(map[int]bool)
+	for _, proc := range processes {
+		if seenPIDs[proc.PID] {
+			continue
+		}
+		seenPIDs[proc.PID] = true
+		listener := describeListener(cfg, proc)
+		report.Protected = report.Protected || listener.Protection != ""
+		report.Listeners = append(report.Listeners, listener)
+	}
+	if count, err := ports.TimeWaitCount(ctx, port); err == nil {
+		report.TimeWait = count
+	} else {
+		log.VerboseLog("could not check TIME_WAIT connections: %v", err)
+	}
+
+	if jsonOutput {
+		data, _ := json.Marshal(report)
+		fmt.Println(string(data))
+		return
+	}
+	printPortReport(report)
+}
+
+func describeListener(cfg *config.Config, proc ports.ProcessInfo) portListener {
+	listener := portListener{
+		PID:         proc.PID,
+		Name:        proc.Name,
+		Cmd:         proc.Cmd,
+		User:        proc.User,
+		StartTime:   proc.StartTime,
+		WorkingDir:  proc.WorkingDir,
+		Executable:  proc.Executable,
+		Arch:        proc.Arch,
+		Emulated:    proc.Emulated,
+		BindAddress: proc.BindAddress,
+		Ignored:     cfg.IsProcessIgnored(proc.Cmd, proc.WorkingDir),
+		Protection:  cfg.ProtectionReason(proc),
+		Class:       "unknown",
+		Container:   proc.Container,
+	}
+	if proc.WorkingDir != "" {
+		listener.Project = ports.FindProjectRoot(proc.WorkingDir)
+	}
+	if reason := ports.InfrastructureReason(proc); reason != "" {
+		listener.Class, listener.Reason = "infrastructure", fmt.Sprintf("matched keyword %q", reason)
+	} else if reason := ports.SafeDevServerReason(proc); reason != "" {
+		listener.Class, listener.Reason = "safe", reason
+	}
+	return listener
+}
+
+func printPortReport(report portReport) {
+	if !report.InUse {
+		log.Log(log.OK, ":%d is free", report.Port)
+	} else if len(report.Listeners) == 0 && toolCapabilities != nil && !toolCapabilities.NeedsSudo {
+		log.Log(log.FOUND, ":%d is in use, but by a process zap can't see (in a container or another network namespace?)", report.Port)
+	} else if len(report.Listeners) == 0 {
+		log.Log(log.FOUND, ":%d is in use, but by a process zap can't see (another user's? try with sudo)", report.Port)
+	}
+
+	for _, l := range report.Listeners {
+		since := "unknown"
+		if !l.StartTime.IsZero() {
+			since = fmt.Sprintf("%s (%s ago)", l.StartTime.Format("2006-01-02 15:04"), formatRuntime(testmode.Since(l.StartTime)))
+		}
+		log.Log(log.FOUND, ":%d is in use by PID %d (%s), user %s", report.Port, l.PID, l.Name, l.User)
+		log.Log(log.INFO, "  since:   %s", since)
+		if l.Cmd != "" {
+			log.Log(log.INFO, "  command: %s", truncateString(l.Cmd, 100))
+		}
+		if l.Executable != "" {
+			log.Log(log.INFO, "  program: %s", l.Executable)
+		}
+		if l.Emulated {
+			log.Log(log.INFO, "  arch:    %s, emulated - a native build would use less CPU", l.Arch)
+		}
+		if l.Project != "" {
+			log.Log(log.INFO, "  project: %s", l.Project)
+		} else if l.WorkingDir != "" {
+			log.Log(log.INFO, "  cwd:     %s", l.WorkingDir)
+		}
+		if l.BindAddress != "" {
+			log.Log(log.INFO, "  bound:   %s", l.BindAddress)
+		}
+		switch {
+		case l.Protection != "":
+			log.Log(log.SKIP, "  protected (%s), zap ports leaves it alone", l.Protection)
+		case l.Ignored:
+			log.Log(log.SKIP, "  ignored, zap ports leaves it alone (zap config ignored list)")
+		case l.Class == "safe":
+			log.Log(log.INFO, "  safe dev server (%s), zap ports can free it", l.Reason)
+		case l.Class == "infrastructure":
+			log.Log(log.INFO, "  infrastructure (%s), zap ports asks first", l.Reason)
+		default:
+			log.Log(log.INFO, "  unknown process, zap ports asks first")
+		}
+	}
+
+	if report.Protected && len(report.Listeners) == 0 {
+		log.Log(log.INFO, ":%d is in protected_ports", report.Port)
+	}
+	if report.TimeWait > 0 {
+		log.Log(log.INFO, "%d connection(s) on :%d in TIME_WAIT - servers without SO_REUSEADDR may fail to bind until they expire (up to a few minutes)", report.TimeWait, report.Port)
+	}
+}
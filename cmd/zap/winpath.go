@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/log"
+)
+
+// windowsUserPath reads the user-level Path from the registry. %PATH% can't be used:
+// it is the merged system+user value and writing it back with setx duplicates entries
+func windowsUserPath() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	output, err := execx.Run(ctx, "reg", "query", `HKCU\Environment`, "/v", "Path")
+	if err != nil {
+		// No user Path value yet
+		return "", nil
+	}
+
+	// Output line: "    Path    REG_EXPAND_SZ    C:\Users\me\go\bin;..."
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.EqualFold(fields[0], "Path") && strings.HasPrefix(fields[1], "REG_") {
+			idx := strings.Index(line, fields[1]) + len(fields[1])
+			return strings.TrimSpace(line[idx:]), nil
+		}
+	}
+	return "", nil
+}
+
+// setWindowsUserPath persists the user Path with setx (applies to new terminals)
+func setWindowsUserPath(value string) error {
+	// setx silently truncates values longer than 1024 characters
+	if len(value) > 1024 {
+		return fmt.Errorf("user PATH would exceed setx's 1024 character limit - edit it in System Properties instead")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := execx.Run(ctx, "setx", "Path", value); err != nil {
+		return fmt.Errorf("setx failed: %w", err)
+	}
+	return nil
+}
+
+// addWindowsUserPath appends dir to the user Path if it isn't there yet
+func addWindowsUserPath(dir string) error {
+	userPath, err := windowsUserPath()
+	if err != nil {
+		return err
+	}
+	for _, entry := range strings.Split(userPath, ";") {
+		if strings.EqualFold(strings.TrimRight(entry, `\`), strings.TrimRight(dir, `\`)) {
+			log.Log(log.INFO, "%s is already in your user PATH", dir)
+			return nil
+		}
+	}
+
+	newPath := dir
+	if userPath != "" {
+		newPath = strings.TrimRight(userPath, ";") + ";" + dir
+	}
+	if err := setWindowsUserPath(newPath); err != nil {
+		return err
+	}
+
+	log.Log(log.OK, "added %s to your user PATH", dir)
+	log.Log(log.INFO, "open a new terminal to use zap")
+	log.Log(log.INFO, "undo with: zap setup path --remove")
+	return nil
+}
+
+// removeWindowsUserPath drops dir from the user Path; reports whether anything changed
+func removeWindowsUserPath(dir string) (bool, error) {
+	userPath, err := windowsUserPath()
+	if err != nil || userPath == "" {
+		return false, err
+	}
+
+	var kept []string
+	for _, entry := range strings.Split(userPath, ";") {
+		if entry == "" || strings.EqualFold(strings.TrimRight(entry, `\`), strings.TrimRight(dir, `\`)) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+
+	newPath := strings.Join(kept, ";")
+	if newPath == strings.Trim(userPath, ";") {
+		return false, nil
+	}
+	return true, setWindowsUserPath(newPath)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes of --yes=<scopes>: the kinds of action it approves without asking
+const (
+	approveSafe           = "safe"           // safe dev servers and stale cleanup directories
+	approveUnknown        = "unknown"        // processes no rule classified
+	approveInfrastructure = "infrastructure" // databases, caches and similar services
+	approveContainers     = "containers"     // docker stop of containers publishing a port
+	approveAll            = "all"
+)
+
+var approveScopes = []string{approveSafe, approveUnknown, approveInfrastructure, approveContainers, approveAll}
+
+// approval is what --yes approves without asking; plain --yes (or -y) is --yes=all,
+// which keeps covering everything that would be prompted. Processes of the current
+// project are asked about regardless.
+type approval map[string]bool
+
+// parseApproval reads --yes, -y and --yes=<scopes> from args. Only the --yes=... form
+// takes scopes: in `zap kill --yes 3000` the port is not a scope.
+func parseApproval(args []string, flags map[string]bool) (approval, error) {
+	approve := approval{}
+	for _, arg := range args {
+		value, ok := strings.CutPrefix(arg, "--yes=")
+		if !ok {
+			continue
+		}
+		for _, scope := range strings.Split(value, ",") {
+			scope = strings.TrimSpace(scope)
+			if !slices.Contains(approveScopes, scope) {
+				return nil, fmt.Errorf("unknown scope %q (use %s)", scope, strings.Join(approveScopes, ", "))
+			}
+			approve[scope] = true
+		}
+	}
+	if len(approve) == 0 && flags["yes"] {
+		approve[approveAll] = true
+	}
+	return approve, nil
+}
+
+// covers reports whether scope is approved without asking
+func (a approval) covers(scope string) bool {
+	return a[approveAll] || a[scope]
+}
+
+// all reports whether everything is approved, as commands without risk levels require
+func (a approval) all() bool {
+	return a[approveAll]
+}
@@ -0,0 +1,93 @@
+// Package age parses and formats the ages zap works with: how long ago a directory was
+// modified, and how old a cleanup candidate has to be. Ages are whole days, written as
+// a number of days ("90") or with a unit ("90d", "3w", "6mo", "1y").
+package age
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// units are the suffixes ParseDays accepts, in days; a month is 30 days and a year 365
+var units = []struct {
+	suffix string
+	days   int
+}{
+	{"mo", 30},
+	{"d", 1},
+	{"w", 7},
+	{"y", 365},
+}
+
+// ParseDays parses an age into days: "90", "90d", "3w", "6mo" or "1y"
+func ParseDays(value string) (int, error) {
+	text := strings.ToLower(strings.TrimSpace(value))
+	number, multiplier := text, 1
+	for _, unit := range units {
+		if prefix, ok := strings.CutSuffix(text, unit.suffix); ok {
+			number, multiplier = prefix, unit.days
+			break
+		}
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(number))
+	if err != nil || n < 0 {
+		if strings.HasSuffix(text, "m") {
+			return 0, fmt.Errorf("invalid age %q (use mo for months, e.g. 6mo)", value)
+		}
+		return 0, fmt.Errorf("invalid age %q (use days, or e.g. 90d, 3w, 6mo, 1y)", value)
+	}
+	return n * multiplier, nil
+}
+
+// Format describes an age of days for people, rounded down to the largest unit that
+// reads naturally: "1 day", "12 days", "3 weeks", "5 months", "2 years"
+func Format(days int) string {
+	switch {
+	case days < 14:
+		return plural(days, "day")
+	case days < 60:
+		return plural(days/7, "week")
+	case days < 2*365:
+		return plural(days/30, "month")
+	default:
+		return plural(days/365, "year")
+	}
+}
+
+// Of formats an age given as a duration, e.g. the time since a directory was modified
+func Of(d time.Duration) string {
+	return Format(int(d.Hours() / 24))
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// Days is an age in a config file: a number of days, or a string ParseDays accepts.
+// It is always written back as a number.
+type Days int
+
+// UnmarshalJSON accepts 90 as well as "90d", "6mo"
+func (d *Days) UnmarshalJSON(data []byte) error {
+	var n int
+	if err := json.Unmarshal(data, &n); err == nil {
+		*d = Days(n)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid age %s (use days, or e.g. \"90d\", \"6mo\")", data)
+	}
+	days, err := ParseDays(text)
+	if err != nil {
+		return err
+	}
+	*d = Days(days)
+	return nil
+}
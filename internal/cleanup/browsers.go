@@ -0,0 +1,104 @@
+package cleanup
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// maxElectronSearchDepth limits how deep projects are searched for Electron apps
+const maxElectronSearchDepth = 4
+
+// findBrowserDownloads proposes browser builds downloaded by Playwright and Puppeteer
+// that no test run has used recently, and Chromium profiles left behind by Electron
+// dev builds of your projects
+func findBrowserDownloads(homeDir string, projectDirs []string) []categoryCandidate {
+	var candidates []categoryCandidate
+
+	cacheRoot := filepath.Join(homeDir, ".cache")
+	userDataRoot := filepath.Join(homeDir, ".config")
+	if runtime.GOOS == "darwin" {
+		cacheRoot = filepath.Join(homeDir, "Library", "Caches")
+		userDataRoot = filepath.Join(homeDir, "Library", "Application Support")
+	}
+
+	// ms-playwright/<browser>-<revision>, one directory per browser build
+	playwright, _ := filepath.Glob(filepath.Join(cacheRoot, "ms-playwright", "*-*"))
+	for _, path := range playwright {
+		candidates = append(candidates, categoryCandidate{
+			Path:          path,
+			Rule:          "Playwright browser build not used recently (reinstalled by `npx playwright install`)",
+			UseAccessTime: true,
+		})
+	}
+	// puppeteer/<browser>/<platform>-<build id>; Puppeteer always uses ~/.cache
+	puppeteer, _ := filepath.Glob(filepath.Join(homeDir, ".cache", "puppeteer", "*", "*"))
+	for _, path := range puppeteer {
+		candidates = append(candidates, categoryCandidate{
+			Path:          path,
+			Rule:          "Puppeteer browser build not used recently (downloaded again on install)",
+			UseAccessTime: true,
+		})
+	}
+
+	// Electron stores userData under the app name, or "Electron" when started without one
+	appNames := append([]string{"Electron"}, electronAppNames(projectDirs)...)
+	seen := make(map[string]bool)
+	for _, name := range appNames {
+		path := filepath.Join(userDataRoot, name)
+		if seen[path] || !isChromiumProfile(path) {
+			continue
+		}
+		seen[path] = true
+		candidates = append(candidates, categoryCandidate{
+			Path: path,
+			Rule: "Electron userData of dev build " + name,
+		})
+	}
+
+	return candidates
+}
+
+// electronAppNames returns the names of Electron apps among the projects, which their
+// dev builds use for userData
+func electronAppNames(projectDirs []string) []string {
+	var names []string
+	walkProjects(projectDirs, maxElectronSearchDepth, func(path, name string) bool {
+		data, err := os.ReadFile(filepath.Join(path, "package.json"))
+		if err != nil {
+			return false
+		}
+		var pkg struct {
+			Name            string            `json:"name"`
+			ProductName     string            `json:"productName"`
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+		}
+		if json.Unmarshal(data, &pkg) != nil {
+			return false
+		}
+		_, dep := pkg.Dependencies["electron"]
+		_, devDep := pkg.DevDependencies["electron"]
+		if !dep && !devDep {
+			return false
+		}
+		for _, appName := range []string{pkg.ProductName, pkg.Name} {
+			if appName != "" {
+				names = append(names, appName)
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// isChromiumProfile reports whether dir looks like a Chromium user data directory
+func isChromiumProfile(dir string) bool {
+	for _, marker := range []string{"Local State", "Preferences"} {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
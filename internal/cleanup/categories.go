@@ -0,0 +1,308 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// Category groups well-known cache locations outside of projects (IDE caches, model
+// downloads, ...) that regular pattern scanning doesn't reach
+type Category struct {
+	Name        string
+	Description string
+	find        func(homeDir string, projectDirs []string) []categoryCandidate
+}
+
+// categoryCandidate is a directory a category proposes for cleanup
+type categoryCandidate struct {
+	Path string
+	Rule string // why the directory is a candidate, shown by --explain
+	// Orphaned candidates belong to something that no longer exists, so they are
+	// stale regardless of how recently they were written
+	Orphaned bool
+	// UseAccessTime also counts reads as use (downloads are read, never modified)
+	UseAccessTime bool
+	// MinSize skips candidates too small to be worth asking about
+	MinSize int64
+}
+
+var categories = []Category{
+	{
+		Name:        "ide",
+		Description: "JetBrains caches of superseded IDE versions, VS Code caches and storage of deleted workspaces",
+		find:        findIDECaches,
+	},
+	{
+		Name:        "ml",
+		Description: "Hugging Face and PyTorch model downloads, Jupyter checkpoints, wandb and MLflow runs",
+		find:        findMLArtifacts,
+	},
+	{
+		Name:        "browsers",
+		Description: "Playwright and Puppeteer browser downloads and Electron dev-build profiles",
+		find:        findBrowserDownloads,
+	},
+}
+
+// Categories returns the available cleanup categories
+func Categories() []Category {
+	return categories
+}
+
+// CategoryNames returns the names of all cleanup categories
+func CategoryNames() []string {
+	names := make([]string, len(categories))
+	for i, category := range categories {
+		names[i] = category.Name
+	}
+	return names
+}
+
+// ScanCategories finds directories of the named categories ("all" selects every
+// category) that haven't been modified within maxAge. Per-project categories look
+// inside projectDirs. Sizing stops with ctx's error when ctx is done.
+func ScanCategories(ctx context.Context, homeDir string, projectDirs []string, names []string, maxAge time.Duration) ([]DirectoryInfo, error) {
+	selected, err := selectCategories(names)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := testmode.Now().Add(-maxAge)
+	var directories []DirectoryInfo
+	for _, category := range selected {
+		for _, candidate := range category.find(homeDir, projectDirs) {
+			info, err := os.Lstat(candidate.Path)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			usage, err := calculateDirSize(ctx, candidate.Path)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, ctxErr
+			}
+			if err != nil {
+				continue
+			}
+			if usage.Disk < candidate.MinSize {
+				continue
+			}
+			lastModified := usage.Newest
+			if lastModified.IsZero() {
+				lastModified = info.ModTime()
+			}
+			if candidate.UseAccessTime {
+				if accessed := newestAccess(candidate.Path); accessed.After(lastModified) {
+					lastModified = accessed
+				}
+			}
+			if !candidate.Orphaned && lastModified.After(cutoff) {
+				continue
+			}
+			directories = append(directories, DirectoryInfo{
+				Path:         candidate.Path,
+				Size:         usage.Disk,
+				ApparentSize: usage.Apparent,
+				ModTime:      lastModified,
+				Pattern:      candidate.Rule,
+				Category:     category.Name,
+			})
+		}
+	}
+	return directories, nil
+}
+
+func selectCategories(names []string) ([]Category, error) {
+	var selected []Category
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			return categories, nil
+		}
+		found := false
+		for _, category := range categories {
+			if category.Name == name {
+				selected = append(selected, category)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown category %q (available: %s, all)", name, strings.Join(CategoryNames(), ", "))
+		}
+	}
+	return selected, nil
+}
+
+// jetbrainsVersionDir matches per-version directories such as "IntelliJIdea2024.2"
+var jetbrainsVersionDir = regexp.MustCompile(`^([A-Za-z]+)(\d{4})\.(\d+)$`)
+
+// findIDECaches proposes JetBrains caches and logs of IDE versions superseded by a newer
+// install, VS Code's regenerable caches, and VS Code storage of deleted workspaces
+func findIDECaches(homeDir string, projectDirs []string) []categoryCandidate {
+	var candidates []categoryCandidate
+
+	var jetbrainsRoots, vscodeRoots []string
+	switch runtime.GOOS {
+	case "darwin":
+		jetbrainsRoots = []string{
+			filepath.Join(homeDir, "Library", "Caches", "JetBrains"),
+			filepath.Join(homeDir, "Library", "Logs", "JetBrains"),
+		}
+		for _, product := range []string{"Code", "Code - Insiders", "VSCodium"} {
+			vscodeRoots = append(vscodeRoots, filepath.Join(homeDir, "Library", "Application Support", product))
+		}
+	default:
+		jetbrainsRoots = []string{filepath.Join(homeDir, ".cache", "JetBrains")}
+		for _, product := range []string{"Code", "Code - Insiders", "VSCodium"} {
+			vscodeRoots = append(vscodeRoots, filepath.Join(homeDir, ".config", product))
+		}
+	}
+
+	for _, root := range jetbrainsRoots {
+		candidates = append(candidates, supersededJetBrainsDirs(root)...)
+	}
+
+	for _, root := range vscodeRoots {
+		for _, cache := range []string{"Cache", "CachedData", "CachedExtensionVSIXs", "Code Cache", "GPUCache"} {
+			candidates = append(candidates, categoryCandidate{
+				Path: filepath.Join(root, cache),
+				Rule: "VS Code " + cache + " (regenerated on demand)",
+			})
+		}
+		candidates = append(candidates, deletedWorkspaceStorage(filepath.Join(root, "User", "workspaceStorage"))...)
+	}
+
+	return candidates
+}
+
+// supersededJetBrainsDirs returns per-version directories of JetBrains products for
+// which a newer version is also installed
+func supersededJetBrainsDirs(root string) []categoryCandidate {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+
+	type versionDir struct {
+		name    string
+		version [2]int
+	}
+	byProduct := make(map[string][]versionDir)
+	for _, entry := range entries {
+		match := jetbrainsVersionDir.FindStringSubmatch(entry.Name())
+		if !entry.IsDir() || match == nil {
+			continue
+		}
+		year, _ := strconv.Atoi(match[2])
+		minor, _ := strconv.Atoi(match[3])
+		byProduct[match[1]] = append(byProduct[match[1]], versionDir{entry.Name(), [2]int{year, minor}})
+	}
+
+	var candidates []categoryCandidate
+	for _, versions := range byProduct {
+		sort.Slice(versions, func(i, j int) bool {
+			a, b := versions[i].version, versions[j].version
+			return a[0] > b[0] || (a[0] == b[0] && a[1] > b[1])
+		})
+		for _, old := range versions[1:] {
+			candidates = append(candidates, categoryCandidate{
+				Path: filepath.Join(root, old.name),
+				Rule: fmt.Sprintf("JetBrains %s, superseded by %s", old.name, versions[0].name),
+			})
+		}
+	}
+	return candidates
+}
+
+// deletedWorkspaceStorage returns VS Code workspace storage whose workspace folder is gone
+func deletedWorkspaceStorage(storageRoot string) []categoryCandidate {
+	entries, err := os.ReadDir(storageRoot)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []categoryCandidate
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(storageRoot, entry.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "workspace.json"))
+		if err != nil {
+			continue
+		}
+		var workspace struct {
+			Folder string `json:"folder"`
+		}
+		if json.Unmarshal(data, &workspace) != nil || workspace.Folder == "" {
+			continue
+		}
+		folder, err := fileURIToPath(workspace.Folder)
+		if err != nil {
+			continue // remote workspaces can't be checked
+		}
+		if _, err := os.Stat(folder); os.IsNotExist(err) {
+			candidates = append(candidates, categoryCandidate{
+				Path:     dir,
+				Rule:     "VS Code storage of deleted workspace " + folder,
+				Orphaned: true,
+			})
+		}
+	}
+	return candidates
+}
+
+// newestAccess returns the latest access or modification time of any file under path
+func newestAccess(path string) time.Time {
+	var newest time.Time
+	filepath.WalkDir(path, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
+		}
+		if used := lastUsedTime(filePath); used.After(newest) {
+			newest = used
+		}
+		return nil
+	})
+	return newest
+}
+
+// walkProjects visits directories inside projectDirs down to maxDepth levels. visit
+// returns true when it claimed a directory, which is then not descended into. Hidden,
+// dependency and system directories are skipped.
+func walkProjects(projectDirs []string, maxDepth int, visit func(path, name string) bool) {
+	for _, root := range projectDirs {
+		filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+			if err != nil || !entry.IsDir() {
+				return nil
+			}
+			if visit(path, entry.Name()) {
+				return filepath.SkipDir
+			}
+			if path == root {
+				return nil
+			}
+			if strings.HasPrefix(entry.Name(), ".") || isCleanupPattern(entry.Name()) || shouldSkipSystemDirectory(path, root) {
+				return filepath.SkipDir
+			}
+			if rel, err := filepath.Rel(root, path); err == nil && strings.Count(rel, string(filepath.Separator)) >= maxDepth-1 {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+	}
+}
+
+func isCleanupPattern(name string) bool {
+	return matchPattern(name, cleanupPatterns) != ""
+}
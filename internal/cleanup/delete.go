@@ -1,11 +1,17 @@
 package cleanup
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/hugoev/zap/internal/stall"
+	"github.com/hugoev/zap/internal/testmode"
 )
 
 const (
@@ -15,11 +21,38 @@ const (
 	DeletionCheckInterval = 100 * time.Millisecond
 )
 
+// ErrDeletionTimeout is returned when a directory could not be deleted within its time budget
+var ErrDeletionTimeout = errors.New("deletion timed out")
+
 func DeleteDirectory(path string) error {
+	return DeleteDirectoryWithContext(context.Background(), path)
+}
+
+// DeleteDirectoryWithTimeout deletes a directory, giving up (and leaving the rest of it in
+// place) if deletion takes longer than timeout. Returns an error wrapping ErrDeletionTimeout
+// so callers can skip the directory and continue; if ctx itself is done, returns its error.
+func DeleteDirectoryWithTimeout(ctx context.Context, path string, timeout time.Duration) error {
+	deleteCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := DeleteDirectoryWithContext(deleteCtx, path)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %v: %s (partially deleted)", ErrDeletionTimeout, timeout, path)
+	}
+	return err
+}
+
+// DeleteDirectoryWithContext deletes a directory, stopping between entries if ctx is done
+func DeleteDirectoryWithContext(ctx context.Context, path string) error {
 	// Validate path security first
 	if err := validatePath(path); err != nil {
 		return fmt.Errorf("path validation failed: %w", err)
 	}
+	if testmode.Enabled() {
+		return testmode.Record(testmode.ActionDelete, path)
+	}
 
 	// Check for network mount disconnection before proceeding
 	if err := checkNetworkMount(path); err != nil {
@@ -27,7 +60,8 @@ func DeleteDirectory(path string) error {
 	}
 
 	// Validate path exists before attempting deletion
-	info, err := os.Stat(path)
+	// Lstat, not Stat: a symlinked root must never be resolved to its target
+	info, err := os.Lstat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil // Already deleted, not an error
@@ -42,6 +76,14 @@ func DeleteDirectory(path string) error {
 		return fmt.Errorf("cannot access path %s: %w", path, err)
 	}
 
+	// Symlinked cache dirs (e.g. node_modules -> /Volumes/External/...) only lose the link
+	if info.Mode()&os.ModeSymlink != 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove symlink %s: %w", path, err)
+		}
+		return nil
+	}
+
 	if !info.IsDir() {
 		return fmt.Errorf("path is not a directory: %s", path)
 	}
@@ -56,6 +98,14 @@ func DeleteDirectory(path string) error {
 		return fmt.Errorf("path is a mount point and cannot be deleted: %s (this would unmount the filesystem)", path)
 	}
 
+	// Bind mounts live on the same device, so the device check below can't see them -
+	// deleting through one would destroy the data it exposes
+	if mounts := mountsInside(path); len(mounts) > 0 {
+		return fmt.Errorf("path contains mount point(s) and cannot be deleted: %s", strings.Join(mounts, ", "))
+	}
+
+	rootDev, hasDev := deviceID(info)
+
 	// Check disk space before deletion (safety check)
 	if err := checkDiskSpace(path, info.Size()); err != nil {
 		return fmt.Errorf("disk space check failed: %w", err)
@@ -67,13 +117,18 @@ func DeleteDirectory(path string) error {
 	
 	var lastErr error
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		err = os.RemoveAll(path)
+		err = removeAll(ctx, path, rootDev, hasDev)
 		if err == nil {
 			// Success
 			break
 		}
-		
+
 		lastErr = err
+
+		// Cancelled or out of time - don't retry
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		
 		// Check for network mount disconnection (not transient, but should be handled)
 		if pathErr, ok := err.(*os.PathError); ok {
@@ -128,12 +183,104 @@ func DeleteDirectory(path string) error {
 	return fmt.Errorf("deletion verification failed: %s still exists", path)
 }
 
-func DeleteDirectories(dirs []DirectoryInfo) error {
+// errCrossDevice is returned for entries that live on a different device than the deletion root
+var errCrossDevice = errors.New("refusing to cross filesystem boundary")
+
+// removeAll is os.RemoveAll with cancellation checks between entries, so a huge or slow
+// (network-mounted) tree can be abandoned part-way instead of stalling the whole cleanup.
+// It never follows symlinks and never descends onto a device other than rootDev.
+func removeAll(ctx context.Context, path string, rootDev uint64, hasDev bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	stall.Progress(ctx, path)
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if !info.IsDir() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if dev, ok := deviceID(info); hasDev && ok && dev != rootDev {
+		return fmt.Errorf("%w: %s", errCrossDevice, path)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var firstErr error
+	for _, entry := range entries {
+		if err := removeAll(ctx, filepath.Join(path, entry.Name()), rootDev, hasDev); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// deviceID returns the device a file lives on
+func deviceID(info os.FileInfo) (uint64, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(stat.Dev), true
+}
+
+// SameFilesystem reports whether a and b are on the same filesystem
+func SameFilesystem(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	devA, okA := deviceID(infoA)
+	devB, okB := deviceID(infoB)
+	return okA && okB && devA == devB
+}
+
+// Removed reports whether path is gone after a deletion; in test mode, whether its
+// deletion was recorded
+func Removed(path string) bool {
+	if testmode.Enabled() {
+		return testmode.Done(testmode.ActionDelete, path)
+	}
+	_, err := os.Lstat(path)
+	return os.IsNotExist(err)
+}
+
+func DeleteDirectories(ctx context.Context, dirs []DirectoryInfo) error {
 	var errors []error
 	deletedCount := 0
 
 	for _, dir := range dirs {
-		if err := DeleteDirectory(dir.Path); err != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := DeleteDirectoryWithContext(ctx, dir.Path); err != nil {
 			errors = append(errors, fmt.Errorf("%s: %w", dir.Path, err))
 			// Continue with other directories even if one fails
 		} else {
@@ -156,4 +303,11 @@ func GetTotalSize(dirs []DirectoryInfo) int64 {
 	return total
 }
 
-
+// GetTotalApparentSize sums the apparent (file length) sizes of dirs
+func GetTotalApparentSize(dirs []DirectoryInfo) int64 {
+	var total int64
+	for _, dir := range dirs {
+		total += dir.ApparentSize
+	}
+	return total
+}
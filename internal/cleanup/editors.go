@@ -0,0 +1,293 @@
+package cleanup
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// OpenProject is a project directory that appears to be open in an editor or IDE
+type OpenProject struct {
+	Path   string
+	Source string // e.g. "vscode", "jetbrains", "lsp:gopls"
+}
+
+// openWorkspaceWindow is how recently VS Code must have written a workspace's state
+// for the workspace to be considered open
+const openWorkspaceWindow = 24 * time.Hour
+
+// languageServers are process name/command fragments of common LSP servers
+// Their working directory is usually the root of the project being edited
+var languageServers = []string{
+	"gopls",
+	"rust-analyzer",
+	"tsserver",
+	"typescript-language-server",
+	"vtsls",
+	"pyright",
+	"pylsp",
+	"basedpyright",
+	"jedi-language-server",
+	"clangd",
+	"sourcekit-lsp",
+	"solargraph",
+	"ruby-lsp",
+	"elixir-ls",
+	"lua-language-server",
+	"zls",
+}
+
+// DetectOpenProjects finds projects that are currently open in VS Code, JetBrains IDEs
+// or have a running language server. Detection is best-effort: any source that cannot be
+// inspected is silently ignored.
+func DetectOpenProjects(ctx context.Context) []OpenProject {
+	homeDir, err := paths.HomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var projects []OpenProject
+	seen := make(map[string]bool)
+	add := func(path, source string) {
+		if path == "" || path == "/" || path == homeDir {
+			return
+		}
+		path = filepath.Clean(path)
+		if seen[path] {
+			return
+		}
+		seen[path] = true
+		projects = append(projects, OpenProject{Path: path, Source: source})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	commands := listProcessCommands(ctx)
+
+	if isVSCodeRunning(commands) {
+		for _, path := range vscodeOpenWorkspaces(homeDir) {
+			add(path, "vscode")
+		}
+	}
+
+	for _, path := range jetbrainsOpenProjects(homeDir) {
+		add(path, "jetbrains")
+	}
+
+	for pid, cmd := range commands {
+		server := matchLanguageServer(cmd)
+		if server == "" {
+			continue
+		}
+		add(processCwd(ctx, pid), "lsp:"+server)
+	}
+
+	return projects
+}
+
+// FindOpenProject returns the open project containing path, if any
+func FindOpenProject(path string, projects []OpenProject) (OpenProject, bool) {
+	for _, project := range projects {
+		rel, err := filepath.Rel(project.Path, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return project, true
+		}
+	}
+	return OpenProject{}, false
+}
+
+// listProcessCommands returns the command line of every running process keyed by PID
+func listProcessCommands(ctx context.Context) map[int]string {
+	commands := make(map[int]string)
+	if runtime.GOOS == "windows" {
+		return commands
+	}
+
+	output, err := execx.Run(ctx, "ps", "-axo", "pid=,command=")
+	if err != nil {
+		return commands
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		commands[pid] = strings.Join(fields[1:], " ")
+	}
+	return commands
+}
+
+func isVSCodeRunning(commands map[int]string) bool {
+	for _, cmd := range commands {
+		base := strings.ToLower(filepath.Base(strings.Fields(cmd)[0]))
+		if base == "code" || base == "code-insiders" || base == "codium" ||
+			strings.Contains(cmd, "Visual Studio Code.app") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLanguageServer returns the name of the language server a command belongs to
+func matchLanguageServer(cmd string) string {
+	lower := strings.ToLower(cmd)
+	for _, server := range languageServers {
+		if strings.Contains(lower, server) {
+			return server
+		}
+	}
+	return ""
+}
+
+// vscodeOpenWorkspaces returns folders of VS Code workspaces whose state was written recently
+func vscodeOpenWorkspaces(homeDir string) []string {
+	var storageRoots []string
+	for _, product := range []string{"Code", "Code - Insiders", "VSCodium"} {
+		switch runtime.GOOS {
+		case "darwin":
+			storageRoots = append(storageRoots, filepath.Join(homeDir, "Library", "Application Support", product, "User", "workspaceStorage"))
+		default:
+			storageRoots = append(storageRoots, filepath.Join(homeDir, ".config", product, "User", "workspaceStorage"))
+		}
+	}
+
+	var folders []string
+	for _, root := range storageRoots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			dir := filepath.Join(root, entry.Name())
+
+			// state.vscdb is rewritten continuously while the workspace is open
+			info, err := os.Stat(filepath.Join(dir, "state.vscdb"))
+			if err != nil || testmode.Since(info.ModTime()) > openWorkspaceWindow {
+				continue
+			}
+
+			data, err := os.ReadFile(filepath.Join(dir, "workspace.json"))
+			if err != nil {
+				continue
+			}
+			var workspace struct {
+				Folder string `json:"folder"`
+			}
+			if json.Unmarshal(data, &workspace) != nil || workspace.Folder == "" {
+				continue
+			}
+			if folder, err := fileURIToPath(workspace.Folder); err == nil {
+				folders = append(folders, folder)
+			}
+		}
+	}
+	return folders
+}
+
+// jetbrainsOpenProjects returns projects marked as opened by a running JetBrains IDE
+func jetbrainsOpenProjects(homeDir string) []string {
+	var configRoot string
+	switch runtime.GOOS {
+	case "darwin":
+		configRoot = filepath.Join(homeDir, "Library", "Application Support", "JetBrains")
+	default:
+		configRoot = filepath.Join(homeDir, ".config", "JetBrains")
+	}
+
+	entries, err := os.ReadDir(configRoot)
+	if err != nil {
+		return nil
+	}
+
+	var projects []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		productDir := filepath.Join(configRoot, entry.Name())
+
+		// The IDE holds a .lock file in its config directory while running
+		if _, err := os.Stat(filepath.Join(productDir, ".lock")); err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(productDir, "options", "recentProjects.xml"))
+		if err != nil {
+			continue
+		}
+		projects = append(projects, parseRecentProjects(string(data), homeDir)...)
+	}
+	return projects
+}
+
+// parseRecentProjects extracts paths of entries with opened="true" from recentProjects.xml
+func parseRecentProjects(content, homeDir string) []string {
+	var projects []string
+	chunks := strings.Split(content, `<entry key="`)
+	for _, chunk := range chunks[1:] {
+		end := strings.Index(chunk, `"`)
+		if end == -1 {
+			continue
+		}
+		if !strings.Contains(chunk, `opened="true"`) {
+			continue
+		}
+		path := strings.ReplaceAll(chunk[:end], "$USER_HOME$", homeDir)
+		projects = append(projects, path)
+	}
+	return projects
+}
+
+func fileURIToPath(uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if parsed.Scheme != "file" {
+		return "", fmt.Errorf("not a local folder: %s", uri)
+	}
+	return parsed.Path, nil
+}
+
+// processCwd returns the working directory of a process
+func processCwd(ctx context.Context, pid int) string {
+	if runtime.GOOS == "linux" {
+		if linkPath, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid)); err == nil {
+			return linkPath
+		}
+	}
+
+	if !execx.Available("lsof") {
+		return ""
+	}
+	output, err := execx.Run(ctx, "lsof", "-p", strconv.Itoa(pid), "-a", "-d", "cwd", "-Fn")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "n") {
+			return strings.TrimPrefix(line, "n")
+		}
+	}
+	return ""
+}
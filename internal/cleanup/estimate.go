@@ -0,0 +1,131 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Estimated sizing samples a few subdirectories of each level instead of walking every
+// file: node_modules holds hundreds of packages of similar size, so eight of them say
+// enough about the rest for a preview
+const (
+	estimateSamples = 8 // subdirectories sized per directory
+	estimateDepth   = 2 // levels sampled before the samples are sized in full
+)
+
+// KnownSizes are directory sizes measured earlier, e.g. by the last dry run
+type KnownSizes struct {
+	Time  time.Time        // when the sizes were measured
+	Sizes map[string]int64 // disk usage by path
+}
+
+type estimateKey struct{}
+
+// WithEstimate returns a context under which ScanDirectories estimates the size of the
+// directories it matches instead of measuring them. A size in known is reused as is
+// for a directory that hasn't changed since it was measured.
+func WithEstimate(ctx context.Context, known KnownSizes) context.Context {
+	return context.WithValue(ctx, estimateKey{}, known)
+}
+
+// sizeMatch measures a directory matched by a scan, or estimates it under WithEstimate;
+// the flag reports an estimate
+func sizeMatch(ctx context.Context, path string, info os.FileInfo) (dirUsage, bool, error) {
+	known, ok := ctx.Value(estimateKey{}).(KnownSizes)
+	if !ok {
+		usage, err := calculateDirSize(ctx, path)
+		return usage, false, err
+	}
+	if size, ok := known.Sizes[path]; ok && info.ModTime().Before(known.Time) {
+		return dirUsage{Apparent: size, Disk: size}, true, nil
+	}
+	usage, err := estimateDirSize(ctx, path, 0)
+	return usage, true, err
+}
+
+// estimateDirSize adds up the files directly in path and extrapolates the size of its
+// subdirectories from an evenly spread sample of them
+func estimateDirSize(ctx context.Context, path string, depth int) (dirUsage, error) {
+	var usage dirUsage
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return usage, err
+	}
+
+	seenInodes := make(map[fileKey]bool)
+	var subdirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			subdirs = append(subdirs, filepath.Join(path, entry.Name()))
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Mode()&os.ModeSymlink != 0 {
+			continue
+		}
+		apparent, disk := fileUsage(info, seenInodes)
+		usage.Apparent += apparent
+		usage.Disk += disk
+	}
+	if len(subdirs) == 0 {
+		return usage, nil
+	}
+
+	sampled := subdirs
+	if len(subdirs) > estimateSamples {
+		sampled = make([]string, estimateSamples)
+		for i := range sampled {
+			sampled[i] = subdirs[i*len(subdirs)/estimateSamples]
+		}
+	}
+	var sample dirUsage
+	for _, subdir := range sampled {
+		var subUsage dirUsage
+		if depth+1 >= estimateDepth {
+			subUsage, err = calculateDirSize(ctx, subdir)
+		} else {
+			subUsage, err = estimateDirSize(ctx, subdir, depth+1)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return usage, ctxErr
+		}
+		if err != nil {
+			usage.Errors = append(usage.Errors, &PathError{Path: subdir, Err: fmt.Errorf("failed to estimate size of %s: %w", subdir, err)})
+		}
+		sample.Apparent += subUsage.Apparent
+		sample.Disk += subUsage.Disk
+		usage.Errors = append(usage.Errors, subUsage.Errors...)
+	}
+	scale := float64(len(subdirs)) / float64(len(sampled))
+	usage.Apparent += int64(float64(sample.Apparent) * scale)
+	usage.Disk += int64(float64(sample.Disk) * scale)
+	return usage, nil
+}
+
+// MeasureExact replaces estimated sizes in dirs with measured ones, as the confirmation
+// of a cleanup previewed with estimates shows what will actually be freed. Directories
+// that can't be measured keep their estimate and are listed in an IncompleteScanError.
+func MeasureExact(ctx context.Context, dirs []DirectoryInfo) error {
+	var failed []*PathError
+	for i := range dirs {
+		if !dirs[i].Estimated {
+			continue
+		}
+		usage, err := calculateDirSize(ctx, dirs[i].Path)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			failed = append(failed, &PathError{Path: dirs[i].Path, Err: fmt.Errorf("failed to calculate size for %s: %w", dirs[i].Path, err)})
+			continue
+		}
+		dirs[i].Size, dirs[i].ApparentSize, dirs[i].Estimated = usage.Disk, usage.Apparent, false
+	}
+	if len(failed) > 0 {
+		return &IncompleteScanError{Errors: failed}
+	}
+	return nil
+}
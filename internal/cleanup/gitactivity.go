@@ -0,0 +1,123 @@
+package cleanup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// gitCheckTimeout bounds the git commands run for one repository
+const gitCheckTimeout = 5 * time.Second
+
+// ActiveRepo is a git repository someone is working in: it has uncommitted changes, or
+// a commit more recent than the activity window
+type ActiveRepo struct {
+	Root       string
+	Dirty      bool
+	LastCommit time.Time
+}
+
+// Reason describes why the repository counts as active
+func (r ActiveRepo) Reason() string {
+	if r.Dirty {
+		return "has uncommitted changes"
+	}
+	since := testmode.Since(r.LastCommit)
+	if since < 24*time.Hour {
+		return "was committed to today"
+	}
+	return fmt.Sprintf("was committed to %s ago", age.Of(since))
+}
+
+// DetectActiveRepos finds the git repository containing each of dirs and returns the
+// dirs whose repository is active, keyed by path. A repository is active when it has
+// uncommitted changes to tracked files or, with window > 0, a commit within window.
+// Each repository is checked once; repositories git can't inspect (or no git) are
+// treated as inactive.
+func DetectActiveRepos(ctx context.Context, dirs []string, window time.Duration) map[string]ActiveRepo {
+	if !execx.Available("git") {
+		return nil
+	}
+	homeDir, _ := paths.HomeDir()
+
+	active := make(map[string]ActiveRepo)
+	checked := make(map[string]*ActiveRepo)
+	for _, path := range dirs {
+		if ctx.Err() != nil {
+			break
+		}
+		root := gitRoot(filepath.Dir(path), homeDir)
+		if root == "" {
+			continue
+		}
+		repo, ok := checked[root]
+		if !ok {
+			repo = checkRepoActivity(ctx, root, window)
+			checked[root] = repo
+		}
+		if repo != nil {
+			active[path] = *repo
+		}
+	}
+	return active
+}
+
+// gitRoot returns the nearest directory at or above dir with a .git entry (a directory,
+// or a file for worktrees and submodules), or "" if dir isn't in a repository. The search
+// stops below homeDir: a dotfiles repository in the home directory is not a project.
+func gitRoot(dir, homeDir string) string {
+	for dir != homeDir {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// checkRepoActivity returns the activity of the repository at root, or nil if it is
+// inactive or can't be inspected
+func checkRepoActivity(ctx context.Context, root string, window time.Duration) *ActiveRepo {
+	ctx, cancel := context.WithTimeout(ctx, gitCheckTimeout)
+	defer cancel()
+
+	// --no-optional-locks: a status check must not take the index lock from the user's git
+	status, err := execx.Run(ctx, "git", "--no-optional-locks", "-C", root, "status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return nil
+	}
+	if len(strings.TrimSpace(string(status))) > 0 {
+		return &ActiveRepo{Root: root, Dirty: true}
+	}
+	if window <= 0 {
+		return nil
+	}
+
+	// An empty repository has no commit; that's not activity
+	output, err := execx.Run(ctx, "git", "-C", root, "log", "-1", "--format=%ct")
+	if err != nil {
+		return nil
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return nil
+	}
+	lastCommit := time.Unix(seconds, 0)
+	if testmode.Since(lastCommit) > window {
+		return nil
+	}
+	return &ActiveRepo{Root: root, LastCommit: lastCommit}
+}
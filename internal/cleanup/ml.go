@@ -0,0 +1,67 @@
+package cleanup
+
+import (
+	"path/filepath"
+)
+
+// Size thresholds for ML artifacts: small experiment logs aren't worth a prompt
+const (
+	minModelDownloadSize = 100 * 1024 * 1024
+	minExperimentRunSize = 50 * 1024 * 1024
+)
+
+// maxMLSearchDepth limits how deep projects are searched for per-project ML artifacts
+const maxMLSearchDepth = 6
+
+// mlProjectDirs are per-project artifact directories, with the size below which they
+// are left alone
+var mlProjectDirs = map[string]struct {
+	rule    string
+	minSize int64
+}{
+	".ipynb_checkpoints": {"Jupyter checkpoints", 0},
+	"wandb":              {"Weights & Biases local runs (synced to wandb.ai)", minExperimentRunSize},
+	"mlruns":             {"MLflow local runs", minExperimentRunSize},
+}
+
+// findMLArtifacts proposes downloaded models not used recently and per-project
+// notebook checkpoints and experiment runs
+func findMLArtifacts(homeDir string, projectDirs []string) []categoryCandidate {
+	var candidates []categoryCandidate
+
+	// Each model or dataset in the Hugging Face hub cache is a separate directory
+	for _, pattern := range []string{"models--*", "datasets--*"} {
+		matches, _ := filepath.Glob(filepath.Join(homeDir, ".cache", "huggingface", "hub", pattern))
+		for _, path := range matches {
+			candidates = append(candidates, categoryCandidate{
+				Path:          path,
+				Rule:          "Hugging Face download not used recently",
+				UseAccessTime: true,
+				MinSize:       minModelDownloadSize,
+			})
+		}
+	}
+	torchHub, _ := filepath.Glob(filepath.Join(homeDir, ".cache", "torch", "hub", "*"))
+	for _, path := range torchHub {
+		candidates = append(candidates, categoryCandidate{
+			Path:          path,
+			Rule:          "PyTorch hub download not used recently",
+			UseAccessTime: true,
+			MinSize:       minModelDownloadSize,
+		})
+	}
+
+	walkProjects(projectDirs, maxMLSearchDepth, func(path, name string) bool {
+		rule, ok := mlProjectDirs[name]
+		if ok {
+			candidates = append(candidates, categoryCandidate{
+				Path:    path,
+				Rule:    rule.rule,
+				MinSize: rule.minSize,
+			})
+		}
+		return ok
+	})
+
+	return candidates
+}
@@ -0,0 +1,327 @@
+package cleanup
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// CacheEntry is an entry of a package manager's global cache that hasn't been used
+// recently. Pruning entries keeps the rest of the cache warm, unlike deleting it whole.
+type CacheEntry struct {
+	Manager  string    `json:"manager"` // "npm", "yarn" or "yarn-berry"
+	Name     string    `json:"name"`    // package or cache key, for display
+	Paths    []string  `json:"paths"`   // files and directories making up the entry
+	Size     int64     `json:"size_bytes"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// FindStaleCacheEntries returns npm and yarn cache entries not used within maxAge.
+// npm records when each index entry was written (refreshed on every fetch); yarn
+// caches are judged by the access time of their files. The search stops with ctx's
+// error when ctx is done.
+func FindStaleCacheEntries(ctx context.Context, homeDir string, maxAge time.Duration) ([]CacheEntry, error) {
+	cutoff := testmode.Now().Add(-maxAge)
+
+	var entries []CacheEntry
+	npmEntries, err := staleNpmEntries(ctx, filepath.Join(homeDir, ".npm", "_cacache"), cutoff)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, npmEntries...)
+
+	for _, dir := range yarnCacheDirs(homeDir) {
+		entries = append(entries, staleYarnEntries(ctx, dir, cutoff)...)
+	}
+	entries = append(entries, staleBerryEntries(filepath.Join(homeDir, ".yarn", "berry", "cache"), cutoff)...)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// PnpmStoreExists reports whether a pnpm store is present. pnpm tracks which packages
+// projects still reference, so it is pruned with `pnpm store prune` instead.
+func PnpmStoreExists(homeDir string) bool {
+	candidates := []string{
+		filepath.Join(homeDir, ".local", "share", "pnpm", "store"),
+		filepath.Join(homeDir, "Library", "pnpm", "store"),
+		filepath.Join(homeDir, ".pnpm-store"),
+	}
+	for _, dir := range candidates {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteCacheEntry removes the files and directories of a cache entry
+func DeleteCacheEntry(entry CacheEntry) error {
+	for _, path := range entry.Paths {
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue // content shared with another pruned entry
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := DeleteDirectory(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := validatePath(path); err != nil {
+			return fmt.Errorf("path validation failed: %w", err)
+		}
+		if testmode.Enabled() {
+			if err := testmode.Record(testmode.ActionDelete, path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// npmIndexEntry is one line of an npm cacache index bucket
+type npmIndexEntry struct {
+	Key       string `json:"key"`
+	Integrity string `json:"integrity"`
+	Time      int64  `json:"time"` // milliseconds since the epoch
+}
+
+// staleNpmEntries finds index buckets whose newest entry is older than cutoff, along with
+// the content they reference that no fresh bucket still needs
+func staleNpmEntries(ctx context.Context, cacheDir string, cutoff time.Time) ([]CacheEntry, error) {
+	indexDir := filepath.Join(cacheDir, "index-v5")
+	if _, err := os.Stat(indexDir); err != nil {
+		return nil, nil
+	}
+
+	type bucket struct {
+		path       string
+		key        string
+		lastUsed   time.Time
+		integrity  []string
+		bucketSize int64
+	}
+	var stale []bucket
+	inUse := make(map[string]bool)
+
+	err := filepath.Walk(indexDir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		entries, err := readNpmBucket(path)
+		if err != nil || len(entries) == 0 {
+			return nil
+		}
+
+		b := bucket{path: path, key: entries[len(entries)-1].Key, bucketSize: info.Size()}
+		for _, entry := range entries {
+			if used := time.UnixMilli(entry.Time); used.After(b.lastUsed) {
+				b.lastUsed = used
+			}
+		}
+		for _, entry := range entries {
+			if b.lastUsed.Before(cutoff) {
+				b.integrity = append(b.integrity, entry.Integrity)
+			} else {
+				inUse[entry.Integrity] = true
+			}
+		}
+		if b.lastUsed.Before(cutoff) {
+			stale = append(stale, b)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read npm cache index: %w", err)
+	}
+
+	var entries []CacheEntry
+	for _, b := range stale {
+		entry := CacheEntry{
+			Manager:  "npm",
+			Name:     strings.TrimPrefix(b.key, "make-fetch-happen:request-cache:"),
+			Paths:    []string{b.path},
+			Size:     b.bucketSize,
+			LastUsed: b.lastUsed,
+		}
+		for _, integrity := range b.integrity {
+			if inUse[integrity] {
+				continue
+			}
+			for _, contentPath := range npmContentPaths(cacheDir, integrity) {
+				if info, err := os.Stat(contentPath); err == nil {
+					entry.Paths = append(entry.Paths, contentPath)
+					entry.Size += info.Size()
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readNpmBucket parses an index bucket: one "<sha1 of line>\t<json entry>" per line
+func readNpmBucket(path string) ([]npmIndexEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []npmIndexEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		_, data, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		var entry npmIndexEntry
+		if json.Unmarshal([]byte(data), &entry) == nil && entry.Key != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// npmContentPaths maps an SRI integrity string ("sha512-<base64>") to cacache content
+// files: content-v2/<algorithm>/<hex[0:2]>/<hex[2:4]>/<hex[4:]>
+func npmContentPaths(cacheDir, integrity string) []string {
+	var contentPaths []string
+	for _, hash := range strings.Fields(integrity) {
+		algorithm, digest, ok := strings.Cut(hash, "-")
+		if !ok {
+			continue
+		}
+		raw, err := base64.StdEncoding.DecodeString(digest)
+		if err != nil || len(raw) < 3 {
+			continue
+		}
+		hexDigest := hex.EncodeToString(raw)
+		contentPaths = append(contentPaths, filepath.Join(cacheDir, "content-v2", algorithm, hexDigest[:2], hexDigest[2:4], hexDigest[4:]))
+	}
+	return contentPaths
+}
+
+// yarnCacheDirs returns yarn v1 cache directories (one per cache format version)
+func yarnCacheDirs(homeDir string) []string {
+	root := filepath.Join(homeDir, ".cache", "yarn")
+	if runtime.GOOS == "darwin" {
+		root = filepath.Join(homeDir, "Library", "Caches", "Yarn")
+	}
+	versions, _ := filepath.Glob(filepath.Join(root, "v[0-9]*"))
+	return versions
+}
+
+// staleYarnEntries returns yarn v1 package directories not read since cutoff
+func staleYarnEntries(ctx context.Context, cacheDir string, cutoff time.Time) []CacheEntry {
+	dirs, err := os.ReadDir(cacheDir)
+	if err != nil {
+		return nil
+	}
+
+	var entries []CacheEntry
+	for _, dir := range dirs {
+		if !dir.IsDir() || strings.HasPrefix(dir.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(cacheDir, dir.Name())
+		// yarn reads the metadata file whenever it installs the package from cache
+		lastUsed := lastUsedTime(filepath.Join(path, ".yarn-metadata.json"))
+		if lastUsed.IsZero() {
+			lastUsed = lastUsedTime(path)
+		}
+		if lastUsed.IsZero() || !lastUsed.Before(cutoff) {
+			continue
+		}
+		usage, err := calculateDirSize(ctx, path)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Manager:  "yarn",
+			Name:     dir.Name(),
+			Paths:    []string{path},
+			Size:     usage.Disk,
+			LastUsed: lastUsed,
+		})
+	}
+	return entries
+}
+
+// staleBerryEntries returns yarn 2+ global cache archives not read since cutoff
+func staleBerryEntries(cacheDir string, cutoff time.Time) []CacheEntry {
+	archives, _ := filepath.Glob(filepath.Join(cacheDir, "*.zip"))
+
+	var entries []CacheEntry
+	for _, path := range archives {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		lastUsed := lastUsedTime(path)
+		if lastUsed.IsZero() || !lastUsed.Before(cutoff) {
+			continue
+		}
+		entries = append(entries, CacheEntry{
+			Manager:  "yarn-berry",
+			Name:     strings.TrimSuffix(filepath.Base(path), ".zip"),
+			Paths:    []string{path},
+			Size:     info.Size(),
+			LastUsed: lastUsed,
+		})
+	}
+	return entries
+}
+
+// lastUsedTime returns the later of a file's access and modification times
+// (filesystems mounted noatime/relatime only update access times coarsely)
+func lastUsedTime(path string) time.Time {
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return time.Time{}
+	}
+	accessed := time.Unix(st.Atim.Unix())
+	modified := time.Unix(st.Mtim.Unix())
+	if modified.After(accessed) {
+		return modified
+	}
+	return accessed
+}
+
+// GetCacheEntriesSize sums the sizes of cache entries
+func GetCacheEntriesSize(entries []CacheEntry) int64 {
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	return total
+}
@@ -0,0 +1,41 @@
+package cleanup
+
+// ProgressKind says what a ProgressEvent reports
+type ProgressKind int
+
+const (
+	// ProgressEntered: the scan entered a directory
+	ProgressEntered ProgressKind = iota
+	// ProgressMatched: a directory matched a cleanup pattern and is about to be sized
+	ProgressMatched
+	// ProgressSized: a matched directory was sized (Size is set)
+	ProgressSized
+	// ProgressError: a path could not be inspected (Err is set)
+	ProgressError
+)
+
+// String returns the kind's name, e.g. for JSON streams
+func (k ProgressKind) String() string {
+	switch k {
+	case ProgressEntered:
+		return "entered"
+	case ProgressMatched:
+		return "matched"
+	case ProgressSized:
+		return "sized"
+	case ProgressError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// ProgressEvent is sent by ScanDirectories as it walks a tree. Events of concurrent
+// scans can share one channel; Root tells them apart.
+type ProgressEvent struct {
+	Kind    ProgressKind
+	Root    string // the root path passed to ScanDirectories
+	Path    string
+	Pattern string // set for matched and sized directories
+	Size    int64  // set for sized directories
+	Err     error  // set for errors
+}
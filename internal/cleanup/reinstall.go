@@ -0,0 +1,231 @@
+package cleanup
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// maxInstallSpan is the longest timestamp spread still believed to be a single install;
+// anything longer means the directory was touched again later
+const maxInstallSpan = 2 * time.Hour
+
+// ReinstallCost estimates what it takes to bring a deleted dependency directory back
+type ReinstallCost struct {
+	Packages int           `json:"packages,omitempty"`    // packages listed in the lockfile (0 if unknown)
+	Lockfile string        `json:"lockfile,omitempty"`    // lockfile the count came from
+	Duration time.Duration `json:"duration_ns,omitempty"` // how long the last install took (0 if unknown)
+}
+
+// Known reports whether anything could be estimated
+func (c ReinstallCost) Known() bool {
+	return c.Packages > 0 || c.Duration > 0
+}
+
+// String formats the estimate, e.g. "reinstall ≈ 2,143 packages / ~3 min"
+func (c ReinstallCost) String() string {
+	var parts []string
+	if c.Packages > 0 {
+		parts = append(parts, formatCount(c.Packages)+" packages")
+	}
+	if c.Duration > 0 {
+		if c.Duration < time.Minute {
+			parts = append(parts, "<1 min")
+		} else {
+			parts = append(parts, fmt.Sprintf("~%d min", int(c.Duration.Round(time.Minute).Minutes())))
+		}
+	}
+	return "reinstall ≈ " + strings.Join(parts, " / ")
+}
+
+// lockfileCounters count packages in the lockfiles of each dependency directory type
+var lockfileCounters = map[string][]struct {
+	name  string
+	count func(path string) int
+}{
+	"node_modules": {
+		{"package-lock.json", countNpmLock},
+		{"npm-shrinkwrap.json", countNpmLock},
+		{"pnpm-lock.yaml", countPnpmLock},
+		{"yarn.lock", countYarnLock},
+	},
+	".venv": {
+		{"uv.lock", countTomlPackages},
+		{"poetry.lock", countTomlPackages},
+		{"Pipfile.lock", countPipfileLock},
+		{"requirements.txt", countRequirements},
+	},
+	"venv": {
+		{"uv.lock", countTomlPackages},
+		{"poetry.lock", countTomlPackages},
+		{"Pipfile.lock", countPipfileLock},
+		{"requirements.txt", countRequirements},
+	},
+	"target": {
+		{"Cargo.lock", countTomlPackages},
+	},
+}
+
+// EstimateReinstall looks at the project's lockfile and at the directory's own
+// timestamps (entries are created one after another during an install) to estimate
+// the cost of recreating a dependency directory
+func EstimateReinstall(dir DirectoryInfo) ReinstallCost {
+	counters, ok := lockfileCounters[dir.Pattern]
+	if !ok {
+		return ReinstallCost{}
+	}
+
+	var cost ReinstallCost
+	projectDir := filepath.Dir(dir.Path)
+	for _, counter := range counters {
+		lockfile := filepath.Join(projectDir, counter.name)
+		if _, err := os.Stat(lockfile); err != nil {
+			continue
+		}
+		if n := counter.count(lockfile); n > 0 {
+			cost.Packages = n
+			cost.Lockfile = counter.name
+			break
+		}
+	}
+	cost.Duration = installSpan(dir.Path)
+	return cost
+}
+
+// installSpan returns the spread between the oldest and newest top-level entries of a
+// dependency directory, which approximates how long it took to install
+func installSpan(path string) time.Duration {
+	entries, err := os.ReadDir(path)
+	if err != nil || len(entries) < 2 {
+		return 0
+	}
+
+	var oldest, newest time.Time
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		modTime := info.ModTime()
+		if oldest.IsZero() || modTime.Before(oldest) {
+			oldest = modTime
+		}
+		if modTime.After(newest) {
+			newest = modTime
+		}
+	}
+
+	span := newest.Sub(oldest)
+	if span <= 0 || span > maxInstallSpan {
+		return 0
+	}
+	return span
+}
+
+// countNpmLock counts installed packages in package-lock.json (lockfileVersion 2+)
+func countNpmLock(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	var lock struct {
+		Packages     map[string]json.RawMessage `json:"packages"`
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if json.Unmarshal(data, &lock) != nil {
+		return 0
+	}
+	count := 0
+	for key := range lock.Packages {
+		if strings.Contains(key, "node_modules/") {
+			count++
+		}
+	}
+	if count == 0 {
+		// lockfileVersion 1 only lists top-level dependencies
+		count = len(lock.Dependencies)
+	}
+	return count
+}
+
+// countPipfileLock counts default and develop packages in Pipfile.lock
+func countPipfileLock(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	var lock struct {
+		Default map[string]json.RawMessage `json:"default"`
+		Develop map[string]json.RawMessage `json:"develop"`
+	}
+	if json.Unmarshal(data, &lock) != nil {
+		return 0
+	}
+	return len(lock.Default) + len(lock.Develop)
+}
+
+// countTomlPackages counts [[package]] tables (Cargo.lock, poetry.lock, uv.lock)
+func countTomlPackages(path string) int {
+	return countLines(path, func(line string) bool {
+		return strings.TrimSpace(line) == "[[package]]"
+	})
+}
+
+// countYarnLock counts package entries: unindented lines ending in ':'
+func countYarnLock(path string) int {
+	return countLines(path, func(line string) bool {
+		return line != "" && line[0] != ' ' && line[0] != '#' &&
+			strings.HasSuffix(line, ":") && !strings.HasPrefix(line, "__metadata")
+	})
+}
+
+// countPnpmLock counts entries of the top-level packages: map
+func countPnpmLock(path string) int {
+	inPackages := false
+	return countLines(path, func(line string) bool {
+		if line != "" && line[0] != ' ' {
+			inPackages = line == "packages:"
+			return false
+		}
+		return inPackages && strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") && strings.HasSuffix(line, ":")
+	})
+}
+
+// countRequirements counts requirement lines, ignoring comments and pip options
+func countRequirements(path string) int {
+	return countLines(path, func(line string) bool {
+		line = strings.TrimSpace(line)
+		return line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "-")
+	})
+}
+
+func countLines(path string, match func(line string) bool) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if match(scanner.Text()) {
+			count++
+		}
+	}
+	return count
+}
+
+// formatCount formats n with thousands separators, e.g. 2143 -> "2,143"
+func formatCount(n int) string {
+	s := fmt.Sprintf("%d", n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
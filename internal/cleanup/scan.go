@@ -1,6 +1,7 @@
 package cleanup
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,14 +9,29 @@ import (
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/hugoev/zap/internal/stall"
 )
 
 type DirectoryInfo struct {
-	Path    string
-	Size    int64
-	ModTime time.Time
+	Path string `json:"path"`
+	// Size is the actual disk usage (allocated blocks), which is what deletion frees
+	Size int64 `json:"size_bytes"`
+	// ApparentSize is the sum of file lengths, as reported by ls
+	ApparentSize int64     `json:"apparent_size_bytes"`
+	ModTime      time.Time `json:"mod_time"`
+	// Pattern is the cleanup pattern the directory matched (or the rule, for categories)
+	Pattern string `json:"pattern"`
+	// Category is set for well-known cache locations found by ScanCategories
+	Category string `json:"category,omitempty"`
+	// Reinstall estimates the cost of recreating a dependency directory, if known
+	Reinstall *ReinstallCost `json:"reinstall,omitempty"`
+	// Estimated marks sizes sampled or reused under WithEstimate rather than measured
+	Estimated bool `json:"estimated,omitempty"`
 }
 
+// cleanupPatterns are the directory names cleaned by default; cleanup_patterns in the
+// config replaces them
 var cleanupPatterns = []string{
 	// Node.js
 	"node_modules",
@@ -63,6 +79,50 @@ var cleanupPatterns = []string{
 	".stylelintcache",
 }
 
+// DefaultPatterns returns the directory names cleaned by default, without duplicates
+func DefaultPatterns() []string {
+	seen := make(map[string]bool, len(cleanupPatterns))
+	var patterns []string
+	for _, pattern := range cleanupPatterns {
+		if !seen[pattern] {
+			seen[pattern] = true
+			patterns = append(patterns, pattern)
+		}
+	}
+	return patterns
+}
+
+// ValidatePattern checks a cleanup pattern: a directory name, optionally with shell
+// wildcards ("*.egg-info"), but no path separators
+func ValidatePattern(pattern string) error {
+	if strings.TrimSpace(pattern) == "" || pattern == "." || pattern == ".." {
+		return fmt.Errorf("invalid cleanup pattern %q", pattern)
+	}
+	if strings.ContainsAny(pattern, `/\`) {
+		return fmt.Errorf("cleanup pattern %q must be a directory name, not a path", pattern)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid cleanup pattern %q: %w", pattern, err)
+	}
+	if strings.Trim(pattern, "*?") == "" {
+		return fmt.Errorf("cleanup pattern %q would match every directory", pattern)
+	}
+	return nil
+}
+
+// matchPattern returns the first of patterns that name matches, or ""
+func matchPattern(name string, patterns []string) string {
+	for _, pattern := range patterns {
+		if name == pattern {
+			return pattern
+		}
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return pattern
+		}
+	}
+	return ""
+}
+
 // shouldSkipSystemDirectory checks if a directory should be skipped based on system paths
 // This prevents scanning macOS system directories like Library, Applications, etc.
 func shouldSkipSystemDirectory(path, rootPath string) bool {
@@ -115,9 +175,27 @@ func shouldSkipSystemDirectory(path, rootPath string) bool {
 	return false
 }
 
-func ScanDirectories(rootPath string, shouldCleanup func(path string, modTime time.Time) bool, progressCallback func(string)) ([]DirectoryInfo, error) {
+// ScanDirectories walks rootPath for directories matching one of patterns (nil means
+// DefaultPatterns) that shouldCleanup accepts, once their size is known. If progress is not nil, it receives an event for every step of
+// the walk; sends block, so the caller must keep draining it until ScanDirectories returns.
+// The walk stops with ctx's error when ctx is done.
+func ScanDirectories(ctx context.Context, rootPath string, patterns []string, shouldCleanup func(dir DirectoryInfo) bool, progress chan<- ProgressEvent) ([]DirectoryInfo, error) {
 	var directories []DirectoryInfo
-	var scanErrors []error
+	var scanErrors []*PathError
+	if patterns == nil {
+		patterns = cleanupPatterns
+	}
+
+	report := func(event ProgressEvent) {
+		if progress != nil {
+			event.Root = rootPath
+			progress <- event
+		}
+	}
+	addError := func(path string, err error) {
+		scanErrors = append(scanErrors, &PathError{Path: path, Err: err})
+		report(ProgressEvent{Kind: ProgressError, Path: path, Err: err})
+	}
 
 	// Validate root path exists and is a directory
 	rootInfo, err := os.Stat(rootPath)
@@ -135,22 +213,25 @@ func ScanDirectories(rootPath string, shouldCleanup func(path string, modTime ti
 	}
 
 	err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			// Check for network mount disconnection
 			if pathErr, ok := err.(*os.PathError); ok {
 				if pathErr.Err == syscall.ENOTCONN || pathErr.Err == syscall.EHOSTUNREACH || pathErr.Err == syscall.ETIMEDOUT {
-					scanErrors = append(scanErrors, fmt.Errorf("network mount disconnected: %s", path))
+					addError(path, fmt.Errorf("network mount disconnected: %s", path))
 					return filepath.SkipDir // Skip this directory and its children
 				}
 			}
 			
 			// Log permission errors but continue
 			if os.IsPermission(err) {
-				scanErrors = append(scanErrors, fmt.Errorf("permission denied: %s", path))
+				addError(path, fmt.Errorf("permission denied: %s", path))
 				return nil // Skip this path, continue scanning
 			}
 			// For other errors, skip but log
-			scanErrors = append(scanErrors, fmt.Errorf("error accessing %s: %w", path, err))
+			addError(path, fmt.Errorf("error accessing %s: %w", path, err))
 			return nil
 		}
 
@@ -166,8 +247,7 @@ func ScanDirectories(rootPath string, shouldCleanup func(path string, modTime ti
 		// Check if this is a mount point (critical safety check)
 		isMount, mountErr := isMountPoint(path)
 		if mountErr == nil && isMount {
-			// Mount point detected - skip it and don't descend
-			scanErrors = append(scanErrors, fmt.Errorf("skipping mount point: %s", path))
+			// Mount point detected - skip it and don't descend (deliberately, so not an error)
 			return filepath.SkipDir
 		}
 
@@ -176,64 +256,119 @@ func ScanDirectories(rootPath string, shouldCleanup func(path string, modTime ti
 			return filepath.SkipDir
 		}
 
-		// Report progress
-		if progressCallback != nil {
-			progressCallback(path)
-		}
+		report(ProgressEvent{Kind: ProgressEntered, Path: path})
+		stall.Progress(ctx, path)
 
 		// Check if this directory matches a cleanup pattern
-		dirName := info.Name()
-		matches := false
-		for _, pattern := range cleanupPatterns {
-			if dirName == pattern {
-				matches = true
-				break
-			}
-		}
-
-		if !matches {
+		matchedPattern := matchPattern(info.Name(), patterns)
+		if matchedPattern == "" {
 			return nil
 		}
 
+		report(ProgressEvent{Kind: ProgressMatched, Path: path, Pattern: matchedPattern})
+
 		// Calculate directory size with timeout protection
-		size, err := calculateDirSize(path)
+		usage, estimated, err := sizeMatch(ctx, path, info)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
-			scanErrors = append(scanErrors, fmt.Errorf("failed to calculate size for %s: %w", path, err))
+			addError(path, fmt.Errorf("failed to calculate size for %s: %w", path, err))
 			return filepath.SkipDir // Skip this directory but continue
 		}
+		for _, sizeErr := range usage.Errors {
+			addError(sizeErr.Path, sizeErr.Err)
+		}
+		report(ProgressEvent{Kind: ProgressSized, Path: path, Pattern: matchedPattern, Size: usage.Disk})
 
 		// Check if should cleanup based on config
-		if shouldCleanup(path, info.ModTime()) {
-			directories = append(directories, DirectoryInfo{
-				Path:    path,
-				Size:    size,
-				ModTime: info.ModTime(),
-			})
+		dir := DirectoryInfo{
+			Path:         path,
+			Size:         usage.Disk,
+			ApparentSize: usage.Apparent,
+			ModTime:      info.ModTime(),
+			Pattern:      matchedPattern,
+			Estimated:    estimated,
+		}
+		if shouldCleanup(dir) {
+			directories = append(directories, dir)
 		}
 
 		// Don't descend into these directories
 		return filepath.SkipDir
 	})
 
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	// Return results even if there were some errors (partial success)
 	if err != nil && len(directories) == 0 {
 		return nil, fmt.Errorf("scan failed: %w", err)
 	}
+	if len(scanErrors) > 0 {
+		return directories, &IncompleteScanError{Errors: scanErrors}
+	}
 
 	return directories, nil
 }
 
-func calculateDirSize(path string) (int64, error) {
-	var size int64
-	var sizeErrors []error
+// PathError is a path a scan could not inspect
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string { return e.Err.Error() }
+func (e *PathError) Unwrap() error { return e.Err }
+
+// IncompleteScanError is returned by ScanDirectories along with its results when some
+// paths could not be inspected (mostly permissions), so the results may be incomplete
+type IncompleteScanError struct {
+	Errors []*PathError
+}
+
+func (e *IncompleteScanError) Error() string {
+	return fmt.Sprintf("%d path(s) could not be inspected", len(e.Errors))
+}
+
+// Paths returns the paths that could not be inspected
+func (e *IncompleteScanError) Paths() []string {
+	paths := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		paths[i] = err.Path
+	}
+	return paths
+}
+
+// dirUsage holds both ways of measuring a directory's size
+type dirUsage struct {
+	Apparent int64        // sum of file lengths
+	Disk     int64        // allocated blocks (st_blocks), like du
+	Newest   time.Time    // latest modification time of anything inside
+	Errors   []*PathError // files that could not be read, so the size is a lower bound
+}
+
+// fileKey identifies an inode so hard-linked files are only counted once
+type fileKey struct {
+	dev uint64
+	ino uint64
+}
+
+// calculateDirSize measures everything under path, stopping with ctx's error when ctx is done
+func calculateDirSize(ctx context.Context, path string) (dirUsage, error) {
+	var usage dirUsage
 	fileCount := 0
 	maxFiles := 1000000 // Increased limit to 1M files (prevents excessive scanning while handling large projects)
+	seenInodes := make(map[fileKey]bool)
 
 	err := filepath.Walk(path, func(filePath string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
 		if err != nil {
 			// Log but continue - permission errors on individual files shouldn't stop us
 			if os.IsPermission(err) {
-				sizeErrors = append(sizeErrors, fmt.Errorf("permission denied: %s", filePath))
+				usage.Errors = append(usage.Errors, &PathError{Path: filePath, Err: fmt.Errorf("permission denied: %s", filePath)})
 				return nil
 			}
 			return err
@@ -243,9 +378,16 @@ func calculateDirSize(path string) (int64, error) {
 		if info.Mode()&os.ModeSymlink != 0 {
 			return nil
 		}
+		stall.Progress(ctx, filePath)
+
+		if info.ModTime().After(usage.Newest) {
+			usage.Newest = info.ModTime()
+		}
 
 		if !info.IsDir() {
-			size += info.Size()
+			apparent, disk := fileUsage(info, seenInodes)
+			usage.Apparent += apparent
+			usage.Disk += disk
 			fileCount++
 			// Safety limit to prevent excessive scanning
 			if fileCount > maxFiles {
@@ -257,27 +399,34 @@ func calculateDirSize(path string) (int64, error) {
 
 	// If we hit the file limit, return partial size with error
 	if err != nil && strings.Contains(err.Error(), "too large") {
-		return size, fmt.Errorf("directory size calculation incomplete (stopped at %d files): %w", fileCount, err)
+		return usage, fmt.Errorf("directory size calculation incomplete (stopped at %d files): %w", fileCount, err)
 	}
 
 	// Return size even if there were some permission errors
 	if err != nil {
-		return size, fmt.Errorf("error calculating directory size: %w", err)
+		return usage, fmt.Errorf("error calculating directory size: %w", err)
 	}
 
-	return size, nil
+	return usage, nil
 }
 
-func FormatSize(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// fileUsage returns the apparent size and the on-disk size of a file.
+// Hard links are only counted once; sparse files report their allocated blocks.
+func fileUsage(info os.FileInfo, seenInodes map[fileKey]bool) (int64, int64) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		// No block information available (e.g. Windows) - fall back to apparent size
+		return info.Size(), info.Size()
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+
+	if stat.Nlink > 1 {
+		key := fileKey{dev: uint64(stat.Dev), ino: uint64(stat.Ino)}
+		if seenInodes[key] {
+			return 0, 0
+		}
+		seenInodes[key] = true
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
 
+	// st_blocks is always in 512-byte units, regardless of the filesystem block size
+	return info.Size(), int64(stat.Blocks) * 512
+}
@@ -1,14 +1,21 @@
 package cleanup
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 
 	"golang.org/x/sys/unix"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/paths"
 )
 
 // validatePath ensures a path is safe and within allowed boundaries
@@ -57,9 +64,9 @@ func validatePath(path string) error {
 	}
 
 	// Ensure path is within allowed boundaries (home directory)
-	homeDir, err := os.UserHomeDir()
+	homeDir, err := paths.HomeDir()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %w", err)
+		return err
 	}
 
 	// Resolve home directory to absolute path
@@ -74,14 +81,51 @@ func validatePath(path string) error {
 		return fmt.Errorf("path outside home directory: %s", absPath)
 	}
 
-	// Prevent escaping from home directory
-	if strings.HasPrefix(rel, "..") {
+	// Prevent escaping from home directory, unless explicitly allowed
+	if strings.HasPrefix(rel, "..") && !insideAllowedRoot(absPath) {
 		return fmt.Errorf("path outside home directory: %s", absPath)
 	}
 
 	return nil
 }
 
+// allowedRoots are directories outside the home directory whose contents may be deleted,
+// from an explicit `zap cleanup --path=... --allow-outside-home`
+var (
+	allowedRootsMutex sync.RWMutex
+	allowedRoots      []string
+)
+
+// AllowRoots lets validatePath accept paths inside roots even though they are outside
+// the home directory. Only what is strictly inside a root is accepted, never the root.
+func AllowRoots(roots []string) error {
+	absRoots := make([]string, 0, len(roots))
+	for _, root := range roots {
+		absRoot, err := filepath.Abs(root)
+		if err != nil {
+			return fmt.Errorf("invalid path %s: %w", root, err)
+		}
+		absRoots = append(absRoots, absRoot)
+	}
+	allowedRootsMutex.Lock()
+	defer allowedRootsMutex.Unlock()
+	allowedRoots = absRoots
+	return nil
+}
+
+// insideAllowedRoot reports whether absPath is strictly inside a root given to AllowRoots
+func insideAllowedRoot(absPath string) bool {
+	allowedRootsMutex.RLock()
+	defer allowedRootsMutex.RUnlock()
+	for _, root := range allowedRoots {
+		rel, err := filepath.Rel(root, absPath)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 // checkDiskSpace verifies sufficient disk space before deletion
 func checkDiskSpace(path string, requiredBytes int64) error {
 	if runtime.GOOS == "windows" {
@@ -138,6 +182,83 @@ func isMountPoint(path string) (bool, error) {
 	return isMount, nil
 }
 
+// mountsInside returns mount points located strictly inside root (including bind mounts,
+// which share a device with their parent and are invisible to isMountPoint)
+func mountsInside(root string) []string {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+
+	var inside []string
+	for _, mountPoint := range listMountPoints() {
+		rel, err := filepath.Rel(absRoot, mountPoint)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		inside = append(inside, mountPoint)
+	}
+	return inside
+}
+
+// listMountPoints returns all mount points on the system (best-effort)
+func listMountPoints() []string {
+	var mountPoints []string
+
+	switch runtime.GOOS {
+	case "linux":
+		// mountinfo lists bind mounts too; field 5 is the mount point
+		data, err := os.ReadFile("/proc/self/mountinfo")
+		if err != nil {
+			return nil
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 5 {
+				continue
+			}
+			mountPoints = append(mountPoints, unescapeMountPath(fields[4]))
+		}
+	case "darwin":
+		// mount output: "/dev/disk3s1 on /System/Volumes/Data (apfs, local, journaled)"
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		output, err := execx.Run(ctx, "mount")
+		if err != nil {
+			return nil
+		}
+		for _, line := range strings.Split(string(output), "\n") {
+			onIdx := strings.Index(line, " on ")
+			typeIdx := strings.LastIndex(line, " (")
+			if onIdx == -1 || typeIdx <= onIdx {
+				continue
+			}
+			mountPoints = append(mountPoints, line[onIdx+4:typeIdx])
+		}
+	}
+
+	return mountPoints
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space, etc.) used in mountinfo
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, "\\") {
+		return path
+	}
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if code, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(code))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+	return b.String()
+}
+
 // checkNetworkMount checks if a path is on a network mount and detects disconnection
 func checkNetworkMount(path string) error {
 	if runtime.GOOS == "windows" {
@@ -201,4 +322,3 @@ func shellEscape(s string) string {
 	escaped := strings.ReplaceAll(s, "'", "'\"'\"'")
 	return "'" + escaped + "'"
 }
-
@@ -0,0 +1,54 @@
+package cleanup
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Units sizes are shown in (size_units); JSON output always has exact bytes
+const (
+	UnitsBinary = "binary" // powers of 1024: KiB, MiB, GiB, as du -h and ls -lh count
+	UnitsSI     = "si"     // powers of 1000: kB, MB, GB, as df -H and disk vendors count
+	UnitsBytes  = "bytes"  // exact byte counts
+)
+
+// SizeUnits lists the accepted size_units values
+var SizeUnits = []string{UnitsBinary, UnitsSI, UnitsBytes}
+
+// Units is how FormatSize shows sizes; set from size_units or --size-units
+var Units = UnitsBinary
+
+// ParseUnits checks a size_units or --size-units value
+func ParseUnits(value string) (string, error) {
+	units := strings.ToLower(strings.TrimSpace(value))
+	for _, known := range SizeUnits {
+		if units == known {
+			return units, nil
+		}
+	}
+	return "", fmt.Errorf("unknown units %q (must be %s)", value, strings.Join(SizeUnits, ", "))
+}
+
+// FormatSize shows a size in Units with one decimal, e.g. "1.5 GiB" or "1.6 GB"
+func FormatSize(bytes int64) string {
+	switch Units {
+	case UnitsBytes:
+		return fmt.Sprintf("%d B", bytes)
+	case UnitsSI:
+		return formatScaled(bytes, 1000, []string{"kB", "MB", "GB", "TB", "PB", "EB"})
+	default:
+		return formatScaled(bytes, 1024, []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"})
+	}
+}
+
+func formatScaled(bytes, unit int64, suffixes []string) string {
+	if bytes < unit && bytes > -unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := unit, 0
+	for n := bytes / unit; n >= unit || n <= -unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", float64(bytes)/float64(div), suffixes[exp])
+}
@@ -0,0 +1,188 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// trashDir returns the trash zap moves directories to: ~/.Trash on macOS, the XDG
+// trash ($XDG_DATA_HOME/Trash, by default ~/.local/share/Trash) elsewhere
+func trashDir() (string, error) {
+	if runtime.GOOS == "windows" {
+		return "", fmt.Errorf("moving to the trash is not supported on Windows")
+	}
+	homeDir, err := paths.HomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(homeDir, ".Trash"), nil
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" || testmode.Enabled() {
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dataHome, "Trash"), nil
+}
+
+// xdgTrash reports whether the trash follows the XDG spec, with files/ and info/
+func xdgTrash() bool {
+	return runtime.GOOS != "darwin"
+}
+
+// MoveToTrash moves the directory at path to the trash instead of deleting it, and
+// returns where it went. Paths are validated as for deletion. The trash must be on the
+// same filesystem: nothing is copied, so a failed move leaves the directory in place.
+func MoveToTrash(ctx context.Context, path string) (string, error) {
+	if err := validatePath(path); err != nil {
+		return "", fmt.Errorf("path validation failed: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	trash, err := trashDir()
+	if err != nil {
+		return "", err
+	}
+	filesDir := trash
+	if xdgTrash() {
+		filesDir = filepath.Join(trash, "files")
+	}
+	if testmode.Enabled() {
+		return filepath.Join(filesDir, filepath.Base(path)), testmode.Record(testmode.ActionTrash, path)
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot access path %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", path)
+	}
+	if err := os.MkdirAll(filesDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create trash %s: %w", filesDir, err)
+	}
+
+	// Claim a free name; in an XDG trash the .trashinfo file is the claim
+	name, infoPath, err := claimTrashName(trash, filesDir, path)
+	if err != nil {
+		return "", err
+	}
+	trashedPath := filepath.Join(filesDir, name)
+	if err := os.Rename(path, trashedPath); err != nil {
+		if infoPath != "" {
+			os.Remove(infoPath)
+		}
+		if errors.Is(err, syscall.EXDEV) {
+			return "", fmt.Errorf("%s is on another filesystem than the trash (%s); delete it without --trash", path, trash)
+		}
+		return "", fmt.Errorf("failed to move %s to the trash: %w", path, err)
+	}
+	return trashedPath, nil
+}
+
+// claimTrashName picks a name for path in filesDir that no trashed item uses yet and,
+// for an XDG trash, writes its .trashinfo (returning the info file's path)
+func claimTrashName(trash, filesDir, path string) (string, string, error) {
+	base := filepath.Base(path)
+	separator := "."
+	if runtime.GOOS == "darwin" {
+		separator = " " // as Finder does: "node_modules 2"
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	for n := 1; n < 1000; n++ {
+		name := base
+		if n > 1 {
+			name = fmt.Sprintf("%s%s%d", base, separator, n)
+		}
+		if _, err := os.Lstat(filepath.Join(filesDir, name)); !os.IsNotExist(err) {
+			continue
+		}
+		if !xdgTrash() {
+			return name, "", nil
+		}
+
+		infoDir := filepath.Join(trash, "info")
+		if err := os.MkdirAll(infoDir, 0700); err != nil {
+			return "", "", fmt.Errorf("failed to create trash %s: %w", infoDir, err)
+		}
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		file, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", fmt.Errorf("failed to write trash info: %w", err)
+		}
+		escaped := (&url.URL{Path: absPath}).EscapedPath()
+		_, err = fmt.Fprintf(file, "[Trash Info]\nPath=%s\nDeletionDate=%s\n", escaped, testmode.Now().Format("2006-01-02T15:04:05"))
+		file.Close()
+		if err != nil {
+			os.Remove(infoPath)
+			return "", "", fmt.Errorf("failed to write trash info: %w", err)
+		}
+		return name, infoPath, nil
+	}
+	return "", "", fmt.Errorf("no free name for %s in the trash", base)
+}
+
+// Trashed reports whether path is gone after MoveToTrash; in test mode, whether the
+// move was recorded
+func Trashed(path string) bool {
+	if testmode.Enabled() {
+		return testmode.Done(testmode.ActionTrash, path)
+	}
+	_, err := os.Lstat(path)
+	return os.IsNotExist(err)
+}
+
+// RestoreFromTrash moves a directory MoveToTrash put at trashedPath back to
+// originalPath. It refuses to overwrite anything at originalPath, e.g. a node_modules
+// reinstalled since.
+func RestoreFromTrash(trashedPath, originalPath string) error {
+	trash, err := trashDir()
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(trash, trashedPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%s is not in the trash %s", trashedPath, trash)
+	}
+	if testmode.Enabled() {
+		return testmode.Record(testmode.ActionRestore, originalPath)
+	}
+
+	if _, err := os.Lstat(trashedPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s is no longer in the trash (emptied?)", trashedPath)
+		}
+		return fmt.Errorf("cannot access %s: %w", trashedPath, err)
+	}
+	if _, err := os.Lstat(originalPath); err == nil {
+		return fmt.Errorf("%s already exists", originalPath)
+	}
+	if err := os.MkdirAll(filepath.Dir(originalPath), 0755); err != nil {
+		return fmt.Errorf("failed to recreate %s: %w", filepath.Dir(originalPath), err)
+	}
+	if err := os.Rename(trashedPath, originalPath); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", originalPath, err)
+	}
+	if xdgTrash() {
+		os.Remove(filepath.Join(trash, "info", filepath.Base(trashedPath)+".trashinfo"))
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// Kinds of Backup
+const (
+	// BackupReset is the config saved by `zap config reset` before resetting
+	BackupReset = "reset"
+	// BackupPrevious is a config from the rotation Save keeps (config.json.backup, .backup2)
+	BackupPrevious = "previous"
+)
+
+// maxResetBackups is how many timestamped backups are kept; older ones are removed
+const maxResetBackups = 10
+
+// resetBackupPrefix and resetBackupLayout name timestamped backups, e.g.
+// config.json.reset-20240101-120000
+const (
+	resetBackupPrefix = ".reset-"
+	resetBackupLayout = "20060102-150405"
+)
+
+// Backup is a previous config.json that can be restored
+type Backup struct {
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+}
+
+// BackupBeforeReset saves the current config.json as a timestamped backup and returns
+// its path ("" when there is no config file yet). Only the newest few are kept.
+func BackupBeforeReset(ctx context.Context) (string, error) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	configPath, err := getConfigPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read config: %w", err)
+	}
+
+	backupPath := configPath + resetBackupPrefix + testmode.Now().Format(resetBackupLayout)
+	if err := checkDiskSpaceForConfig(backupPath, int64(len(data))); err != nil {
+		return "", fmt.Errorf("insufficient disk space for config backup: %w", err)
+	}
+	if err := os.WriteFile(backupPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write config backup: %w", err)
+	}
+
+	// Prune the oldest timestamped backups; the layout sorts chronologically
+	matches, _ := filepath.Glob(configPath + resetBackupPrefix + "*")
+	sort.Strings(matches)
+	for len(matches) > maxResetBackups {
+		os.Remove(matches[0])
+		matches = matches[1:]
+	}
+	return backupPath, nil
+}
+
+// Backups lists the configs that can be restored, newest first: the timestamped backups
+// of `zap config reset` and the rotation Save keeps. Backups identical to the current
+// config or to another backup are listed once, preferring the timestamped one.
+func Backups() ([]Backup, error) {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	matches, _ := filepath.Glob(configPath + resetBackupPrefix + "*")
+	for _, path := range matches {
+		stamp := strings.TrimPrefix(path, configPath+resetBackupPrefix)
+		t, err := time.ParseInLocation(resetBackupLayout, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{Path: path, Time: t, Kind: BackupReset})
+	}
+	for _, path := range []string{getBackupPath(configPath), getBackupPath2(configPath)} {
+		if info, err := os.Stat(path); err == nil {
+			backups = append(backups, Backup{Path: path, Time: info.ModTime(), Kind: BackupPrevious})
+		}
+	}
+	// Timestamped backups come first so they win over identical rotation copies
+	sort.SliceStable(backups, func(i, j int) bool {
+		if backups[i].Kind != backups[j].Kind {
+			return backups[i].Kind == BackupReset
+		}
+		return backups[i].Time.After(backups[j].Time)
+	})
+
+	current, _ := os.ReadFile(configPath)
+	seen := [][]byte{bytes.TrimSpace(current)}
+	var distinct []Backup
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup.Path)
+		if err != nil {
+			continue
+		}
+		data = bytes.TrimSpace(data)
+		duplicate := false
+		for _, other := range seen {
+			if bytes.Equal(data, other) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			seen = append(seen, data)
+			distinct = append(distinct, backup)
+		}
+	}
+	sort.SliceStable(distinct, func(i, j int) bool { return distinct[i].Time.After(distinct[j].Time) })
+	return distinct, nil
+}
+
+// ReadBackup loads and validates the config saved in a backup
+func ReadBackup(backup Backup) (*Config, error) {
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("backup %s is corrupted: %w", filepath.Base(backup.Path), err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("backup %s is invalid: %w", filepath.Base(backup.Path), err)
+	}
+	mergeWithDefaults(&cfg)
+	return &cfg, nil
+}
@@ -1,59 +1,264 @@
 package config
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"runtime"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/ports"
+	"github.com/hugoev/zap/internal/testmode"
 	"golang.org/x/sys/unix"
 )
 
+// Config is zap's config.json. The desc tags document each key for `zap config keys`.
 type Config struct {
-	ProtectedPorts         []int    `json:"protected_ports"`
-	MaxAgeDaysForCleanup   int      `json:"max_age_days_for_cleanup"`
-	ExcludePaths           []string `json:"exclude_paths"`
-	AutoConfirmSafeActions bool     `json:"auto_confirm_safe_actions"`
+	ProtectedPorts         []int    `json:"protected_ports" desc:"Ports whose processes are never terminated"`
+	MaxAgeDaysForCleanup   age.Days `json:"max_age_days_for_cleanup" desc:"Days without modification before a directory counts as stale (or e.g. \"90d\", \"6mo\")"`
+	ExcludePaths           []string `json:"exclude_paths" desc:"Directories cleanup never touches"`
+	AutoConfirmSafeActions bool     `json:"auto_confirm_safe_actions" desc:"Terminate safe dev servers without asking"`
+	DeletionTimeoutSeconds int      `json:"deletion_timeout_seconds" desc:"Skip a directory whose deletion takes longer than this many seconds"`
+	PathSetup              string   `json:"path_setup" desc:"Whether zap may add itself to PATH in shell rc files (never, prompt, auto)"`
+	ReportWebhook          string   `json:"report_webhook" desc:"http(s) URL that receives a summary of unattended cleanups"`
+	ReportWebhookFormat    string   `json:"report_webhook_format" desc:"Payload format of report_webhook (json, slack)"`
+	CelebrateMilestones    bool     `json:"celebrate_milestones" desc:"Mention when lifetime reclaimed space crosses a milestone"`
+	// ProtectCurrentProject asks before killing processes of the project zap runs in,
+	// even with --yes (nil means the default, true)
+	ProtectCurrentProject *bool `json:"protect_current_project" desc:"Always ask before terminating processes of the project zap runs in"`
+	// ScanConcurrency limits parallel port and directory scans (0 means auto)
+	ScanConcurrency int `json:"scan_concurrency" desc:"Parallel port and directory scans (0 = 2x CPUs, up to 20)"`
+	// IgnoredProcesses are processes the user declined to terminate and asked not to be
+	// prompted about again
+	IgnoredProcesses []IgnoredProcess `json:"ignored_processes" desc:"Processes zap ports no longer asks about (zap config ignored)"`
+	// IgnoredDirs are cleanup candidates the user declined to delete and asked not to be
+	// proposed again
+	IgnoredDirs []IgnoredDir `json:"ignored_dirs" desc:"Directories zap cleanup no longer proposes (zap config ignored-dirs)"`
+	// AllowSudo retries kills that fail for lack of permissions with `sudo -n`, when
+	// sudo works without a password prompt
+	AllowSudo bool `json:"allow_sudo" desc:"Retry permission-denied kills with passwordless sudo"`
+	// SignalEscalation maps a process class ("safe", "infrastructure", "unknown") to the
+	// signals sent to terminate it, e.g. ["INT", "TERM@5s", "KILL@10s"]; classes not
+	// listed get SIGTERM, then SIGKILL after 3s
+	SignalEscalation map[string][]string `json:"signal_escalation" desc:"Signals sent to terminate each process class, e.g. infrastructure: INT, TERM@5s, KILL@10s"`
+	// CleanupPatterns are the directory names zap cleanup looks for, optionally with
+	// shell wildcards ("*.egg-info")
+	CleanupPatterns []string `json:"cleanup_patterns" desc:"Directory names zap cleanup removes when stale, e.g. node_modules, .venv, *.egg-info"`
+	// CleanupRules override max_age_days_for_cleanup per pattern, skip small directories
+	// or turn a pattern off; the first rule matching a directory applies
+	CleanupRules []CleanupRule `json:"cleanup_rules" desc:"Per-pattern cleanup settings: max_age_days, min_size_mb, enabled"`
+	// WatchIntervalSeconds is how often `zap ports --watch` re-scans
+	WatchIntervalSeconds int `json:"watch_interval_seconds" desc:"Seconds between scans of zap ports --watch"`
+	// CPUSpikePercent is the CPU usage, in percent of one core, above which zap ports
+	// --watch considers a listener busy
+	CPUSpikePercent int `json:"cpu_spike_percent" desc:"CPU usage (percent of one core) above which zap ports --watch flags a listener"`
+	// CPUSpikeMinutes is how long a listener must stay above cpu_spike_percent before
+	// zap ports --watch reports it (nil means the default, 0 turns it off)
+	CPUSpikeMinutes *int `json:"cpu_spike_minutes" desc:"Minutes above cpu_spike_percent before zap ports --watch reports a listener (0 = off)"`
+	// ActiveRepoDays skips cleanup in git repositories committed to within this many
+	// days; repositories with uncommitted changes are always skipped (nil means the
+	// default, 0 only skips those)
+	ActiveRepoDays *int `json:"active_repo_days" desc:"Skip cleanup in git repositories committed to within this many days (0 = only uncommitted changes)"`
+	// StallTimeoutSeconds is how long a port lookup, directory scan or deletion may make
+	// no progress before zap reports it and offers to skip it (nil means the default, 0
+	// turns stall detection off)
+	StallTimeoutSeconds *int `json:"stall_timeout_seconds" desc:"Seconds without progress before a stuck lookup, scan or deletion is reported and can be skipped (0 = off)"`
+	// HugeCleanupGB and HugeCleanupDirs make a cleanup that would delete more than this
+	// many GiB or directories unasked (--yes) need a recent dry run listing them, or
+	// --i-know (nil means the default, 0 turns the limit off)
+	HugeCleanupGB   *int `json:"huge_cleanup_gb" desc:"Cleanups with --yes deleting more GiB than this need a recent dry run or --i-know (0 = no limit)"`
+	HugeCleanupDirs *int `json:"huge_cleanup_dirs" desc:"Cleanups with --yes deleting more directories than this need a recent dry run or --i-know (0 = no limit)"`
+	// DryRunWindowMinutes is how recent that dry run has to be
+	DryRunWindowMinutes int `json:"dry_run_window_minutes" desc:"Minutes a dry run counts as recent for huge_cleanup_gb and huge_cleanup_dirs"`
+	// PortBackend pins how ports are scanned, for systems where the tool auto-detection
+	// picks is slow or broken
+	PortBackend string `json:"port_backend" desc:"How ports are scanned (auto, lsof, ss, netstat, native)"`
+	// ProtectedProcesses are never offered for termination, whatever port they bind:
+	// shell wildcards matched against the process name or command line ("postgres*",
+	// "*ssh*"), or regular expressions after "re:"
+	ProtectedProcesses []string `json:"protected_processes" desc:"Processes never terminated, by name or command line: postgres*, *ssh*, re:<regexp>"`
+	// ProcessVerification is how closely a process must still match what the scan found
+	// before it is killed, guarding against its PID having been reused meanwhile
+	ProcessVerification string `json:"process_verification" desc:"How strictly a process is re-checked before it is killed (lenient, normal, paranoid)"`
+	// SizeUnits is how sizes are shown: binary (KiB, MiB, GiB), si (kB, MB, GB, matching
+	// df -H) or bytes
+	SizeUnits string `json:"size_units" desc:"How sizes are shown (binary: GiB, si: GB as df -H shows them, bytes)"`
+	// ScanPorts replaces the built-in development ports `zap ports` scans by default,
+	// as ranges like "3000-3999,8000-8999" ("" means the built-in list)
+	ScanPorts string `json:"scan_ports" desc:"Ports zap ports scans by default, e.g. 3000-3999,8000-8999 (empty = common development ports)"`
+	// UpdateCheck looks for a newer release once a day while commands run and mentions
+	// it when they finish; off unless turned on, as it asks GitHub
+	UpdateCheck bool `json:"update_check" desc:"Check for a newer release once a day and mention it after commands"`
+
+	// maxAgeOverride replaces every max age for one run (--older-than); it is never saved
+	maxAgeOverride int
+	// layers are config.json's own values, kept while a project config or environment
+	// variables override some of them (see overlay)
+	layers *layers
+	// project is the project config laid over this one
+	project *Project
+	// envKeys are the keys set by environment variables, with the variable
+	envKeys map[string]string
 }
 
+// Process classes that can have their own signal escalation
+var EscalationClasses = []string{"safe", "infrastructure", "unknown"}
+
+// escalationStep matches a step of a signal escalation: a signal, optionally @delay
+var escalationStep = regexp.MustCompile(`^(?i)(SIG)?(HUP|INT|QUIT|TERM|KILL|USR1|USR2)(@(.+))?$`)
+
+// IgnoredProcess identifies a process by command line and working directory, so it
+// still matches after a restart with a new PID
+type IgnoredProcess struct {
+	Name       string    `json:"name,omitempty"`
+	Cmd        string    `json:"cmd"`
+	WorkingDir string    `json:"working_dir"`
+	Since      time.Time `json:"since"`
+}
+
+// IgnoredDir is a directory zap cleanup lists as ignored instead of proposing it
+type IgnoredDir struct {
+	Path   string    `json:"path"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// CleanupRule tunes cleanup for the directories matching Pattern: a cleanup pattern
+// (node_modules) or a name glob (*.egg-info)
+type CleanupRule struct {
+	Pattern string `json:"pattern"`
+	// MaxAgeDays replaces max_age_days_for_cleanup (0 means use it)
+	MaxAgeDays age.Days `json:"max_age_days,omitempty"`
+	// MinSizeMB skips smaller directories, in MiB
+	MinSizeMB int64 `json:"min_size_mb,omitempty"`
+	// Enabled false never cleans these directories (nil means enabled)
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// Validate checks the rule's pattern and limits
+func (r CleanupRule) Validate() error {
+	if err := cleanup.ValidatePattern(r.Pattern); err != nil {
+		return err
+	}
+	if r.MaxAgeDays < 0 || r.MaxAgeDays > 365 {
+		return fmt.Errorf("cleanup rule %s: max_age_days must be between 1 and 365", r.Pattern)
+	}
+	if r.MinSizeMB < 0 {
+		return fmt.Errorf("cleanup rule %s: min_size_mb cannot be negative", r.Pattern)
+	}
+	return nil
+}
+
+// IsEnabled reports whether directories matching the rule may be cleaned
+func (r CleanupRule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// MaxScanConcurrency is the highest accepted scan_concurrency
+const MaxScanConcurrency = 64
+
+// PathSetup modes control whether zap may edit shell rc files to fix PATH
+const (
+	PathSetupNever  = "never"  // only via explicit `zap setup path`
+	PathSetupPrompt = "prompt" // ask when zap is installed but not in PATH
+	PathSetupAuto   = "auto"   // edit rc files without asking
+)
+
+// Report webhook payload formats
+const (
+	ReportFormatJSON  = "json"  // the cleanup summary as-is
+	ReportFormatSlack = "slack" // {"text": ...} for Slack-compatible incoming webhooks
+)
+
 var defaultConfig = Config{
 	ProtectedPorts:         []int{5432, 6379, 3306, 27017}, // Postgres, Redis, MySQL, MongoDB
 	MaxAgeDaysForCleanup:   14,
 	ExcludePaths:           []string{},
 	AutoConfirmSafeActions: false,
+	DeletionTimeoutSeconds: 120,
+	PathSetup:              PathSetupNever,
+	ReportWebhook:          "",
+	ReportWebhookFormat:    ReportFormatJSON,
+	CelebrateMilestones:    false,
+	ProtectCurrentProject:  boolPtr(true),
+	ScanConcurrency:        0,
+	IgnoredProcesses:       []IgnoredProcess{},
+	IgnoredDirs:            []IgnoredDir{},
+	AllowSudo:              false,
+	SignalEscalation:       map[string][]string{},
+	CleanupPatterns:        cleanup.DefaultPatterns(),
+	CleanupRules:           []CleanupRule{},
+	WatchIntervalSeconds:   2,
+	CPUSpikePercent:        90,
+	CPUSpikeMinutes:        intPtr(5),
+	ActiveRepoDays:         intPtr(7),
+	StallTimeoutSeconds:    intPtr(10),
+	HugeCleanupGB:          intPtr(20),
+	HugeCleanupDirs:        intPtr(50),
+	DryRunWindowMinutes:    30,
+	PortBackend:            ports.BackendAuto,
+	ProtectedProcesses:     []string{},
+	ProcessVerification:    ports.VerifyNormal,
+	SizeUnits:              cleanup.UnitsBinary,
+	ScanPorts:              "",
+	UpdateCheck:            false,
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+// Default returns a copy of the default configuration
+func Default() Config {
+	cfg := defaultConfig
+	cfg.ProtectedPorts = append([]int(nil), defaultConfig.ProtectedPorts...)
+	cfg.ExcludePaths = []string{}
+	cfg.IgnoredProcesses = []IgnoredProcess{}
+	cfg.IgnoredDirs = []IgnoredDir{}
+	cfg.SignalEscalation = map[string][]string{}
+	cfg.CleanupPatterns = append([]string(nil), defaultConfig.CleanupPatterns...)
+	cfg.CleanupRules = []CleanupRule{}
+	cfg.ProtectCurrentProject = boolPtr(*defaultConfig.ProtectCurrentProject)
+	cfg.ActiveRepoDays = intPtr(*defaultConfig.ActiveRepoDays)
+	cfg.StallTimeoutSeconds = intPtr(*defaultConfig.StallTimeoutSeconds)
+	cfg.CPUSpikeMinutes = intPtr(*defaultConfig.CPUSpikeMinutes)
+	cfg.HugeCleanupGB = intPtr(*defaultConfig.HugeCleanupGB)
+	cfg.HugeCleanupDirs = intPtr(*defaultConfig.HugeCleanupDirs)
+	cfg.ProtectedProcesses = []string{}
+	return cfg
 }
 
 // configMutex protects concurrent access to config file
 var configMutex sync.RWMutex
 
-func getConfigPath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		// Fallback to temp directory if home directory is unavailable
-		tempDir := os.TempDir()
-		configDir := filepath.Join(tempDir, "zap-config")
-		if err := os.MkdirAll(configDir, 0755); err != nil {
-			return "", fmt.Errorf("failed to create config directory in temp: %w", err)
-		}
-		return filepath.Join(configDir, "config.json"), nil
-	}
+// Path returns where config.json is: $ZAP_CONFIG if set, else config.json in zap's
+// directory
+func Path() (string, error) {
+	return getConfigPath()
+}
 
-	configDir := filepath.Join(homeDir, ".config", "zap")
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		// Try alternative location if .config can't be created
-		altDir := filepath.Join(os.TempDir(), "zap-config")
-		if mkdirErr := os.MkdirAll(altDir, 0755); mkdirErr == nil {
-			return filepath.Join(altDir, "config.json"), nil
-		}
-		return "", fmt.Errorf("failed to create config directory: %w", err)
+func getConfigPath() (string, error) {
+	if path := os.Getenv(EnvConfig); path != "" {
+		return filepath.Abs(path)
 	}
-	return filepath.Join(configDir, "config.json"), nil
+	return paths.File("config.json")
 }
 
 func getBackupPath(configPath string) string {
@@ -158,15 +363,39 @@ func checkDiskSpaceForConfig(filePath string, requiredBytes int64) error {
 	return nil
 }
 
-func Load() (*Config, error) {
+// Load reads config.json, creating it with the defaults or repairing it from a backup
+// when needed, and applies the environment variables of its keys (ZAP_PROTECTED_PORTS,
+// ...) for this run. It returns ctx's error without touching anything if ctx is
+// already done.
+func Load(ctx context.Context) (*Config, error) {
+	cfg, err := loadFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func loadFile(ctx context.Context) (*Config, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	configMutex.RLock()
-	defer configMutex.RUnlock()
+	// Creating a missing config upgrades to the write lock, so release whichever is held
+	unlock := configMutex.RUnlock
+	defer func() { unlock() }()
 
 	configPath, err := getConfigPath()
 	if err != nil {
 		return nil, err
 	}
 
+	if paths.ReadOnly() {
+		return loadReadOnly(configPath), nil
+	}
+
 	// Open with shared lock for reading (on Unix systems)
 	var file *os.File
 	if runtime.GOOS != "windows" {
@@ -175,7 +404,7 @@ func Load() (*Config, error) {
 			// Release read lock and acquire write lock for creation
 			configMutex.RUnlock()
 			configMutex.Lock()
-			defer configMutex.Unlock()
+			unlock = configMutex.Unlock
 
 			cfg := defaultConfig
 			if err := saveWithLock(&cfg); err != nil {
@@ -199,7 +428,7 @@ func Load() (*Config, error) {
 		if os.IsNotExist(err) {
 			configMutex.RUnlock()
 			configMutex.Lock()
-			defer configMutex.Unlock()
+			unlock = configMutex.Unlock
 
 			cfg := defaultConfig
 			if err := saveWithLock(&cfg); err != nil {
@@ -285,6 +514,71 @@ func Load() (*Config, error) {
 	return &cfg, nil
 }
 
+// loadReadOnly reads the config without creating, backing up or repairing it.
+// A missing or unusable config falls back to the defaults in memory.
+func loadReadOnly(configPath string) *Config {
+	cfg := Default()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return &cfg
+	}
+
+	var fileCfg Config
+	if json.Unmarshal(data, &fileCfg) != nil || fileCfg.Validate() != nil {
+		return &cfg
+	}
+	mergeWithDefaults(&fileCfg)
+	return &fileCfg
+}
+
+// Check reads config.json without repairing it, as Load would, and reports what is
+// wrong with it: unreadable, not JSON, unknown keys (often typos) or invalid values.
+// A missing config.json is fine; Load creates it with the defaults.
+func Check() error {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := Parse(data); err != nil {
+		return fmt.Errorf("%s: %w", configPath, err)
+	}
+	return nil
+}
+
+// Parse reads config.json content strictly, rejecting unknown keys and invalid values,
+// and fills in the defaults of missing keys as Load does
+func Parse(data []byte) (*Config, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	mergeWithDefaults(&cfg)
+	return &cfg, nil
+}
+
+// CorruptedFiles lists the unreadable config files Load set aside before starting over
+// with the defaults
+func CorruptedFiles() []string {
+	configPath, err := getConfigPath()
+	if err != nil {
+		return nil
+	}
+	matches, _ := filepath.Glob(configPath + ".corrupted.*")
+	return matches
+}
+
 func recoverFromCorruption(configPath string, decodeErr error) (*Config, error) {
 	// Try to restore from primary backup first
 	if backupCfg, err := loadFromBackup(configPath); err == nil {
@@ -346,12 +640,127 @@ func mergeWithDefaults(cfg *Config) {
 	if cfg.ExcludePaths == nil {
 		cfg.ExcludePaths = []string{}
 	}
+	if cfg.IgnoredProcesses == nil {
+		cfg.IgnoredProcesses = []IgnoredProcess{}
+	}
+	if cfg.IgnoredDirs == nil {
+		cfg.IgnoredDirs = []IgnoredDir{}
+	}
+	if cfg.SignalEscalation == nil {
+		cfg.SignalEscalation = map[string][]string{}
+	}
+	if cfg.DeletionTimeoutSeconds == 0 {
+		cfg.DeletionTimeoutSeconds = defaultConfig.DeletionTimeoutSeconds
+	}
+	if cfg.CleanupPatterns == nil {
+		cfg.CleanupPatterns = append([]string(nil), defaultConfig.CleanupPatterns...)
+	}
+	if cfg.CleanupRules == nil {
+		cfg.CleanupRules = []CleanupRule{}
+	}
+	if cfg.WatchIntervalSeconds == 0 {
+		cfg.WatchIntervalSeconds = defaultConfig.WatchIntervalSeconds
+	}
+	if cfg.CPUSpikePercent == 0 {
+		cfg.CPUSpikePercent = defaultConfig.CPUSpikePercent
+	}
+	if cfg.CPUSpikeMinutes == nil {
+		cfg.CPUSpikeMinutes = intPtr(*defaultConfig.CPUSpikeMinutes)
+	}
+	if cfg.PathSetup == "" {
+		cfg.PathSetup = defaultConfig.PathSetup
+	}
+	if cfg.ReportWebhookFormat == "" {
+		cfg.ReportWebhookFormat = defaultConfig.ReportWebhookFormat
+	}
+	if cfg.ProtectCurrentProject == nil {
+		cfg.ProtectCurrentProject = boolPtr(*defaultConfig.ProtectCurrentProject)
+	}
+	if cfg.ActiveRepoDays == nil {
+		cfg.ActiveRepoDays = intPtr(*defaultConfig.ActiveRepoDays)
+	}
+	if cfg.StallTimeoutSeconds == nil {
+		cfg.StallTimeoutSeconds = intPtr(*defaultConfig.StallTimeoutSeconds)
+	}
+	if cfg.HugeCleanupGB == nil {
+		cfg.HugeCleanupGB = intPtr(*defaultConfig.HugeCleanupGB)
+	}
+	if cfg.HugeCleanupDirs == nil {
+		cfg.HugeCleanupDirs = intPtr(*defaultConfig.HugeCleanupDirs)
+	}
+	if cfg.DryRunWindowMinutes == 0 {
+		cfg.DryRunWindowMinutes = defaultConfig.DryRunWindowMinutes
+	}
+	if cfg.PortBackend == "" {
+		cfg.PortBackend = defaultConfig.PortBackend
+	}
+	if cfg.ProtectedProcesses == nil {
+		cfg.ProtectedProcesses = []string{}
+	}
+	if cfg.ProcessVerification == "" {
+		cfg.ProcessVerification = defaultConfig.ProcessVerification
+	}
+	if cfg.SizeUnits == "" {
+		cfg.SizeUnits = defaultConfig.SizeUnits
+	}
 }
 
-func Save(cfg *Config) error {
+// Save writes cfg atomically. A done ctx stops the save before anything is written; once
+// started, the write always completes so config.json is never left half-written.
+func Save(ctx context.Context, cfg *Config) error {
 	configMutex.Lock()
 	defer configMutex.Unlock()
-	return saveWithLock(cfg)
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("config not saved: %w", err)
+	}
+	if paths.ReadOnly() {
+		dir, _ := paths.BaseDir()
+		return fmt.Errorf("cannot save config: %s is read-only (set %s to a writable directory)", dir, paths.EnvHome)
+	}
+	return saveWithLock(cfg.fileValues())
+}
+
+// layers remembers the values an overlay replaced
+type layers struct {
+	file    Config // as read from config.json
+	overlay Config // as the overlays left it
+}
+
+// overlay applies change, a project config or environment variables, to c for this run
+// only: Save keeps writing config.json's own value of every key the command hasn't
+// changed since
+func (c *Config) overlay(change func(*Config)) {
+	file := c.clone()
+	if c.layers != nil {
+		file = c.layers.file
+	}
+	change(c)
+	c.layers = &layers{file: file, overlay: c.clone()}
+}
+
+// clone deep-copies the saved fields of c
+func (c *Config) clone() Config {
+	var copied Config
+	data, _ := json.Marshal(c)
+	json.Unmarshal(data, &copied)
+	return copied
+}
+
+// fileValues returns c as it should be saved: keys left as the overlays set them go
+// back to config.json's values
+func (c *Config) fileValues() *Config {
+	if c.layers == nil {
+		return c
+	}
+	saved := *c
+	fields := reflect.ValueOf(&saved).Elem()
+	file, overlaid := reflect.ValueOf(c.layers.file), reflect.ValueOf(c.layers.overlay)
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Type().Field(i).IsExported() && bytes.Equal(marshalValue(fields.Field(i)), marshalValue(overlaid.Field(i))) {
+			fields.Field(i).Set(file.Field(i))
+		}
+	}
+	return &saved
 }
 
 // saveWithLock performs atomic write with file locking (must be called with configMutex held)
@@ -482,6 +891,60 @@ func saveWithLock(cfg *Config) error {
 	return nil
 }
 
+// IsProtected reports whether proc must never be terminated, because of its port or
+// its name or command line
+func (c *Config) IsProtected(proc ports.ProcessInfo) bool {
+	return c.ProtectionReason(proc) != ""
+}
+
+// ProtectionReason explains why proc is protected, e.g. `port 5432 is in protected_ports`;
+// "" if it isn't
+func (c *Config) ProtectionReason(proc ports.ProcessInfo) string {
+	if c.IsPortProtected(proc.Port) {
+		return fmt.Sprintf("port %d is in protected_ports", proc.Port)
+	}
+	for _, pattern := range c.ProtectedProcesses {
+		re, err := compileProcessPattern(pattern)
+		if err != nil {
+			continue
+		}
+		if proc.Name != "" && re.MatchString(proc.Name) {
+			return fmt.Sprintf("name %s matches protected_processes %q", proc.Name, pattern)
+		}
+		if proc.Cmd != "" && re.MatchString(proc.Cmd) {
+			return fmt.Sprintf("command line matches protected_processes %q", pattern)
+		}
+	}
+	return ""
+}
+
+// ValidateProcessPattern checks a protected_processes pattern
+func ValidateProcessPattern(pattern string) error {
+	_, err := compileProcessPattern(pattern)
+	return err
+}
+
+// compileProcessPattern turns a protected_processes pattern into a regular expression.
+// A wildcard pattern must match the whole name or command line, ignoring case; * also
+// matches "/" and spaces, so "*ssh*" matches "/usr/sbin/sshd -D". A "re:" pattern is
+// used as written and may match anywhere.
+func compileProcessPattern(pattern string) (*regexp.Regexp, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, fmt.Errorf("empty protected_processes pattern")
+	}
+	if expr, ok := strings.CutPrefix(pattern, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid protected_processes pattern %q: %w", pattern, err)
+		}
+		return re, nil
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.MustCompile("(?is)^" + expr + "$"), nil
+}
+
 func (c *Config) IsPortProtected(port int) bool {
 	for _, p := range c.ProtectedPorts {
 		if p == port {
@@ -491,6 +954,8 @@ func (c *Config) IsPortProtected(port int) bool {
 	return false
 }
 
+// AddExcludePath adds path (with ~ expanded, made absolute) to ExcludePaths; it must
+// exist. The caller saves the config.
 func (c *Config) AddExcludePath(path string) error {
 	if path == "" {
 		return fmt.Errorf("path cannot be empty")
@@ -498,9 +963,9 @@ func (c *Config) AddExcludePath(path string) error {
 
 	// Expand ~ to home directory
 	if len(path) >= 2 && path[:2] == "~/" {
-		homeDir, err := os.UserHomeDir()
+		homeDir, err := paths.HomeDir()
 		if err != nil {
-			return fmt.Errorf("failed to get home directory: %w", err)
+			return err
 		}
 		path = filepath.Join(homeDir, path[2:])
 	}
@@ -526,7 +991,86 @@ func (c *Config) AddExcludePath(path string) error {
 	}
 
 	c.ExcludePaths = append(c.ExcludePaths, absPath)
-	return Save(c)
+	return nil
+}
+
+// IsProcessIgnored reports whether a process with this command line and working
+// directory was ignored by the user
+func (c *Config) IsProcessIgnored(cmd, workingDir string) bool {
+	for _, ignored := range c.IgnoredProcesses {
+		if ignored.Cmd == cmd && ignored.WorkingDir == workingDir {
+			return true
+		}
+	}
+	return false
+}
+
+// IgnoreProcess remembers not to offer terminating a process again and saves the config
+func (c *Config) IgnoreProcess(ctx context.Context, name, cmd, workingDir string) error {
+	if cmd == "" {
+		return fmt.Errorf("cannot ignore a process without a command line")
+	}
+	if c.IsProcessIgnored(cmd, workingDir) {
+		return nil
+	}
+	c.IgnoredProcesses = append(c.IgnoredProcesses, IgnoredProcess{
+		Name:       name,
+		Cmd:        cmd,
+		WorkingDir: workingDir,
+		Since:      testmode.Now(),
+	})
+	return Save(ctx, c)
+}
+
+// RemoveIgnoredProcess forgets the ignored process at index (0-based), saves the config
+// and returns the removed entry
+func (c *Config) RemoveIgnoredProcess(ctx context.Context, index int) (IgnoredProcess, error) {
+	if index < 0 || index >= len(c.IgnoredProcesses) {
+		return IgnoredProcess{}, fmt.Errorf("no ignored process #%d", index+1)
+	}
+	removed := c.IgnoredProcesses[index]
+	c.IgnoredProcesses = append(c.IgnoredProcesses[:index], c.IgnoredProcesses[index+1:]...)
+	return removed, Save(ctx, c)
+}
+
+// IgnoredDirFor returns the ignore entry of a cleanup candidate, if the user ignored it
+func (c *Config) IgnoredDirFor(path string) (IgnoredDir, bool) {
+	for _, ignored := range c.IgnoredDirs {
+		if ignored.Path == path {
+			return ignored, true
+		}
+	}
+	return IgnoredDir{}, false
+}
+
+// IgnoreDirs remembers not to propose deleting these directories again, for reason
+// (which may be empty), and saves the config
+func (c *Config) IgnoreDirs(ctx context.Context, paths []string, reason string) error {
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("cannot ignore %s: not an absolute path", path)
+		}
+		if _, ok := c.IgnoredDirFor(path); ok {
+			continue
+		}
+		c.IgnoredDirs = append(c.IgnoredDirs, IgnoredDir{
+			Path:   path,
+			Reason: reason,
+			Since:  testmode.Now(),
+		})
+	}
+	return Save(ctx, c)
+}
+
+// RemoveIgnoredDir forgets the ignored directory at index (0-based), saves the config
+// and returns the removed entry
+func (c *Config) RemoveIgnoredDir(ctx context.Context, index int) (IgnoredDir, error) {
+	if index < 0 || index >= len(c.IgnoredDirs) {
+		return IgnoredDir{}, fmt.Errorf("no ignored directory #%d", index+1)
+	}
+	removed := c.IgnoredDirs[index]
+	c.IgnoredDirs = append(c.IgnoredDirs[:index], c.IgnoredDirs[index+1:]...)
+	return removed, Save(ctx, c)
 }
 
 // Validate checks that all config values are within acceptable ranges
@@ -546,6 +1090,114 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("max_age_days_for_cleanup cannot exceed 365 days")
 	}
 
+	// Validate deletion timeout (0 means unset and is replaced by the default)
+	if c.DeletionTimeoutSeconds < 0 {
+		return fmt.Errorf("deletion_timeout_seconds cannot be negative")
+	}
+	for _, pattern := range c.CleanupPatterns {
+		if err := cleanup.ValidatePattern(pattern); err != nil {
+			return err
+		}
+	}
+	for _, rule := range c.CleanupRules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range c.ProtectedProcesses {
+		if err := ValidateProcessPattern(pattern); err != nil {
+			return err
+		}
+	}
+	if c.WatchIntervalSeconds < 0 {
+		return fmt.Errorf("watch_interval_seconds cannot be negative")
+	}
+	if c.CPUSpikePercent < 0 || c.CPUSpikePercent > 10000 {
+		return fmt.Errorf("cpu_spike_percent must be between 1 and 10000")
+	}
+	if c.CPUSpikeMinutes != nil && (*c.CPUSpikeMinutes < 0 || *c.CPUSpikeMinutes > 24*60) {
+		return fmt.Errorf("cpu_spike_minutes must be between 0 and %d", 24*60)
+	}
+	if c.ActiveRepoDays != nil && (*c.ActiveRepoDays < 0 || *c.ActiveRepoDays > 365) {
+		return fmt.Errorf("active_repo_days must be between 0 and 365")
+	}
+	if c.StallTimeoutSeconds != nil && (*c.StallTimeoutSeconds < 0 || *c.StallTimeoutSeconds > 3600) {
+		return fmt.Errorf("stall_timeout_seconds must be between 0 and 3600")
+	}
+	if c.HugeCleanupGB != nil && *c.HugeCleanupGB < 0 {
+		return fmt.Errorf("huge_cleanup_gb cannot be negative")
+	}
+	if c.HugeCleanupDirs != nil && *c.HugeCleanupDirs < 0 {
+		return fmt.Errorf("huge_cleanup_dirs cannot be negative")
+	}
+	// 0 is what a config.json without the key decodes to
+	if c.DryRunWindowMinutes < 0 || c.DryRunWindowMinutes > 24*60 {
+		return fmt.Errorf("dry_run_window_minutes must be between 0 and %d (0 uses the default, %d)", 24*60, defaultConfig.DryRunWindowMinutes)
+	}
+
+	// Validate PATH setup mode
+	switch c.PathSetup {
+	case "", PathSetupNever, PathSetupPrompt, PathSetupAuto:
+	default:
+		return fmt.Errorf("invalid path_setup: %s (must be never, prompt or auto)", c.PathSetup)
+	}
+
+	// Validate report webhook
+	if c.ReportWebhook != "" {
+		if err := ValidateWebhookURL(c.ReportWebhook); err != nil {
+			return err
+		}
+	}
+	switch c.ReportWebhookFormat {
+	case "", ReportFormatJSON, ReportFormatSlack:
+	default:
+		return fmt.Errorf("invalid report_webhook_format: %s (must be json or slack)", c.ReportWebhookFormat)
+	}
+
+	// Validate scan concurrency (0 means auto)
+	if c.ScanConcurrency < 0 || c.ScanConcurrency > MaxScanConcurrency {
+		return fmt.Errorf("scan_concurrency must be between 0 (auto) and %d", MaxScanConcurrency)
+	}
+	if c.PortBackend != "" {
+		if _, err := ports.ParseBackend(c.PortBackend); err != nil {
+			return fmt.Errorf("invalid port_backend: %w", err)
+		}
+	}
+	if c.ProcessVerification != "" {
+		if _, err := ports.ParseStrictness(c.ProcessVerification); err != nil {
+			return fmt.Errorf("invalid process_verification: %w", err)
+		}
+	}
+	if c.SizeUnits != "" {
+		if _, err := cleanup.ParseUnits(c.SizeUnits); err != nil {
+			return fmt.Errorf("invalid size_units: %w", err)
+		}
+	}
+	if c.ScanPorts != "" {
+		if _, err := ports.ParsePortRange(c.ScanPorts); err != nil {
+			return fmt.Errorf("invalid scan_ports: %w", err)
+		}
+	}
+
+	// Validate ignored processes
+	for _, ignored := range c.IgnoredProcesses {
+		if ignored.Cmd == "" {
+			return fmt.Errorf("ignored process must have a command")
+		}
+	}
+	for _, ignored := range c.IgnoredDirs {
+		if !filepath.IsAbs(ignored.Path) {
+			return fmt.Errorf("invalid ignored_dirs path %q: must be absolute", ignored.Path)
+		}
+	}
+
+	// Validate signal escalation policies
+	for class, steps := range c.SignalEscalation {
+		if err := ValidateEscalation(class, steps); err != nil {
+			return err
+		}
+	}
+
 	// Validate exclude paths
 	for _, path := range c.ExcludePaths {
 		if path == "" {
@@ -559,35 +1211,248 @@ func (c *Config) Validate() error {
 	return nil
 }
 
-func (c *Config) ShouldCleanup(path string, modTime time.Time) bool {
-	// Validate inputs
-	if path == "" {
-		return false
+// ValidateEscalation checks a signal_escalation entry, e.g. "infrastructure":
+// ["INT", "TERM@5s", "KILL@10s"]
+func ValidateEscalation(class string, steps []string) error {
+	known := false
+	for _, c := range EscalationClasses {
+		known = known || c == class
 	}
-	if modTime.IsZero() {
-		return false
+	if !known {
+		return fmt.Errorf("invalid signal_escalation class: %s (must be one of %s)", class, strings.Join(EscalationClasses, ", "))
+	}
+	if len(steps) == 0 {
+		return fmt.Errorf("signal_escalation for %s has no steps", class)
+	}
+	for _, step := range steps {
+		match := escalationStep.FindStringSubmatch(strings.TrimSpace(step))
+		if match == nil {
+			return fmt.Errorf("invalid signal_escalation step for %s: %q (use a signal such as INT, TERM or KILL, optionally with @delay, e.g. KILL@10s)", class, step)
+		}
+		if match[4] != "" {
+			if delay, err := time.ParseDuration(match[4]); err != nil || delay < 0 {
+				return fmt.Errorf("invalid delay in signal_escalation step for %s: %q", class, step)
+			}
+		}
 	}
+	return nil
+}
 
-	// Check if path is excluded
+// EscalationFor returns the configured signal escalation steps for a process class
+// (nil means the default)
+func (c *Config) EscalationFor(class string) []string {
+	return c.SignalEscalation[class]
+}
+
+// ValidateWebhookURL checks that a report webhook is an absolute http(s) URL
+func ValidateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return fmt.Errorf("invalid report_webhook: %s (must be an http(s) URL)", rawURL)
+	}
+	return nil
+}
+
+// ScanWorkers returns how many scans may run in parallel: scan_concurrency, or twice
+// the number of CPUs (capped at 20) when it is left on auto
+func (c *Config) ScanWorkers() int {
+	if c.ScanConcurrency > 0 {
+		return c.ScanConcurrency
+	}
+	workers := runtime.NumCPU() * 2
+	if workers > 20 {
+		workers = 20
+	}
+	return workers
+}
+
+// ProtectsCurrentProject reports whether processes of the current project need explicit confirmation
+func (c *Config) ProtectsCurrentProject() bool {
+	return c.ProtectCurrentProject == nil || *c.ProtectCurrentProject
+}
+
+// ActiveRepoWindow returns how recent a commit must be for cleanup to skip its
+// repository; 0 means only uncommitted changes count
+func (c *Config) ActiveRepoWindow() time.Duration {
+	days := *defaultConfig.ActiveRepoDays
+	if c.ActiveRepoDays != nil {
+		days = *c.ActiveRepoDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DefaultScanPorts returns the ports `zap ports` scans when none are given: scan_ports,
+// else the common development ports
+func (c *Config) DefaultScanPorts() []int {
+	if c.ScanPorts != "" {
+		if scanPorts, err := ports.ParsePortRange(c.ScanPorts); err == nil {
+			return scanPorts
+		}
+	}
+	return ports.CommonDevPorts
+}
+
+// StallTimeout returns how long work may make no progress before it is reported as
+// stalled; 0 means stalls aren't detected
+func (c *Config) StallTimeout() time.Duration {
+	seconds := *defaultConfig.StallTimeoutSeconds
+	if c.StallTimeoutSeconds != nil {
+		seconds = *c.StallTimeoutSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// HugeCleanup reports whether deleting dirs directories of size bytes in total crosses
+// huge_cleanup_gb or huge_cleanup_dirs
+func (c *Config) HugeCleanup(dirs int, bytes int64) bool {
+	gb, maxDirs := *defaultConfig.HugeCleanupGB, *defaultConfig.HugeCleanupDirs
+	if c.HugeCleanupGB != nil {
+		gb = *c.HugeCleanupGB
+	}
+	if c.HugeCleanupDirs != nil {
+		maxDirs = *c.HugeCleanupDirs
+	}
+	return (gb > 0 && bytes > int64(gb)<<30) || (maxDirs > 0 && dirs > maxDirs)
+}
+
+// DryRunWindow returns how recent a dry run must be to clear a huge cleanup
+func (c *Config) DryRunWindow() time.Duration {
+	minutes := c.DryRunWindowMinutes
+	if minutes <= 0 {
+		minutes = defaultConfig.DryRunWindowMinutes
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// DeletionTimeout returns the per-directory deletion time budget
+func (c *Config) DeletionTimeout() time.Duration {
+	seconds := c.DeletionTimeoutSeconds
+	if seconds <= 0 {
+		seconds = defaultConfig.DeletionTimeoutSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// WatchInterval returns how often `zap ports --watch` re-scans
+func (c *Config) WatchInterval() time.Duration {
+	seconds := c.WatchIntervalSeconds
+	if seconds <= 0 {
+		seconds = defaultConfig.WatchIntervalSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// CPUSpike returns the CPU usage, in percent of one core, and how long a listener must
+// stay above it for zap ports --watch to report it; a duration of 0 means CPU usage
+// isn't watched
+func (c *Config) CPUSpike() (int, time.Duration) {
+	percent := c.CPUSpikePercent
+	if percent <= 0 {
+		percent = defaultConfig.CPUSpikePercent
+	}
+	minutes := *defaultConfig.CPUSpikeMinutes
+	if c.CPUSpikeMinutes != nil {
+		minutes = *c.CPUSpikeMinutes
+	}
+	return percent, time.Duration(minutes) * time.Minute
+}
+
+// IsExcluded reports whether path is, or is inside, one of exclude_paths
+func (c *Config) IsExcluded(path string) bool {
 	absPath, err := filepath.Abs(path)
 	if err != nil {
 		// If we can't resolve the path, err on the side of caution and don't cleanup
-		return false
+		return true
 	}
 
 	for _, excluded := range c.ExcludePaths {
 		if absPath == excluded {
-			return false
+			return true
 		}
 		// Also check if the path is a subdirectory of an excluded path
 		rel, err := filepath.Rel(excluded, absPath)
 		if err == nil && rel != ".." && !strings.HasPrefix(rel, "..") {
-			return false
+			return true
 		}
 	}
+	return false
+}
+
+// CleanupRule returns the first cleanup rule for a directory at path that matched
+// pattern, or nil
+func (c *Config) CleanupRule(path, pattern string) *CleanupRule {
+	name := filepath.Base(path)
+	for i, rule := range c.CleanupRules {
+		if rule.Pattern == pattern || rule.Pattern == name {
+			return &c.CleanupRules[i]
+		}
+		if matched, _ := filepath.Match(rule.Pattern, name); matched {
+			return &c.CleanupRules[i]
+		}
+	}
+	return nil
+}
+
+// MaxAgeDaysFor returns the days without modification after which a directory matching
+// pattern is stale: its cleanup rule's max_age_days, else max_age_days_for_cleanup
+func (c *Config) MaxAgeDaysFor(path, pattern string) int {
+	if c.maxAgeOverride > 0 {
+		return c.maxAgeOverride
+	}
+	if rule := c.CleanupRule(path, pattern); rule != nil && rule.MaxAgeDays > 0 {
+		return int(rule.MaxAgeDays)
+	}
+	return int(c.MaxAgeDaysForCleanup)
+}
+
+// MaxAgeDays returns the days without modification after which a directory counts as
+// stale when no cleanup rule says otherwise
+func (c *Config) MaxAgeDays() int {
+	if c.maxAgeOverride > 0 {
+		return c.maxAgeOverride
+	}
+	return int(c.MaxAgeDaysForCleanup)
+}
+
+// OverrideMaxAge makes directories count as stale after days without modification for
+// this run, whatever max_age_days_for_cleanup and the cleanup rules say (--older-than)
+func (c *Config) OverrideMaxAge(days int) {
+	c.maxAgeOverride = days
+}
+
+// ShouldCleanupDirectory is ShouldCleanup with the cleanup rule of the directory's
+// pattern applied: its max age, minimum size and whether it is enabled
+func (c *Config) ShouldCleanupDirectory(dir cleanup.DirectoryInfo) bool {
+	rule := c.CleanupRule(dir.Path, dir.Pattern)
+	if rule == nil {
+		return c.ShouldCleanup(dir.Path, dir.ModTime)
+	}
+	if !rule.IsEnabled() || dir.Size < rule.MinSizeMB*1024*1024 {
+		return false
+	}
+	if rule.MaxAgeDays == 0 || c.maxAgeOverride > 0 {
+		return c.ShouldCleanup(dir.Path, dir.ModTime)
+	}
+	withRule := *c
+	withRule.MaxAgeDaysForCleanup = rule.MaxAgeDays
+	return withRule.ShouldCleanup(dir.Path, dir.ModTime)
+}
+
+func (c *Config) ShouldCleanup(path string, modTime time.Time) bool {
+	// Validate inputs
+	if path == "" {
+		return false
+	}
+	if modTime.IsZero() {
+		return false
+	}
+
+	if c.IsExcluded(path) {
+		return false
+	}
 
 	// Validate max age is reasonable
-	maxAgeDays := c.MaxAgeDaysForCleanup
+	maxAgeDays := c.MaxAgeDays()
 	if maxAgeDays <= 0 {
 		maxAgeDays = 14 // Default fallback
 	}
@@ -596,7 +1461,7 @@ func (c *Config) ShouldCleanup(path string, modTime time.Time) bool {
 	}
 
 	// Check if recently modified
-	age := time.Since(modTime)
+	age := testmode.Since(modTime)
 	maxAge := time.Duration(maxAgeDays) * 24 * time.Hour
 	return age > maxAge
 }
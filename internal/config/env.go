@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvConfig replaces the path of config.json
+const EnvConfig = "ZAP_CONFIG"
+
+// EnvPrefix starts the environment variable of every config key: ZAP_ and the key in
+// upper case, e.g. ZAP_PROTECTED_PORTS for protected_ports
+const EnvPrefix = "ZAP_"
+
+// envAliases are shorter variables for long keys, named like their zap config set keys
+var envAliases = map[string]string{
+	"max_age_days_for_cleanup":  "ZAP_MAX_AGE_DAYS",
+	"auto_confirm_safe_actions": "ZAP_AUTO_CONFIRM",
+	"deletion_timeout_seconds":  "ZAP_DELETION_TIMEOUT",
+	"watch_interval_seconds":    "ZAP_WATCH_INTERVAL",
+	"stall_timeout_seconds":     "ZAP_STALL_TIMEOUT",
+	"dry_run_window_minutes":    "ZAP_DRY_RUN_WINDOW",
+}
+
+// EnvNames returns the environment variables that set key, the full name first
+func EnvNames(key string) []string {
+	names := []string{EnvPrefix + strings.ToUpper(key)}
+	if alias, ok := envAliases[key]; ok {
+		names = append(names, alias)
+	}
+	return names
+}
+
+// EnvOverride returns the environment variable that set key for this run, or ""
+func (c *Config) EnvOverride(key string) string {
+	return c.envKeys[key]
+}
+
+// applyEnv sets the keys that have an environment variable, so CI jobs and containers
+// can configure zap without writing config.json. Values are JSON, or for convenience
+// plain text: a string, a number, true/false, or a comma-separated list.
+func (c *Config) applyEnv() error {
+	set := make(map[string]string)
+	values := make(map[int]reflect.Value)
+	configType := reflect.TypeOf(*c)
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		for _, name := range EnvNames(key) {
+			value := os.Getenv(name)
+			if value == "" {
+				continue
+			}
+			data, err := envJSON(field.Type, value)
+			if err == nil {
+				parsed := reflect.New(field.Type)
+				err = json.Unmarshal(data, parsed.Interface())
+				values[i] = parsed.Elem()
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			set[key] = name
+			break
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+
+	c.overlay(func(cfg *Config) {
+		fields := reflect.ValueOf(cfg).Elem()
+		for i, value := range values {
+			fields.Field(i).Set(value)
+		}
+	})
+	if err := c.Validate(); err != nil {
+		var names []string
+		for _, name := range set {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("invalid config from %s: %w", strings.Join(names, ", "), err)
+	}
+	c.envKeys = set
+	return nil
+}
+
+// envJSON turns the value of an environment variable into JSON for a field of type t:
+// as is if it already is JSON of the right type, else read as plain text
+func envJSON(t reflect.Type, value string) ([]byte, error) {
+	if strings.TrimSpace(value) == "null" {
+		return nil, fmt.Errorf("null isn't a value (unset the variable for the config.json value)")
+	}
+	if json.Unmarshal([]byte(value), reflect.New(t).Interface()) == nil {
+		return []byte(value), nil
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not true or false", value)
+		}
+		return json.Marshal(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			itemJSON, err := envJSON(t.Elem(), item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, string(itemJSON))
+		}
+		return []byte("[" + strings.Join(items, ",") + "]"), nil
+	case reflect.String:
+		return json.Marshal(value)
+	case reflect.Int:
+		// e.g. age.Days, which reads "6mo" from a JSON string
+		quoted, _ := json.Marshal(value)
+		if json.Unmarshal(quoted, reflect.New(t).Interface()) == nil {
+			return quoted, nil
+		}
+		return nil, fmt.Errorf("%q is not a number", value)
+	}
+	return nil, fmt.Errorf("%q is not valid JSON for this key", value)
+}
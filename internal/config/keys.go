@@ -0,0 +1,92 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// KeyInfo documents one config.json key
+type KeyInfo struct {
+	Key         string          `json:"key"`
+	Type        string          `json:"type"`
+	Default     json.RawMessage `json:"default"`
+	Current     json.RawMessage `json:"current"`
+	Description string          `json:"description"`
+	// Env are the environment variables that set the key; EnvSet is the one that did
+	// for this run
+	Env    []string `json:"env"`
+	EnvSet string   `json:"env_set,omitempty"`
+}
+
+// Keys describes every key of cfg, in config.json order, from the Config struct tags
+func Keys(cfg *Config) []KeyInfo {
+	defaults := Default()
+	defaultValue := reflect.ValueOf(defaults)
+	currentValue := reflect.ValueOf(*cfg)
+	configType := currentValue.Type()
+
+	var keys []KeyInfo
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		keys = append(keys, KeyInfo{
+			Key:         name,
+			Type:        typeName(field.Type),
+			Default:     marshalValue(defaultValue.Field(i)),
+			Current:     marshalValue(currentValue.Field(i)),
+			Description: field.Tag.Get("desc"),
+			Env:         EnvNames(name),
+			EnvSet:      cfg.EnvOverride(name),
+		})
+	}
+	return keys
+}
+
+// Unset puts key back to its default value
+func (c *Config) Unset(key string) error {
+	defaults := Default()
+	fields := reflect.ValueOf(c).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Type().Field(i).Tag.Get("json"), ",")
+		if name == key && name != "-" {
+			fields.Field(i).Set(reflect.ValueOf(defaults).Field(i))
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown config key: %s", key)
+}
+
+// typeName names a config value's type the way it's written in JSON
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeName(t.Elem())
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.String:
+		return "string"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Struct {
+			return "list of objects"
+		}
+		return "list of " + typeName(t.Elem()) + "s"
+	case reflect.Map:
+		return "map of " + typeName(t.Elem())
+	}
+	return t.Kind().String()
+}
+
+func marshalValue(value reflect.Value) json.RawMessage {
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
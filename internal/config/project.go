@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/paths"
+)
+
+// ProjectFiles are the names of a project config, looked for in this order
+var ProjectFiles = []string{".zaprc", "zap.json"}
+
+// Project is a project's own config (.zaprc or zap.json), laid over config.json for
+// commands run inside the project, e.g. to give a monorepo other cleanup ages and
+// protected ports. Keys it leaves out keep their global value.
+type Project struct {
+	// Path is the project config file
+	Path string `json:"-"`
+	// ProtectedPorts are protected in addition to the global protected_ports; a project
+	// can't unprotect a port
+	ProtectedPorts       []int     `json:"protected_ports"`
+	MaxAgeDaysForCleanup *age.Days `json:"max_age_days_for_cleanup"`
+	// CleanupPatterns replace the global cleanup_patterns
+	CleanupPatterns []string `json:"cleanup_patterns"`
+	// CleanupRules come before the global cleanup_rules, so they win for their patterns
+	CleanupRules []CleanupRule `json:"cleanup_rules"`
+	// ExcludePaths are excluded in addition to the global exclude_paths; relative paths
+	// are relative to the directory of the project config
+	ExcludePaths []string `json:"exclude_paths"`
+	ScanPorts    *string  `json:"scan_ports"`
+}
+
+// FindProject returns the project config nearest to dir: in dir itself or the closest
+// parent that has one. It returns nil if there is none.
+func FindProject(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		for _, name := range ProjectFiles {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			return readProject(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// readProject reads a project config, rejecting unknown keys: they are either typos or
+// global settings a project can't change
+func readProject(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var project Project
+	if err := decoder.Decode(&project); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	project.Path = path
+
+	dir := filepath.Dir(path)
+	for i, excluded := range project.ExcludePaths {
+		if rest, ok := strings.CutPrefix(excluded, "~/"); ok {
+			home, err := paths.HomeDir()
+			if err != nil {
+				return nil, err
+			}
+			excluded = filepath.Join(home, rest)
+		} else if excluded != "" && !filepath.IsAbs(excluded) {
+			excluded = filepath.Join(dir, excluded)
+		}
+		project.ExcludePaths[i] = filepath.Clean(excluded)
+	}
+	return &project, nil
+}
+
+// ApplyProject lays a project config over c. Keys set by environment variables keep
+// their value, and Save keeps writing config.json's values of the keys the project
+// changes.
+func (c *Config) ApplyProject(project *Project) error {
+	merged := *c
+	if c.envKeys["protected_ports"] == "" {
+		for _, port := range project.ProtectedPorts {
+			if !slices.Contains(merged.ProtectedPorts, port) {
+				merged.ProtectedPorts = append(slices.Clip(merged.ProtectedPorts), port)
+			}
+		}
+	}
+	if project.MaxAgeDaysForCleanup != nil && c.envKeys["max_age_days_for_cleanup"] == "" {
+		merged.MaxAgeDaysForCleanup = *project.MaxAgeDaysForCleanup
+	}
+	if project.CleanupPatterns != nil && c.envKeys["cleanup_patterns"] == "" {
+		merged.CleanupPatterns = project.CleanupPatterns
+	}
+	if len(project.CleanupRules) > 0 && c.envKeys["cleanup_rules"] == "" {
+		merged.CleanupRules = append(append([]CleanupRule(nil), project.CleanupRules...), c.CleanupRules...)
+	}
+	if c.envKeys["exclude_paths"] == "" {
+		for _, excluded := range project.ExcludePaths {
+			if !slices.Contains(merged.ExcludePaths, excluded) {
+				merged.ExcludePaths = append(slices.Clip(merged.ExcludePaths), excluded)
+			}
+		}
+	}
+	if project.ScanPorts != nil && c.envKeys["scan_ports"] == "" {
+		merged.ScanPorts = *project.ScanPorts
+	}
+	if err := merged.Validate(); err != nil {
+		return fmt.Errorf("%s: %w", project.Path, err)
+	}
+
+	merged.project = project
+	c.overlay(func(cfg *Config) { *cfg = merged })
+	return nil
+}
+
+// Project returns the project config laid over c, or nil
+func (c *Config) Project() *Project {
+	return c.project
+}
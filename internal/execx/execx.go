@@ -0,0 +1,257 @@
+// Package execx wraps the external tools zap shells out to (lsof, ps, git, go, docker, ...)
+// behind a Tool interface, so every invocation gets the same timeout/retry policy and
+// --trace-exec tracing, and tools can be replaced by fakes in tests.
+package execx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoev/zap/internal/log"
+)
+
+// Tool is an external program zap runs
+type Tool interface {
+	// Name returns the program name (or path) the tool runs
+	Name() string
+	// Lookup returns the resolved path of the program, or an error if it isn't installed
+	Lookup() (string, error)
+	// Run runs the program with args and returns its standard output.
+	// A non-zero exit status is returned as an *ExitError.
+	Run(ctx context.Context, args ...string) ([]byte, error)
+}
+
+// Policy controls how a tool is run
+type Policy struct {
+	Timeout time.Duration // applied unless ctx already has an earlier deadline (0 = none)
+	Retries int           // extra attempts when the program could not be started or was killed
+}
+
+// DefaultPolicy applies to tools without an entry in policies
+var DefaultPolicy = Policy{Timeout: 10 * time.Second, Retries: 1}
+
+// policies holds per-tool overrides; git and go are network-bound, so callers set the deadline
+var policies = map[string]Policy{
+	"git":    {Timeout: 0, Retries: 0},
+	"go":     {Timeout: 0, Retries: 0},
+	"docker": {Timeout: 15 * time.Second, Retries: 0},
+	"setx":   {Timeout: 10 * time.Second, Retries: 0},
+}
+
+var (
+	mu        sync.RWMutex
+	overrides = make(map[string]Tool)
+)
+
+// Get returns the tool for a program name or path
+func Get(name string) Tool {
+	mu.RLock()
+	tool, ok := overrides[name]
+	mu.RUnlock()
+	if ok {
+		return tool
+	}
+
+	policy, ok := policies[name]
+	if !ok {
+		policy = DefaultPolicy
+	}
+	return &commandTool{name: name, policy: policy}
+}
+
+// Set replaces the tool for a program name (e.g. with a fake in tests) and returns a
+// function that restores the previous one
+func Set(name string, tool Tool) (restore func()) {
+	mu.Lock()
+	previous, hadPrevious := overrides[name]
+	overrides[name] = tool
+	mu.Unlock()
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if hadPrevious {
+			overrides[name] = previous
+		} else {
+			delete(overrides, name)
+		}
+	}
+}
+
+var (
+	lookupMu sync.Mutex
+	lookups  = make(map[string]string) // program → resolved path, "" when not installed
+)
+
+// Remember seeds the lookup cache with programs found earlier, e.g. by a previous run:
+// path "" marks a program as not installed
+func Remember(found map[string]string) {
+	lookupMu.Lock()
+	defer lookupMu.Unlock()
+	for name, path := range found {
+		lookups[name] = path
+	}
+}
+
+// Forget drops the cached lookups, so the next ones search PATH again
+func Forget() {
+	lookupMu.Lock()
+	defer lookupMu.Unlock()
+	clear(lookups)
+}
+
+// Run is shorthand for Get(name).Run(ctx, args...)
+func Run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return Get(name).Run(ctx, args...)
+}
+
+// Available reports whether a program is installed
+func Available(name string) bool {
+	_, err := Get(name).Lookup()
+	return err == nil
+}
+
+// ExitError is returned when a tool ran but exited with a non-zero status
+type ExitError struct {
+	Tool   string
+	Code   int
+	Stderr string
+	Err    *exec.ExitError
+}
+
+func (e *ExitError) Error() string {
+	if e.Stderr != "" {
+		return fmt.Sprintf("%s exited with status %d: %s", e.Tool, e.Code, e.Stderr)
+	}
+	return fmt.Sprintf("%s exited with status %d", e.Tool, e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit status of a failed tool run, or -1 if err isn't an exit error
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return -1
+}
+
+// commandTool runs a real program with os/exec
+type commandTool struct {
+	name   string
+	policy Policy
+}
+
+func (t *commandTool) Name() string {
+	return t.name
+}
+
+// Lookup searches PATH once per program; later lookups, for every port of a scan, reuse
+// the answer
+func (t *commandTool) Lookup() (string, error) {
+	lookupMu.Lock()
+	defer lookupMu.Unlock()
+	if path, ok := lookups[t.name]; ok {
+		if path == "" {
+			return "", &exec.Error{Name: t.name, Err: exec.ErrNotFound}
+		}
+		return path, nil
+	}
+	path, err := exec.LookPath(t.name)
+	lookups[t.name] = path
+	return path, err
+}
+
+func (t *commandTool) Run(ctx context.Context, args ...string) ([]byte, error) {
+	var output []byte
+	var err error
+	for attempt := 0; attempt <= t.policy.Retries; attempt++ {
+		output, err = t.runOnce(ctx, args)
+		if err == nil || ctx.Err() != nil || !isRetryable(err) {
+			break
+		}
+	}
+	return output, err
+}
+
+func (t *commandTool) runOnce(ctx context.Context, args []string) ([]byte, error) {
+	if t.policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.policy.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, t.name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	output, err := cmd.Output()
+	trace(cmd.Args, time.Since(start), err)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return output, &ExitError{
+			Tool:   t.name,
+			Code:   exitErr.ExitCode(),
+			Stderr: strings.TrimSpace(stderr.String()),
+			Err:    exitErr,
+		}
+	}
+	if err != nil {
+		return output, fmt.Errorf("%s: %w", t.name, err)
+	}
+	return output, nil
+}
+
+// isRetryable reports whether a failed run is worth repeating: the program exiting with
+// an error status is an answer, but failing to start or being killed by a signal isn't
+func isRetryable(err error) bool {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return false
+	}
+	return !errors.Is(err, exec.ErrNotFound)
+}
+
+// trace logs the command line, duration and exit status of a finished command
+func trace(args []string, duration time.Duration, err error) {
+	if !log.TraceExec {
+		return
+	}
+
+	status := "exit 0"
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+			status = "exit " + strconv.Itoa(exitErr.ExitCode())
+		} else {
+			// Killed by a signal or timeout, or never started
+			status = err.Error()
+		}
+	}
+	log.Trace("%s [%v, %s]", formatArgs(args), duration.Round(time.Millisecond), status)
+}
+
+// formatArgs joins arguments into a copy-pasteable command line
+func formatArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'$`\\|&;<>()*?[]{}") {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+		} else {
+			quoted[i] = arg
+		}
+	}
+	return strings.Join(quoted, " ")
+}
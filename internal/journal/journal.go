@@ -0,0 +1,202 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoev/zap/internal/paths"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// maxJournalSize is the size at which the journal is rotated to journal.jsonl.1
+const maxJournalSize = 5 * 1024 * 1024
+
+// Actions recorded in the journal
+const (
+	ActionDelete = "delete"
+	ActionKill   = "kill"
+	// ActionTrash is a directory moved to the trash by `zap cleanup --trash`
+	ActionTrash = "trash"
+	// ActionRestore is a trashed directory moved back by `zap restore`
+	ActionRestore = "restore"
+	// ActionUpdate is the zap binary replaced by `zap update`; the detail is
+	// "<old version> -> <new version>"
+	ActionUpdate = "update"
+	// ActionRollback is the zap binary put back by `zap update --rollback`, detailed
+	// like ActionUpdate
+	ActionRollback = "rollback"
+)
+
+// Results recorded in the journal
+const (
+	ResultOK      = "ok"
+	ResultFailed  = "failed"
+	ResultSkipped = "skipped"
+)
+
+// Entry is a single destructive action taken (or attempted) by zap
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+	Result string    `json:"result"`
+	Detail string    `json:"detail,omitempty"`
+	Bytes  int64     `json:"bytes,omitempty"`
+	// InstallSeconds is how long a deleted dependency directory took to install
+	InstallSeconds float64 `json:"install_seconds,omitempty"`
+	// TrashPath is where a trashed directory went
+	TrashPath string `json:"trash_path,omitempty"`
+	// Batch identifies the cleanup run that trashed a directory
+	Batch string `json:"batch,omitempty"`
+}
+
+// String formats the entry as one self-contained line of key=value pairs, e.g.
+// `time=2024-01-01T12:00:00+01:00 action=kill target="PID 4242 (node) :3000" result=ok`.
+// The keys and their order are stable, so the lines can be grepped; values with spaces
+// or quotes are quoted.
+func (e Entry) String() string {
+	fields := []string{
+		"time=" + e.Time.Format(time.RFC3339),
+		"action=" + quoteValue(e.Action),
+		"target=" + quoteValue(e.Target),
+		"result=" + quoteValue(e.Result),
+	}
+	if e.Bytes > 0 {
+		fields = append(fields, "bytes="+strconv.FormatInt(e.Bytes, 10))
+	}
+	if e.Detail != "" {
+		fields = append(fields, "detail="+quoteValue(e.Detail))
+	}
+	return strings.Join(fields, " ")
+}
+
+func quoteValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"=\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// journalMutex serializes appends from concurrent goroutines
+var journalMutex sync.Mutex
+
+func getJournalPath() (string, error) {
+	return paths.File("journal.jsonl")
+}
+
+// Record appends an entry to the journal (one JSON object per line)
+func Record(entry Entry) error {
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	journalPath, err := getJournalPath()
+	if err != nil {
+		return err
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = testmode.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Rotate before the journal grows without bound
+	if info, err := os.Stat(journalPath); err == nil && info.Size() > maxJournalSize {
+		os.Rename(journalPath, journalPath+".1")
+	}
+
+	file, err := os.OpenFile(journalPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// LastInstallDuration returns the install time recorded when target was last deleted
+func LastInstallDuration(entries []Entry, target string) (time.Duration, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Target == target && entry.Action == ActionDelete && entry.InstallSeconds > 0 {
+			return time.Duration(entry.InstallSeconds * float64(time.Second)), true
+		}
+	}
+	return 0, false
+}
+
+// Read returns all entries in the current journal, oldest first.
+// Malformed lines (e.g. from an interrupted write) are skipped.
+func Read() ([]Entry, error) {
+	journalPath, err := getJournalPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(journalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	defer file.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// LastTrashBatch returns the directories moved to the trash by the most recent cleanup
+// run that used --trash and haven't been restored since, in the order they were trashed
+func LastTrashBatch(entries []Entry) []Entry {
+	// pending maps a trash path to the entry that put a directory there, until restored
+	pending := make(map[string]int)
+	for i, entry := range entries {
+		if entry.Result != ResultOK {
+			continue
+		}
+		switch entry.Action {
+		case ActionTrash:
+			pending[entry.TrashPath] = i
+		case ActionRestore:
+			delete(pending, entry.TrashPath)
+		}
+	}
+
+	latest := -1
+	for _, i := range pending {
+		if i > latest {
+			latest = i
+		}
+	}
+	if latest < 0 {
+		return nil
+	}
+	var trashed []Entry
+	for i, entry := range entries {
+		if j, ok := pending[entry.TrashPath]; ok && j == i && entry.Batch == entries[latest].Batch {
+			trashed = append(trashed, entry)
+		}
+	}
+	return trashed
+}
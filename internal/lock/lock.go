@@ -1,31 +1,46 @@
 package lock
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/paths"
 )
 
 // InstanceLock prevents multiple instances of zap from running simultaneously
 type InstanceLock struct {
 	lockFile *os.File
 	path     string
+	unlocked bool // placeholder for read-only runs, see Unlocked
+}
+
+// ErrReadOnly is returned when the lock can't be created because zap's directory is read-only
+var ErrReadOnly = errors.New("zap directory is read-only")
+
+// Unlocked returns a placeholder lock for non-destructive commands run without a
+// writable zap directory; Release and Reacquire are no-ops
+func Unlocked() *InstanceLock {
+	return &InstanceLock{unlocked: true}
 }
 
 // AcquireLock creates a lock file and acquires an exclusive lock
 // Returns an error if another instance is already running
 func AcquireLock() (*InstanceLock, error) {
-	homeDir, err := os.UserHomeDir()
+	lockPath, err := paths.File(".lock")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get home directory: %w", err)
+		return nil, err
+	}
+	if paths.ReadOnly() {
+		return nil, fmt.Errorf("%w: %s", ErrReadOnly, filepath.Dir(lockPath))
 	}
-
-	lockPath := filepath.Join(homeDir, ".config", "zap", ".lock")
 
 	// Check if lock directory is on a network mount (could cause issues)
 	// We'll handle this gracefully by checking if we can create the directory
@@ -133,8 +148,7 @@ func isProcessRunning(pid int) bool {
 	}
 
 	// Use ps to check if process exists
-	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "pid=")
-	output, err := cmd.Output()
+	output, err := execx.Run(context.Background(), "ps", "-p", strconv.Itoa(pid), "-o", "pid=")
 	if err != nil {
 		return false
 	}
@@ -149,7 +163,22 @@ func (l *InstanceLock) Release() error {
 		syscall.Flock(int(l.lockFile.Fd()), syscall.LOCK_UN)
 		l.lockFile.Close()
 		os.Remove(l.path)
+		l.lockFile = nil
+	}
+	return nil
+}
+
+// Reacquire takes the lock again after Release, reusing the same InstanceLock
+// so deferred Release calls keep working
+func (l *InstanceLock) Reacquire() error {
+	if l.unlocked {
+		return nil
+	}
+	newLock, err := AcquireLock()
+	if err != nil {
+		return err
 	}
+	*l = *newLock
 	return nil
 }
 
@@ -1,8 +1,14 @@
 package log
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/mattn/go-colorable"
@@ -12,16 +18,55 @@ import (
 var (
 	// Use colorable output to ensure colors work on all platforms
 	colorableOut = colorable.NewColorable(os.Stdout)
+	colorableErr = colorable.NewColorable(os.Stderr)
+)
+
+// Color modes of --color: auto colors log lines written to a terminal unless NO_COLOR
+// is set (https://no-color.org), always and never override both
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// ColorModes lists the accepted --color values
+var ColorModes = []string{ColorAuto, ColorAlways, ColorNever}
+
+var (
+	colorMode = ColorAuto
+	// toStderr is set once log lines go to stderr, whose terminal decides in auto mode
+	toStderr bool
 )
 
 func init() {
-	// Enable colors if stdout is a TTY (terminal)
-	// The color library disables colors by default when not a TTY
-	if isatty.IsTerminal(os.Stdout.Fd()) {
-		color.NoColor = false
-	} else {
-		// Even if not a TTY, try to enable colors (for CI/CD that supports it)
+	applyColorMode()
+}
+
+// SetColor switches the color mode (--color, --no-color)
+func SetColor(mode string) error {
+	for _, known := range ColorModes {
+		if mode == known {
+			colorMode = mode
+			applyColorMode()
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown color mode %q (must be %s)", mode, strings.Join(ColorModes, ", "))
+}
+
+func applyColorMode() {
+	switch colorMode {
+	case ColorAlways:
 		color.NoColor = false
+	case ColorNever:
+		color.NoColor = true
+	default:
+		fd := os.Stdout.Fd()
+		if toStderr {
+			fd = os.Stderr.Fd()
+		}
+		terminal := isatty.IsTerminal(fd) || isatty.IsCygwinTerminal(fd)
+		color.NoColor = os.Getenv("NO_COLOR") != "" || !terminal
 	}
 }
 
@@ -36,8 +81,12 @@ const (
 	DELETE LogLevel = "DELETE"
 	OK     LogLevel = "OK"
 	FAIL   LogLevel = "FAIL"
+	WARN   LogLevel = "WARN"
 	INFO   LogLevel = "INFO"
 	STATS  LogLevel = "STATS"
+	TRACE  LogLevel = "TRACE"
+	// RECORD lines are the final, uncolored account of a kill or deletion
+	RECORD LogLevel = "RECORD"
 )
 
 var (
@@ -49,49 +98,219 @@ var (
 	deleteColor = color.New(color.FgRed)
 	okColor     = color.New(color.FgGreen)
 	failColor   = color.New(color.FgRed)
+	warnColor   = color.New(color.FgYellow)
 	infoColor   = color.New(color.FgCyan) // Changed from white to cyan for better visibility
 	statsColor  = color.New(color.FgCyan, color.Bold)
+	traceColor  = color.New(color.FgHiBlack)
 )
 
-func Log(level LogLevel, message string, args ...interface{}) {
-	var c *color.Color
+// tagColors are the colors of the tags in text lines; RECORD and unknown tags stay plain
+var tagColors = map[LogLevel]*color.Color{
+	SCAN:   scanColor,
+	FOUND:  foundColor,
+	SKIP:   skipColor,
+	ACTION: actionColor,
+	STOP:   stopColor,
+	DELETE: deleteColor,
+	OK:     okColor,
+	FAIL:   failColor,
+	WARN:   warnColor,
+	INFO:   infoColor,
+	STATS:  statsColor,
+	TRACE:  traceColor,
+}
+
+// Severity is how much a log line matters to a log processor. The tag a line is shown
+// with (FOUND, DELETE...) says what it is about; the severity says whether it is a
+// problem.
+type Severity string
+
+const (
+	SeverityDebug Severity = "debug" // -v and -vv diagnostics, --trace-exec
+	SeverityInfo  Severity = "info"
+	SeverityWarn  Severity = "warn"
+	SeverityError Severity = "error"
+)
+
+// Severity returns the severity of lines logged with the tag
+func (level LogLevel) Severity() Severity {
 	switch level {
-	case SCAN:
-		c = scanColor
-	case FOUND:
-		c = foundColor
-	case SKIP:
-		c = skipColor
-	case ACTION:
-		c = actionColor
-	case STOP:
-		c = stopColor
-	case DELETE:
-		c = deleteColor
-	case OK:
-		c = okColor
 	case FAIL:
-		c = failColor
-	case INFO:
-		c = infoColor
-	case STATS:
-		c = statsColor
+		return SeverityError
+	case WARN:
+		return SeverityWarn
+	case TRACE:
+		return SeverityDebug
 	default:
-		c = color.New()
+		return SeverityInfo
+	}
+}
+
+// Formats of log lines: text is for people, json is one object per line for log processors
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Formats lists the accepted --log-format values
+var Formats = []string{FormatText, FormatJSON}
+
+// Fields are structured details of a log line, e.g. the PID of a killed process. JSON
+// lines carry them; text lines leave them out, as the message already says it.
+type Fields map[string]interface{}
+
+var (
+	format  = FormatText
+	logFile io.Writer // with --log-file, every line is also appended here
+	// writeMutex keeps a line and its copy in the log file together
+	writeMutex sync.Mutex
+)
+
+// SetFormat switches the format of log lines (--log-format)
+func SetFormat(name string) error {
+	for _, known := range Formats {
+		if name == known {
+			format = name
+			return nil
+		}
 	}
+	return fmt.Errorf("unknown log format %q (must be %s)", name, strings.Join(Formats, ", "))
+}
 
-	formatted := fmt.Sprintf(message, args...)
+// SetFile appends every log line to the file at path as well (--log-file), without colors
+func SetFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	logFile = file
+	return nil
+}
+
+// jsonLine is a log line in the json format
+type jsonLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     Severity  `json:"level"`
+	Tag       LogLevel  `json:"tag"`
+	Message   string    `json:"message"`
+	Fields    Fields    `json:"fields,omitempty"`
+}
 
-	// Use Fprint to write directly to colorable output
-	// This ensures colors work properly
-	fmt.Fprint(colorableOut, c.Sprint(string(level)))
-	fmt.Fprintf(colorableOut, " %s\n", formatted)
+// write prints one log line to out, and to the log file if there is one. Each goes out
+// in a single write, so lines from concurrent goroutines (e.g. -vv port timings) don't
+// interleave.
+func write(out io.Writer, level LogLevel, severity Severity, fields Fields, message string) {
+	var line, plain string
+	if format == FormatJSON {
+		encoded, err := json.Marshal(jsonLine{Timestamp: time.Now(), Level: severity, Tag: level, Message: message, Fields: fields})
+		if err != nil {
+			encoded, _ = json.Marshal(jsonLine{Timestamp: time.Now(), Level: severity, Tag: level, Message: message})
+		}
+		line = string(encoded) + "\n"
+		plain = line
+	} else {
+		tag := string(level)
+		if c, ok := tagColors[level]; ok {
+			tag = c.Sprint(tag)
+		}
+		line = fmt.Sprintf("%s %s\n", tag, message)
+		plain = fmt.Sprintf("%s %s\n", level, message)
+	}
+
+	writeMutex.Lock()
+	defer writeMutex.Unlock()
+	fmt.Fprint(out, line)
+	if logFile != nil {
+		fmt.Fprint(logFile, plain)
+	}
+}
+
+func Log(level LogLevel, message string, args ...interface{}) {
+	write(colorableOut, level, level.Severity(), nil, fmt.Sprintf(message, args...))
+}
+
+// LogFields logs a line with structured details for --log-format=json
+func LogFields(level LogLevel, fields Fields, message string, args ...interface{}) {
+	write(colorableOut, level, level.Severity(), fields, fmt.Sprintf(message, args...))
+}
+
+// Record prints a RECORD line without colors, so it reads the same in a terminal's
+// scrollback, a redirected log and grep; fields carry the same account for log processors
+func Record(line string, fields Fields) {
+	write(colorableOut, RECORD, RECORD.Severity(), fields, line)
 }
 
 var Verbose bool = false
 
+// Debug enables the most detailed diagnostics (-vv), such as how long each port lookup took
+var Debug bool = false
+
+// TraceExec enables tracing of every external command zap runs (--trace-exec)
+var TraceExec bool = false
+
+// Trace writes a TRACE line to stderr, keeping stdout clean for --json output
+func Trace(message string, args ...interface{}) {
+	write(colorableErr, TRACE, TRACE.Severity(), nil, fmt.Sprintf(message, args...))
+}
+
 func VerboseLog(message string, args ...interface{}) {
 	if Verbose {
-		Log(INFO, message, args...)
+		write(colorableOut, INFO, SeverityDebug, nil, fmt.Sprintf(message, args...))
+	}
+}
+
+// DebugLog logs an INFO line with -vv
+func DebugLog(message string, args ...interface{}) {
+	if Debug {
+		write(colorableOut, INFO, SeverityDebug, nil, fmt.Sprintf(message, args...))
+	}
+}
+
+// Writer returns where log lines go, for output that belongs with them (e.g. the lists
+// shown before a prompt). Under --log-format=json each line written to it becomes an
+// INFO line.
+func Writer() io.Writer {
+	return writer
+}
+
+var writer = &lineWriter{}
+
+// lineWriter passes output to the log line by line, as callers may print a line in pieces
+type lineWriter struct {
+	mu      sync.Mutex
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.IndexByte(w.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		line := string(w.pending[:end])
+		w.pending = w.pending[end+1:]
+		if format == FormatJSON {
+			if text := strings.TrimSpace(line); text != "" {
+				write(colorableOut, INFO, SeverityInfo, nil, text)
+			}
+			continue
+		}
+		writeMutex.Lock()
+		fmt.Fprintln(colorableOut, line)
+		if logFile != nil {
+			fmt.Fprintln(logFile, line)
+		}
+		writeMutex.Unlock()
 	}
 }
+
+// UseStderr sends all log lines to stderr, leaving stdout to machine-readable output
+// (e.g. launcher formats) that a single stray line would break
+func UseStderr() {
+	colorableOut = colorableErr
+	toStderr = true
+	applyColorMode()
+}
@@ -0,0 +1,113 @@
+// Package paths resolves where zap keeps its files (config, lock, journal, state).
+// Every package goes through here so that running without HOME - under systemd,
+// cron or in a container - behaves the same everywhere.
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// EnvHome overrides the base directory (default ~/.config/zap)
+const EnvHome = "ZAP_HOME"
+
+var (
+	baseOnce     sync.Once
+	baseDir      string
+	baseErr      error
+	baseReadOnly bool
+)
+
+// HomeDir returns the user's home directory with an actionable error when it is unknown
+func HomeDir() (string, error) {
+	if testmode.Enabled() {
+		return testmode.HomeDir(), nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil || homeDir == "" {
+		return "", fmt.Errorf("home directory is unknown (HOME is not set); set HOME, or %s for zap's own files", EnvHome)
+	}
+	return homeDir, nil
+}
+
+// BaseDir returns the directory zap stores its files in, creating it if needed:
+// $ZAP_HOME if set, else ~/.config/zap, else a per-user directory under the system temp dir
+func BaseDir() (string, error) {
+	baseOnce.Do(func() {
+		baseDir, baseErr = resolveBaseDir()
+	})
+	return baseDir, baseErr
+}
+
+// ReadOnly reports whether the base directory exists but can't be written to
+// (read-only home, nix-managed or locked-down containers)
+func ReadOnly() bool {
+	BaseDir()
+	return baseReadOnly
+}
+
+// File returns the path of a file in the base directory
+func File(name string) (string, error) {
+	dir, err := BaseDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func resolveBaseDir() (string, error) {
+	if testmode.Enabled() {
+		dir := testmode.BaseDir()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create test mode directory %s: %w", dir, err)
+		}
+		return dir, nil
+	}
+
+	// An explicit override is used as-is: silently falling back would hide a typo
+	if dir := os.Getenv(EnvHome); dir != "" {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s %q: %w", EnvHome, dir, err)
+		}
+		if err := os.MkdirAll(absDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create %s directory %s: %w", EnvHome, absDir, err)
+		}
+		baseReadOnly = !isWritable(absDir)
+		return absDir, nil
+	}
+
+	if homeDir, err := HomeDir(); err == nil {
+		dir := filepath.Join(homeDir, ".config", "zap")
+		if err := os.MkdirAll(dir, 0755); err == nil {
+			// An existing but read-only directory still holds the user's config, so
+			// prefer it over a writable temp directory
+			baseReadOnly = !isWritable(dir)
+			return dir, nil
+		}
+	}
+
+	// No usable home: keep files in a per-user temp directory so concurrent users
+	// (and their locks) don't collide
+	dir := filepath.Join(os.TempDir(), "zap-"+strconv.Itoa(os.Getuid()))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create zap directory (no usable home, set %s): %w", EnvHome, err)
+	}
+	return dir, nil
+}
+
+// isWritable checks whether files can be created in dir
+func isWritable(dir string) bool {
+	file, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return false
+	}
+	file.Close()
+	os.Remove(file.Name())
+	return true
+}
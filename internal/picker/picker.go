@@ -0,0 +1,191 @@
+// Package picker is a minimal full-screen multi-select list for the terminal: move with
+// the arrow keys, toggle with space, confirm with enter
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/sys/unix"
+)
+
+// Item is one selectable line, with details shown in a pane below the list
+type Item struct {
+	Label    string
+	Details  []string
+	Selected bool
+}
+
+// ErrCancelled is returned when the picker is left with q, Esc or Ctrl-C
+var ErrCancelled = errors.New("selection cancelled")
+
+// ErrNoTerminal is returned when stdin or stdout isn't a terminal
+var ErrNoTerminal = errors.New("interactive mode needs a terminal")
+
+const help = "↑/↓ move  space toggle  a all/none  enter confirm  q cancel"
+
+// Available reports whether the picker can run: stdin and stdout are both terminals
+func Available() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// Run shows items under title and returns the indexes of the selected items, in order
+func Run(title string, items []Item) ([]int, error) {
+	if !Available() {
+		return nil, ErrNoTerminal
+	}
+	restore, err := rawMode(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to switch the terminal to raw mode: %w", err)
+	}
+	// Alternate screen and hidden cursor, undone however we leave
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		restore()
+	}()
+
+	selected := make([]bool, len(items))
+	for i, item := range items {
+		selected[i] = item.Selected
+	}
+	cursor, offset := 0, 0
+	buf := make([]byte, 16)
+
+	for {
+		offset = render(title, items, selected, cursor, offset)
+
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		switch key := string(buf[:n]); key {
+		case "\x1b[A", "\x1bOA", "k":
+			if cursor > 0 {
+				cursor--
+			}
+		case "\x1b[B", "\x1bOB", "j":
+			if cursor < len(items)-1 {
+				cursor++
+			}
+		case " ":
+			if len(items) > 0 {
+				selected[cursor] = !selected[cursor]
+			}
+		case "a":
+			all := true
+			for _, s := range selected {
+				all = all && s
+			}
+			for i := range selected {
+				selected[i] = !all
+			}
+		case "\r", "\n":
+			var chosen []int
+			for i, s := range selected {
+				if s {
+					chosen = append(chosen, i)
+				}
+			}
+			return chosen, nil
+		case "q", "\x1b", "\x03":
+			return nil, ErrCancelled
+		}
+	}
+}
+
+// render draws the screen and returns the scroll offset that keeps the cursor visible
+func render(title string, items []Item, selected []bool, cursor, offset int) int {
+	width, height := terminalSize()
+	details := 0
+	if len(items) > 0 {
+		details = len(items[cursor].Details)
+	}
+	// title, blank, list, blank, details, blank, help
+	visible := height - details - 5
+	if visible < 3 {
+		visible = 3
+	}
+	if cursor < offset {
+		offset = cursor
+	}
+	if cursor >= offset+visible {
+		offset = cursor - visible + 1
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+	chosen := 0
+	for _, s := range selected {
+		if s {
+			chosen++
+		}
+	}
+	line(&b, fmt.Sprintf("%s (%d of %d selected)", title, chosen, len(items)), width)
+	b.WriteString("\r\n")
+	for i := offset; i < len(items) && i < offset+visible; i++ {
+		pointer, mark := "  ", "[ ]"
+		if i == cursor {
+			pointer = "> "
+		}
+		if selected[i] {
+			mark = "[x]"
+		}
+		text := pointer + mark + " " + items[i].Label
+		if i == cursor {
+			b.WriteString("\x1b[7m")
+			line(&b, text, width)
+			b.WriteString("\x1b[0m")
+		} else {
+			line(&b, text, width)
+		}
+	}
+	if len(items) > 0 {
+		b.WriteString("\r\n")
+		for _, detail := range items[cursor].Details {
+			line(&b, "    "+detail, width)
+		}
+	}
+	b.WriteString("\r\n")
+	line(&b, help, width)
+	fmt.Print(b.String())
+	return offset
+}
+
+// line writes text cut to the terminal width, so long lines don't wrap and scroll
+func line(b *strings.Builder, text string, width int) {
+	if runes := []rune(text); width > 1 && len(runes) > width-1 {
+		text = string(runes[:width-2]) + "…"
+	}
+	b.WriteString(text)
+	b.WriteString("\r\n")
+}
+
+func terminalSize() (int, int) {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdout.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+// rawMode turns off line buffering, echo and signal keys on fd, so every key press is
+// read as it happens; restore puts the terminal back
+func rawMode(fd int) (restore func(), err error) {
+	original, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+	raw := *original
+	raw.Lflag &^= unix.ICANON | unix.ECHO | unix.ISIG | unix.IEXTEN
+	raw.Iflag &^= unix.ICRNL | unix.IXON
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return func() { unix.IoctlSetTermios(fd, ioctlSetTermios, original) }, nil
+}
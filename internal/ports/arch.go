@@ -0,0 +1,99 @@
+package ports
+
+import (
+	"context"
+	"debug/elf"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+)
+
+// pTranslated is P_TRANSLATED in the process flags macOS's ps reports: the process is
+// x86_64 code translated by Rosetta 2
+const pTranslated = 0x00020000
+
+// elfArchs names the ELF machine types zap reports, in uname -m spelling
+var elfArchs = map[elf.Machine]string{
+	elf.EM_X86_64:  "x86_64",
+	elf.EM_386:     "i386",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_ARM:     "arm",
+	elf.EM_RISCV:   "riscv64",
+	elf.EM_PPC64:   "ppc64",
+	elf.EM_S390:    "s390x",
+}
+
+// nativeArchs are the architectures the machine runs without emulation: its own, and
+// the 32-bit one of its family
+var nativeArchs = map[string][]string{
+	"amd64":   {"x86_64", "i386"},
+	"386":     {"i386"},
+	"arm64":   {"arm64", "arm"},
+	"arm":     {"arm"},
+	"riscv64": {"riscv64"},
+	"ppc64le": {"ppc64"},
+	"s390x":   {"s390x"},
+}
+
+// ArchLabel describes an emulated process's architecture for listings, e.g.
+// "x86_64 under Rosetta"; "" for processes running natively
+func (p ProcessInfo) ArchLabel() string {
+	if !p.Emulated {
+		return ""
+	}
+	if runtime.GOOS == "darwin" {
+		return p.Arch + " under Rosetta"
+	}
+	return p.Arch + ", emulated"
+}
+
+// processArch returns the architecture a process runs as and whether it is emulated: an
+// x86_64 process translated by Rosetta 2 on Apple Silicon, or a foreign binary run by
+// qemu-user through binfmt_misc on Linux. The architecture is "" if unknown.
+func processArch(pid int) (string, bool) {
+	switch runtime.GOOS {
+	case "darwin":
+		if runtime.GOARCH != "arm64" {
+			return "x86_64", false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		output, err := execx.Run(ctx, "ps", "-p", strconv.Itoa(pid), "-o", "flags=")
+		if err != nil {
+			return "", false
+		}
+		flags, err := strconv.ParseUint(strings.TrimSpace(string(output)), 16, 64)
+		if err != nil {
+			return "", false
+		}
+		if flags&pTranslated != 0 {
+			return "x86_64", true
+		}
+		return "arm64", false
+	case "linux":
+		file, err := elf.Open(filepath.Join("/proc", strconv.Itoa(pid), "exe"))
+		if err != nil {
+			return "", false
+		}
+		defer file.Close()
+		arch, ok := elfArchs[file.Machine]
+		if !ok {
+			arch = strings.ToLower(strings.TrimPrefix(file.Machine.String(), "EM_"))
+		}
+		native, known := nativeArchs[runtime.GOARCH]
+		if !known {
+			return arch, false
+		}
+		for _, candidate := range native {
+			if arch == candidate {
+				return arch, false
+			}
+		}
+		return arch, true
+	}
+	return "", false
+}
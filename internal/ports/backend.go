@@ -0,0 +1,69 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hugoev/zap/internal/execx"
+)
+
+// Backends find the processes listening on ports; port_backend or --backend pins one
+// when auto-detection picks a tool that is slow or broken on a system
+const (
+	BackendAuto    = "auto"    // /proc where available, else lsof, ss and netstat in turn
+	BackendLsof    = "lsof"    // lsof only
+	BackendSS      = "ss"      // ss only (Linux)
+	BackendNetstat = "netstat" // netstat only
+	BackendNative  = "native"  // /proc/net only (Linux), one pass for all ports
+)
+
+// Backends lists the accepted port_backend values
+var Backends = []string{BackendAuto, BackendLsof, BackendSS, BackendNetstat, BackendNative}
+
+// ParseBackend checks a port_backend or --backend value
+func ParseBackend(value string) (string, error) {
+	backend := strings.ToLower(strings.TrimSpace(value))
+	for _, known := range Backends {
+		if backend == known {
+			return backend, nil
+		}
+	}
+	return "", fmt.Errorf("unknown backend %q (must be %s)", value, strings.Join(Backends, ", "))
+}
+
+type backendKey struct{}
+
+// WithBackend returns a context under which port scans use backend
+func WithBackend(ctx context.Context, backend string) context.Context {
+	return context.WithValue(ctx, backendKey{}, backend)
+}
+
+// BackendFrom returns the backend port scans under ctx use, BackendAuto if none was set
+func BackendFrom(ctx context.Context) string {
+	if backend, ok := ctx.Value(backendKey{}).(string); ok && backend != "" {
+		return backend
+	}
+	return BackendAuto
+}
+
+// CheckBackend reports why backend can't scan ports on this system, if it can't
+func CheckBackend(backend string) error {
+	switch backend {
+	case BackendAuto:
+		if NativeScanAvailable() || execx.Available(BackendLsof) || execx.Available(BackendSS) || execx.Available(BackendNetstat) {
+			return nil
+		}
+		return fmt.Errorf("no port scanning tools found (lsof, ss, or netstat). Please install one of them")
+	case BackendNative:
+		if !NativeScanAvailable() {
+			return fmt.Errorf("the native backend reads /proc/net, which this system doesn't have (use lsof, ss or netstat)")
+		}
+		return nil
+	default:
+		if !execx.Available(backend) {
+			return fmt.Errorf("%s not found; install it or pick another backend (%s)", backend, strings.Join(Backends, ", "))
+		}
+		return nil
+	}
+}
@@ -1,12 +1,14 @@
 package ports
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
+
+	"github.com/hugoev/zap/internal/execx"
 )
 
 // IsProcessInContainer checks if a process is running in a container (Docker, LXC, etc.)
@@ -57,8 +59,7 @@ func IsProcessInContainer(pid int) (bool, error) {
 	if runtime.GOOS == "darwin" {
 		// Check if process is in Docker Desktop VM
 		// This is a heuristic - Docker Desktop runs in a VM
-		cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "command=")
-		output, err := cmd.Output()
+		output, err := execx.Run(context.Background(), "ps", "-p", strconv.Itoa(pid), "-o", "command=")
 		if err == nil {
 			cmdStr := strings.ToLower(string(output))
 			if strings.Contains(cmdStr, "docker") || strings.Contains(cmdStr, "com.docker") {
@@ -0,0 +1,92 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// errNoCPUTime is returned where a process's CPU time can't be read
+var errNoCPUTime = errors.New("CPU time not available")
+
+// CPUTime returns how much CPU time, user and system, a process has used since it
+// started: utime and stime of /proc/[pid]/stat on Linux, ps's time column elsewhere
+func CPUTime(ctx context.Context, pid int) (time.Duration, error) {
+	if testmode.Enabled() {
+		return 0, errNoCPUTime // listeners.json has no CPU usage
+	}
+	if runtime.GOOS == "linux" {
+		return procCPUTime(pid)
+	}
+	output, err := execx.Run(ctx, "ps", "-p", strconv.Itoa(pid), "-o", "time=")
+	if err != nil {
+		return 0, err
+	}
+	return parsePSTime(strings.TrimSpace(string(output)))
+}
+
+// procCPUTime reads fields 14 (utime) and 15 (stime) of /proc/[pid]/stat, in clock ticks
+func procCPUTime(pid int) (time.Duration, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// The command name (field 2) is parenthesised and may contain spaces
+	end := strings.LastIndex(string(data), ")")
+	if end == -1 {
+		return 0, fmt.Errorf("malformed stat")
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	// fields[0] is field 3 (state), so fields 14 and 15 are fields[11] and fields[12]
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("malformed stat")
+	}
+	var ticks int64
+	for _, field := range fields[11:13] {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		ticks += value
+	}
+	return time.Duration(ticks) * time.Second / clockTicks, nil
+}
+
+// parsePSTime parses ps's time column, [[dd-]hh:]mm:ss with optional fractions of a
+// second: "0:01.52" on macOS, "00:00:01" or "1-02:03:04" with procps
+func parsePSTime(value string) (time.Duration, error) {
+	var total time.Duration
+	if days, rest, ok := strings.Cut(value, "-"); ok {
+		count, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU time %q", value)
+		}
+		total, value = time.Duration(count)*24*time.Hour, rest
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid CPU time %q", value)
+	}
+	seconds, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid CPU time %q", value)
+	}
+	total += time.Duration(seconds * float64(time.Second))
+	units := []time.Duration{time.Minute, time.Hour}
+	for i, part := range parts[:len(parts)-1] {
+		count, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, fmt.Errorf("invalid CPU time %q", value)
+		}
+		total += time.Duration(count) * units[len(parts)-2-i]
+	}
+	return total, nil
+}
@@ -0,0 +1,131 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// Container is a running Docker container that publishes a port on the host
+type Container struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// dockerForwarders are the processes Docker uses to listen on published ports: killing
+// one drops the forwarding but leaves the container running, often with the port stuck
+var dockerForwarders = []string{"docker-proxy", "com.docker.backend", "com.docker.vpnkit", "vpnkit", "rootlesskit"}
+
+// IsDockerForwarder reports whether proc is Docker's port forwarder rather than a server
+func IsDockerForwarder(proc ProcessInfo) bool {
+	name := strings.ToLower(proc.Name)
+	for _, forwarder := range dockerForwarders {
+		// ps truncates long names on macOS
+		if name == forwarder || (len(name) >= 15 && strings.HasPrefix(forwarder, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// DockerAvailable reports whether the docker CLI is installed
+func DockerAvailable() bool {
+	return execx.Available("docker")
+}
+
+// AttachContainers sets Container on the processes that forward a port published by a
+// running container, so they can be freed with StopContainer
+func AttachContainers(ctx context.Context, processes []ProcessInfo) error {
+	published, err := publishedPorts(ctx)
+	if err != nil {
+		return err
+	}
+	for i, proc := range processes {
+		if !IsDockerForwarder(proc) {
+			continue
+		}
+		if container, ok := published[portKey(proc.Port, proc.Protocol)]; ok {
+			c := container
+			processes[i].Container = &c
+		}
+	}
+	return nil
+}
+
+// StopContainer stops a container with docker stop, which gives it the same grace period
+// as `docker stop` on the command line before killing it
+func StopContainer(ctx context.Context, container Container) error {
+	if testmode.Enabled() {
+		return testmode.Record(testmode.ActionStop, container.ID)
+	}
+	if _, err := execx.Run(ctx, "docker", "stop", container.ID); err != nil {
+		return fmt.Errorf("docker stop %s: %w", container.Name, err)
+	}
+	return nil
+}
+
+// publishedPorts maps "port/protocol" to the running container publishing it
+func publishedPorts(ctx context.Context) (map[string]Container, error) {
+	output, err := execx.Run(ctx, "docker", "ps", "--no-trunc", "--format", "{{.ID}}\t{{.Names}}\t{{.Image}}\t{{.Ports}}")
+	if err != nil {
+		return nil, fmt.Errorf("docker ps: %w", err)
+	}
+	published := make(map[string]Container)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		container := Container{ID: fields[0], Name: fields[1], Image: fields[2]}
+		if len(container.ID) > 12 {
+			container.ID = container.ID[:12]
+		}
+		for _, key := range parsePublished(fields[3]) {
+			published[key] = container
+		}
+	}
+	return published, nil
+}
+
+// parsePublished returns the "port/protocol" keys of the host ports in a docker ps Ports
+// column, e.g. "0.0.0.0:5432->5432/tcp, :::5432->5432/tcp, 0.0.0.0:8000-8001->80-81/tcp".
+// Exposed but unpublished ports ("6379/tcp") have no host side and are left out.
+func parsePublished(column string) []string {
+	var keys []string
+	for _, mapping := range strings.Split(column, ",") {
+		host, target, ok := strings.Cut(strings.TrimSpace(mapping), "->")
+		if !ok {
+			continue
+		}
+		protocol := ProtocolTCP
+		if _, proto, ok := strings.Cut(target, "/"); ok {
+			protocol = proto
+		}
+		hostPorts := host[strings.LastIndex(host, ":")+1:]
+		first, last, isRange := strings.Cut(hostPorts, "-")
+		if !isRange {
+			last = first
+		}
+		from, err1 := strconv.Atoi(first)
+		to, err2 := strconv.Atoi(last)
+		if err1 != nil || err2 != nil || to < from || to-from > 65535 {
+			continue
+		}
+		for port := from; port <= to; port++ {
+			keys = append(keys, portKey(port, protocol))
+		}
+	}
+	return keys
+}
+
+func portKey(port int, protocol string) string {
+	if protocol == "" {
+		protocol = ProtocolTCP
+	}
+	return fmt.Sprintf("%d/%s", port, protocol)
+}
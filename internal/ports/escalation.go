@@ -0,0 +1,104 @@
+package ports
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// EscalationStep sends Signal, After the previous step (0 for the first step)
+type EscalationStep struct {
+	Signal syscall.Signal
+	After  time.Duration
+}
+
+// Escalation is the sequence of signals used to terminate a process; the next step is
+// only taken if the process is still running
+type Escalation []EscalationStep
+
+// escalationSignals are the signals accepted in an escalation policy
+var escalationSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// DefaultEscalation is SIGTERM, then SIGKILL after GracefulTerminationTimeout
+func DefaultEscalation() Escalation {
+	return Escalation{
+		{Signal: syscall.SIGTERM},
+		{Signal: syscall.SIGKILL, After: GracefulTerminationTimeout},
+	}
+}
+
+// ParseEscalation parses steps like ["INT", "TERM@5s", "KILL@10s"]: a signal name
+// (with or without the SIG prefix), optionally followed by @ and the time to wait
+// after the previous step. An empty list yields DefaultEscalation.
+func ParseEscalation(steps []string) (Escalation, error) {
+	if len(steps) == 0 {
+		return DefaultEscalation(), nil
+	}
+
+	var escalation Escalation
+	for i, step := range steps {
+		name, delay, hasDelay := strings.Cut(strings.TrimSpace(step), "@")
+		signal, ok := escalationSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+		if !ok {
+			return nil, fmt.Errorf("unknown signal %q in step %q (use HUP, INT, QUIT, TERM, KILL, USR1 or USR2)", name, step)
+		}
+		var after time.Duration
+		if hasDelay {
+			var err error
+			after, err = time.ParseDuration(delay)
+			if err != nil || after < 0 {
+				return nil, fmt.Errorf("invalid delay in step %q (use e.g. TERM@5s)", step)
+			}
+		} else if i > 0 {
+			after = GracefulTerminationTimeout
+		}
+		escalation = append(escalation, EscalationStep{Signal: signal, After: after})
+	}
+	return escalation, nil
+}
+
+// SignalEscalation sends signal (a name such as "INT") and, unless that is KILL, SIGKILL
+// after timeout if the process is still running
+func SignalEscalation(signal string, timeout time.Duration) (Escalation, error) {
+	first, ok := escalationSignals[strings.TrimPrefix(strings.ToUpper(signal), "SIG")]
+	if !ok {
+		return nil, fmt.Errorf("unknown signal %q (use TERM, INT, HUP, QUIT, KILL, USR1 or USR2)", signal)
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("timeout must be positive, got %v", timeout)
+	}
+	if first == syscall.SIGKILL {
+		return Escalation{{Signal: first}}, nil
+	}
+	return Escalation{{Signal: first}, {Signal: syscall.SIGKILL, After: timeout}}, nil
+}
+
+// String formats the policy like ParseEscalation's input, e.g. "INT, TERM@5s, KILL@10s"
+func (e Escalation) String() string {
+	steps := make([]string, len(e))
+	for i, step := range e {
+		steps[i] = signalName(step.Signal)
+		if i > 0 {
+			steps[i] += "@" + step.After.String()
+		}
+	}
+	return strings.Join(steps, ", ")
+}
+
+func signalName(signal syscall.Signal) string {
+	for name, s := range escalationSignals {
+		if s == signal {
+			return name
+		}
+	}
+	return fmt.Sprintf("%d", int(signal))
+}
@@ -0,0 +1,57 @@
+package ports
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+	"golang.org/x/sys/unix"
+)
+
+// processExecutable returns the path and inode of the program a process runs, read from
+// /proc/PID/exe, or where there is no /proc (macOS) from the txt file lsof reports. Both
+// are empty when neither may be read (another user's process). On Linux a binary
+// replaced since the process started reads as "<path> (deleted)".
+func processExecutable(pid int) (string, uint64) {
+	exe := filepath.Join("/proc", strconv.Itoa(pid), "exe")
+	path, err := os.Readlink(exe)
+	if err != nil {
+		if NativeScanAvailable() {
+			return "", 0
+		}
+		path = lsofExecutable(pid)
+		exe = path
+	}
+	if path == "" {
+		return "", 0
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(exe, &st); err != nil {
+		return path, 0
+	}
+	return path, st.Ino
+}
+
+// lsofExecutable returns the program file of a process as lsof lists it: the first file
+// of its txt descriptors, which are the executable followed by the libraries it maps
+func lsofExecutable(pid int) string {
+	if !execx.Available("lsof") {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	output, err := execx.Run(ctx, "lsof", "-p", strconv.Itoa(pid), "-a", "-d", "txt", "-Fn")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		if name, ok := strings.CutPrefix(line, "n"); ok && strings.HasPrefix(name, "/") {
+			return name
+		}
+	}
+	return ""
+}
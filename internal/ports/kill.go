@@ -1,9 +1,9 @@
 package ports
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
@@ -11,6 +11,9 @@ import (
 	"time"
 
 	"golang.org/x/sys/unix"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/testmode"
 )
 
 const (
@@ -21,18 +24,33 @@ const (
 )
 
 // KillProcessWithVerification kills a process after verifying it matches expected details
-// This prevents PID reuse race conditions
-func KillProcessWithVerification(pid int, expected ProcessInfo) error {
+// This prevents PID reuse race conditions. A nil policy means DefaultEscalation.
+func KillProcessWithVerification(pid int, expected ProcessInfo, policy Escalation) error {
+	if testmode.Enabled() {
+		return fixtureKill(pid)
+	}
 	// Verify process still matches expected details (prevents PID reuse)
 	matches, err := VerifyProcessMatches(pid, expected)
 	if err != nil || !matches {
 		return fmt.Errorf("process verification failed (PID may have been reused): %w", err)
 	}
 
-	return KillProcess(pid)
+	if policy == nil {
+		policy = DefaultEscalation()
+	}
+	return KillProcessWithPolicy(pid, policy)
 }
 
 func KillProcess(pid int) error {
+	return KillProcessWithPolicy(pid, DefaultEscalation())
+}
+
+// KillProcessWithPolicy terminates a process (and its process group) by walking through
+// the signals of policy until it exits
+func KillProcessWithPolicy(pid int, policy Escalation) error {
+	if testmode.Enabled() {
+		return fixtureKill(pid)
+	}
 	// First verify the process exists and is running
 	if !IsProcessRunning(pid) {
 		return fmt.Errorf("process %d is not running", pid)
@@ -50,7 +68,7 @@ func KillProcess(pid int) error {
 	}
 
 	// Try to kill process group first (handles child processes)
-	if err := KillProcessGroup(pid); err == nil {
+	if err := killProcessGroup(pid, policy); err == nil {
 		// Verify process didn't respawn (check for process managers)
 		time.Sleep(500 * time.Millisecond)
 		if IsProcessRunning(pid) {
@@ -67,35 +85,34 @@ func KillProcess(pid int) error {
 		return fmt.Errorf("process %d not found: %w", pid, err)
 	}
 
-	// Try graceful termination first (SIGTERM)
-	err = process.Signal(syscall.SIGTERM)
-	if err != nil {
-		// Process might already be gone, verify
-		if !IsProcessRunning(pid) {
-			return nil // Process already terminated
+	for i, step := range policy {
+		if i > 0 && waitForExit(func() bool { return IsProcessRunning(pid) }, step.After) {
+			return nil // Terminated by the previous signal
 		}
-		return fmt.Errorf("failed to send SIGTERM to process %d: %w", pid, err)
-	}
-
-	// Wait for graceful termination with timeout
-	deadline := time.Now().Add(GracefulTerminationTimeout)
-	for time.Now().Before(deadline) {
-		if !IsProcessRunning(pid) {
-			return nil // Process terminated gracefully
+		if err := process.Signal(step.Signal); err != nil {
+			// Process might already be gone, verify
+			if !IsProcessRunning(pid) {
+				return nil // Process already terminated
+			}
+			return fmt.Errorf("failed to send SIG%s to process %d: %w", signalName(step.Signal), pid, err)
 		}
-		time.Sleep(ProcessCheckInterval)
 	}
 
-	// If still running after graceful timeout, force kill
-	if IsProcessRunning(pid) {
-		return KillProcessForce(pid)
+	if waitForExit(func() bool { return IsProcessRunning(pid) }, finalWait(policy, 0)) {
+		return nil
 	}
-
-	return nil
+	return fmt.Errorf("process %d still running after %s", pid, policy)
 }
 
 // KillProcessGroup kills the entire process group, including child processes
 func KillProcessGroup(pid int) error {
+	return killProcessGroup(pid, DefaultEscalation())
+}
+
+func killProcessGroup(pid int, policy Escalation) error {
+	if testmode.Enabled() {
+		return fixtureKill(pid)
+	}
 	if pid <= 0 {
 		return fmt.Errorf("invalid PID: %d", pid)
 	}
@@ -123,72 +140,71 @@ func KillProcessGroup(pid int) error {
 		processCount = 1
 	}
 
-	// Adaptive timeout: base timeout + additional time per process
-	// For large process groups (1000+), allow more time
-	// Formula: base 3s + 10ms per process, capped at 30s for very large groups
+	// Adaptive timeout: large process groups (1000+) get additional time per process
+	// on every step. Formula: 10ms per process, capped at 27s.
 	// Use int64 to prevent overflow for extremely large process counts
-	var adaptiveTimeout time.Duration
+	var additionalTime time.Duration
 	if processCount > 0 {
 		// Calculate with overflow protection
 		timePerProcess := time.Duration(10) * time.Millisecond
-		additionalTime := time.Duration(processCount) * timePerProcess
-		// Cap additional time to prevent overflow (max 27s additional = 30s total)
+		additionalTime = time.Duration(processCount) * timePerProcess
+		// Cap additional time to prevent overflow (max 27s additional)
 		maxAdditionalTime := 27 * time.Second
 		if additionalTime > maxAdditionalTime {
 			additionalTime = maxAdditionalTime
 		}
-		adaptiveTimeout = GracefulTerminationTimeout + additionalTime
-	} else {
-		adaptiveTimeout = GracefulTerminationTimeout
-	}
-	maxTimeout := 30 * time.Second
-	if adaptiveTimeout > maxTimeout {
-		adaptiveTimeout = maxTimeout
 	}
 
-	// Minimum timeout of 3 seconds
-	if adaptiveTimeout < GracefulTerminationTimeout {
-		adaptiveTimeout = GracefulTerminationTimeout
+	groupRunning := func() bool { return isProcessGroupRunning(pgid) }
+	for i, step := range policy {
+		if i > 0 && waitForExit(groupRunning, step.After+additionalTime) {
+			return nil // Process group terminated by the previous signal
+		}
+		// Negative PID means process group
+		err = unix.Kill(-pgid, step.Signal)
+		if err != nil {
+			if err == unix.ESRCH {
+				if i == 0 {
+					// If process group doesn't exist, try single process
+					return fmt.Errorf("process group not found")
+				}
+				return nil
+			}
+			return fmt.Errorf("failed to signal process group: %w", err)
+		}
 	}
 
-	// Send SIGTERM to entire process group (negative PID means process group)
-	err = unix.Kill(-pgid, syscall.SIGTERM)
-	if err != nil {
-		// If process group doesn't exist, try single process
-		if err == unix.ESRCH {
-			return fmt.Errorf("process group not found")
-		}
-		return fmt.Errorf("failed to signal process group: %w", err)
+	if waitForExit(groupRunning, finalWait(policy, additionalTime)) {
+		return nil
 	}
+	return fmt.Errorf("process group %d did not terminate after %s", pgid, policy)
+}
 
-	// Wait for graceful termination with adaptive timeout
-	deadline := time.Now().Add(adaptiveTimeout)
+// waitForExit polls running until it reports false or timeout passes; it returns
+// whether the process exited
+func waitForExit(running func() bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
-		if !isProcessGroupRunning(pgid) {
-			return nil // Process group terminated gracefully
+		if !running() {
+			return true
 		}
 		time.Sleep(ProcessCheckInterval)
 	}
+	return !running()
+}
 
-	// Force kill entire group if still running
-	if isProcessGroupRunning(pgid) {
-		err = unix.Kill(-pgid, syscall.SIGKILL)
-		if err != nil && err != unix.ESRCH {
-			return fmt.Errorf("failed to force kill process group: %w", err)
-		}
-		time.Sleep(200 * time.Millisecond)
-		if isProcessGroupRunning(pgid) {
-			return fmt.Errorf("process group %d did not terminate after SIGKILL", pgid)
-		}
+// finalWait is how long to wait after the last signal of a policy: SIGKILL can't be
+// handled, so it takes effect almost immediately
+func finalWait(policy Escalation, additionalTime time.Duration) time.Duration {
+	if len(policy) > 0 && policy[len(policy)-1].Signal == syscall.SIGKILL {
+		return 200 * time.Millisecond
 	}
-
-	return nil
+	return GracefulTerminationTimeout + additionalTime
 }
 
 func isProcessGroupRunning(pgid int) bool {
 	// Check if any process in the group is still running
-	cmd := exec.Command("ps", "-o", "pid=", "-g", strconv.Itoa(pgid))
-	output, err := cmd.Output()
+	output, err := execx.Run(context.Background(), "ps", "-o", "pid=", "-g", strconv.Itoa(pgid))
 	if err != nil {
 		return false
 	}
@@ -197,8 +213,7 @@ func isProcessGroupRunning(pgid int) bool {
 
 // countProcessGroupSize counts the number of processes in a process group
 func countProcessGroupSize(pgid int) (int, error) {
-	cmd := exec.Command("ps", "-o", "pid=", "-g", strconv.Itoa(pgid))
-	output, err := cmd.Output()
+	output, err := execx.Run(context.Background(), "ps", "-o", "pid=", "-g", strconv.Itoa(pgid))
 	if err != nil {
 		return 0, err
 	}
@@ -215,6 +230,9 @@ func countProcessGroupSize(pgid int) (int, error) {
 }
 
 func KillProcessForce(pid int) error {
+	if testmode.Enabled() {
+		return fixtureKill(pid)
+	}
 	// Verify process is still running before attempting kill
 	if !IsProcessRunning(pid) {
 		return nil // Already terminated
@@ -263,10 +281,12 @@ func IsProcessRunning(pid int) bool {
 	if pid <= 0 {
 		return false
 	}
+	if testmode.Enabled() {
+		return fixtureRunning(pid)
+	}
 
 	// Use ps to check if process exists
-	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "pid=")
-	output, err := cmd.Output()
+	output, err := execx.Run(context.Background(), "ps", "-p", strconv.Itoa(pid), "-o", "pid=")
 	if err != nil {
 		return false
 	}
@@ -291,15 +311,13 @@ func detectProcessManager(pid int) string {
 		}
 
 		// Check systemd status
-		cmd := exec.Command("systemctl", "status", strconv.Itoa(pid))
-		if err := cmd.Run(); err == nil {
+		if _, err := execx.Run(context.Background(), "systemctl", "status", strconv.Itoa(pid)); err == nil {
 			return "systemd"
 		}
 	}
 
 	// Check supervisor
-	cmd := exec.Command("supervisorctl", "status", strconv.Itoa(pid))
-	if err := cmd.Run(); err == nil {
+	if _, err := execx.Run(context.Background(), "supervisorctl", "status", strconv.Itoa(pid)); err == nil {
 		return "supervisor"
 	}
 
@@ -311,8 +329,7 @@ func getServiceStopCommand(pid int, manager string) string {
 	switch manager {
 	case "systemd":
 		// Try to get service name from systemd
-		cmd := exec.Command("systemctl", "status", strconv.Itoa(pid))
-		output, err := cmd.Output()
+		output, err := execx.Run(context.Background(), "systemctl", "status", strconv.Itoa(pid))
 		if err == nil {
 			// Parse service name from output (simplified)
 			lines := strings.Split(string(output), "\n")
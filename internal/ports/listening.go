@@ -0,0 +1,172 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// ListeningPorts returns every port something listens on over protocols, sorted, so a
+// scan can cover all of them instead of a fixed list. On Linux the ports are read from
+// /proc/net in one pass; elsewhere from a single lsof, ss or netstat call per protocol.
+// A backend set with WithBackend is used alone, as for scans.
+func ListeningPorts(ctx context.Context, protocols []string) ([]int, error) {
+	if testmode.Enabled() {
+		return fixturePorts(protocols)
+	}
+	backend := BackendFrom(ctx)
+	if err := CheckBackend(backend); err != nil {
+		return nil, err
+	}
+
+	found := make(map[int]bool)
+	if backend == BackendNative || backend == BackendAuto && NativeScanAvailable() {
+		sockets := make(map[string]listenSocket)
+		var err error
+		for _, protocol := range protocols {
+			if err = readListenSockets(protocol, nil, sockets); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			for _, socket := range sockets {
+				found[socket.port] = true
+			}
+			return sortedPorts(found), nil
+		}
+		if backend == BackendNative {
+			return nil, fmt.Errorf("native /proc scan failed: %w", err)
+		}
+		log.DebugLog("native /proc scan failed, falling back to lsof/ss/netstat: %v", err)
+	}
+
+	for _, protocol := range protocols {
+		if err := listToolPorts(ctx, backend, protocol, found); err != nil {
+			return nil, err
+		}
+	}
+	return sortedPorts(found), nil
+}
+
+// listToolPorts adds the ports listening over protocol to found, asking lsof, ss and
+// netstat in turn (or only the tool backend names) until one answers
+func listToolPorts(ctx context.Context, backend, protocol string, found map[int]bool) error {
+	listings := []struct {
+		tool  string
+		args  []string
+		local func(fields []string) string // the local address of an output line
+	}{
+		{BackendLsof, []string{"-nP", "-i" + strings.ToUpper(protocol), "-sTCP:LISTEN", "-Fn"}, lsofLocal},
+		{BackendSS, []string{"-H", "-" + protocol[:1] + "ln"}, ssLocal},
+		{BackendNetstat, []string{"-" + protocol[:1] + "ln"}, netstatLocal},
+	}
+	if protocol == ProtocolUDP {
+		listings[0].args = []string{"-nP", "-iUDP", "-Fn"}
+	}
+
+	var lastErr error
+	for _, listing := range listings {
+		if (backend != BackendAuto && backend != listing.tool) || !execx.Available(listing.tool) {
+			continue
+		}
+		start := time.Now()
+		timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		output, err := execx.Run(timeoutCtx, listing.tool, listing.args...)
+		cancel()
+		// lsof exits with 1 when nothing matches
+		if err != nil && !(listing.tool == BackendLsof && execx.ExitCode(err) == 1) {
+			log.DebugLog("listing %s ports: %s failed after %v: %v", protocol, listing.tool, time.Since(start).Round(time.Millisecond), err)
+			lastErr = fmt.Errorf("%s failed to list listening ports: %w", listing.tool, err)
+			continue
+		}
+		log.DebugLog("listing %s ports: %s answered in %v", protocol, listing.tool, time.Since(start).Round(time.Millisecond))
+		for _, line := range strings.Split(string(output), "\n") {
+			if port, ok := localPort(listing.local(strings.Fields(line))); ok {
+				found[port] = true
+			}
+		}
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no port scanning tools found (lsof, ss, or netstat). Please install one of them")
+	}
+	return lastErr
+}
+
+// lsofLocal returns the local address of an lsof -Fn name line ("n*:3000"); lines of
+// other fields and connected sockets ("n127.0.0.1:5353->10.0.0.1:53") have none
+func lsofLocal(fields []string) string {
+	if len(fields) != 1 || !strings.HasPrefix(fields[0], "n") || strings.Contains(fields[0], "->") {
+		return ""
+	}
+	return strings.TrimPrefix(fields[0], "n")
+}
+
+// netstatLocal returns the local address of a netstat socket line: "tcp 0 0 0.0.0.0:3000
+// 0.0.0.0:* LISTEN" on Linux, "tcp4 0 0 *.3000 *.* LISTEN" on macOS
+func netstatLocal(fields []string) string {
+	if len(fields) < 4 || !strings.HasPrefix(fields[0], "tcp") && !strings.HasPrefix(fields[0], "udp") {
+		return ""
+	}
+	if strings.HasPrefix(fields[0], "tcp") && fields[len(fields)-1] != "LISTEN" {
+		return ""
+	}
+	return fields[3]
+}
+
+// ssLocal returns the local address of an ss -H line: "LISTEN 0 4096 0.0.0.0:3000 0.0.0.0:*"
+func ssLocal(fields []string) string {
+	if len(fields) < 5 {
+		return ""
+	}
+	return fields[3]
+}
+
+// localPort returns the port of a local address such as "*:3000", "[::1]:8080" or
+// "127.0.0.1.5353" (BSD netstat)
+func localPort(address string) (int, bool) {
+	i := strings.LastIndexAny(address, ":.")
+	if i == -1 {
+		return 0, false
+	}
+	port, err := strconv.Atoi(address[i+1:])
+	if err != nil || port < 1 || port > 65535 {
+		return 0, false
+	}
+	return port, true
+}
+
+func sortedPorts(found map[int]bool) []int {
+	ports := make([]int, 0, len(found))
+	for port := range found {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// fixturePorts returns the ports of the running listeners of listeners.json
+func fixturePorts(protocols []string) ([]int, error) {
+	listeners, err := testmode.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(protocols))
+	for _, protocol := range protocols {
+		wanted[protocol] = true
+	}
+	found := make(map[int]bool)
+	for _, l := range listeners {
+		if wanted[l.Protocol] && !fixtureKilled(l.PID) {
+			found[l.Port] = true
+		}
+	}
+	return sortedPorts(found), nil
+}
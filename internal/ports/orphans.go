@@ -0,0 +1,71 @@
+package ports
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// Descendants returns the PIDs of all children, grandchildren, ... of pid. Taken before
+// a kill, it recognises children that were re-parented to init when their parent died.
+func Descendants(ctx context.Context, pid int) map[int]bool {
+	if testmode.Enabled() {
+		return nil // listeners.json has no process tree
+	}
+	output, err := execx.Run(ctx, "ps", "-A", "-o", "pid=,ppid=")
+	if err != nil {
+		return nil
+	}
+
+	children := make(map[int][]int)
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		child, err1 := strconv.Atoi(fields[0])
+		parent, err2 := strconv.Atoi(fields[1])
+		if err1 == nil && err2 == nil {
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	descendants := make(map[int]bool)
+	queue := []int{pid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if !descendants[child] {
+				descendants[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return descendants
+}
+
+// SurvivingChildren returns the processes still listening on port (over protocol) that
+// descend from a killed process (descendants as returned by Descendants before the kill)
+func SurvivingChildren(ctx context.Context, port int, protocol string, descendants map[int]bool) ([]ProcessInfo, error) {
+	if len(descendants) == 0 {
+		return nil, nil
+	}
+	listeners, err := ScanPortsRangeWithProtocols(ctx, []int{port}, []string{protocol}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var survivors []ProcessInfo
+	seen := make(map[int]bool)
+	for _, proc := range listeners {
+		if descendants[proc.PID] && !seen[proc.PID] {
+			seen[proc.PID] = true
+			survivors = append(survivors, proc)
+		}
+	}
+	return survivors, nil
+}
@@ -1,13 +1,15 @@
 package ports
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"os/user"
 	"runtime"
 	"strconv"
 	"strings"
+
+	"github.com/hugoev/zap/internal/execx"
 )
 
 // canKillProcess checks if we have permission to kill the given process
@@ -86,8 +88,7 @@ func getProcessUID(pid int) (string, error) {
 	}
 
 	// Fallback: use ps command
-	cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "uid=")
-	output, err := cmd.Output()
+	output, err := execx.Run(context.Background(), "ps", "-p", strconv.Itoa(pid), "-o", "uid=")
 	if err != nil {
 		return "", err
 	}
@@ -108,7 +109,7 @@ func checkPermissionBeforeKill(pid int) error {
 	}
 
 	if !canKill {
-		return fmt.Errorf("permission denied: %s", reason)
+		return fmt.Errorf("%w: %s", ErrPermissionDenied, reason)
 	}
 
 	return nil
@@ -0,0 +1,70 @@
+package ports
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParsePortRange parses port ranges like "3000-3010,8080,9000-9005", in the order given
+// and without duplicates
+func ParsePortRange(portsStr string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+
+	parts := strings.Split(portsStr, ",")
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		// Check if it's a range (e.g., "3000-3010")
+		if strings.Contains(part, "-") {
+			rangeParts := strings.Split(part, "-")
+			if len(rangeParts) != 2 {
+				return nil, fmt.Errorf("invalid port range: %s", part)
+			}
+			start, err := strconv.Atoi(strings.TrimSpace(rangeParts[0]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid start port: %s", rangeParts[0])
+			}
+			end, err := strconv.Atoi(strings.TrimSpace(rangeParts[1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid end port: %s", rangeParts[1])
+			}
+			if start > end {
+				return nil, fmt.Errorf("start port (%d) must be <= end port (%d)", start, end)
+			}
+			if start < 1 || end > 65535 {
+				return nil, fmt.Errorf("ports must be in range 1-65535")
+			}
+			// Add all ports in range
+			for p := start; p <= end; p++ {
+				if !seen[p] {
+					ports = append(ports, p)
+					seen[p] = true
+				}
+			}
+		} else {
+			// Single port
+			port, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port: %s", part)
+			}
+			if port < 1 || port > 65535 {
+				return nil, fmt.Errorf("port must be in range 1-65535: %d", port)
+			}
+			if !seen[port] {
+				ports = append(ports, port)
+				seen[port] = true
+			}
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no valid ports specified")
+	}
+
+	return ports, nil
+}
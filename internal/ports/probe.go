@@ -0,0 +1,96 @@
+package ports
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProbeTimeout bounds a --probe request, so a hung server doesn't stall the scan
+const ProbeTimeout = 1500 * time.Millisecond
+
+// ProbeResult is what a listener answered to an HTTP GET
+type ProbeResult struct {
+	Status     string // e.g. "200 OK"
+	Server     string // Server header
+	PoweredBy  string // X-Powered-By header
+	Identified string // recognised dev server, e.g. "vite dev server" ("" if unknown)
+}
+
+// String describes the result, e.g. "vite dev server, 200 OK"
+func (r ProbeResult) String() string {
+	var parts []string
+	switch {
+	case r.Identified != "":
+		parts = append(parts, r.Identified)
+	case r.PoweredBy != "":
+		parts = append(parts, r.PoweredBy)
+	case r.Server != "":
+		parts = append(parts, r.Server)
+	}
+	parts = append(parts, r.Status)
+	return strings.Join(parts, ", ")
+}
+
+// bodyMarkers recognise dev servers by what they serve
+var bodyMarkers = []struct {
+	marker string
+	server string
+}{
+	{"/@vite/client", "vite dev server"},
+	{"__NEXT_DATA__", "Next.js"},
+	{"/_next/static", "Next.js"},
+	{"__nuxt", "Nuxt"},
+	{"data-sveltekit", "SvelteKit"},
+	{"ng-version", "Angular dev server"},
+	{"webpack-dev-server", "webpack dev server"},
+	{"/__parcel", "Parcel"},
+	{"astro-island", "Astro"},
+	{"Directory listing for", "Python http.server"},
+}
+
+// Probe sends an HTTP GET to the port the process listens on and reports the answer,
+// so users can confirm what they are about to terminate
+func Probe(ctx context.Context, proc ProcessInfo) (ProbeResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, ProbeTimeout)
+	defer cancel()
+
+	host := proc.BindAddress
+	if host == "" || host == "*" || net.ParseIP(host) != nil && net.ParseIP(host).IsUnspecified() {
+		host = "localhost"
+	}
+	url := "http://" + net.JoinHostPort(host, strconv.Itoa(proc.Port)) + "/"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return ProbeResult{}, err
+	}
+	client := &http.Client{
+		// The first answer is what identifies the server; don't wander off to other hosts
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return ProbeResult{}, fmt.Errorf("no HTTP response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	result := ProbeResult{
+		Status:    resp.Status,
+		Server:    resp.Header.Get("Server"),
+		PoweredBy: resp.Header.Get("X-Powered-By"),
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	for _, m := range bodyMarkers {
+		if strings.Contains(string(body), m.marker) {
+			result.Identified = m.server
+			break
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,325 @@
+package ports
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Socket states in /proc/net/{tcp,udp}'s "st" column: TCP_LISTEN, and TCP_CLOSE, which
+// is what a bound but unconnected UDP socket reports
+const (
+	tcpStateListen = "0A"
+	udpStateBound  = "07"
+)
+
+// clockTicks is USER_HZ, the unit of start times in /proc/[pid]/stat. It is 100 on
+// every architecture Linux supports in practice.
+const clockTicks = 100
+
+// listenSocket is a listening socket from /proc/net/{tcp,udp}{,6}
+type listenSocket struct {
+	port        int
+	bindAddress string
+	protocol    string
+}
+
+// NativeScanAvailable reports whether listeners can be read from /proc directly,
+// without lsof, ss or netstat
+func NativeScanAvailable() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	_, err := os.Stat("/proc/net/tcp")
+	return err == nil
+}
+
+// scanProcNet finds the processes listening on ports with one pass over the kernel's
+// socket tables and the file descriptors in /proc, instead of a lookup per port.
+// Sockets of processes we may not inspect (other users', unless root) aren't reported,
+// the same as with lsof.
+func scanProcNet(ctx context.Context, ports []int, protocols []string) ([]ProcessInfo, error) {
+	wanted := make(map[int]bool, len(ports))
+	for _, port := range ports {
+		wanted[port] = true
+	}
+
+	sockets := make(map[string]listenSocket)
+	for _, protocol := range protocols {
+		if err := readListenSockets(protocol, wanted, sockets); err != nil {
+			return nil, err
+		}
+	}
+	if len(sockets) == 0 {
+		return nil, nil
+	}
+
+	procDirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	var processes []ProcessInfo
+	for _, dir := range procDirs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var found []listenSocket
+		for _, inode := range socketInodes(pid) {
+			socket, ok := sockets[inode]
+			// A process listening on both tcp and tcp6 is reported once per address
+			key := fmt.Sprintf("%d/%s/%s", socket.port, socket.protocol, socket.bindAddress)
+			if !ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			found = append(found, socket)
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		name, details := procProcessDetails(pid)
+		for _, socket := range found {
+			processes = append(processes, ProcessInfo{
+				PID:         pid,
+				Port:        socket.port,
+				Name:        name,
+				Cmd:         details.Cmd,
+				User:        details.User,
+				StartTime:   details.StartTime,
+				Runtime:     details.Runtime,
+				WorkingDir:  details.WorkingDir,
+				Executable:  details.Executable,
+				ExeInode:    details.ExeInode,
+				Arch:        details.Arch,
+				Emulated:    details.Emulated,
+				BindAddress: socket.bindAddress,
+				Protocol:    socket.protocol,
+			})
+		}
+	}
+	return processes, nil
+}
+
+// readListenSockets adds the listening sockets of protocol on the wanted ports (all of
+// them when wanted is nil) to sockets, keyed by inode
+func readListenSockets(protocol string, wanted map[int]bool, sockets map[string]listenSocket) error {
+	read := 0
+	for _, path := range []string{"/proc/net/" + protocol, "/proc/net/" + protocol + "6"} {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		read++
+
+		scanner := bufio.NewScanner(file)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode ...
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[9] == "0" || !listening(protocol, fields[3], fields[2]) {
+				continue
+			}
+			hexAddr, hexPort, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			port, err := strconv.ParseInt(hexPort, 16, 32)
+			if err != nil || wanted != nil && !wanted[int(port)] {
+				continue
+			}
+			sockets[fields[9]] = listenSocket{port: int(port), bindAddress: procNetHost(hexAddr), protocol: protocol}
+		}
+		file.Close()
+	}
+	if read == 0 {
+		return fmt.Errorf("/proc/net/%s not readable", protocol)
+	}
+	return nil
+}
+
+// listening reports whether a socket table row is a server socket: TCP in LISTEN, or
+// UDP bound without a peer (connected UDP sockets are clients)
+func listening(protocol, state, remote string) bool {
+	if protocol == ProtocolUDP {
+		host, port, _ := strings.Cut(remote, ":")
+		return state == udpStateBound && port == "0000" && strings.Trim(host, "0") == ""
+	}
+	return state == tcpStateListen
+}
+
+// procNetHost decodes a /proc/net address, stored as 32-bit words in host byte order
+// ("0100007F" is 127.0.0.1 on little-endian machines), with wildcards shown as "*"
+func procNetHost(hexAddr string) string {
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return ""
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		word := raw[i : i+4]
+		if littleEndian() {
+			ip[i], ip[i+1], ip[i+2], ip[i+3] = word[3], word[2], word[1], word[0]
+		} else {
+			copy(ip[i:i+4], word)
+		}
+	}
+	if ip.IsUnspecified() {
+		return "*"
+	}
+	return ip.String()
+}
+
+func littleEndian() bool {
+	switch runtime.GOARCH {
+	case "ppc64", "s390x", "mips", "mips64", "sparc64":
+		return false
+	}
+	return true
+}
+
+// socketInodes returns the inodes of the sockets a process has open, read from the
+// "socket:[12345]" links in /proc/[pid]/fd
+func socketInodes(pid int) []string {
+	fdDir := filepath.Join("/proc", strconv.Itoa(pid), "fd")
+	fds, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil // exited, or not ours to inspect
+	}
+	var inodes []string
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil || !strings.HasPrefix(link, "socket:[") {
+			continue
+		}
+		inodes = append(inodes, strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"))
+	}
+	return inodes
+}
+
+// procProcessDetails reads a process's name, command line, owner, start time and
+// working directory from /proc, falling back to ps for anything it can't read
+func procProcessDetails(pid int) (string, processDetails) {
+	procDir := filepath.Join("/proc", strconv.Itoa(pid))
+	var details processDetails
+
+	comm, err := os.ReadFile(filepath.Join(procDir, "comm"))
+	if err != nil {
+		return "", getProcessDetails(pid)
+	}
+	name := strings.TrimSpace(string(comm))
+
+	if cmdline, err := os.ReadFile(filepath.Join(procDir, "cmdline")); err == nil {
+		details.Cmd = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+	}
+	if details.Cmd == "" {
+		details.Cmd = name // kernel threads have no command line
+	}
+	details.User = procUser(procDir)
+	if start, err := procStartTime(procDir); err == nil {
+		details.StartTime = start
+		details.Runtime = time.Since(start)
+	}
+	if cwd, err := os.Readlink(filepath.Join(procDir, "cwd")); err == nil {
+		details.WorkingDir = cwd
+	}
+	details.Executable, details.ExeInode = processExecutable(pid)
+	details.Arch, details.Emulated = processArch(pid)
+
+	if details.User == "" || details.StartTime.IsZero() {
+		fallback := getProcessDetails(pid)
+		if details.User == "" {
+			details.User = fallback.User
+		}
+		if details.StartTime.IsZero() {
+			details.StartTime, details.Runtime = fallback.StartTime, fallback.Runtime
+		}
+	}
+	return name, details
+}
+
+// procUser returns the name of the real user of a process, or its uid if unknown
+func procUser(procDir string) string {
+	file, err := os.Open(filepath.Join(procDir, "status"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "Uid:" {
+			continue
+		}
+		if u, err := user.LookupId(fields[1]); err == nil {
+			return u.Username
+		}
+		return fields[1]
+	}
+	return ""
+}
+
+// procStartTime reads when a process started: field 22 of /proc/[pid]/stat counts
+// clock ticks since boot, and /proc/stat's btime is the boot time
+func procStartTime(procDir string) (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(procDir, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	// The command name (field 2) is parenthesised and may contain spaces
+	end := strings.LastIndex(string(data), ")")
+	if end == -1 {
+		return time.Time{}, fmt.Errorf("malformed stat")
+	}
+	fields := strings.Fields(string(data[end+1:]))
+	// fields[0] is field 3 (state), so field 22 is fields[19]
+	if len(fields) < 20 {
+		return time.Time{}, fmt.Errorf("malformed stat")
+	}
+	ticks, err := strconv.ParseInt(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	boot, err := bootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return boot.Add(time.Duration(ticks) * time.Second / clockTicks), nil
+}
+
+func bootTime() (time.Time, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "btime "); ok {
+			seconds, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(seconds, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("btime not found in /proc/stat")
+}
@@ -0,0 +1,43 @@
+package ports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// projectMarkers are files or directories found at the root of a project
+var projectMarkers = []string{
+	".git", "go.mod", "package.json", "Cargo.toml", "pyproject.toml",
+	"requirements.txt", "Gemfile", "mix.exs", "pom.xml", "build.gradle",
+	"build.gradle.kts", "composer.json", "deno.json",
+}
+
+// FindProjectRoot walks up from dir to the nearest directory containing a project
+// marker. Returns "" when dir isn't inside a project (the home directory and the
+// filesystem root never count as projects).
+func FindProjectRoot(dir string) string {
+	homeDir, _ := os.UserHomeDir()
+
+	dir = filepath.Clean(dir)
+	for {
+		if dir == homeDir || dir == filepath.Dir(dir) {
+			return ""
+		}
+		for _, marker := range projectMarkers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir
+			}
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// InProject reports whether a process runs inside the project rooted at root
+func InProject(proc ProcessInfo, root string) bool {
+	if root == "" || proc.WorkingDir == "" {
+		return false
+	}
+	rel, err := filepath.Rel(root, proc.WorkingDir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
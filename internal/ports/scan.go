@@ -2,76 +2,237 @@ package ports
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"os"
-	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
-)
 
-type ProcessInfo struct {
-	PID        int
-	Port       int
-	Name       string
-	Cmd        string
-	User       string
-	StartTime  time.Time
-	Runtime    time.Duration
-	WorkingDir string
-}
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/stall"
+	"github.com/hugoev/zap/internal/testmode"
+)
 
-var commonDevPorts = []int{
-	// Node.js, React, Next.js
+// CommonDevPorts are the ports scanned by default: those development servers use. Each
+// port is listed once; scan every listening port with ListeningPorts instead.
+var CommonDevPorts = []int{
+	// Node.js, React, Next.js, Ruby on Rails, Remix, Bun
 	3000, 3001, 3002, 3003, 3004, 3005,
-	// Vite, Vite-based frameworks
+	// Vite, Vite-based frameworks, SvelteKit
 	5173, 5174, 5175, 5176, 5177,
-	// Python (Flask, Django, FastAPI, Uvicorn)
-	5000, 5001, 8000, 8001, 8080, 8081, 8888,
-	// Go, Rust, general dev servers
+	// Python (Flask, Django, FastAPI, Uvicorn), .NET, Deno
+	5000, 5001, 8000, 8001, 8888,
+	// Java Spring Boot, HTTP alternates
+	8080, 8081, 8082,
+	// Go, Rust, Phoenix, general dev servers
 	4000, 4001, 4002, 4003,
 	// Angular
 	4200, 4201,
-	// SvelteKit
-	5173, 5174,
 	// Play framework, Scala
 	9000, 9001, 9002,
-	// Phoenix, Elixir
-	4000, 4001,
-	// Ruby on Rails
-	3000, 3001,
-	// Remix, SvelteKit
-	3000, 5173,
-	// Bun, Deno
-	3000, 8000,
-	// Java Spring Boot
-	8080, 8081, 8082,
-	// .NET
-	5000, 5001,
+	// Phoenix LiveView, Elixir
+	7000, 7001, 7002,
 	// Additional common ranges
-	7000, 7001, 7002, // Phoenix, LiveView
-	6000, 6001, // Additional dev servers
+	6000, 6001,
+}
+
+// InfrastructurePorts are the default ports of databases, caches, brokers and similar
+// services, where `zap config suggest-protected` looks for infrastructure
+var InfrastructurePorts = []int{
+	5432, 5433, // PostgreSQL
+	6379, 6380, // Redis
+	3306, 33060, // MySQL
+	27017, 27018, // MongoDB
+	11211,       // Memcached
+	5672, 15672, // RabbitMQ
+	9200, 9300, // Elasticsearch
+	9092, 2181, // Kafka, ZooKeeper
+	8500,       // Consul
+	2379, 2380, // etcd
+	2375, 2376, // Docker daemon
+	1433, // SQL Server
+	1521, // Oracle
+	5984, // CouchDB
+	7687, // Neo4j
+	8086, // InfluxDB
+	9042, // Cassandra
+	4222, // NATS
+}
+
+type ProcessInfo struct {
+	PID         int
+	Port        int
+	Name        string
+	Cmd         string
+	User        string
+	StartTime   time.Time
+	Runtime     time.Duration
+	WorkingDir  string
+	Executable  string     // path of the program the process runs, "" if unknown
+	ExeInode    uint64     // inode of Executable, 0 if unknown
+	Arch        string     // architecture the process runs as, e.g. "arm64"; "" if unknown
+	Emulated    bool       // Arch isn't native: translated by Rosetta or run by an emulator
+	BindAddress string     // local address the socket listens on, e.g. "127.0.0.1", "::" or "*"
+	Protocol    string     // ProtocolTCP or ProtocolUDP
+	Container   *Container // set by AttachContainers when proc forwards a container's port
+}
+
+// Protocols a port can be scanned for
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// ParseProtocols parses a --proto value such as "tcp", "udp" or "udp,tcp"
+func ParseProtocols(value string) ([]string, error) {
+	var protocols []string
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		protocol := strings.ToLower(strings.TrimSpace(part))
+		if protocol != ProtocolTCP && protocol != ProtocolUDP {
+			return nil, fmt.Errorf("invalid protocol %q (must be %s or %s)", part, ProtocolTCP, ProtocolUDP)
+		}
+		if !seen[protocol] {
+			seen[protocol] = true
+			protocols = append(protocols, protocol)
+		}
+	}
+	return protocols, nil
+}
+
+// PortLabel formats the process's port for display: ":3000", or ":5353/udp" for UDP
+func (p ProcessInfo) PortLabel() string {
+	if p.Protocol == ProtocolUDP {
+		return fmt.Sprintf(":%d/udp", p.Port)
+	}
+	return fmt.Sprintf(":%d", p.Port)
+}
+
+// Interface filters for listeners by bind address
+const (
+	InterfaceLoopback = "lo"  // reachable only from this machine
+	InterfaceAll      = "all" // reachable from the network (wildcard or external address)
+)
+
+// IsLoopback reports whether the process listens only on a loopback address
+func (p ProcessInfo) IsLoopback() bool {
+	if p.BindAddress == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(p.BindAddress)
+	return ip != nil && ip.IsLoopback()
+}
+
+// FilterByInterface keeps the processes matching an --interface value ("lo" or "all")
+func FilterByInterface(processes []ProcessInfo, iface string) ([]ProcessInfo, error) {
+	if iface != InterfaceLoopback && iface != InterfaceAll {
+		return nil, fmt.Errorf("invalid interface %q (must be %s or %s)", iface, InterfaceLoopback, InterfaceAll)
+	}
+	var filtered []ProcessInfo
+	for _, proc := range processes {
+		if proc.IsLoopback() == (iface == InterfaceLoopback) {
+			filtered = append(filtered, proc)
+		}
+	}
+	return filtered, nil
+}
+
+// bindHost extracts the host from a local address column such as "127.0.0.1:3000",
+// "[::1]:3000", "*:3000", ":::3000" or "127.0.0.1%lo:3000"
+func bindHost(local string) string {
+	i := strings.LastIndex(local, ":")
+	if i == -1 {
+		return ""
+	}
+	host := strings.TrimSuffix(strings.TrimPrefix(local[:i], "["), "]")
+	if zone := strings.Index(host, "%"); zone != -1 {
+		host = host[:zone]
+	}
+	if host == "" || host == "::" || host == "0.0.0.0" {
+		return "*"
+	}
+	return host
 }
 
 func ScanPorts(ctx context.Context) ([]ProcessInfo, error) {
-	return ScanPortsRange(ctx, commonDevPorts)
+	return ScanPortsRange(ctx, CommonDevPorts)
 }
 
 // ScanPortsRange scans a specific list of ports (allows custom port ranges)
 func ScanPortsRange(ctx context.Context, ports []int) ([]ProcessInfo, error) {
+	return ScanPortsRangeWithConcurrency(ctx, ports, 0)
+}
+
+// ScanTimeout bounds a whole port scan; ports not checked by then are reported
+// through a *PartialScanError
+const ScanTimeout = 30 * time.Second
+
+// ErrScanTimeout is wrapped by the PartialScanError returned when ScanTimeout is exceeded
+var ErrScanTimeout = errors.New("scan timeout exceeded")
+
+// PartialScanError is returned together with the processes found so far when a scan
+// ran out of time before every port was checked, or stalled lookups were skipped
+type PartialScanError struct {
+	Unchecked []int // ports that were not checked, in scan order
+	Err       error // ErrScanTimeout, context.DeadlineExceeded or stall.ErrSkipped
+}
+
+func (e *PartialScanError) Error() string {
+	return fmt.Sprintf("%v: %d port(s) not checked", e.Err, len(e.Unchecked))
+}
+
+func (e *PartialScanError) Unwrap() error {
+	return e.Err
+}
+
+// ScanPortsRangeWithConcurrency scans TCP ports with at most maxConcurrency lookups in
+// flight; values below 1 pick a default based on the number of CPUs.
+// If the scan runs out of time, the processes found so far are returned along with
+// a *PartialScanError listing the ports that were not checked.
+func ScanPortsRangeWithConcurrency(ctx context.Context, ports []int, maxConcurrency int) ([]ProcessInfo, error) {
+	return ScanPortsRangeWithProtocols(ctx, ports, []string{ProtocolTCP}, maxConcurrency)
+}
+
+// ScanPortsRangeWithProtocols is ScanPortsRangeWithConcurrency for the given protocols
+// (ProtocolTCP, ProtocolUDP): TCP sockets in LISTEN state and bound, unconnected UDP
+// sockets. On Linux all ports are read from /proc in a single pass; the per-port
+// lookups through lsof, ss or netstat are the fallback when /proc isn't available.
+// A backend set with WithBackend is used alone, without falling back.
+func ScanPortsRangeWithProtocols(ctx context.Context, ports []int, protocols []string, maxConcurrency int) ([]ProcessInfo, error) {
+	if testmode.Enabled() {
+		return scanFixture(ports, protocols)
+	}
+	backend := BackendFrom(ctx)
+	if err := CheckBackend(backend); err != nil {
+		return nil, err
+	}
+	if backend == BackendNative || backend == BackendAuto && NativeScanAvailable() {
+		start := time.Now()
+		processes, err := scanProcNet(ctx, ports, protocols)
+		if err == nil || err == context.Canceled {
+			// One pass over /proc answers for every port at once
+			log.DebugLog("%d port(s): native /proc scan answered in %v", len(ports), time.Since(start).Round(time.Millisecond))
+			return processes, err
+		}
+		if backend == BackendNative {
+			return nil, fmt.Errorf("native /proc scan failed: %w", err)
+		}
+		log.DebugLog("native /proc scan failed after %v, falling back to lsof/ss/netstat: %v", time.Since(start).Round(time.Millisecond), err)
+	}
+
 	var processes []ProcessInfo
 	var scanErrors []error
 
 	// Limit concurrent goroutines to prevent resource exhaustion
-	maxConcurrency := runtime.NumCPU() * 2
-	if maxConcurrency > 20 {
-		maxConcurrency = 20 // Cap at 20
-	}
 	if maxConcurrency < 1 {
-		maxConcurrency = 1
+		maxConcurrency = runtime.NumCPU() * 2
+		if maxConcurrency > 20 {
+			maxConcurrency = 20 // Cap at 20
+		}
 	}
 
 	// Use goroutines for parallel scanning (faster on multi-core systems)
@@ -82,69 +243,99 @@ func ScanPortsRange(ctx context.Context, ports []int) ([]ProcessInfo, error) {
 	}
 
 	semaphore := make(chan struct{}, maxConcurrency)
-	results := make(chan result, len(ports))
-	var wg sync.WaitGroup
+	// Buffered for every port so scans still running after a timeout never block
+	results := make(chan result, len(ports)*len(protocols))
 
 	// Launch parallel scans with resource limits
 	for _, port := range ports {
-		// Check for cancellation
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
-		}
-
-		wg.Add(1)
-		go func(p int) {
-			defer wg.Done()
-
-			// Acquire semaphore (limit concurrency)
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
-
-			// Check for cancellation before scanning
+		for _, protocol := range protocols {
+			// Check for cancellation
 			select {
 			case <-ctx.Done():
-				results <- result{procs: nil, err: ctx.Err(), port: p}
-				return
+				return nil, ctx.Err()
 			default:
 			}
 
-			procs, err := getProcessesOnPort(ctx, p)
-			results <- result{procs: procs, err: err, port: p}
-		}(port)
-	}
-
-	// Wait for all goroutines with timeout
-	done := make(chan struct{})
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
-
-	// Wait for completion or cancellation
-	select {
-	case <-done:
-		// All completed
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-time.After(30 * time.Second):
-		return nil, fmt.Errorf("scan timeout exceeded (30s)")
-	}
-
-	// Collect results
-	close(results)
-	for res := range results {
-		if res.err != nil {
-			// Skip cancellation errors (they're expected)
-			if res.err == context.Canceled || res.err == context.DeadlineExceeded {
+			go func(p int, protocol string) {
+				// Acquire semaphore (limit concurrency)
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+
+				// Check for cancellation before scanning
+				select {
+				case <-ctx.Done():
+					results <- result{procs: nil, err: ctx.Err(), port: p}
+					return
+				default:
+				}
+
+				portCtx, task := stall.Track(ctx, fmt.Sprintf("lookup of port %d/%s", p, protocol))
+				defer task.Done()
+				procs, err := getProcessesOnPort(portCtx, p, protocol)
+				if errors.Is(context.Cause(portCtx), stall.ErrSkipped) {
+					err = stall.ErrSkipped
+				}
+				results <- result{procs: procs, err: err, port: p}
+			}(port, protocol)
+		}
+	}
+
+	// Collect results as they arrive, so a timeout keeps everything found so far
+	checked := make(map[int]bool)
+	skipped := false
+	uncheckedPorts := func() []int {
+		var unchecked []int
+		reported := make(map[int]bool)
+		for _, port := range ports {
+			if !checked[port] && !reported[port] {
+				reported[port] = true
+				unchecked = append(unchecked, port)
+			}
+		}
+		return unchecked
+	}
+	partial := func(err error) ([]ProcessInfo, error) {
+		return processes, &PartialScanError{Unchecked: uncheckedPorts(), Err: err}
+	}
+
+	timeout := time.NewTimer(ScanTimeout)
+	defer timeout.Stop()
+	for remaining := len(ports) * len(protocols); remaining > 0; remaining-- {
+		select {
+		case res := <-results:
+			if res.err != nil {
+				// Skip cancellation errors (they're expected); the port counts as unchecked
+				if res.err == context.Canceled || res.err == context.DeadlineExceeded {
+					continue
+				}
+				if res.err == stall.ErrSkipped {
+					skipped = true
+					continue
+				}
+				// Log error but continue scanning other ports
+				checked[res.port] = true
+				scanErrors = append(scanErrors, fmt.Errorf("port %d: %w", res.port, res.err))
 				continue
 			}
-			// Log error but continue scanning other ports
-			scanErrors = append(scanErrors, fmt.Errorf("port %d: %w", res.port, res.err))
-			continue
+			checked[res.port] = true
+			processes = append(processes, res.procs...)
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return partial(ctx.Err())
+			}
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return partial(fmt.Errorf("%w (%v)", ErrScanTimeout, ScanTimeout))
 		}
-		processes = append(processes, res.procs...)
+	}
+
+	// Ports whose scan was cut short by the context deadline were not checked
+	if err := ctx.Err(); err == context.DeadlineExceeded && len(uncheckedPorts()) > 0 {
+		return partial(err)
+	}
+	// Neither were ports whose stalled lookup was skipped
+	if skipped && len(uncheckedPorts()) > 0 {
+		return partial(stall.ErrSkipped)
 	}
 
 	// If we got some processes, return them even if there were some scan errors
@@ -160,7 +351,26 @@ func ScanPortsRange(ctx context.Context, ports []int) ([]ProcessInfo, error) {
 	return processes, nil
 }
 
-func getProcessesOnPort(ctx context.Context, port int) ([]ProcessInfo, error) {
+// getProcessesOnPort finds the processes listening on one port, over TCP or UDP. With
+// -vv it logs how long the lookup took and which tool answered, to tell slow scans apart.
+func getProcessesOnPort(ctx context.Context, port int, protocol string) ([]ProcessInfo, error) {
+	start := time.Now()
+	tool := "none"
+	processes, err := lookupPort(ctx, port, protocol, BackendFrom(ctx), &tool)
+	for i := range processes {
+		processes[i].Protocol = protocol
+	}
+	if err != nil {
+		log.DebugLog("port %d/%s: %s failed after %v: %v", port, protocol, tool, time.Since(start).Round(time.Millisecond), err)
+	} else {
+		log.DebugLog("port %d/%s: %s answered in %v", port, protocol, tool, time.Since(start).Round(time.Millisecond))
+	}
+	return processes, err
+}
+
+// lookupPort tries lsof, ss and netstat in turn, or only the tool backend names, setting
+// tool to the one tried last
+func lookupPort(ctx context.Context, port int, protocol, backend string, tool *string) ([]ProcessInfo, error) {
 	var processes []ProcessInfo
 
 	// Validate port number
@@ -175,14 +385,21 @@ func getProcessesOnPort(ctx context.Context, port int) ([]ProcessInfo, error) {
 
 	var output []byte
 	var err error
+	use := func(name string) bool {
+		return (backend == BackendAuto || backend == name) && execx.Available(name)
+	}
 	// Use provided context or create timeout context
 	timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	// Method 1: lsof (macOS, most Linux)
-	if lsofPath, err := exec.LookPath("lsof"); err == nil {
-		cmd := exec.CommandContext(timeoutCtx, lsofPath, "-i", fmt.Sprintf(":%d", port), "-sTCP:LISTEN", "-P", "-n")
-		output, err = cmd.Output()
+	if use("lsof") {
+		*tool = "lsof"
+		args := []string{"-i", fmt.Sprintf("TCP:%d", port), "-sTCP:LISTEN", "-P", "-n"}
+		if protocol == ProtocolUDP {
+			args = []string{"-i", fmt.Sprintf("UDP:%d", port), "-P", "-n"}
+		}
+		output, err = execx.Run(timeoutCtx, "lsof", args...)
 		if err == nil {
 			// Success with lsof
 			return parseLsofOutput(output, port)
@@ -192,18 +409,22 @@ func getProcessesOnPort(ctx context.Context, port int) ([]ProcessInfo, error) {
 			return nil, fmt.Errorf("timeout scanning port %d", port)
 		}
 		// Exit code 1 means no process found (normal)
-		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+		if execx.ExitCode(err) == 1 {
 			return processes, nil
 		}
 		// Other lsof errors, try fallback
 	}
 
 	// Method 2: ss (modern Linux, faster than netstat)
-	if ssPath, err := exec.LookPath("ss"); err == nil {
+	if use("ss") {
+		*tool = "ss"
 		ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel2()
-		cmd := exec.CommandContext(ctx2, ssPath, "-tlnp", fmt.Sprintf("sport = :%d", port))
-		output, err = cmd.Output()
+		ssFlags := "-tlnp"
+		if protocol == ProtocolUDP {
+			ssFlags = "-ulnp"
+		}
+		output, err = execx.Run(ctx2, "ss", ssFlags, fmt.Sprintf("sport = :%d", port))
 		if err == nil {
 			return parseSsOutput(output, port)
 		}
@@ -211,28 +432,37 @@ func getProcessesOnPort(ctx context.Context, port int) ([]ProcessInfo, error) {
 			return nil, fmt.Errorf("timeout scanning port %d", port)
 		}
 		// Exit code 1 means no process found
-		if exitError, ok := err.(*exec.ExitError); ok && exitError.ExitCode() == 1 {
+		if execx.ExitCode(err) == 1 {
 			return processes, nil
 		}
 	}
 
 	// Method 3: netstat (fallback for older Linux)
-	if netstatPath, err := exec.LookPath("netstat"); err == nil {
+	if use("netstat") {
+		*tool = "netstat"
 		ctx3, cancel3 := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel3()
 		// Try different netstat flags for different systems
-		cmd := exec.CommandContext(ctx3, netstatPath, "-tlnp")
-		output, err = cmd.Output()
+		netstatFlags := "-tlnp"
+		if protocol == ProtocolUDP {
+			netstatFlags = "-ulnp"
+		}
+		output, err = execx.Run(ctx3, "netstat", netstatFlags)
 		if err == nil {
-			return parseNetstatOutput(output, port)
+			return parseNetstatOutput(output, port, protocol)
 		}
 		if ctx3.Err() == context.DeadlineExceeded {
 			return nil, fmt.Errorf("timeout scanning port %d", port)
 		}
 	}
 
+	// A pinned tool that failed has no fallback
+	if backend != BackendAuto {
+		return nil, fmt.Errorf("%s failed to scan port %d: %w", backend, port, err)
+	}
+
 	// If all methods failed and we didn't find lsof initially, return error
-	if _, err := exec.LookPath("lsof"); err != nil {
+	if !execx.Available("lsof") {
 		return nil, fmt.Errorf("no port scanning tools found (lsof, ss, or netstat). Please install one of them")
 	}
 
@@ -268,14 +498,19 @@ func parseLsofOutput(output []byte, port int) ([]ProcessInfo, error) {
 		procInfo := getProcessDetails(pid)
 
 		processes = append(processes, ProcessInfo{
-			PID:        pid,
-			Port:       port,
-			Name:       cmdName,
-			Cmd:        procInfo.Cmd,
-			User:       procInfo.User,
-			StartTime:  procInfo.StartTime,
-			Runtime:    procInfo.Runtime,
-			WorkingDir: procInfo.WorkingDir,
+			PID:         pid,
+			Port:        port,
+			Name:        cmdName,
+			Cmd:         procInfo.Cmd,
+			User:        procInfo.User,
+			StartTime:   procInfo.StartTime,
+			Runtime:     procInfo.Runtime,
+			WorkingDir:  procInfo.WorkingDir,
+			Executable:  procInfo.Executable,
+			ExeInode:    procInfo.ExeInode,
+			Arch:        procInfo.Arch,
+			Emulated:    procInfo.Emulated,
+			BindAddress: bindHost(fields[8]),
 		})
 	}
 
@@ -335,15 +570,26 @@ func parseSsOutput(output []byte, port int) ([]ProcessInfo, error) {
 			}
 		}
 
+		// Local address is the fourth column: State Recv-Q Send-Q Local:Port Peer:Port
+		var bindAddress string
+		if fields := strings.Fields(line); len(fields) >= 4 {
+			bindAddress = bindHost(fields[3])
+		}
+
 		processes = append(processes, ProcessInfo{
-			PID:        pid,
-			Port:       port,
-			Name:       cmdName,
-			Cmd:        procInfo.Cmd,
-			User:       procInfo.User,
-			StartTime:  procInfo.StartTime,
-			Runtime:    procInfo.Runtime,
-			WorkingDir: procInfo.WorkingDir,
+			PID:         pid,
+			Port:        port,
+			Name:        cmdName,
+			Cmd:         procInfo.Cmd,
+			User:        procInfo.User,
+			StartTime:   procInfo.StartTime,
+			Runtime:     procInfo.Runtime,
+			WorkingDir:  procInfo.WorkingDir,
+			Executable:  procInfo.Executable,
+			ExeInode:    procInfo.ExeInode,
+			Arch:        procInfo.Arch,
+			Emulated:    procInfo.Emulated,
+			BindAddress: bindAddress,
 		})
 	}
 
@@ -351,18 +597,23 @@ func parseSsOutput(output []byte, port int) ([]ProcessInfo, error) {
 }
 
 // parseNetstatOutput parses netstat output (older Linux fallback)
-func parseNetstatOutput(output []byte, port int) ([]ProcessInfo, error) {
+func parseNetstatOutput(output []byte, port int, protocol string) ([]ProcessInfo, error) {
 	var processes []ProcessInfo
 	lines := strings.Split(string(output), "\n")
 	portStr := fmt.Sprintf(":%d", port)
 
 	for _, line := range lines {
-		if !strings.Contains(line, portStr) || !strings.Contains(line, "LISTEN") {
+		// UDP sockets have no state column: udp 0 0 0.0.0.0:5353 0.0.0.0:* 12345/mdns
+		if protocol == ProtocolUDP {
+			if !strings.HasPrefix(line, "udp") {
+				continue
+			}
+		} else if !strings.Contains(line, "LISTEN") {
 			continue
 		}
 
 		fields := strings.Fields(line)
-		if len(fields) < 7 {
+		if len(fields) < 6 || !strings.HasSuffix(fields[3], portStr) {
 			continue
 		}
 
@@ -383,14 +634,19 @@ func parseNetstatOutput(output []byte, port int) ([]ProcessInfo, error) {
 		procInfo := getProcessDetails(pid)
 
 		processes = append(processes, ProcessInfo{
-			PID:        pid,
-			Port:       port,
-			Name:       cmdName,
-			Cmd:        procInfo.Cmd,
-			User:       procInfo.User,
-			StartTime:  procInfo.StartTime,
-			Runtime:    procInfo.Runtime,
-			WorkingDir: procInfo.WorkingDir,
+			PID:         pid,
+			Port:        port,
+			Name:        cmdName,
+			Cmd:         procInfo.Cmd,
+			User:        procInfo.User,
+			StartTime:   procInfo.StartTime,
+			Runtime:     procInfo.Runtime,
+			WorkingDir:  procInfo.WorkingDir,
+			Executable:  procInfo.Executable,
+			ExeInode:    procInfo.ExeInode,
+			Arch:        procInfo.Arch,
+			Emulated:    procInfo.Emulated,
+			BindAddress: bindHost(fields[3]),
 		})
 	}
 
@@ -403,6 +659,10 @@ type processDetails struct {
 	StartTime  time.Time
 	Runtime    time.Duration
 	WorkingDir string
+	Executable string
+	ExeInode   uint64
+	Arch       string
+	Emulated   bool
 }
 
 func getProcessDetails(pid int) processDetails {
@@ -431,8 +691,7 @@ func getProcessDetails(pid int) processDetails {
 
 	// Get command line
 	for _, format := range psFormats {
-		cmd := exec.CommandContext(ctx, format.cmdFormat, format.args...)
-		output, err := cmd.Output()
+		output, err := execx.Run(ctx, format.cmdFormat, format.args...)
 		if err == nil && len(output) > 0 {
 			details.Cmd = strings.TrimSpace(string(output))
 			break
@@ -447,8 +706,7 @@ func getProcessDetails(pid int) processDetails {
 		{[]string{"-p", strconv.Itoa(pid), "-o", "uid="}},
 	}
 	for _, format := range userFormats {
-		cmd := exec.CommandContext(ctx, "ps", format.args...)
-		output, err := cmd.Output()
+		output, err := execx.Run(ctx, "ps", format.args...)
 		if err == nil && len(output) > 0 {
 			details.User = strings.TrimSpace(string(output))
 			break
@@ -463,8 +721,7 @@ func getProcessDetails(pid int) processDetails {
 		{[]string{"-p", strconv.Itoa(pid), "-o", "start="}},  // GNU/Linux
 	}
 	for _, format := range startFormats {
-		cmd := exec.CommandContext(ctx, "ps", format.args...)
-		output, err := cmd.Output()
+		output, err := execx.Run(ctx, "ps", format.args...)
 		if err == nil && len(output) > 0 {
 			startStr := strings.TrimSpace(string(output))
 			if startStr != "" {
@@ -477,11 +734,13 @@ func getProcessDetails(pid int) processDetails {
 		}
 	}
 
+	details.Executable, details.ExeInode = processExecutable(pid)
+	details.Arch, details.Emulated = processArch(pid)
+
 	// Get working directory - try multiple methods
 	// Method 1: lsof (macOS, most Linux)
-	if lsofPath, err := exec.LookPath("lsof"); err == nil {
-		cmd := exec.CommandContext(ctx, lsofPath, "-p", strconv.Itoa(pid), "-a", "-d", "cwd", "-Fn")
-		output, err := cmd.Output()
+	if execx.Available("lsof") {
+		output, err := execx.Run(ctx, "lsof", "-p", strconv.Itoa(pid), "-a", "-d", "cwd", "-Fn")
 		if err == nil && len(output) > 0 {
 			lines := strings.Split(string(output), "\n")
 			for _, line := range lines {
@@ -494,9 +753,8 @@ func getProcessDetails(pid int) processDetails {
 	}
 
 	// Method 2: pwdx (Linux)
-	if pwdxPath, err := exec.LookPath("pwdx"); err == nil {
-		cmd := exec.CommandContext(ctx, pwdxPath, strconv.Itoa(pid))
-		output, err := cmd.Output()
+	if execx.Available("pwdx") {
+		output, err := execx.Run(ctx, "pwdx", strconv.Itoa(pid))
 		if err == nil && len(output) > 0 {
 			// pwdx output: "PID: /path/to/dir"
 			parts := strings.SplitN(strings.TrimSpace(string(output)), ":", 2)
@@ -536,7 +794,23 @@ func parseProcessStartTime(startStr string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unable to parse time: %s", startStr)
 }
 
+// IsUDPPortInUse reports whether a UDP socket is bound to port
+func IsUDPPortInUse(port int) bool {
+	if testmode.Enabled() {
+		return fixturePortInUse(port, ProtocolUDP)
+	}
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return true
+	}
+	conn.Close()
+	return false
+}
+
 func IsPortInUse(port int) bool {
+	if testmode.Enabled() {
+		return fixturePortInUse(port, ProtocolTCP)
+	}
 	addr := fmt.Sprintf(":%d", port)
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -547,6 +821,12 @@ func IsPortInUse(port int) bool {
 }
 
 func IsSafeDevServer(proc ProcessInfo) bool {
+	return SafeDevServerReason(proc) != ""
+}
+
+// SafeDevServerReason returns the rule that classifies proc as a safe dev server,
+// or "" if none matches
+func SafeDevServerReason(proc ProcessInfo) string {
 	cmdLower := strings.ToLower(proc.Cmd)
 	nameLower := strings.ToLower(proc.Name)
 	workingDirLower := strings.ToLower(proc.WorkingDir)
@@ -560,22 +840,22 @@ func IsSafeDevServer(proc ProcessInfo) bool {
 	if strings.Contains(cmdLower, "node") {
 		for _, pattern := range nodeDevPatterns {
 			if strings.Contains(cmdLower, pattern) {
-				return true
+				return "node dev tool \"" + pattern + "\" in command"
 			}
 		}
 	}
 
 	// Modern JavaScript runtimes
 	if strings.Contains(cmdLower, "bun") || nameLower == "bun" {
-		return true
+		return "bun runtime"
 	}
 	if strings.Contains(cmdLower, "deno") || nameLower == "deno" {
-		return true
+		return "deno runtime"
 	}
 
 	// Vite and Vite-based frameworks
 	if strings.Contains(cmdLower, "vite") {
-		return true
+		return "vite in command"
 	}
 
 	// Python dev servers
@@ -586,7 +866,7 @@ func IsSafeDevServer(proc ProcessInfo) bool {
 	if strings.Contains(cmdLower, "python") || strings.Contains(cmdLower, "python3") {
 		for _, pattern := range pythonDevPatterns {
 			if strings.Contains(cmdLower, pattern) {
-				return true
+				return "python dev server \"" + pattern + "\" in command"
 			}
 		}
 	}
@@ -596,7 +876,7 @@ func IsSafeDevServer(proc ProcessInfo) bool {
 	if strings.Contains(cmdLower, "go") {
 		for _, pattern := range goDevPatterns {
 			if strings.Contains(cmdLower, pattern) {
-				return true
+				return "go dev tool \"" + pattern + "\" in command"
 			}
 		}
 	}
@@ -604,32 +884,32 @@ func IsSafeDevServer(proc ProcessInfo) bool {
 	// Ruby/Rails
 	if strings.Contains(cmdLower, "rails") || strings.Contains(cmdLower, "rackup") ||
 		strings.Contains(cmdLower, "puma") || strings.Contains(cmdLower, "unicorn") {
-		return true
+		return "ruby app server (rails/rackup/puma/unicorn)"
 	}
 
 	// Elixir/Phoenix
 	if strings.Contains(cmdLower, "phoenix") || strings.Contains(cmdLower, "mix phx.server") ||
 		strings.Contains(cmdLower, "elixir") {
-		return true
+		return "elixir/phoenix dev server"
 	}
 
 	// Rust dev servers
 	if strings.Contains(cmdLower, "cargo") && (strings.Contains(cmdLower, "run") ||
 		strings.Contains(cmdLower, "watch")) {
-		return true
+		return "cargo run/watch"
 	}
 
 	// Java/Kotlin dev servers
 	if strings.Contains(cmdLower, "gradle") && strings.Contains(cmdLower, "bootrun") {
-		return true
+		return "gradle bootRun"
 	}
 	if strings.Contains(cmdLower, "mvn") && strings.Contains(cmdLower, "spring-boot:run") {
-		return true
+		return "mvn spring-boot:run"
 	}
 
 	// .NET dev servers
 	if strings.Contains(cmdLower, "dotnet") && strings.Contains(cmdLower, "watch") {
-		return true
+		return "dotnet watch"
 	}
 
 	// Check working directory for common dev indicators
@@ -638,7 +918,7 @@ func IsSafeDevServer(proc ProcessInfo) bool {
 		if strings.Contains(workingDirLower, indicator) {
 			// If in a project directory with dev indicators, likely a dev server
 			if nameLower == "node" || nameLower == "python" || nameLower == "go" {
-				return true
+				return nameLower + " process in a project directory (" + indicator + ")"
 			}
 		}
 	}
@@ -646,13 +926,18 @@ func IsSafeDevServer(proc ProcessInfo) bool {
 	// Generic node/python/go process on common dev port
 	if (nameLower == "node" || nameLower == "python" || nameLower == "python3" || nameLower == "go") &&
 		proc.Port >= 3000 && proc.Port < 10000 {
-		return true
+		return fmt.Sprintf("%s process on dev port range 3000-9999", nameLower)
 	}
 
-	return false
+	return ""
 }
 
 func IsInfrastructureProcess(proc ProcessInfo) bool {
+	return InfrastructureReason(proc) != ""
+}
+
+// InfrastructureReason returns the infrastructure keyword proc matches, or "" if none
+func InfrastructureReason(proc ProcessInfo) string {
 	cmdLower := strings.ToLower(proc.Cmd)
 	nameLower := strings.ToLower(proc.Name)
 
@@ -671,9 +956,9 @@ func IsInfrastructureProcess(proc ProcessInfo) bool {
 
 	for _, keyword := range infraKeywords {
 		if strings.Contains(cmdLower, keyword) || strings.Contains(nameLower, keyword) {
-			return true
+			return keyword
 		}
 	}
 
-	return false
+	return ""
 }
@@ -0,0 +1,57 @@
+package ports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"syscall"
+
+	"github.com/hugoev/zap/internal/execx"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// ErrPermissionDenied is returned (wrapped) when a process can't be signalled
+// without elevated privileges
+var ErrPermissionDenied = errors.New("permission denied")
+
+// IsPermissionError reports whether a kill failed for lack of privileges
+func IsPermissionError(err error) bool {
+	return errors.Is(err, ErrPermissionDenied) || errors.Is(err, syscall.EPERM)
+}
+
+// SudoAvailable reports whether sudo can be used without a password prompt, either
+// because it is passwordless or because credentials are still cached
+func SudoAvailable(ctx context.Context) bool {
+	if _, err := execx.Get("sudo").Lookup(); err != nil {
+		return false
+	}
+	_, err := execx.Run(ctx, "sudo", "-n", "true")
+	return err == nil
+}
+
+// KillProcessWithSudo terminates a single process through `sudo -n kill`, walking
+// through the signals of policy like KillProcessWithPolicy. It never prompts for a password.
+func KillProcessWithSudo(ctx context.Context, pid int, policy Escalation) error {
+	if testmode.Enabled() {
+		return fixtureKill(pid)
+	}
+	running := func() bool { return IsProcessRunning(pid) }
+	for i, step := range policy {
+		if i > 0 && waitForExit(running, step.After) {
+			return nil
+		}
+		signal := "-" + signalName(step.Signal)
+		if _, err := execx.Run(ctx, "sudo", "-n", "kill", signal, strconv.Itoa(pid)); err != nil {
+			if !IsProcessRunning(pid) {
+				return nil
+			}
+			return fmt.Errorf("sudo kill %s %d failed: %w", signal, pid, err)
+		}
+	}
+
+	if waitForExit(running, finalWait(policy, 0)) {
+		return nil
+	}
+	return fmt.Errorf("process %d still running after %s", pid, policy)
+}
@@ -0,0 +1,82 @@
+package ports
+
+import (
+	"strconv"
+
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// scanFixture returns the listeners of testmode's listeners.json on the given ports and
+// protocols, leaving out processes this run has killed
+func scanFixture(ports []int, protocols []string) ([]ProcessInfo, error) {
+	listeners, err := testmode.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	wantedPorts := make(map[int]bool, len(ports))
+	for _, port := range ports {
+		wantedPorts[port] = true
+	}
+	wantedProtocols := make(map[string]bool, len(protocols))
+	for _, protocol := range protocols {
+		wantedProtocols[protocol] = true
+	}
+
+	var processes []ProcessInfo
+	for _, l := range listeners {
+		if !wantedPorts[l.Port] || !wantedProtocols[l.Protocol] || fixtureKilled(l.PID) {
+			continue
+		}
+		proc := ProcessInfo{
+			PID:         l.PID,
+			Port:        l.Port,
+			Name:        l.Name,
+			Cmd:         l.Cmd,
+			User:        l.User,
+			StartTime:   l.StartTime,
+			WorkingDir:  l.WorkingDir,
+			Executable:  l.Executable,
+			Arch:        l.Arch,
+			Emulated:    l.Emulated,
+			BindAddress: l.BindAddress,
+			Protocol:    l.Protocol,
+		}
+		if !l.StartTime.IsZero() {
+			proc.Runtime = testmode.Since(l.StartTime)
+		}
+		processes = append(processes, proc)
+	}
+	return processes, nil
+}
+
+// fixtureRunning reports whether pid is a listener of listeners.json not yet killed
+func fixtureRunning(pid int) bool {
+	listeners, err := testmode.Listeners()
+	if err != nil {
+		return false
+	}
+	for _, l := range listeners {
+		if l.PID == pid {
+			return !fixtureKilled(pid)
+		}
+	}
+	return false
+}
+
+// fixturePortInUse reports whether a running listener of listeners.json holds port
+func fixturePortInUse(port int, protocol string) bool {
+	processes, err := scanFixture([]int{port}, []string{protocol})
+	return err == nil && len(processes) > 0
+}
+
+// fixtureKill records a kill in test mode instead of sending a signal
+func fixtureKill(pid int) error {
+	if !fixtureRunning(pid) {
+		return nil
+	}
+	return testmode.Record(testmode.ActionKill, strconv.Itoa(pid))
+}
+
+func fixtureKilled(pid int) bool {
+	return testmode.Done(testmode.ActionKill, strconv.Itoa(pid))
+}
@@ -0,0 +1,87 @@
+package ports
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/hugoev/zap/internal/execx"
+)
+
+// tcpStateTimeWait is TCP_TIME_WAIT in /proc/net/tcp's "st" column
+const tcpStateTimeWait = "06"
+
+// TimeWaitCount returns how many TCP connections on a local port are in TIME_WAIT.
+// They belong to no process but can still make bind() fail for servers that don't set
+// SO_REUSEADDR, which looks like "address already in use" with nothing listening.
+func TimeWaitCount(ctx context.Context, port int) (int, error) {
+	if runtime.GOOS == "linux" {
+		if count, err := procNetTimeWait(port); err == nil {
+			return count, nil
+		}
+	}
+
+	if !execx.Available("netstat") {
+		return 0, fmt.Errorf("netstat not found")
+	}
+	output, err := execx.Run(ctx, "netstat", "-an")
+	if err != nil {
+		return 0, err
+	}
+
+	// Local address is "127.0.0.1:3000" on Linux and "127.0.0.1.3000" on macOS/BSD
+	suffixes := []string{fmt.Sprintf(":%d", port), fmt.Sprintf(".%d", port)}
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 || !strings.HasPrefix(fields[0], "tcp") || fields[len(fields)-1] != "TIME_WAIT" {
+			continue
+		}
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(fields[3], suffix) {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// procNetTimeWait counts TIME_WAIT entries for port in /proc/net/tcp and /proc/net/tcp6
+func procNetTimeWait(port int) (int, error) {
+	count := 0
+	read := 0
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		read++
+
+		scanner := bufio.NewScanner(file)
+		scanner.Scan() // header
+		for scanner.Scan() {
+			// sl local_address rem_address st ...; addresses are hex "0100007F:0BB8"
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 4 || fields[3] != tcpStateTimeWait {
+				continue
+			}
+			colon := strings.LastIndex(fields[1], ":")
+			if colon == -1 {
+				continue
+			}
+			if localPort, err := strconv.ParseInt(fields[1][colon+1:], 16, 32); err == nil && int(localPort) == port {
+				count++
+			}
+		}
+		file.Close()
+	}
+	if read == 0 {
+		return 0, fmt.Errorf("/proc/net/tcp not readable")
+	}
+	return count, nil
+}
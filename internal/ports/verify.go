@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/hugoev/zap/internal/execx"
 )
 
 const (
@@ -18,13 +19,39 @@ const (
 	ProcessVerificationMaxRetries = 2
 )
 
+// Strictness levels of the check that a process about to be killed is still the one a
+// scan found (process_verification)
+const (
+	VerifyLenient  = "lenient"  // any of start time (within a minute), working directory or command
+	VerifyNormal   = "normal"   // two of start time (within a second), working directory and command
+	VerifyParanoid = "paranoid" // normal, plus the same start time and executable (path and inode)
+)
+
+// Strictnesses lists the accepted process_verification values
+var Strictnesses = []string{VerifyLenient, VerifyNormal, VerifyParanoid}
+
+// Strictness is the level VerifyProcessMatches applies; set from process_verification
+var Strictness = VerifyNormal
+
+// ParseStrictness checks a process_verification value
+func ParseStrictness(value string) (string, error) {
+	strictness := strings.ToLower(strings.TrimSpace(value))
+	for _, known := range Strictnesses {
+		if strictness == known {
+			return strictness, nil
+		}
+	}
+	return "", fmt.Errorf("unknown strictness %q (must be %s)", value, strings.Join(Strictnesses, ", "))
+}
+
 // VerifyProcessMatches verifies that a process still matches the expected ProcessInfo
 // This prevents PID reuse race conditions where a different process might have taken the PID
 func VerifyProcessMatches(pid int, expected ProcessInfo) (bool, error) {
 	return VerifyProcessMatchesWithContext(context.Background(), pid, expected)
 }
 
-// VerifyProcessMatchesWithContext verifies with a context for timeout control
+// VerifyProcessMatchesWithContext verifies with a context for timeout control, at the
+// level of Strictness
 func VerifyProcessMatchesWithContext(ctx context.Context, pid int, expected ProcessInfo) (bool, error) {
 	if pid <= 0 {
 		return false, fmt.Errorf("invalid PID: %d", pid)
@@ -85,12 +112,17 @@ verificationComplete:
 	// Verify key attributes match with tolerance for legitimate changes
 	// Priority: PID > Working Directory > Start Time > Command
 
-	// 1. Start time should be close (within 1 second tolerance for clock skew)
+	// 1. Start time should be close (within 1 second tolerance for clock skew; a minute
+	// when lenient, for ps versions that only report the start to the minute)
 	// This is the most reliable indicator - if start time matches, it's likely the same process
+	tolerance := time.Second
+	if Strictness == VerifyLenient {
+		tolerance = time.Minute
+	}
 	startTimeMatches := false
 	if !expected.StartTime.IsZero() && !current.StartTime.IsZero() {
 		timeDiff := current.StartTime.Sub(expected.StartTime)
-		if timeDiff >= -1*time.Second && timeDiff <= 1*time.Second {
+		if timeDiff >= -tolerance && timeDiff <= tolerance {
 			startTimeMatches = true
 		}
 	} else if expected.StartTime.IsZero() && current.StartTime.IsZero() {
@@ -139,6 +171,25 @@ verificationComplete:
 		matchCount++
 	}
 
+	// Paranoid: the start time must be known and match, and the process must still run
+	// the very executable the scan saw, before anything else counts
+	if Strictness == VerifyParanoid {
+		if expected.StartTime.IsZero() || current.StartTime.IsZero() || !startTimeMatches {
+			return false, fmt.Errorf("process verification failed: start time doesn't match or is unknown (process_verification is paranoid)")
+		}
+		if expected.Executable == "" || expected.ExeInode == 0 || current.Executable == "" || current.ExeInode == 0 {
+			return false, fmt.Errorf("process verification failed: executable is unknown (process_verification is paranoid)")
+		}
+		if expected.Executable != current.Executable || expected.ExeInode != current.ExeInode {
+			return false, fmt.Errorf("process verification failed: executable changed from %s (inode %d) to %s (inode %d)", expected.Executable, expected.ExeInode, current.Executable, current.ExeInode)
+		}
+	}
+
+	// Lenient: one match is enough
+	if Strictness == VerifyLenient && matchCount >= 1 {
+		return true, nil
+	}
+
 	// Require at least 2 matches, OR working dir + start time (allows command changes)
 	if matchCount >= 2 {
 		return true, nil
@@ -200,8 +251,7 @@ func GetProcessState(pid int) (string, error) {
 		return state, nil
 	} else if runtime.GOOS == "darwin" {
 		// macOS: use ps to get state
-		cmd := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "state=")
-		output, err := cmd.Output()
+		output, err := execx.Run(context.Background(), "ps", "-p", strconv.Itoa(pid), "-o", "state=")
 		if err != nil {
 			return "", fmt.Errorf("failed to get process state: %w", err)
 		}
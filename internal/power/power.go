@@ -0,0 +1,129 @@
+// Package power tells whether now is a bad time for heavy disk work: running on
+// battery, in low-power mode or while the machine is being thermally throttled
+package power
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/execx"
+)
+
+// checkTimeout bounds the pmset calls on macOS
+const checkTimeout = 2 * time.Second
+
+// Constraint returns why heavy work should be deferred ("on battery power",
+// "low power mode", "thermal throttling"), or "" if nothing is known to be in the way.
+// Unknown or unreadable power state never defers anything.
+func Constraint(ctx context.Context) string {
+	switch runtime.GOOS {
+	case "darwin":
+		return darwinConstraint(ctx)
+	case "linux":
+		return linuxConstraint("/sys")
+	}
+	return ""
+}
+
+func darwinConstraint(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	if output, err := execx.Run(ctx, "pmset", "-g", "batt"); err == nil &&
+		strings.Contains(string(output), "'Battery Power'") {
+		return "on battery power"
+	}
+	if output, err := execx.Run(ctx, "pmset", "-g"); err == nil {
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && (fields[0] == "lowpowermode" || fields[0] == "powermode") && fields[1] == "1" {
+				return "low power mode"
+			}
+		}
+	}
+	// "CPU_Speed_Limit = 100" unless the CPU is being throttled
+	if output, err := execx.Run(ctx, "pmset", "-g", "therm"); err == nil {
+		if match := cpuSpeedLimit.FindStringSubmatch(string(output)); match != nil {
+			if limit, err := strconv.Atoi(match[1]); err == nil && limit < 100 {
+				return "thermal throttling (CPU limited to " + match[1] + "%)"
+			}
+		}
+	}
+	return ""
+}
+
+var cpuSpeedLimit = regexp.MustCompile(`CPU_Speed_Limit\s*=\s*(\d+)`)
+
+func linuxConstraint(sysfs string) string {
+	if onBattery(filepath.Join(sysfs, "class", "power_supply")) {
+		return "on battery power"
+	}
+	if profile := readTrimmed(filepath.Join(sysfs, "firmware", "acpi", "platform_profile")); profile == "low-power" || profile == "quiet" {
+		return "low power mode (platform profile " + profile + ")"
+	}
+	if zone := throttledZone(filepath.Join(sysfs, "class", "thermal")); zone != "" {
+		return "thermal throttling (" + zone + " above its passive trip point)"
+	}
+	return ""
+}
+
+// onBattery reports whether the machine has a battery and no mains or USB supply is online
+func onBattery(supplies string) bool {
+	entries, err := os.ReadDir(supplies)
+	if err != nil {
+		return false
+	}
+	hasBattery := false
+	for _, entry := range entries {
+		dir := filepath.Join(supplies, entry.Name())
+		switch readTrimmed(filepath.Join(dir, "type")) {
+		case "Battery":
+			// Peripherals (mice, headsets) report batteries too, scoped to a device
+			if readTrimmed(filepath.Join(dir, "scope")) != "Device" {
+				hasBattery = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if readTrimmed(filepath.Join(dir, "online")) == "1" {
+				return false
+			}
+		}
+	}
+	return hasBattery
+}
+
+// throttledZone returns the type of a thermal zone at or above a passive trip point,
+// where the kernel starts slowing the CPU down
+func throttledZone(thermal string) string {
+	zones, _ := filepath.Glob(filepath.Join(thermal, "thermal_zone*"))
+	for _, zone := range zones {
+		temp, err := strconv.Atoi(readTrimmed(filepath.Join(zone, "temp")))
+		if err != nil || temp <= 0 {
+			continue
+		}
+		trips, _ := filepath.Glob(filepath.Join(zone, "trip_point_*_type"))
+		for _, trip := range trips {
+			if readTrimmed(trip) != "passive" {
+				continue
+			}
+			limit, err := strconv.Atoi(readTrimmed(strings.TrimSuffix(trip, "_type") + "_temp"))
+			if err == nil && limit > 0 && temp >= limit {
+				return readTrimmed(filepath.Join(zone, "type"))
+			}
+		}
+	}
+	return ""
+}
+
+func readTrimmed(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,237 @@
+// Package release finds zap's releases on GitHub and downloads their prebuilt binaries.
+// Every download is checked against the SHA-256 checksums published with the release
+// and, when zap was built with a release key, against the signature of those checksums.
+package release
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hugoev/zap/internal/semver"
+	"github.com/hugoev/zap/internal/version"
+)
+
+// Repo is the GitHub repository zap is released from
+const Repo = "hugoev/zap"
+
+// Names of the release assets next to the binaries
+const (
+	ChecksumsName = "checksums.txt"     // sha256sum output for every binary
+	SignatureName = "checksums.txt.sig" // base64 ed25519 signature of the checksums
+)
+
+// EnvAPI overrides the GitHub API base URL, for mirrors and GitHub Enterprise
+const EnvAPI = "ZAP_RELEASES_API"
+
+// PublicKey is the base64 ed25519 key release checksums are signed with, set at build
+// time with -ldflags "-X github.com/hugoev/zap/internal/release.PublicKey=..."; builds
+// without it check checksums only
+var PublicKey string
+
+// ErrNoBinary means a release has no prebuilt binary for this platform, e.g. because it
+// predates prebuilt binaries
+var ErrNoBinary = errors.New("no prebuilt binary for this platform")
+
+// ChecksumError means a downloaded file doesn't match the release's checksums, or the
+// checksums don't match their signature
+type ChecksumError struct {
+	Name string
+	Err  string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("%s failed verification: %s", e.Name, e.Err)
+}
+
+// Release is a published release
+type Release struct {
+	Tag        string         `json:"tag_name"`
+	Prerelease bool           `json:"prerelease"`
+	Draft      bool           `json:"draft"`
+	Assets     []Asset        `json:"assets"`
+	Version    semver.Version `json:"-"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+	Size int64  `json:"size"`
+}
+
+func apiURL() string {
+	if url := os.Getenv(EnvAPI); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "https://api.github.com"
+}
+
+// List returns the published releases, newest version first, leaving out drafts and
+// tags that aren't versions
+func List(ctx context.Context) ([]Release, error) {
+	body, err := get(ctx, fmt.Sprintf("%s/repos/%s/releases?per_page=100", apiURL(), Repo), "application/vnd.github+json")
+	if err != nil {
+		return nil, err
+	}
+	var all []Release
+	if err := json.Unmarshal(body, &all); err != nil {
+		return nil, fmt.Errorf("unexpected answer from the releases API: %w", err)
+	}
+	var releases []Release
+	for _, release := range all {
+		ver, err := semver.Parse(release.Tag)
+		if release.Draft || err != nil {
+			continue
+		}
+		release.Version = ver
+		releases = append(releases, release)
+	}
+	sort.SliceStable(releases, func(i, j int) bool { return releases[i].Version.Compare(releases[j].Version) > 0 })
+	return releases, nil
+}
+
+// BinaryName is the asset name of the binary of a version for a platform, e.g.
+// zap_1.4.0_linux_amd64 or zap_1.4.0_windows_arm64.exe
+func BinaryName(ver semver.Version, goos, goarch string) string {
+	name := fmt.Sprintf("zap_%s_%s_%s", ver, goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// Asset returns the release's asset with this name
+func (r Release) Asset(name string) (Asset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return Asset{}, false
+}
+
+// DownloadBinary downloads the release's binary for goos/goarch to path (created with
+// mode 0755) and verifies it. It returns ErrNoBinary if there is none, and a
+// *ChecksumError, having removed path, if verification fails.
+func (r Release) DownloadBinary(ctx context.Context, goos, goarch, path string) error {
+	name := BinaryName(r.Version, goos, goarch)
+	binary, ok := r.Asset(name)
+	if !ok {
+		return fmt.Errorf("%w (%s has no %s)", ErrNoBinary, r.Tag, name)
+	}
+	want, err := r.checksum(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	err = download(ctx, binary.URL, io.MultiWriter(file, hash))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("failed to download %s: %w", name, err)
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		os.Remove(path)
+		return &ChecksumError{Name: name, Err: fmt.Sprintf("SHA-256 is %s, the release says %s", got, want)}
+	}
+	return nil
+}
+
+// checksum returns the SHA-256 the release publishes for the asset name, after checking
+// the signature of the checksums if zap has a release key
+func (r Release) checksum(ctx context.Context, name string) (string, error) {
+	asset, ok := r.Asset(ChecksumsName)
+	if !ok {
+		return "", &ChecksumError{Name: name, Err: fmt.Sprintf("%s has no %s, refusing to install an unverified binary", r.Tag, ChecksumsName)}
+	}
+	var checksums bytes.Buffer
+	if err := download(ctx, asset.URL, &checksums); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", ChecksumsName, err)
+	}
+	if PublicKey != "" {
+		if err := r.verifySignature(ctx, checksums.Bytes()); err != nil {
+			return "", err
+		}
+	}
+
+	// sha256sum format: "<hex>  <name>", or "<hex> *<name>" for binary mode
+	scanner := bufio.NewScanner(&checksums)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", &ChecksumError{Name: name, Err: fmt.Sprintf("not listed in %s", ChecksumsName)}
+}
+
+func (r Release) verifySignature(ctx context.Context, checksums []byte) error {
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid release key built into zap")
+	}
+	asset, ok := r.Asset(SignatureName)
+	if !ok {
+		return &ChecksumError{Name: ChecksumsName, Err: fmt.Sprintf("%s has no %s", r.Tag, SignatureName)}
+	}
+	var encoded bytes.Buffer
+	if err := download(ctx, asset.URL, &encoded); err != nil {
+		return fmt.Errorf("failed to download %s: %w", SignatureName, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded.String()))
+	if err != nil || !ed25519.Verify(key, checksums, signature) {
+		return &ChecksumError{Name: ChecksumsName, Err: "signature doesn't match zap's release key"}
+	}
+	return nil
+}
+
+func get(ctx context.Context, url, accept string) ([]byte, error) {
+	var body bytes.Buffer
+	if err := fetch(ctx, url, accept, &body); err != nil {
+		return nil, err
+	}
+	return body.Bytes(), nil
+}
+
+func download(ctx context.Context, url string, w io.Writer) error {
+	return fetch(ctx, url, "application/octet-stream", w)
+}
+
+func fetch(ctx context.Context, url, accept string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", "zap/"+version.Get())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
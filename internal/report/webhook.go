@@ -0,0 +1,109 @@
+// Package report sends cleanup summaries to the report_webhook configured by the user
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"strings"
+	"time"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/testmode"
+	"github.com/hugoev/zap/internal/version"
+)
+
+// webhookTimeout bounds how long a cleanup run can be held up by a slow endpoint
+const webhookTimeout = 10 * time.Second
+
+// CleanupSummary is the outcome of one cleanup run
+type CleanupSummary struct {
+	Host       string    `json:"host"`
+	User       string    `json:"user"`
+	Time       time.Time `json:"time"`
+	Version    string    `json:"version"`
+	Found      int       `json:"found"`
+	Deleted    int       `json:"deleted"`
+	Failed     int       `json:"failed"`
+	Skipped    int       `json:"skipped"`
+	FreedBytes int64     `json:"freed_bytes"`
+}
+
+// NewCleanupSummary fills in the host/user/time fields of a summary
+func NewCleanupSummary() CleanupSummary {
+	host, _ := os.Hostname()
+	username := ""
+	if current, err := user.Current(); err == nil {
+		username = current.Username
+	}
+	return CleanupSummary{
+		Host:    host,
+		User:    username,
+		Time:    time.Now().UTC(),
+		Version: version.Get(),
+	}
+}
+
+// SendCleanup POSTs a cleanup summary to the configured webhook (no-op if none is set)
+func SendCleanup(ctx context.Context, cfg *config.Config, summary CleanupSummary) error {
+	if cfg.ReportWebhook == "" {
+		return nil
+	}
+	if testmode.Enabled() {
+		return testmode.Record(testmode.ActionReport, cfg.ReportWebhook)
+	}
+
+	var payload interface{} = summary
+	if cfg.ReportWebhookFormat == config.ReportFormatSlack {
+		payload = map[string]string{"text": slackText(summary)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.ReportWebhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "zap/"+summary.Version)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+// slackText renders a summary as a one-line Slack message
+func slackText(summary CleanupSummary) string {
+	text := fmt.Sprintf("zap cleanup on %s (%s): freed %s, deleted %d of %d directories",
+		summary.Host, summary.User, cleanup.FormatSize(summary.FreedBytes), summary.Deleted, summary.Found)
+	var notes []string
+	if summary.Failed > 0 {
+		notes = append(notes, fmt.Sprintf("%d failed", summary.Failed))
+	}
+	if summary.Skipped > 0 {
+		notes = append(notes, fmt.Sprintf("%d skipped", summary.Skipped))
+	}
+	if len(notes) > 0 {
+		text += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return text
+}
@@ -0,0 +1,177 @@
+// Package semver parses and orders zap's release versions following Semantic Versioning
+// 2.0.0, including pre-release ("0.5.0-rc.1") and build metadata ("0.5.0+abc123")
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed semantic version
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string // dot-separated identifiers after "-", e.g. ["rc", "1"]
+	Build      string   // metadata after "+", ignored when comparing
+}
+
+// pattern finds a version inside other text, such as the output of "zap version"
+var pattern = regexp.MustCompile(`\d+\.\d+\.\d+(?:-[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?`)
+
+// Parse parses a version such as "0.3.0", "v0.3.0", "0.5.0-rc.1+abc123" or, for
+// compatibility with older tags, "4.1" (read as "4.1.0")
+func Parse(s string) (Version, error) {
+	v := strings.TrimPrefix(strings.TrimSpace(s), "v")
+
+	var version Version
+	if core, build, ok := strings.Cut(v, "+"); ok {
+		if !validIdentifiers(build, false) {
+			return Version{}, fmt.Errorf("invalid build metadata in version %s", s)
+		}
+		v, version.Build = core, build
+	}
+	if core, pre, ok := strings.Cut(v, "-"); ok {
+		if !validIdentifiers(pre, true) {
+			return Version{}, fmt.Errorf("invalid pre-release in version %s", s)
+		}
+		v, version.Prerelease = core, strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(v, ".")
+	if len(parts) == 2 {
+		parts = append(parts, "0")
+	}
+	if len(parts) != 3 {
+		return Version{}, fmt.Errorf("invalid version format: %s (expected MAJOR.MINOR.PATCH or MAJOR.MINOR)", s)
+	}
+
+	for i, name := range []string{"major", "minor", "patch"} {
+		n, err := strconv.Atoi(parts[i])
+		if err != nil || n < 0 {
+			return Version{}, fmt.Errorf("invalid %s version: %s", name, parts[i])
+		}
+		switch i {
+		case 0:
+			version.Major = n
+		case 1:
+			version.Minor = n
+		case 2:
+			version.Patch = n
+		}
+	}
+	return version, nil
+}
+
+// validIdentifiers checks dot-separated identifiers: non-empty, alphanumerics and
+// hyphens, and for pre-releases no leading zeros on numeric identifiers
+func validIdentifiers(s string, prerelease bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		for _, r := range id {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+		if prerelease && numeric(id) && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract finds the first version in text, e.g. "0.3.0" in "zap version 0.3.0"
+func Extract(text string) (string, error) {
+	match := pattern.FindString(text)
+	if match == "" {
+		return "", fmt.Errorf("could not extract version from: %s", text)
+	}
+	return match, nil
+}
+
+// Compare returns -1 if v < other, 0 if v == other, 1 if v > other. A pre-release is
+// older than its release, and build metadata doesn't count.
+func (v Version) Compare(other Version) int {
+	if c := compareInt(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInt(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+
+	switch {
+	case len(v.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		if c := compareIdentifier(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInt(len(v.Prerelease), len(other.Prerelease))
+}
+
+// IsPrerelease reports whether v is a pre-release such as "0.5.0-rc.1"
+func (v Version) IsPrerelease() bool {
+	return len(v.Prerelease) > 0
+}
+
+// String returns the version without a "v" prefix, e.g. "0.5.0-rc.1+abc123"
+func (v Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// compareIdentifier orders pre-release identifiers: numeric ones numerically and below
+// alphanumeric ones, which are compared in ASCII order
+func compareIdentifier(a, b string) int {
+	aNum, bNum := numeric(a), numeric(b)
+	switch {
+	case aNum && bNum:
+		if c := compareInt(len(a), len(b)); c != 0 {
+			return c // no leading zeros, so longer is larger
+		}
+		return strings.Compare(a, b)
+	case aNum:
+		return -1
+	case bNum:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func numeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
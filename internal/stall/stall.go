@@ -0,0 +1,144 @@
+// Package stall notices work that stops making progress: a port lookup stuck on a dead
+// network mount, a deletion hanging on NFS. Work is tracked per target through its
+// context; a Watchdog reports targets that have made no progress for a while and can
+// cancel them, so one stuck target doesn't freeze a whole command until its global timeout.
+package stall
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrSkipped is the cancellation cause of a target skipped after stalling; check it with
+// errors.Is(context.Cause(ctx), ErrSkipped)
+var ErrSkipped = errors.New("skipped after stalling")
+
+// Watchdog watches the targets tracked under its context
+type Watchdog struct {
+	threshold time.Duration
+	// onStall is called, one target at a time, when a target has made no progress for
+	// threshold; returning true skips (cancels) it
+	onStall func(target string, idle time.Duration) bool
+
+	mu    sync.Mutex
+	tasks map[*Task]struct{}
+}
+
+// Task is one tracked target
+type Task struct {
+	target   string
+	cancel   context.CancelCauseFunc
+	watchdog *Watchdog
+	last     time.Time // guarded by watchdog.mu, as are at and reported
+	at       string    // where the work last got to, e.g. the file being removed
+	reported bool      // onStall was called since the last progress
+}
+
+type watchdogKey struct{}
+type taskKey struct{}
+
+// New creates a watchdog that calls onStall for targets idle for threshold
+func New(threshold time.Duration, onStall func(target string, idle time.Duration) bool) *Watchdog {
+	return &Watchdog{threshold: threshold, onStall: onStall, tasks: make(map[*Task]struct{})}
+}
+
+// WithWatchdog returns a context under which Track reports to w
+func WithWatchdog(ctx context.Context, w *Watchdog) context.Context {
+	return context.WithValue(ctx, watchdogKey{}, w)
+}
+
+// Run checks the tracked targets until ctx is done. A target is reported once per stall,
+// as "<target> (at <where it got to>)": it is reported again only after it made
+// progress and stalled anew.
+func (w *Watchdog) Run(ctx context.Context) {
+	interval := w.threshold / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, task := range w.stalled() {
+			if ctx.Err() != nil {
+				return
+			}
+			description, idle := task.state()
+			if w.onStall(description, idle) {
+				task.cancel(ErrSkipped)
+			}
+		}
+	}
+}
+
+// stalled returns the tasks idle for threshold that weren't reported yet, marking them reported
+func (w *Watchdog) stalled() []*Task {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var stalled []*Task
+	for task := range w.tasks {
+		if !task.reported && time.Since(task.last) >= w.threshold {
+			task.reported = true
+			stalled = append(stalled, task)
+		}
+	}
+	return stalled
+}
+
+// Track starts tracking target under ctx. The returned context is cancelled with cause
+// ErrSkipped if the target is skipped; call Done when the work ends. Without a watchdog
+// in ctx, Track returns ctx and a nil Task, whose methods do nothing.
+func Track(ctx context.Context, target string) (context.Context, *Task) {
+	w, ok := ctx.Value(watchdogKey{}).(*Watchdog)
+	if !ok {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	task := &Task{target: target, cancel: cancel, watchdog: w, last: time.Now()}
+	w.mu.Lock()
+	w.tasks[task] = struct{}{}
+	w.mu.Unlock()
+	return context.WithValue(ctx, taskKey{}, task), task
+}
+
+// Done stops tracking the task
+func (t *Task) Done() {
+	if t == nil {
+		return
+	}
+	t.watchdog.mu.Lock()
+	delete(t.watchdog.tasks, t)
+	t.watchdog.mu.Unlock()
+	t.cancel(nil)
+}
+
+// state describes the task and how long it has been idle
+func (t *Task) state() (string, time.Duration) {
+	t.watchdog.mu.Lock()
+	defer t.watchdog.mu.Unlock()
+	description := t.target
+	if t.at != "" {
+		description += " (at " + t.at + ")"
+	}
+	return description, time.Since(t.last)
+}
+
+// Progress notes that the target tracked in ctx moved forward to at, e.g. a directory
+// entered or a file removed; it does nothing for untracked work
+func Progress(ctx context.Context, at string) {
+	task, ok := ctx.Value(taskKey{}).(*Task)
+	if !ok {
+		return
+	}
+	task.watchdog.mu.Lock()
+	task.last = time.Now()
+	task.at = at
+	task.reported = false
+	task.watchdog.mu.Unlock()
+}
@@ -0,0 +1,68 @@
+package state
+
+import "time"
+
+// DryRun is the candidate set of a `zap cleanup --dry-run`, kept for --compare
+type DryRun struct {
+	Time       time.Time   `json:"time"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// Candidate is a directory a dry run would have deleted
+type Candidate struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size_bytes"`
+	Pattern string `json:"pattern"`
+}
+
+// SaveDryRun replaces the remembered dry run
+func SaveDryRun(run DryRun) error {
+	return Update(func(st *State) {
+		st.LastDryRun = &run
+	})
+}
+
+// DryRunDiff is how the candidate set changed between two dry runs
+type DryRunDiff struct {
+	Added   []Candidate
+	Removed []Candidate
+}
+
+// Empty reports whether both runs proposed the same directories
+func (d DryRunDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0
+}
+
+// CompareDryRuns reports directories that became or stopped being candidates
+func CompareDryRuns(previous, current DryRun) DryRunDiff {
+	before := make(map[string]bool)
+	for _, c := range previous.Candidates {
+		before[c.Path] = true
+	}
+	after := make(map[string]bool)
+	for _, c := range current.Candidates {
+		after[c.Path] = true
+	}
+
+	var diff DryRunDiff
+	for _, c := range current.Candidates {
+		if !before[c.Path] {
+			diff.Added = append(diff.Added, c)
+		}
+	}
+	for _, c := range previous.Candidates {
+		if !after[c.Path] {
+			diff.Removed = append(diff.Removed, c)
+		}
+	}
+	return diff
+}
+
+// SizeOf sums the sizes of candidates
+func SizeOf(candidates []Candidate) int64 {
+	var total int64
+	for _, c := range candidates {
+		total += c.Size
+	}
+	return total
+}
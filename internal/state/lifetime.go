@@ -0,0 +1,63 @@
+package state
+
+import "github.com/hugoev/zap/internal/testmode"
+
+// milestones are lifetime reclaimed-space totals worth celebrating
+var milestones = []int64{
+	1 << 30,   // 1 GB
+	10 << 30,  // 10 GB
+	50 << 30,  // 50 GB
+	100 << 30, // 100 GB
+	500 << 30, // 500 GB
+	1 << 40,   // 1 TB
+	5 << 40,   // 5 TB
+}
+
+// RecordCleanup adds a cleanup run to the lifetime counters and returns the
+// milestone crossed by it, if any
+func RecordCleanup(deleted int, freedBytes int64) (int64, bool, error) {
+	var crossed int64
+	err := Update(func(st *State) {
+		st.Lifetime.start()
+		before := st.Lifetime.BytesReclaimed
+		st.Lifetime.BytesReclaimed += freedBytes
+		st.Lifetime.DirectoriesDeleted += deleted
+		crossed = crossedMilestone(before, st.Lifetime.BytesReclaimed)
+	})
+	return crossed, crossed > 0, err
+}
+
+// RecordKills adds terminated processes to the lifetime counters
+func RecordKills(killed int) error {
+	return Update(func(st *State) {
+		st.Lifetime.start()
+		st.Lifetime.ProcessesKilled += killed
+	})
+}
+
+// NextMilestone returns the next reclaimed-space milestone above total (0 past the last one)
+func NextMilestone(total int64) int64 {
+	for _, milestone := range milestones {
+		if total < milestone {
+			return milestone
+		}
+	}
+	return 0
+}
+
+func (l *Lifetime) start() {
+	if l.Since.IsZero() {
+		l.Since = testmode.Now()
+	}
+}
+
+// crossedMilestone returns the highest milestone in (before, after]
+func crossedMilestone(before, after int64) int64 {
+	var crossed int64
+	for _, milestone := range milestones {
+		if before < milestone && after >= milestone {
+			crossed = milestone
+		}
+	}
+	return crossed
+}
@@ -0,0 +1,90 @@
+package state
+
+import "time"
+
+// PortScan is the result of a `zap ports` scan, kept for `zap ports --diff`
+type PortScan struct {
+	Time      time.Time  `json:"time"`
+	Ports     []int      `json:"ports"`
+	Listeners []Listener `json:"listeners"`
+}
+
+// Listener is a process listening on a scanned port
+type Listener struct {
+	Port       int    `json:"port"`
+	PID        int    `json:"pid"`
+	Name       string `json:"name"`
+	Cmd        string `json:"cmd,omitempty"`
+	WorkingDir string `json:"working_dir,omitempty"`
+}
+
+// SavePortScan replaces the remembered port scan
+func SavePortScan(scan PortScan) error {
+	return Update(func(st *State) {
+		st.LastScan = &scan
+	})
+}
+
+// PortScanDiff is what changed between two port scans
+type PortScanDiff struct {
+	Added   []Listener
+	Removed []Listener
+	Changed [][2]Listener // same port, different PID: {before, after}
+}
+
+// Empty reports whether nothing changed
+func (d PortScanDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares two scans. Ports that only one of the scans checked are ignored,
+// so scanning a different --ports range doesn't report everything as gone.
+func Diff(previous, current PortScan) PortScanDiff {
+	checkedBoth := make(map[int]bool)
+	inPrevious := make(map[int]bool)
+	for _, port := range previous.Ports {
+		inPrevious[port] = true
+	}
+	for _, port := range current.Ports {
+		if inPrevious[port] {
+			checkedBoth[port] = true
+		}
+	}
+
+	before := listenersByPort(previous.Listeners)
+	after := listenersByPort(current.Listeners)
+
+	var diff PortScanDiff
+	for _, listener := range current.Listeners {
+		if !checkedBoth[listener.Port] || after[listener.Port].PID != listener.PID {
+			continue
+		}
+		old, existed := before[listener.Port]
+		switch {
+		case !existed:
+			diff.Added = append(diff.Added, listener)
+		case old.PID != listener.PID:
+			diff.Changed = append(diff.Changed, [2]Listener{old, listener})
+		}
+	}
+	for _, listener := range previous.Listeners {
+		if !checkedBoth[listener.Port] || before[listener.Port].PID != listener.PID {
+			continue
+		}
+		if _, stillThere := after[listener.Port]; !stillThere {
+			diff.Removed = append(diff.Removed, listener)
+		}
+	}
+	return diff
+}
+
+// listenersByPort keys listeners by port, keeping the first one seen per port
+func listenersByPort(listeners []Listener) map[int]Listener {
+	byPort := make(map[int]Listener)
+	for _, listener := range listeners {
+		if _, ok := byPort[listener.Port]; !ok {
+			byPort[listener.Port] = listener
+		}
+	}
+	return byPort
+}
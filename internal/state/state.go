@@ -0,0 +1,117 @@
+// Package state persists zap's own bookkeeping between runs in state.json
+// (unlike config.json, nothing in here is meant to be edited by the user)
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hugoev/zap/internal/paths"
+)
+
+// State is everything zap remembers between runs
+type State struct {
+	Lifetime   Lifetime  `json:"lifetime"`
+	LastScan   *PortScan `json:"last_port_scan,omitempty"`
+	LastDryRun *DryRun   `json:"last_cleanup_dry_run,omitempty"`
+	Tools      *Tools    `json:"tools,omitempty"`
+	// UpdateCheck is only kept with update_check on
+	UpdateCheck *UpdateCheck `json:"update_check,omitempty"`
+	// Workspaces are the project trees cleanup has found candidates in, and whether it
+	// may delete in them
+	Workspaces []Workspace `json:"workspaces,omitempty"`
+	// TrustSince is when cleanup started keeping track of trust; zero before the first
+	// cleanup that did
+	TrustSince time.Time `json:"trust_since,omitempty"`
+}
+
+// Lifetime holds cumulative counters across all of zap's runs
+type Lifetime struct {
+	Since              time.Time `json:"since"`
+	BytesReclaimed     int64     `json:"bytes_reclaimed"`
+	DirectoriesDeleted int       `json:"directories_deleted"`
+	ProcessesKilled    int       `json:"processes_killed"`
+}
+
+// stateMutex serializes read-modify-write cycles within one process
+// (the instance lock keeps other zap processes out)
+var stateMutex sync.Mutex
+
+func getStatePath() (string, error) {
+	return paths.File("state.json")
+}
+
+// Load reads the saved state; a missing or unreadable file yields an empty state
+func Load() (*State, error) {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+	return load()
+}
+
+// Update applies fn to the saved state and writes the result back atomically
+func Update(fn func(*State)) error {
+	stateMutex.Lock()
+	defer stateMutex.Unlock()
+
+	st, err := load()
+	if err != nil {
+		return err
+	}
+	fn(st)
+	return save(st)
+}
+
+func load() (*State, error) {
+	statePath, err := getStatePath()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &State{}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		// State is only bookkeeping - start over rather than fail the run
+		return &State{}, nil
+	}
+	return st, nil
+}
+
+func save(st *State) error {
+	statePath, err := getStatePath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(statePath), ".state-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	tempPath := tempFile.Name()
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	tempFile.Close()
+
+	if err := os.Rename(tempPath, statePath); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package state
+
+import (
+	"os"
+	"time"
+)
+
+// ToolsMaxAge is how long a tool detection is trusted; a tool installed since is
+// noticed after this at the latest (or right away by `zap doctor`)
+const ToolsMaxAge = 24 * time.Hour
+
+// Tools records which external tools were found for a user and PATH, so runs don't
+// search PATH for each of them again
+type Tools struct {
+	Time  time.Time         `json:"time"`
+	PATH  string            `json:"path"`
+	UID   int               `json:"uid"`
+	Paths map[string]string `json:"paths"` // tool → resolved path, "" when not installed
+	// NeedsSudo is set when scans only see the user's own processes: listeners of other
+	// users are found (the port is in use) but not who owns them
+	NeedsSudo bool `json:"needs_sudo"`
+}
+
+// Fresh reports whether the detection still applies to a run as uid with PATH path:
+// it is recent, and every tool found is still there
+func (t *Tools) Fresh(path string, uid int) bool {
+	if t == nil || t.PATH != path || t.UID != uid || time.Since(t.Time) > ToolsMaxAge {
+		return false
+	}
+	for _, resolved := range t.Paths {
+		if resolved == "" {
+			continue
+		}
+		if _, err := os.Stat(resolved); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveTools replaces the remembered tool detection
+func SaveTools(tools Tools) error {
+	return Update(func(st *State) {
+		st.Tools = &tools
+	})
+}
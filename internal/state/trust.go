@@ -0,0 +1,79 @@
+package state
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Workspace is a project tree zap cleanup has found candidates in, or a tree trusted
+// with zap trust. Cleanup only deletes in trusted trees; in others, e.g. a repository
+// cloned since or an external drive, it reports what it found.
+type Workspace struct {
+	Path      string    `json:"path"`
+	FirstSeen time.Time `json:"first_seen"`
+	Trusted   bool      `json:"trusted"`
+}
+
+// Trusted reports whether path is inside a trusted workspace
+func (s *State) Trusted(path string) bool {
+	for _, workspace := range s.Workspaces {
+		if workspace.Trusted && within(workspace.Path, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Workspace returns the workspace recorded for exactly path
+func (s *State) Workspace(path string) (Workspace, bool) {
+	for _, workspace := range s.Workspaces {
+		if workspace.Path == path {
+			return workspace, true
+		}
+	}
+	return Workspace{}, false
+}
+
+// SeeWorkspaces records the trees a cleanup found that aren't known yet, trusting those
+// trust approves, and starts keeping track of trust if it hasn't yet. It returns the
+// state as saved.
+func SeeWorkspaces(paths []string, now time.Time, trust func(path string) bool) (*State, error) {
+	var saved *State
+	err := Update(func(st *State) {
+		if st.TrustSince.IsZero() {
+			st.TrustSince = now
+		}
+		for _, path := range paths {
+			if _, ok := st.Workspace(path); ok {
+				continue
+			}
+			st.Workspaces = append(st.Workspaces, Workspace{Path: path, FirstSeen: now, Trusted: trust(path)})
+		}
+		saved = st
+	})
+	return saved, err
+}
+
+// SetTrusted marks the trees at paths trusted or untrusted, recording them if needed
+func SetTrusted(paths []string, trusted bool, now time.Time) error {
+	return Update(func(st *State) {
+		for _, path := range paths {
+			found := false
+			for i := range st.Workspaces {
+				if st.Workspaces[i].Path == path {
+					st.Workspaces[i].Trusted, found = trusted, true
+				}
+			}
+			if !found {
+				st.Workspaces = append(st.Workspaces, Workspace{Path: path, FirstSeen: now, Trusted: trusted})
+			}
+		}
+	})
+}
+
+// within reports whether path is dir or inside it
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
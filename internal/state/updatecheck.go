@@ -0,0 +1,24 @@
+package state
+
+import "time"
+
+// UpdateCheckInterval is how often update_check asks for a newer release
+const UpdateCheckInterval = 24 * time.Hour
+
+// UpdateCheck is the outcome of the last look for a newer release (update_check)
+type UpdateCheck struct {
+	Time   time.Time `json:"time"`
+	Latest string    `json:"latest"` // newest stable release, "" if none is known
+}
+
+// Due reports whether it is time to look again
+func (c *UpdateCheck) Due() bool {
+	return c == nil || time.Since(c.Time) > UpdateCheckInterval
+}
+
+// SaveUpdateCheck replaces the remembered update check
+func SaveUpdateCheck(check UpdateCheck) error {
+	return Update(func(st *State) {
+		st.UpdateCheck = &check
+	})
+}
@@ -0,0 +1,236 @@
+// Package summary is the outcome of a zap command: what it counted and freed, how long it
+// took, what went wrong and every action it took. Commands fill in a Summary as they go
+// and print it once, the same way for all of them: a STATS line, a JSON object (also
+// embedded in the --json output of ports and cleanup), or NDJSON lines.
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/log"
+	"github.com/hugoev/zap/internal/testmode"
+)
+
+// Formats a Summary is printed in
+const (
+	FormatText   = "text"   // one STATS line
+	FormatJSON   = "json"   // one JSON object on stdout
+	FormatNDJSON = "ndjson" // one JSON line per action, then one for the summary, told apart by "event"
+)
+
+// unitBytes marks a stat that is a size, shown as e.g. "freed 1.5 GiB"
+const unitBytes = "bytes"
+
+// stat is a named number of a Summary, in the order it was added
+type stat struct {
+	name     string
+	value    int64
+	unit     string // noun for counts ("process(es)"), or unitBytes
+	hideZero bool   // left out of the STATS line when zero
+}
+
+// Action is something a command did to a process or directory
+type Action struct {
+	Action string `json:"action"`
+	Target string `json:"target"`
+	Result string `json:"result"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Summary is the outcome of a command run
+type Summary struct {
+	Command string
+	DryRun  bool
+	Errors  []string
+	Actions []Action
+
+	stats    []stat
+	started  time.Time
+	finished time.Time
+	mu       sync.Mutex // guards Actions, which may be added from several goroutines
+}
+
+// New starts the summary of a command; its duration runs until Finish
+func New(command string, dryRun bool) *Summary {
+	return &Summary{Command: command, DryRun: dryRun, started: testmode.Now()}
+}
+
+// Count sets a count, e.g. Count("terminated", 2, "process(es)")
+func (s *Summary) Count(name string, value int, noun string) {
+	s.set(stat{name: name, value: int64(value), unit: noun})
+}
+
+// CountIfAny sets a count that only shows in the STATS line when non-zero, e.g. failures
+func (s *Summary) CountIfAny(name string, value int, noun string) {
+	s.set(stat{name: name, value: int64(value), unit: noun, hideZero: true})
+}
+
+// Bytes sets a size, e.g. Bytes("freed", 1<<30)
+func (s *Summary) Bytes(name string, value int64) {
+	s.set(stat{name: name, value: value, unit: unitBytes})
+}
+
+func (s *Summary) set(st stat) {
+	for i := range s.stats {
+		if s.stats[i].name == st.name {
+			s.stats[i] = st
+			return
+		}
+	}
+	s.stats = append(s.stats, st)
+}
+
+// Get returns a count or size, 0 if it was never set
+func (s *Summary) Get(name string) int64 {
+	for _, st := range s.stats {
+		if st.name == name {
+			return st.value
+		}
+	}
+	return 0
+}
+
+// AddAction records something the command did
+func (s *Summary) AddAction(action Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Actions = append(s.Actions, action)
+}
+
+// AddError records a problem that makes the outcome incomplete
+func (s *Summary) AddError(err string) {
+	s.Errors = append(s.Errors, err)
+}
+
+// Finish stops the duration; printing finishes the summary if it wasn't already
+func (s *Summary) Finish() {
+	if s.finished.IsZero() {
+		s.finished = testmode.Now()
+	}
+}
+
+// Duration is how long the command ran, up to Finish
+func (s *Summary) Duration() time.Duration {
+	if s.finished.IsZero() {
+		return testmode.Since(s.started)
+	}
+	return s.finished.Sub(s.started)
+}
+
+// Text is the STATS line, e.g. "terminated 2 process(es), skipped 1 in 1.2s"; underscores
+// in names read as spaces ("would_terminate")
+func (s *Summary) Text() string {
+	var parts []string
+	for _, st := range s.stats {
+		if st.hideZero && st.value == 0 {
+			continue
+		}
+		name := strings.ReplaceAll(st.name, "_", " ")
+		if st.unit == unitBytes {
+			parts = append(parts, fmt.Sprintf("%s %s", name, cleanup.FormatSize(st.value)))
+		} else {
+			parts = append(parts, strings.TrimSpace(fmt.Sprintf("%s %d %s", name, st.value, st.unit)))
+		}
+	}
+	text := strings.Join(parts, ", ")
+	// Sub-second durations are noise next to the counts
+	if duration := s.Duration(); duration >= time.Second {
+		text += fmt.Sprintf(" in %v", duration.Round(100*time.Millisecond))
+	}
+	if len(s.Errors) > 0 {
+		text += fmt.Sprintf(" (%d error(s))", len(s.Errors))
+	}
+	return text
+}
+
+// MarshalJSON encodes the summary as
+// {"command", "dry_run", "counts": {...}, "bytes": {...}, "duration_ms", "errors", "actions"}
+// with counts and sizes keyed by name
+func (s *Summary) MarshalJSON() ([]byte, error) {
+	counts := newOrderedMap()
+	sizes := newOrderedMap()
+	for _, st := range s.stats {
+		if st.unit == unitBytes {
+			sizes.set(st.name, st.value)
+		} else {
+			counts.set(st.name, st.value)
+		}
+	}
+	errs, actions := s.Errors, s.Actions
+	if errs == nil {
+		errs = []string{}
+	}
+	if actions == nil {
+		actions = []Action{}
+	}
+	return json.Marshal(struct {
+		Command    string      `json:"command"`
+		DryRun     bool        `json:"dry_run"`
+		Counts     *orderedMap `json:"counts"`
+		Bytes      *orderedMap `json:"bytes"`
+		DurationMS int64       `json:"duration_ms"`
+		Errors     []string    `json:"errors"`
+		Actions    []Action    `json:"actions"`
+	}{s.Command, s.DryRun, counts, sizes, s.Duration().Milliseconds(), errs, actions})
+}
+
+// Print finishes the summary and prints it in format: FormatText logs the STATS line,
+// FormatJSON and FormatNDJSON write to stdout
+func (s *Summary) Print(format string) {
+	s.Finish()
+	switch format {
+	case FormatJSON:
+		data, _ := json.Marshal(s)
+		fmt.Println(string(data))
+	case FormatNDJSON:
+		for _, action := range s.Actions {
+			data, _ := json.Marshal(struct {
+				Event string `json:"event"`
+				Action
+			}{"action", action})
+			fmt.Println(string(data))
+		}
+		data, _ := json.Marshal(s)
+		fmt.Printf("{\"event\":\"summary\",%s\n", data[1:])
+	default:
+		log.Log(log.STATS, "%s", s.Text())
+	}
+}
+
+// orderedMap is a JSON object that keeps the order its keys were set in
+type orderedMap struct {
+	keys   []string
+	values map[string]int64
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]int64)}
+}
+
+func (m *orderedMap) set(key string, value int64) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		b.Write(name)
+		fmt.Fprintf(&b, ":%d", m.values[key])
+	}
+	b.WriteByte('}')
+	return []byte(b.String()), nil
+}
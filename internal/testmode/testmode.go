@@ -0,0 +1,167 @@
+// Package testmode is ZAP_TEST_MODE: zap runs against a sandbox directory instead of
+// the machine, so end-to-end tests can drive the real commands deterministically. In
+// test mode:
+//
+//   - config, state, lock and journal live in <sandbox>/zap, and the home directory
+//     cleanup scans is <sandbox>/home
+//   - the clock is frozen at ZAP_TEST_NOW (default 2024-01-01T00:00:00Z)
+//   - listening processes are read from <sandbox>/listeners.json instead of the system
+//   - kills, container stops, deletions, moves to and from the trash and webhook
+//     reports are appended to <sandbox>/actions.jsonl instead of being carried out
+package testmode
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EnvTestMode enables test mode; its value is the sandbox directory
+const EnvTestMode = "ZAP_TEST_MODE"
+
+// EnvNow sets the frozen clock, in RFC 3339
+const EnvNow = "ZAP_TEST_NOW"
+
+// defaultNow is the frozen clock when EnvNow isn't set
+var defaultNow = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Actions recorded in actions.jsonl
+const (
+	ActionKill    = "kill"
+	ActionStop    = "docker_stop"
+	ActionDelete  = "delete"
+	ActionReport  = "report"
+	ActionTrash   = "trash"
+	ActionRestore = "restore"
+)
+
+// Listener is an entry of listeners.json: a process listening on a port
+type Listener struct {
+	PID         int       `json:"pid"`
+	Port        int       `json:"port"`
+	Protocol    string    `json:"protocol,omitempty"` // "tcp" (default) or "udp"
+	Name        string    `json:"name"`
+	Cmd         string    `json:"cmd,omitempty"`
+	User        string    `json:"user,omitempty"`
+	WorkingDir  string    `json:"working_dir,omitempty"`
+	Executable  string    `json:"executable,omitempty"`
+	Arch        string    `json:"arch,omitempty"`
+	Emulated    bool      `json:"emulated,omitempty"`
+	BindAddress string    `json:"bind_address,omitempty"` // default "*"
+	StartTime   time.Time `json:"start_time,omitempty"`
+}
+
+// action is a line of actions.jsonl
+type action struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Target string    `json:"target"`
+}
+
+var (
+	nowOnce sync.Once
+	now     time.Time
+
+	// done holds the actions recorded by this run, so a killed process stops running
+	// and a deleted directory is gone for the rest of it
+	doneMutex sync.Mutex
+	done      = make(map[string]bool)
+)
+
+// Enabled reports whether zap runs in test mode
+func Enabled() bool {
+	return os.Getenv(EnvTestMode) != ""
+}
+
+// Dir returns the sandbox directory, made absolute
+func Dir() string {
+	dir := os.Getenv(EnvTestMode)
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return dir
+}
+
+// HomeDir returns the home directory used in test mode
+func HomeDir() string {
+	return filepath.Join(Dir(), "home")
+}
+
+// BaseDir returns the directory zap keeps its files in during test mode
+func BaseDir() string {
+	return filepath.Join(Dir(), "zap")
+}
+
+// Now returns the current time, or the frozen clock in test mode
+func Now() time.Time {
+	if !Enabled() {
+		return time.Now()
+	}
+	nowOnce.Do(func() {
+		now = defaultNow
+		if value := os.Getenv(EnvNow); value != "" {
+			if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+				now = parsed
+			}
+		}
+	})
+	return now
+}
+
+// Since is time.Since measured against Now
+func Since(t time.Time) time.Duration {
+	return Now().Sub(t)
+}
+
+// Listeners reads listeners.json; a missing file means nothing is listening
+func Listeners() ([]Listener, error) {
+	data, err := os.ReadFile(filepath.Join(Dir(), "listeners.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var listeners []Listener
+	if err := json.Unmarshal(data, &listeners); err != nil {
+		return nil, fmt.Errorf("invalid listeners.json: %w", err)
+	}
+	for i := range listeners {
+		if listeners[i].Protocol == "" {
+			listeners[i].Protocol = "tcp"
+		}
+		if listeners[i].BindAddress == "" {
+			listeners[i].BindAddress = "*"
+		}
+	}
+	return listeners, nil
+}
+
+// Record appends a destructive action to actions.jsonl in place of carrying it out
+func Record(actionName, target string) error {
+	doneMutex.Lock()
+	defer doneMutex.Unlock()
+	done[actionName+" "+target] = true
+
+	data, err := json.Marshal(action{Time: Now(), Action: actionName, Target: target})
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(Dir(), "actions.jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open test mode actions: %w", err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Done reports whether this run recorded actionName on target
+func Done(actionName, target string) bool {
+	doneMutex.Lock()
+	defer doneMutex.Unlock()
+	return done[actionName+" "+target]
+}
@@ -1,11 +1,14 @@
 package version
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+)
 
 // Version is the current version of zap.
 // This is set at build time using -ldflags from git tags.
 // Defaults to "dev" for development builds.
-// Format: MAJOR.MINOR.PATCH (semantic versioning)
+// Format: MAJOR.MINOR.PATCH[-PRERELEASE] (semantic versioning, see internal/semver)
 var Version = "dev"
 
 // Commit is the git commit hash (set at build time).
@@ -15,21 +18,19 @@ var Commit = "unknown"
 var Date = "unknown"
 
 // Get returns the current version string.
-// Cleans up git describe output (removes "v" prefix and commit info).
+// Cleans up git describe output (removes "v" prefix and commit info), keeping any
+// pre-release such as "-rc.1".
 func Get() string {
-	// If version contains git describe format (e.g., "v0.3.0-14-g045e86a"), extract just the version
-	if strings.Contains(Version, "-") {
-		// Extract version part before the first dash
-		parts := strings.Split(Version, "-")
-		versionPart := parts[0]
-		// Remove "v" prefix if present
-		versionPart = strings.TrimPrefix(versionPart, "v")
-		return versionPart
-	}
+	// If version contains git describe format (e.g., "v0.3.0-14-g045e86a"), drop the commit info
+	v := describeSuffix.ReplaceAllString(Version, "")
 	// Remove "v" prefix if present
-	return strings.TrimPrefix(Version, "v")
+	return strings.TrimPrefix(v, "v")
 }
 
+// describeSuffix matches what git describe appends to a tag: commits since the tag,
+// the abbreviated hash and an optional dirty marker
+var describeSuffix = regexp.MustCompile(`-\d+-g[0-9a-f]+(-dirty)?$|-dirty$`)
+
 // GetFull returns the full version string including commit and date.
 func GetFull() string {
 	if Version == "dev" {
@@ -0,0 +1,187 @@
+// Package cleanup finds stale dependency and build directories (node_modules, .venv,
+// target, ...) and deletes them, the way `zap cleanup` does, for tools that embed zap
+// instead of running the CLI. Functions never print, prompt or exit: results and errors
+// are returned to the caller, and every call that may block honours its context.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/hugoev/zap/internal/age"
+	"github.com/hugoev/zap/internal/cleanup"
+	"github.com/hugoev/zap/internal/config"
+	"github.com/hugoev/zap/internal/paths"
+)
+
+// DefaultMaxAge is how long a directory must go unmodified to be stale when
+// ScanOptions.MaxAge is zero
+const DefaultMaxAge = 14 * 24 * time.Hour
+
+// Directory is a stale directory found by Scan
+type Directory struct {
+	Path string `json:"path"`
+	// Size is the disk usage, which is what deleting the directory frees
+	Size int64 `json:"size_bytes"`
+	// ApparentSize is the sum of file lengths, as reported by ls
+	ApparentSize int64     `json:"apparent_size_bytes"`
+	ModTime      time.Time `json:"mod_time"`
+	// Pattern is the cleanup pattern (or category rule) the directory matched
+	Pattern string `json:"pattern"`
+	// Category is set for directories found through ScanOptions.Categories
+	Category string `json:"category,omitempty"`
+}
+
+// Progress is a step of a scan, for callers that show one
+type Progress = cleanup.ProgressEvent
+
+// ScanOptions select what Scan looks at
+type ScanOptions struct {
+	// Root is the directory to walk; "" means the home directory
+	Root string
+	// MaxAge is how long a directory must go unmodified to be stale; 0 means DefaultMaxAge
+	MaxAge time.Duration
+	// Patterns are the directory names to look for, optionally with shell wildcards
+	// ("*.egg-info"); nil means DefaultPatterns
+	Patterns []string
+	// Exclude are directories that are never returned, nor anything inside them
+	Exclude []string
+	// Categories also scans well-known caches outside projects (see CategoryNames)
+	Categories []string
+	// Progress, if set, is called for every step of the walk, from Scan's goroutine
+	Progress func(Progress)
+}
+
+// IncompleteScanError is returned along with the results when some paths could not be
+// inspected (mostly permissions), so the results may be incomplete
+type IncompleteScanError = cleanup.IncompleteScanError
+
+// ErrDeletionTimeout is wrapped by the error of a deletion that ran out of time
+var ErrDeletionTimeout = cleanup.ErrDeletionTimeout
+
+// DefaultPatterns returns the directory names Scan looks for when ScanOptions.Patterns is nil
+func DefaultPatterns() []string {
+	return cleanup.DefaultPatterns()
+}
+
+// CategoryNames returns the names accepted in ScanOptions.Categories
+func CategoryNames() []string {
+	return cleanup.CategoryNames()
+}
+
+// Scan returns the stale directories under opts.Root. On an *IncompleteScanError the
+// directories that could be inspected are returned too.
+func Scan(ctx context.Context, opts ScanOptions) ([]Directory, error) {
+	root := opts.Root
+	if root == "" {
+		homeDir, err := paths.HomeDir()
+		if err != nil {
+			return nil, err
+		}
+		root = homeDir
+	}
+	maxAge := opts.MaxAge
+	if maxAge == 0 {
+		maxAge = DefaultMaxAge
+	}
+	if maxAge < 24*time.Hour || maxAge > 365*24*time.Hour {
+		return nil, fmt.Errorf("max age must be between 1 and 365 days: %v", maxAge)
+	}
+
+	for _, pattern := range opts.Patterns {
+		if err := cleanup.ValidatePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg := config.Default()
+	cfg.MaxAgeDaysForCleanup = age.Days(maxAge / (24 * time.Hour))
+	for _, path := range opts.Exclude {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exclude path %s: %w", path, err)
+		}
+		cfg.ExcludePaths = append(cfg.ExcludePaths, absPath)
+	}
+
+	var progress chan cleanup.ProgressEvent
+	done := make(chan struct{})
+	if opts.Progress != nil {
+		progress = make(chan cleanup.ProgressEvent)
+		go func() {
+			defer close(done)
+			for event := range progress {
+				opts.Progress(event)
+			}
+		}()
+	} else {
+		close(done)
+	}
+	found, err := cleanup.ScanDirectories(ctx, root, opts.Patterns, cfg.ShouldCleanupDirectory, progress)
+	if progress != nil {
+		close(progress)
+	}
+	<-done
+
+	var incomplete *IncompleteScanError
+	if err != nil && !errors.As(err, &incomplete) {
+		return nil, err
+	}
+
+	if len(opts.Categories) > 0 {
+		homeDir, homeErr := paths.HomeDir()
+		if homeErr != nil {
+			return nil, homeErr
+		}
+		extra, catErr := cleanup.ScanCategories(ctx, homeDir, []string{root}, opts.Categories, maxAge)
+		if catErr != nil {
+			return nil, catErr
+		}
+		for _, dir := range extra {
+			if !cfg.IsExcluded(dir.Path) {
+				found = append(found, dir)
+			}
+		}
+	}
+
+	directories := make([]Directory, len(found))
+	for i, dir := range found {
+		directories[i] = Directory{
+			Path:         dir.Path,
+			Size:         dir.Size,
+			ApparentSize: dir.ApparentSize,
+			ModTime:      dir.ModTime,
+			Pattern:      dir.Pattern,
+			Category:     dir.Category,
+		}
+	}
+	return directories, err
+}
+
+// Delete removes a directory found by Scan. It refuses paths outside the home directory
+// and system locations, never follows symlinks or crosses onto another filesystem, and
+// gives up after timeout (0 means no limit) with an error wrapping ErrDeletionTimeout,
+// leaving the rest of the directory in place.
+func Delete(ctx context.Context, path string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cleanup.DeleteDirectoryWithContext(ctx, path)
+	}
+	return cleanup.DeleteDirectoryWithTimeout(ctx, path, timeout)
+}
+
+// TotalSize sums the disk usage of dirs
+func TotalSize(dirs []Directory) int64 {
+	var total int64
+	for _, dir := range dirs {
+		total += dir.Size
+	}
+	return total
+}
+
+// FormatSize formats a size in bytes for display, e.g. "1.5 GiB"
+func FormatSize(bytes int64) string {
+	return cleanup.FormatSize(bytes)
+}
@@ -0,0 +1,208 @@
+// Package ports finds the processes listening on local ports and frees the ports, the
+// way `zap ports` does, for tools that embed zap instead of running the CLI (editor
+// plugins, dashboards). Functions never print, prompt or exit: results and errors are
+// returned to the caller, and every call that may block honours its context.
+package ports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hugoev/zap/internal/ports"
+)
+
+// Protocols a port can be scanned for
+const (
+	TCP = ports.ProtocolTCP
+	UDP = ports.ProtocolUDP
+)
+
+// Class is how zap classifies a listening process, which decides whether it asks first
+type Class string
+
+const (
+	// ClassSafe is a development server that is safe to terminate (node, vite, ...)
+	ClassSafe Class = "safe"
+	// ClassInfrastructure is a database, cache or similar service (postgres, redis, ...)
+	ClassInfrastructure Class = "infrastructure"
+	// ClassUnknown matched no rule
+	ClassUnknown Class = "unknown"
+)
+
+// Listener is a process listening on a port
+type Listener struct {
+	PID         int           `json:"pid"`
+	Port        int           `json:"port"`
+	Protocol    string        `json:"protocol"` // TCP or UDP
+	Name        string        `json:"name"`
+	Cmd         string        `json:"cmd"`
+	User        string        `json:"user"`
+	WorkingDir  string        `json:"working_dir"`
+	Executable  string        `json:"executable"`   // the program file, "" if unknown
+	Arch        string        `json:"arch"`         // e.g. "arm64", "" if unknown
+	Emulated    bool          `json:"emulated"`     // translated by Rosetta or run by an emulator
+	BindAddress string        `json:"bind_address"` // "*" for all interfaces
+	StartTime   time.Time     `json:"start_time"`
+	Runtime     time.Duration `json:"runtime"`
+	Class       Class         `json:"class"`
+	Reason      string        `json:"reason"` // the rule that decided Class
+}
+
+// ScanOptions select what Scan looks at; the zero value scans the default development
+// ports over TCP
+type ScanOptions struct {
+	Ports       []int    // nil means DefaultPorts
+	Protocols   []string // nil means TCP
+	Concurrency int      // parallel per-port lookups where /proc isn't available; 0 means automatic
+}
+
+// PartialScanError is returned along with the listeners found so far when a scan ran
+// out of time; Unchecked lists the ports that were not looked at
+type PartialScanError = ports.PartialScanError
+
+// ErrScanTimeout is wrapped by a PartialScanError when the scan hit its time limit
+var ErrScanTimeout = ports.ErrScanTimeout
+
+// DefaultPorts returns the ports scanned when none are given: those development
+// servers commonly use
+func DefaultPorts() []int {
+	seen := make(map[int]bool, len(ports.CommonDevPorts))
+	var list []int
+	for _, port := range ports.CommonDevPorts {
+		if !seen[port] {
+			seen[port] = true
+			list = append(list, port)
+		}
+	}
+	sort.Ints(list)
+	return list
+}
+
+// Scan returns the processes listening on the ports in opts, classified. A process
+// listening on several addresses of a port is returned once per address. On a
+// *PartialScanError, the listeners found before the scan stopped are returned too.
+func Scan(ctx context.Context, opts ScanOptions) ([]Listener, error) {
+	portList := opts.Ports
+	if portList == nil {
+		portList = DefaultPorts()
+	}
+	for _, port := range portList {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("port must be in range 1-65535: %d", port)
+		}
+	}
+	protocols := opts.Protocols
+	if protocols == nil {
+		protocols = []string{TCP}
+	}
+	for _, protocol := range protocols {
+		if protocol != TCP && protocol != UDP {
+			return nil, fmt.Errorf("unknown protocol %q (must be %s or %s)", protocol, TCP, UDP)
+		}
+	}
+
+	processes, err := ports.ScanPortsRangeWithProtocols(ctx, portList, protocols, opts.Concurrency)
+	var partial *PartialScanError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+	listeners := make([]Listener, len(processes))
+	for i, proc := range processes {
+		listeners[i] = fromProcess(proc)
+	}
+	return listeners, err
+}
+
+// Classify returns the class of a process and the rule that decided it
+func Classify(l Listener) (Class, string) {
+	proc := l.process()
+	if keyword := ports.InfrastructureReason(proc); keyword != "" {
+		return ClassInfrastructure, fmt.Sprintf("matched keyword %q", keyword)
+	}
+	if reason := ports.SafeDevServerReason(proc); reason != "" {
+		return ClassSafe, reason
+	}
+	return ClassUnknown, ""
+}
+
+// Running reports whether the process of l is still running
+func Running(l Listener) bool {
+	return ports.IsProcessRunning(l.PID)
+}
+
+// Terminate stops the process of l with SIGTERM, then SIGKILL if it is still running
+// after a few seconds. It first checks that the PID still belongs to the same process,
+// so a PID reused since the scan is never signalled. Whether to terminate (e.g. only
+// ClassSafe processes) is the caller's decision.
+func Terminate(ctx context.Context, l Listener) error {
+	return TerminateWithSignals(ctx, l, nil)
+}
+
+// TerminateWithSignals is Terminate with an escalation policy: each step is a signal,
+// optionally followed by "@" and how long to wait after the previous step, e.g.
+// []string{"INT", "TERM@5s", "KILL@10s"}. Nil means the default TERM, KILL@3s.
+func TerminateWithSignals(ctx context.Context, l Listener, steps []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	policy, err := ports.ParseEscalation(steps)
+	if err != nil {
+		return err
+	}
+	if err := ports.KillProcessWithVerification(l.PID, l.process(), policy); err != nil {
+		return err
+	}
+	if ports.IsProcessRunning(l.PID) {
+		return fmt.Errorf("process %d is still running", l.PID)
+	}
+	return nil
+}
+
+// PortInUse reports whether anything is listening on port over protocol
+func PortInUse(port int, protocol string) bool {
+	if protocol == UDP {
+		return ports.IsUDPPortInUse(port)
+	}
+	return ports.IsPortInUse(port)
+}
+
+func fromProcess(proc ports.ProcessInfo) Listener {
+	l := Listener{
+		PID:         proc.PID,
+		Port:        proc.Port,
+		Protocol:    proc.Protocol,
+		Name:        proc.Name,
+		Cmd:         proc.Cmd,
+		User:        proc.User,
+		WorkingDir:  proc.WorkingDir,
+		Executable:  proc.Executable,
+		Arch:        proc.Arch,
+		Emulated:    proc.Emulated,
+		BindAddress: proc.BindAddress,
+		StartTime:   proc.StartTime,
+		Runtime:     proc.Runtime,
+	}
+	l.Class, l.Reason = Classify(l)
+	return l
+}
+
+func (l Listener) process() ports.ProcessInfo {
+	return ports.ProcessInfo{
+		PID:         l.PID,
+		Port:        l.Port,
+		Name:        l.Name,
+		Cmd:         l.Cmd,
+		User:        l.User,
+		StartTime:   l.StartTime,
+		Runtime:     l.Runtime,
+		WorkingDir:  l.WorkingDir,
+		Executable:  l.Executable,
+		Arch:        l.Arch,
+		Emulated:    l.Emulated,
+		BindAddress: l.BindAddress,
+		Protocol:    l.Protocol,
+	}
+}